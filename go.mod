@@ -3,10 +3,13 @@ module github.com/roadrunner-server/goridge/v3
 go 1.22.4
 
 require (
+	github.com/cespare/xxhash/v2 v2.3.0
 	github.com/goccy/go-json v0.10.3
+	github.com/klauspost/compress v1.17.9
 	github.com/roadrunner-server/errors v1.4.0
 	github.com/stretchr/testify v1.9.0
 	github.com/vmihailenco/msgpack/v5 v5.4.1
+	go.mongodb.org/mongo-driver v1.16.0
 	google.golang.org/protobuf v1.34.2
 )
 
@@ -0,0 +1,77 @@
+package internal
+
+import (
+	"sync"
+	"sync/atomic"
+)
+
+// adaptive sizing is disabled by default, the fixed size buckets are used
+var (
+	adaptiveEnabled atomic.Bool   //nolint:gochecknoglobals
+	adaptivePool    sync.Pool     //nolint:gochecknoglobals
+	adaptiveAvg     atomic.Uint64 //nolint:gochecknoglobals
+	adaptiveDev     atomic.Uint64 //nolint:gochecknoglobals
+	adaptiveGrows   atomic.Uint64 //nolint:gochecknoglobals
+)
+
+// SetAdaptiveSizing switches the payload buffers from the fixed size buckets to the buffers sized by the observed
+// payload sizes (moving average plus two mean deviations). Buffers smaller than the payload are grown.
+func SetAdaptiveSizing(enabled bool) {
+	adaptiveEnabled.Store(enabled)
+}
+
+// AdaptiveGrows returns how many times the pooled buffer was too small for the payload and was reallocated.
+func AdaptiveGrows() uint64 {
+	return adaptiveGrows.Load()
+}
+
+// observe updates the moving average and the mean deviation of the payload sizes with 1/16 weight.
+// Concurrent updates might be lost, which is fine for the estimation.
+func observe(size uint32) {
+	avg := adaptiveAvg.Load()
+	dev := adaptiveDev.Load()
+
+	s := uint64(size)
+	if avg == 0 {
+		adaptiveAvg.Store(s)
+		return
+	}
+
+	diff := s - avg
+	if s < avg {
+		diff = avg - s
+	}
+
+	adaptiveAvg.Store(avg - avg/16 + s/16)
+	adaptiveDev.Store(dev - dev/16 + diff/16)
+}
+
+// target returns the capacity for the new buffers
+func target() uint64 {
+	return adaptiveAvg.Load() + 2*adaptiveDev.Load()
+}
+
+func getAdaptive(size uint32) *[]byte {
+	observe(size)
+
+	if pb, ok := adaptivePool.Get().(*[]byte); ok {
+		if cap(*pb) >= int(size) {
+			*pb = (*pb)[:cap(*pb)]
+			return pb
+		}
+
+		adaptiveGrows.Add(1)
+	}
+
+	data := make([]byte, max(uint64(size), target()))
+	return &data
+}
+
+func putAdaptive(data *[]byte) {
+	// don't keep the buffers left from the outliers
+	if t := target(); t > 0 && uint64(cap(*data)) > 4*t {
+		return
+	}
+
+	adaptivePool.Put(data)
+}
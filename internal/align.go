@@ -0,0 +1,49 @@
+package internal
+
+import (
+	"io"
+	"unsafe"
+
+	"github.com/roadrunner-server/errors"
+)
+
+// ValidateAlignment checks that the payload alignment is 0 (disabled) or a power of two
+func ValidateAlignment(n int) error {
+	if n < 0 || n&(n-1) != 0 {
+		return errors.Errorf("payload alignment should be a power of two, got: %d", n)
+	}
+
+	return nil
+}
+
+// alignedBuffer returns the buffer of the size which starts at the address aligned to align bytes
+func alignedBuffer(size, align int) []byte {
+	buf := make([]byte, size+align-1)
+	// buf is not empty: align > 1
+	offset := int(uintptr(unsafe.Pointer(&buf[0])) & uintptr(align-1)) //nolint:gosec
+	if offset != 0 {
+		offset = align - offset
+	}
+
+	return buf[offset : offset+size : offset+size]
+}
+
+// readAligned reads the payload into the buffer aligned to align bytes. The payloads which don't fit into
+// the smallest bucket are read in chunks (see readChunked) and copied once received, so the truncated frames
+// declaring huge payloads fail without allocating the declared size upfront.
+func readAligned(r io.Reader, size uint32, align int) ([]byte, error) {
+	if size <= OneMB {
+		buf := alignedBuffer(int(size), align)
+		n, err := readFull(r, buf)
+		return buf[:n], err
+	}
+
+	data, err := readChunked(r, size)
+	if err != nil {
+		return data, err
+	}
+
+	buf := alignedBuffer(int(size), align)
+	copy(buf, data)
+	return buf, nil
+}
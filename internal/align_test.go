@@ -0,0 +1,51 @@
+package internal
+
+import (
+	"bytes"
+	"testing"
+	"unsafe"
+
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateAlignment(t *testing.T) {
+	for _, n := range []int{0, 1, 2, 8, 64, 4096} {
+		assert.NoError(t, ValidateAlignment(n), n)
+	}
+
+	for _, n := range []int{-8, 3, 12, 100} {
+		assert.Error(t, ValidateAlignment(n), n)
+	}
+}
+
+func TestReceiveFrameAligned(t *testing.T) {
+	payload := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+
+	nf := frame.NewFrame()
+	nf.WriteVersion(nf.Header(), frame.Version1)
+	nf.WriteFlags(nf.Header(), frame.CodecRaw)
+	nf.WriteOptions(nf.HeaderPtr(), 1, 10)
+	nf.WritePayloadLen(nf.Header(), uint32(len(payload)))
+	nf.WritePayload(payload)
+	nf.WriteCRC(nf.Header())
+	data := nf.Bytes()
+
+	for _, align := range []int{8, 16, 64, 4096} {
+		for i := 0; i < 10; i++ {
+			fr := frame.NewFrame()
+			err := ReceiveFrameWithConfig(bytes.NewReader(data), fr, ReceiveConfig{Alignment: align})
+			require.NoError(t, err)
+
+			require.Equal(t, payload, fr.Payload())
+			assert.Len(t, fr.Payload(), cap(fr.Payload()))
+			assert.Zero(t, uintptr(unsafe.Pointer(&fr.Payload()[0]))%uintptr(align), align)
+		}
+	}
+
+	// truncated payload
+	fr := frame.NewFrame()
+	err := ReceiveFrameWithConfig(bytes.NewReader(data[:len(data)-1]), fr, ReceiveConfig{Alignment: 8})
+	assert.ErrorIs(t, err, frame.ErrTruncatedPayload)
+}
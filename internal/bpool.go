@@ -1,77 +1,156 @@
 package internal
 
 import (
+	"slices"
 	"sync"
+	"sync/atomic"
+
+	"github.com/roadrunner-server/errors"
 )
 
-var frameChunkedPool = &sync.Map{}
+var buckets atomic.Pointer[[]*bucket] //nolint:gochecknoglobals
 var preallocate = &sync.Once{}
 
+// oversized payloads don't fit into the largest bucket and are allocated on every get
+var oversized atomic.Uint64 //nolint:gochecknoglobals
+
 const (
 	OneMB  uint32 = 1024 * 1024 * 1
 	FiveMB uint32 = 1024 * 1024 * 5
 	TenMB  uint32 = 1024 * 1024 * 10
 )
 
+// bucket is a pool of the buffers of the same size for the payloads up to the size
+type bucket struct {
+	size   uint32
+	pool   sync.Pool
+	gets   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// BucketStats contains the bucket counters, Misses are the gets which allocated a new buffer
+type BucketStats struct {
+	Size   uint32
+	Gets   uint64
+	Misses uint64
+}
+
+func newBucket(size uint32) *bucket {
+	b := &bucket{size: size}
+	b.pool.New = func() any {
+		b.misses.Add(1)
+		data := make([]byte, size)
+		return &data
+	}
+
+	return b
+}
+
 func Preallocate() {
 	preallocate.Do(internalAllocate)
 }
 
 func internalAllocate() {
-	pool1 := &sync.Pool{
-		New: func() any {
-			data := make([]byte, OneMB)
-			return &data
-		},
+	// buckets might be already configured
+	list := []*bucket{newBucket(OneMB), newBucket(FiveMB), newBucket(TenMB)}
+	buckets.CompareAndSwap(nil, &list)
+}
+
+// SetBuckets replaces the payload buffer buckets, sizes are the payload size thresholds in bytes.
+// Payloads bigger than the largest bucket are allocated on every read.
+func SetBuckets(sizes ...uint32) error {
+	if len(sizes) == 0 {
+		return errors.Str("at least one bucket size is required")
+	}
+
+	sizes = slices.Clone(sizes)
+	slices.Sort(sizes)
+	sizes = slices.Compact(sizes)
+	if sizes[0] == 0 {
+		return errors.Str("bucket size should be positive")
 	}
-	pool5 := &sync.Pool{
-		New: func() any {
-			data := make([]byte, FiveMB)
-			return &data
-		},
+
+	list := make([]*bucket, 0, len(sizes))
+	for _, size := range sizes {
+		list = append(list, newBucket(size))
 	}
-	pool10 := &sync.Pool{
-		New: func() any {
-			data := make([]byte, TenMB)
-			return &data
-		},
+
+	buckets.Store(&list)
+	return nil
+}
+
+// Stats returns the counters of the buckets and the number of the oversized payload allocations
+func Stats() ([]BucketStats, uint64) {
+	list := loadBuckets()
+	st := make([]BucketStats, 0, len(list))
+	for _, b := range list {
+		// misses are loaded first, every miss is counted after its get
+		misses := b.misses.Load()
+		st = append(st, BucketStats{Size: b.size, Gets: b.gets.Load(), Misses: misses})
 	}
 
-	frameChunkedPool.Store(OneMB, pool1)
-	frameChunkedPool.Store(FiveMB, pool5)
-	frameChunkedPool.Store(TenMB, pool10)
+	return st, oversized.Load()
+}
+
+func loadBuckets() []*bucket {
+	list := buckets.Load()
+	if list == nil {
+		Preallocate()
+		list = buckets.Load()
+	}
+
+	return *list
+}
+
+// find returns the smallest bucket for the size
+func find(list []*bucket, size uint32) *bucket {
+	for _, b := range list {
+		if size <= b.size {
+			return b
+		}
+	}
+
+	return nil
+}
+
+// pooled reports whether the payload of the size is read into the pooled buffer, the adaptive pool takes
+// the payloads up to the outliers (see putAdaptive)
+func pooled(size uint32) bool {
+	if adaptiveEnabled.Load() {
+		return uint64(size) <= max(4*target(), uint64(TenMB))
+	}
+
+	return find(loadBuckets(), size) != nil
 }
 
 func get(size uint32) *[]byte {
-	switch {
-	case size <= OneMB:
-		val, _ := frameChunkedPool.Load(OneMB)
-		return val.(*sync.Pool).Get().(*[]byte)
-	case size <= FiveMB:
-		val, _ := frameChunkedPool.Load(FiveMB)
-		return val.(*sync.Pool).Get().(*[]byte)
-	case size <= TenMB:
-		val, _ := frameChunkedPool.Load(TenMB)
-		return val.(*sync.Pool).Get().(*[]byte)
-	default:
+	if adaptiveEnabled.Load() {
+		return getAdaptive(size)
+	}
+
+	b := find(loadBuckets(), size)
+	if b == nil {
+		oversized.Add(1)
 		data := make([]byte, size)
 		return &data
 	}
+
+	b.gets.Add(1)
+	return b.pool.Get().(*[]byte)
 }
 
 func put(size uint32, data *[]byte) {
-	switch {
-	case size <= OneMB:
-		pool, _ := frameChunkedPool.Load(OneMB)
-		pool.(*sync.Pool).Put(data)
+	if adaptiveEnabled.Load() {
+		putAdaptive(data)
 		return
-	case size <= FiveMB:
-		pool, _ := frameChunkedPool.Load(FiveMB)
-		pool.(*sync.Pool).Put(data)
-		return
-	default:
-		pool, _ := frameChunkedPool.Load(TenMB)
-		pool.(*sync.Pool).Put(data)
+	}
+
+	b := find(loadBuckets(), size)
+	// the buffer might come from the adaptive pool or from the buckets before the reconfiguration
+	// and be smaller than the bucket
+	if b == nil || uint32(len(*data)) < b.size { //nolint:gosec
 		return
 	}
+
+	b.pool.Put(data)
 }
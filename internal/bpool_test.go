@@ -0,0 +1,144 @@
+package internal
+
+import (
+	"testing"
+)
+
+// shifting payload sizes: small, then medium, then large requests
+func shiftingSizes() []uint32 {
+	sizes := make([]uint32, 0, 3000)
+	for _, base := range []uint32{512, 64 * 1024, 2 * OneMB} {
+		for i := uint32(0); i < 1000; i++ {
+			sizes = append(sizes, base+(i%50)*base/100)
+		}
+	}
+
+	return sizes
+}
+
+func TestAdaptiveSizing(t *testing.T) {
+	Preallocate()
+	SetAdaptiveSizing(true)
+	defer SetAdaptiveSizing(false)
+
+	grows := AdaptiveGrows()
+	for _, size := range shiftingSizes() {
+		pb := get(size)
+		if len(*pb) < int(size) {
+			t.Fatalf("buffer is smaller than the payload: %d < %d", len(*pb), size)
+		}
+		put(size, pb)
+	}
+
+	// only a few grows at the distribution shifts
+	if g := AdaptiveGrows() - grows; g > 300 {
+		t.Fatalf("too many grows: %d", g)
+	}
+
+	// adaptive buffers don't break the buckets after switching back
+	pb := get(10)
+	SetAdaptiveSizing(false)
+	put(10, pb)
+	if pb = get(OneMB); len(*pb) < int(OneMB) {
+		t.Fatalf("bucket buffer is smaller than the bucket: %d", len(*pb))
+	}
+}
+
+func BenchmarkPayloadBuffers(b *testing.B) {
+	Preallocate()
+	sizes := shiftingSizes()
+
+	for _, adaptive := range []bool{false, true} {
+		name := "buckets"
+		if adaptive {
+			name = "adaptive"
+		}
+
+		b.Run(name, func(b *testing.B) {
+			SetAdaptiveSizing(adaptive)
+			defer SetAdaptiveSizing(false)
+
+			grows := AdaptiveGrows()
+			var capacity uint64
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				size := sizes[i%len(sizes)]
+				pb := get(size)
+				capacity += uint64(cap(*pb))
+				put(size, pb)
+			}
+
+			b.ReportMetric(float64(AdaptiveGrows()-grows)/float64(b.N), "grows/op")
+			// memory held by a buffer per payload
+			b.ReportMetric(float64(capacity)/float64(b.N), "bufbytes/op")
+		})
+	}
+}
+
+func TestSetBuckets(t *testing.T) {
+	Preallocate()
+	defer func() {
+		_ = SetBuckets(OneMB, FiveMB, TenMB)
+	}()
+
+	if err := SetBuckets(); err == nil {
+		t.Fatal("empty buckets should be rejected")
+	}
+	if err := SetBuckets(0, 10); err == nil {
+		t.Fatal("zero bucket should be rejected")
+	}
+
+	// unsorted and duplicated sizes
+	if err := SetBuckets(4096, 1024, 4096); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, size := range []uint32{10, 1000, 2000, 4096, 4096, 5000} {
+		pb := get(size)
+		if len(*pb) < int(size) {
+			t.Fatalf("buffer is smaller than the payload: %d < %d", len(*pb), size)
+		}
+		put(size, pb)
+	}
+
+	st, over := Stats()
+	if len(st) != 2 || st[0].Size != 1024 || st[1].Size != 4096 {
+		t.Fatalf("unexpected buckets: %+v", st)
+	}
+	if st[0].Gets != 2 || st[1].Gets != 3 || over != 1 {
+		t.Fatalf("unexpected counters: %+v, oversized: %d", st, over)
+	}
+	if st[0].Misses > st[0].Gets || st[1].Misses > st[1].Gets {
+		t.Fatalf("more misses than gets: %+v", st)
+	}
+}
+
+// 12MB payloads don't fit into the default buckets and are allocated on every read
+func BenchmarkTunedBuckets(b *testing.B) {
+	Preallocate()
+	const size = 12 * OneMB
+
+	for _, tuned := range []bool{false, true} {
+		name := "default"
+		if tuned {
+			name = "tuned"
+		}
+
+		b.Run(name, func(b *testing.B) {
+			if tuned {
+				_ = SetBuckets(OneMB, 16*OneMB)
+				defer func() {
+					_ = SetBuckets(OneMB, FiveMB, TenMB)
+				}()
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				pb := get(size)
+				put(size, pb)
+			}
+		})
+	}
+}
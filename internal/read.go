@@ -0,0 +1,94 @@
+package internal
+
+import (
+	stderr "errors"
+	"io"
+	"slices"
+)
+
+// maxEmptyReads is the number of the consecutive reads returning no data and no error after which the relay
+// is considered broken (as in bufio), io.ReadFull and io.ReadAll would spin on such readers forever
+const maxEmptyReads = 100
+
+// readFull is io.ReadFull which fails with io.ErrNoProgress on the readers returning no data and no error
+// and with io.ErrShortBuffer on the readers reporting more bytes than requested
+func readFull(r io.Reader, buf []byte) (int, error) {
+	n, empty := 0, 0
+	for n < len(buf) {
+		m, err := r.Read(buf[n:])
+		if m < 0 || m > len(buf)-n {
+			return n, io.ErrShortBuffer
+		}
+
+		n += m
+		switch {
+		case err != nil:
+			if n == len(buf) {
+				return n, nil
+			}
+			if n > 0 && stderr.Is(err, io.EOF) {
+				return n, io.ErrUnexpectedEOF
+			}
+			return n, err
+		case m == 0:
+			empty++
+			if empty >= maxEmptyReads {
+				return n, io.ErrNoProgress
+			}
+		default:
+			empty = 0
+		}
+	}
+
+	return n, nil
+}
+
+// readAll is io.ReadAll which stops on the readers returning no data and no error
+func readAll(r io.Reader) ([]byte, error) {
+	return io.ReadAll(&progressReader{r: r})
+}
+
+// progressReader fails with io.ErrNoProgress after maxEmptyReads consecutive empty reads
+type progressReader struct {
+	r     io.Reader
+	empty int
+}
+
+func (p *progressReader) Read(buf []byte) (int, error) {
+	n, err := p.r.Read(buf)
+	if n < 0 || n > len(buf) {
+		return 0, io.ErrShortBuffer
+	}
+
+	if n > 0 || err != nil || len(buf) == 0 {
+		p.empty = 0
+		return n, err
+	}
+
+	p.empty++
+	if p.empty >= maxEmptyReads {
+		return 0, io.ErrNoProgress
+	}
+
+	return 0, nil
+}
+
+// readChunked reads the payload which doesn't fit into the pooled buffers growing the buffer as the data arrives,
+// so the truncated frames declaring huge payloads fail without allocating the declared size upfront
+func readChunked(r io.Reader, size uint32) ([]byte, error) {
+	buf := make([]byte, 0, min(size, OneMB))
+	for uint32(len(buf)) < size { //nolint:gosec
+		if len(buf) == cap(buf) {
+			buf = slices.Grow(buf, int(min(size-uint32(len(buf)), uint32(len(buf))))) //nolint:gosec
+		}
+
+		end := min(cap(buf), int(size))
+		n, err := readFull(r, buf[len(buf):end])
+		buf = buf[:len(buf)+n]
+		if err != nil {
+			return buf, err
+		}
+	}
+
+	return buf, nil
+}
@@ -10,85 +10,183 @@ import (
 	"github.com/roadrunner-server/goridge/v3/pkg/frame"
 )
 
-// shortland for the Could not open input file: ../roadrunner/tests/psr-wfsdorker.php
-var res = []byte("Could not op") //nolint:gochecknoglobals
+type phpPrefix struct {
+	prefix []byte
+	// fatal errors are followed by the process exit, so the rest of the output is read until EOF
+	fatal bool
+}
+
+// the beginnings of the PHP messages written to STDOUT, e.g. Could not open input file: ../roadrunner/tests/psr-worker.php
+// none of them is a valid goridge header start (version 1)
+var phpPrefixes = []phpPrefix{ //nolint:gochecknoglobals
+	{[]byte("Could not op"), true},
+	{[]byte("PHP Fatal"), true},
+	{[]byte("PHP Parse"), true},
+	{[]byte("Fatal error"), true},
+	{[]byte("Parse error"), true},
+	{[]byte("PHP "), false},
+	{[]byte("Warning:"), false},
+	{[]byte("Notice:"), false},
+	{[]byte("Deprecated:"), false},
+}
+
+// request lines of HTTP/1.x and HTTP/2 connection preface
+var httpPrefixes = [][]byte{ //nolint:gochecknoglobals
+	[]byte("GET "), []byte("POST"), []byte("PUT "), []byte("HEAD"), []byte("DELE"),
+	[]byte("OPTI"), []byte("PATC"), []byte("CONN"), []byte("TRAC"), []byte("PRI "),
+}
 
 const validationError = "validation failed on the message sent to STDOUT, see: https://docs.roadrunner.dev/error-codes/stdout-crc, invalid message: %s"
 
 func ReceiveFrame(relay io.Reader, fr *frame.Frame) error {
+	return ReceiveFrameWithPolicy(relay, fr, frame.CRCRequired)
+}
+
+// ReceiveConfig configures the frame receiving of the relay
+type ReceiveConfig struct {
+	// Policy of the header CRC verification
+	Policy frame.CRCPolicy
+	// Alignment of the payload start in bytes (power of two), 0 - no alignment
+	Alignment int
+	// MaxFrameSize is the limit of the header, options and payload size in bytes, 0 - unlimited
+	MaxFrameSize int
+}
+
+// ReceiveFrameWithPolicy receives the frame, the header CRC is verified according to the policy
+func ReceiveFrameWithPolicy(relay io.Reader, fr *frame.Frame, policy frame.CRCPolicy) error {
+	return ReceiveFrameWithConfig(relay, fr, ReceiveConfig{Policy: policy})
+}
+
+// ReceiveFrameWithConfig receives the frame according to the config
+func ReceiveFrameWithConfig(relay io.Reader, fr *frame.Frame, cfg ReceiveConfig) error {
 	const op = errors.Op("goridge_frame_receive")
 
-	_, err := io.ReadFull(relay, fr.Header())
+	_, err := readFull(relay, fr.Header())
 	if err != nil {
 		return err
 	}
 
-	// todo: rustatian: think about smarter solution
-	if bytes.Equal(fr.Header(), res) {
-		data, errRa := io.ReadAll(relay)
-		if errRa == nil && len(data) > 0 {
-			return errors.E(op, errors.FileNotFound, errors.Str(string(fr.Header())+string(data)))
-		}
-
-		return errors.E(op, errors.FileNotFound, errors.Str("file not found"))
+	if fatal, ok := detectPHP(fr.Header()); ok {
+		// the kind is kept for the callers checking errors.Is(errors.FileNotFound, err)
+		output := string(fr.Header()) + readPHPOutput(relay, fatal)
+		return errors.E(op, errors.FileNotFound, &frame.PHPOutputError{Output: output})
 	}
 
-	// we have options
-	if fr.ReadHL(fr.Header()) > 3 {
-		// we should read the options
-		optsLen := (fr.ReadHL(fr.Header()) - 3) * frame.WORD
-		opts := make([]byte, optsLen)
-
-		// read the next part of the frame - options
-		_, err = io.ReadFull(relay, opts)
-		if err != nil {
-			if stderr.Is(err, io.EOF) {
-				return err
-			}
-			return errors.E(op, err)
-		}
+	// detect the clients of other protocols before reading the options, which might never arrive
+	if protocol := detectProtocol(fr); protocol != "" {
+		return &frame.NotGoridgeError{Protocol: protocol}
+	}
 
-		// we should append frame's
-		fr.AppendOptions(fr.HeaderPtr(), opts)
+	// frames without CRC are verified only by the trusted receivers
+	crcDisabled := fr.IsCRCDisabled(fr.Header())
+	if crcDisabled && cfg.Policy != frame.CRCTrusted {
+		return frame.ErrCRCRequired
 	}
 
 	// verify header CRC
-	if !fr.VerifyCRC(fr.Header()) {
+	if !crcDisabled && !fr.VerifyCRC(fr.Header()) {
 		type deadliner interface {
 			SetReadDeadline(time.Time) error
 		}
 
+		header := make([]byte, len(fr.Header()))
+		copy(header, fr.Header())
+
 		if d, ok := relay.(deadliner); ok {
 			err = d.SetReadDeadline(time.Now().Add(time.Second * 2))
 			if err != nil {
-				return errors.E(op, errors.Errorf(validationError, fr.Header()))
+				return crcMismatch(op, header, errors.Errorf(validationError, fr.Header()))
 			}
 
 			// we don't care about error here
-			resp, _ := io.ReadAll(relay)
+			resp, _ := readAll(relay)
 
-			return errors.E(op, errors.Errorf(validationError, string(fr.Header())+string(resp)))
+			return crcMismatch(op, header, errors.Errorf(validationError, string(fr.Header())+string(resp)))
 		}
 
 		// no deadline, so, only 14 bytes
-		return errors.E(op, errors.Errorf(validationError, fr.Header()))
+		return crcMismatch(op, header, errors.Errorf(validationError, fr.Header()))
+	}
+
+	// the header length is trusted only after the CRC verification, the options region is capped anyway
+	if fr.ReadHL(fr.Header()) > 3 {
+		// we should read the options
+		optsLen := int(fr.ReadHL(fr.Header())-3) * frame.WORD
+		if optsLen > frame.OptionsMaxSize {
+			// the options are not read, only the fixed part of the header is available
+			return &frame.OptionsLengthError{Length: len(fr.Header()) - 12, Declared: optsLen}
+		}
+
+		opts := make([]byte, optsLen)
+
+		// read the next part of the frame - options
+		_, err = readFull(relay, opts)
+		if err != nil {
+			if stderr.Is(err, io.EOF) {
+				return err
+			}
+			return errors.E(op, err)
+		}
+
+		// we should append frame's
+		fr.AppendOptions(fr.HeaderPtr(), opts)
+	}
+
+	// the options might still be corrupted, they are not covered by the CRC
+	err = fr.ValidateOptions(fr.Header())
+	if err != nil {
+		return err
 	}
 
 	// read the read payload
 	pl := fr.ReadPayloadLen(fr.Header())
+	// the payload of the oversized frame is never read
+	if size := uint64(len(fr.Header())) + uint64(pl); cfg.MaxFrameSize > 0 && size > uint64(cfg.MaxFrameSize) {
+		return &frame.FrameTooLargeError{Size: size, Limit: uint64(cfg.MaxFrameSize)}
+	}
 	// no payload
 	if pl == 0 {
 		return nil
 	}
 
+	// aligned payloads are not pooled, the pooled buffers would be copied anyway
+	if cfg.Alignment > 1 {
+		payload, errR := readAligned(relay, pl, cfg.Alignment)
+		if errR != nil {
+			if stderr.Is(errR, io.EOF) || stderr.Is(errR, io.ErrUnexpectedEOF) {
+				return &frame.TruncatedPayloadError{Expected: pl, Received: uint32(len(payload))} //nolint:gosec
+			}
+			return errors.E(op, errR)
+		}
+
+		fr.SetPayload(payload)
+		return nil
+	}
+
+	// the payloads which don't fit into the buckets are allocated as they arrive, the length is not covered by the CRC
+	// with the CRCTrusted policy
+	if !pooled(pl) {
+		oversized.Add(1)
+		payload, errC := readChunked(relay, pl)
+		if errC != nil {
+			if stderr.Is(errC, io.EOF) || stderr.Is(errC, io.ErrUnexpectedEOF) {
+				return &frame.TruncatedPayloadError{Expected: pl, Received: uint32(len(payload))} //nolint:gosec
+			}
+			return errors.E(op, errC)
+		}
+
+		fr.SetPayload(payload)
+		return nil
+	}
+
 	pb := get(pl)
-	_, err2 := io.ReadFull(relay, (*pb)[:pl])
+	n, err2 := readFull(relay, (*pb)[:pl])
 	if err2 != nil {
-		if stderr.Is(err2, io.EOF) {
-			put(pl, pb)
-			return err
-		}
 		put(pl, pb)
+		// header was received, but the payload is shorter than declared
+		if stderr.Is(err2, io.EOF) || stderr.Is(err2, io.ErrUnexpectedEOF) {
+			return &frame.TruncatedPayloadError{Expected: pl, Received: uint32(n)} //nolint:gosec
+		}
 		return errors.E(op, err2)
 	}
 
@@ -96,3 +194,59 @@ func ReceiveFrame(relay io.Reader, fr *frame.Frame) error {
 	put(pl, pb)
 	return nil
 }
+
+// crcMismatch keeps the human-readable validation message, but makes the error detectable with errors.Is
+func crcMismatch(op errors.Op, header []byte, err error) error {
+	return &frame.CRCMismatchError{Header: header, Message: errors.E(op, err).Error()}
+}
+
+// detectProtocol returns the name of the protocol if the header is likely the first bytes of a non-goridge client
+func detectProtocol(fr *frame.Frame) string {
+	header := fr.Header()
+	for _, prefix := range httpPrefixes {
+		if bytes.HasPrefix(header, prefix) {
+			return "HTTP"
+		}
+	}
+
+	// TLS handshake record: content type 0x16, protocol version 3.x
+	// 0x16 is also a valid goridge header start (version 1, 6 words), so the CRC is checked too
+	if header[0] == 0x16 && header[1] == 0x03 && !fr.VerifyCRC(header) {
+		return "TLS"
+	}
+
+	return ""
+}
+
+// detectPHP reports whether the header is likely the beginning of the PHP error message, leading new lines are
+// skipped (display_errors prefixes the message with one)
+func detectPHP(header []byte) (fatal bool, ok bool) {
+	header = bytes.TrimLeft(header, "\r\n")
+	for _, p := range phpPrefixes {
+		if bytes.HasPrefix(header, p.prefix) {
+			return p.fatal, true
+		}
+	}
+
+	return false, false
+}
+
+// readPHPOutput reads the rest of the PHP output. The output after the warnings is read only until the read
+// deadline, if the relay supports it, since the process might still be running.
+func readPHPOutput(relay io.Reader, fatal bool) string {
+	type deadliner interface {
+		SetReadDeadline(time.Time) error
+	}
+
+	if d, ok := relay.(deadliner); ok {
+		if d.SetReadDeadline(time.Now().Add(time.Second*2)) != nil {
+			return ""
+		}
+	} else if !fatal {
+		return ""
+	}
+
+	// we don't care about error here
+	data, _ := readAll(relay)
+	return string(data)
+}
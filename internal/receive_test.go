@@ -0,0 +1,330 @@
+package internal
+
+import (
+	"bytes"
+	stderr "errors"
+	"io"
+	"math"
+	"testing"
+	"testing/iotest"
+	"unsafe"
+
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+)
+
+func BenchmarkReceiveFrameCRC(b *testing.B) {
+	Preallocate()
+
+	for _, policy := range []frame.CRCPolicy{frame.CRCRequired, frame.CRCTrusted} {
+		nf := frame.NewFrame()
+		nf.WriteVersion(nf.Header(), frame.Version1)
+		nf.WriteFlags(nf.Header(), frame.CodecRaw)
+		nf.WriteOptions(nf.HeaderPtr(), 1, 10)
+		nf.WritePayloadLen(nf.Header(), 100)
+		nf.WritePayload(make([]byte, 100))
+
+		name := "verified"
+		if policy == frame.CRCTrusted {
+			name = "skipped"
+			nf.SetCRCDisabled(nf.Header())
+		} else {
+			nf.WriteCRC(nf.Header())
+		}
+
+		data := nf.Bytes()
+		b.Run(name, func(b *testing.B) {
+			r := bytes.NewReader(data)
+			fr := frame.NewFrame()
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				r.Reset(data)
+				fr.Reset()
+				err := ReceiveFrameWithPolicy(r, fr, policy)
+				if err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestReceiveFrameInvalidOptions(t *testing.T) {
+	Preallocate()
+
+	// 11 options (44 bytes) with a valid CRC
+	nf := frame.NewFrame()
+	nf.WriteVersion(nf.Header(), frame.Version1)
+	nf.WriteOptions(nf.HeaderPtr(), 1, 2, 3, 4, 5, 6, 7, 8, 9, 10)
+	header := append(nf.Header(), 0, 0, 0, 0)
+	header[0] = frame.Version1<<4 | 14
+	nf.WriteCRC(header)
+
+	err := ReceiveFrame(bytes.NewReader(header), frame.NewFrame())
+	if !stderr.Is(err, frame.ErrInvalidOptions) {
+		t.Fatalf("expected ErrInvalidOptions, got: %v", err)
+	}
+}
+
+func TestReceiveFrameAbsurdHeaderLength(t *testing.T) {
+	Preallocate()
+
+	// the max HL, 12 options (48 bytes), with a valid CRC and nothing after the header
+	nf := frame.NewFrame()
+	nf.WriteVersion(nf.Header(), frame.Version1)
+	header := nf.Header()
+	header[0] = frame.Version1<<4 | 0x0F
+	nf.WritePayloadLen(header, 1<<30)
+	nf.WriteCRC(header)
+
+	// rejected before reading the options or the payload
+	err := ReceiveFrame(bytes.NewReader(header), frame.NewFrame())
+	var oe *frame.OptionsLengthError
+	if !stderr.As(err, &oe) || !stderr.Is(err, frame.ErrInvalidOptions) {
+		t.Fatalf("expected OptionsLengthError, got: %v", err)
+	}
+	if oe.Length != 0 || oe.Declared != 48 {
+		t.Fatalf("expected 0 of 48 declared bytes, got: %d of %d", oe.Length, oe.Declared)
+	}
+
+	r := bytes.NewReader(header)
+	fr := frame.NewFrame()
+	allocs := testing.AllocsPerRun(100, func() {
+		r.Reset(header)
+		_ = ReceiveFrame(r, fr)
+	})
+	if allocs > 1 {
+		t.Fatalf("expected at most 1 allocation, got: %v", allocs)
+	}
+
+	// the header length is not trusted until the CRC is verified
+	corrupted := append([]byte(nil), header...)
+	corrupted[6] ^= 0xFF
+	err = ReceiveFrame(bytes.NewReader(corrupted), frame.NewFrame())
+	if !stderr.Is(err, frame.ErrCRCMismatch) {
+		t.Fatalf("expected ErrCRCMismatch, got: %v", err)
+	}
+}
+
+func TestReceiveFramePHPOutput(t *testing.T) {
+	Preallocate()
+
+	tests := []struct {
+		output string
+		// the output expected in the error, the warnings are read only until the deadline
+		expected string
+	}{
+		{"Could not open input file: ../tests/psr-worker.php", "Could not open input file: ../tests/psr-worker.php"},
+		{"PHP Fatal error:  Uncaught Error: Class not found in worker.php:5", "PHP Fatal error:  Uncaught Error: Class not found in worker.php:5"},
+		{"PHP Parse error:  syntax error, unexpected ';' in worker.php on line 3", "PHP Parse error:  syntax error, unexpected ';' in worker.php on line 3"},
+		{"\nFatal error: Allowed memory size exhausted in worker.php on line 10", "\nFatal error: Allowed memory size exhausted in worker.php on line 10"},
+		{"\nParse error: syntax error in worker.php on line 3", "\nParse error: syntax error in worker.php on line 3"},
+		{"PHP Warning:  Undefined variable $foo in worker.php on line 7", "PHP Warning:"},
+		{"Warning: Undefined array key 1 in worker.php on line 8", "Warning: Und"},
+		{"Deprecated: Creation of dynamic property in worker.php on line 9", "Deprecated: "},
+	}
+
+	for _, tt := range tests {
+		err := ReceiveFrame(bytes.NewReader([]byte(tt.output)), frame.NewFrame())
+		if !errors.Is(errors.FileNotFound, err) {
+			t.Fatalf("expected the FileNotFound kind for %q, got: %v", tt.output, err)
+		}
+		var e *errors.Error
+		if !stderr.As(err, &e) {
+			t.Fatalf("expected *errors.Error for %q, got: %v", tt.output, err)
+		}
+		var pe *frame.PHPOutputError
+		if !stderr.As(e.Err, &pe) || !stderr.Is(e.Err, frame.ErrPHPOutput) {
+			t.Fatalf("expected PHPOutputError for %q, got: %v", tt.output, err)
+		}
+		if pe.Output != tt.expected {
+			t.Fatalf("expected output %q, got: %q", tt.expected, pe.Output)
+		}
+	}
+}
+
+// emptyReader returns no data and no error on every other read
+type emptyReader struct {
+	r     io.Reader
+	empty bool
+}
+
+func (e *emptyReader) Read(p []byte) (int, error) {
+	e.empty = !e.empty
+	if e.empty {
+		return 0, nil
+	}
+
+	return e.r.Read(p)
+}
+
+// stuckReader never returns the data nor the error
+type stuckReader struct{}
+
+func (stuckReader) Read([]byte) (int, error) {
+	return 0, nil
+}
+
+func TestReceiveFrameEmptyReads(t *testing.T) {
+	Preallocate()
+
+	nf := frame.NewFrame()
+	nf.WriteVersion(nf.Header(), frame.Version1)
+	nf.WriteOptions(nf.HeaderPtr(), 1, 4)
+	nf.WritePayloadLen(nf.Header(), 4)
+	nf.WritePayload([]byte("test"))
+	nf.WriteCRC(nf.Header())
+
+	// the empty reads between the data are retried
+	fr := frame.NewFrame()
+	err := ReceiveFrame(&emptyReader{r: iotest.OneByteReader(bytes.NewReader(nf.Bytes()))}, fr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(fr.Payload()) != "test" {
+		t.Fatalf("unexpected payload: %q", fr.Payload())
+	}
+
+	// the reader which never returns the data fails instead of spinning
+	err = ReceiveFrame(stuckReader{}, frame.NewFrame())
+	if !stderr.Is(err, io.ErrNoProgress) {
+		t.Fatalf("expected io.ErrNoProgress, got: %v", err)
+	}
+}
+
+func TestReceiveFrameHugePayloadLength(t *testing.T) {
+	Preallocate()
+
+	// 4Gb payload declared by the frame without CRC
+	nf := frame.NewFrame()
+	nf.WriteVersion(nf.Header(), frame.Version1)
+	nf.WriteOptions(nf.HeaderPtr(), 1, 0)
+	nf.WritePayloadLen(nf.Header(), math.MaxUint32)
+	nf.SetCRCDisabled(nf.Header())
+	data := append(nf.Header(), "truncated"...)
+
+	// the payload is not allocated upfront
+	err := ReceiveFrameWithPolicy(bytes.NewReader(data), frame.NewFrame(), frame.CRCTrusted)
+	var te *frame.TruncatedPayloadError
+	if !stderr.As(err, &te) {
+		t.Fatalf("expected TruncatedPayloadError, got: %v", err)
+	}
+	if te.Expected != math.MaxUint32 || te.Received != uint32(len("truncated")) {
+		t.Fatalf("unexpected counters: %+v", te)
+	}
+}
+
+func FuzzReceiveFrame(f *testing.F) {
+	Preallocate()
+
+	nf := frame.NewFrame()
+	nf.WriteVersion(nf.Header(), frame.Version1)
+	nf.WriteFlags(nf.Header(), frame.CodecJSON)
+	nf.WriteOptions(nf.HeaderPtr(), 1, 14)
+	nf.WritePayloadLen(nf.Header(), 21)
+	nf.WritePayload([]byte(`Service.Method{"a":1}`))
+	nf.WriteCRC(nf.Header())
+	f.Add(nf.Bytes(), false, byte(0))
+
+	// the fields after the CRC are mutated only without the CRC
+	nf.SetCRCDisabled(nf.Header())
+	f.Add(nf.Bytes(), true, byte(1))
+	f.Add(nf.Header(), true, byte(2))
+	f.Add([]byte{}, false, byte(0))
+	f.Add(make([]byte, 12), true, byte(0))
+	f.Add([]byte("PHP Fatal error"), false, byte(2))
+	// the aligned payloads, including the truncated one declaring 4GB
+	f.Add(nf.Bytes(), true, byte(3))
+	nf.WritePayloadLen(nf.Header(), math.MaxUint32)
+	f.Add(nf.Bytes(), true, byte(4))
+
+	f.Fuzz(func(t *testing.T, data []byte, trusted bool, mode byte) {
+		var r io.Reader = bytes.NewReader(data)
+		switch mode % 3 {
+		case 1:
+			r = iotest.OneByteReader(r)
+		case 2:
+			r = &emptyReader{r: r}
+		}
+
+		cfg := ReceiveConfig{Policy: frame.CRCRequired}
+		if trusted {
+			cfg.Policy = frame.CRCTrusted
+		}
+		if mode/3%2 == 1 {
+			cfg.Alignment = 64
+		}
+
+		fr := frame.NewFrame()
+		err := ReceiveFrameWithConfig(r, fr, cfg)
+		if err != nil {
+			return
+		}
+
+		// the received frame is consistent
+		if err := fr.ValidateOptions(fr.Header()); err != nil {
+			t.Fatalf("invalid options of the received frame: %v", err)
+		}
+		if int(fr.ReadPayloadLen(fr.Header())) != len(fr.Payload()) {
+			t.Fatalf("payload length %d doesn't match the header %d", len(fr.Payload()), fr.ReadPayloadLen(fr.Header()))
+		}
+		if cfg.Alignment > 0 && len(fr.Payload()) > 0 && uintptr(unsafe.Pointer(&fr.Payload()[0]))%uintptr(cfg.Alignment) != 0 {
+			t.Fatalf("payload is not aligned to %d bytes", cfg.Alignment)
+		}
+		_ = fr.ReadOptions(fr.Header())
+	})
+}
+
+func TestReceiveFrameMaxFrameSize(t *testing.T) {
+	Preallocate()
+
+	nf := frame.NewFrame()
+	nf.WriteVersion(nf.Header(), frame.Version1)
+	nf.WriteOptions(nf.HeaderPtr(), 1, 0)
+	nf.WritePayloadLen(nf.Header(), 100)
+	nf.WritePayload(make([]byte, 100))
+	nf.WriteCRC(nf.Header())
+
+	// the payload is not read
+	r := bytes.NewReader(nf.Bytes())
+	err := ReceiveFrameWithConfig(r, frame.NewFrame(), ReceiveConfig{MaxFrameSize: 119})
+	var fe *frame.FrameTooLargeError
+	if !stderr.As(err, &fe) || !stderr.Is(err, frame.ErrFrameTooLarge) {
+		t.Fatalf("expected FrameTooLargeError, got: %v", err)
+	}
+	if fe.Size != 120 || fe.Limit != 119 || r.Len() != 100 {
+		t.Fatalf("unexpected error: %+v, unread: %d", fe, r.Len())
+	}
+
+	err = ReceiveFrameWithConfig(bytes.NewReader(nf.Bytes()), frame.NewFrame(), ReceiveConfig{MaxFrameSize: 120})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReceiveFrameBuilder(t *testing.T) {
+	Preallocate()
+
+	built, err := frame.NewBuilder().
+		Payload([]byte("Test.Methodbody")).
+		Options(7, 11, 1, 2).
+		Flags(frame.CodecRaw).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fr := frame.NewFrame()
+	err = ReceiveFrame(bytes.NewReader(built.Bytes()), fr)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(fr.Header(), built.Header()) || !bytes.Equal(fr.Payload(), []byte("Test.Methodbody")) {
+		t.Fatalf("frame is not reconstructed:\n%s", frame.DumpFrame(fr))
+	}
+	if opts := fr.ReadOptions(fr.Header()); len(opts) != 4 || opts[0] != 7 || opts[1] != 11 {
+		t.Fatalf("unexpected options: %v", opts)
+	}
+}
@@ -0,0 +1,68 @@
+package internal
+
+import (
+	"io"
+	"net"
+)
+
+// maxEmptyWrites is the number of the consecutive writes accepting no data and returning no error after which
+// the relay is considered broken
+const maxEmptyWrites = 100
+
+// WriteFull writes the whole data, looping over the short writes returning no error (some transports and
+// the writers interrupted by signals do that), so the frame is never split. Fails with io.ErrShortWrite on
+// the writers accepting no data or reporting more bytes than requested.
+func WriteFull(w io.Writer, data []byte) (int, error) {
+	n, empty := 0, 0
+	for n < len(data) {
+		m, err := w.Write(data[n:])
+		if m < 0 || m > len(data)-n {
+			return n, io.ErrShortWrite
+		}
+
+		n += m
+		switch {
+		case err != nil:
+			return n, err
+		case m == 0:
+			empty++
+			if empty >= maxEmptyWrites {
+				return n, io.ErrShortWrite
+			}
+		default:
+			empty = 0
+		}
+	}
+
+	return n, nil
+}
+
+// WriteFrame writes the header and the payload of the frame without joining them into one buffer. The TCP and
+// Unix connections write both with one vectored write, the other writers - with WriteFull each.
+func WriteFrame(w io.Writer, header, payload []byte) error {
+	switch w.(type) {
+	case *net.TCPConn, *net.UnixConn:
+		// writev of these connections never returns the short writes without an error
+		bufs := net.Buffers{header, payload}
+		_, err := bufs.WriteTo(w)
+		return err
+	}
+
+	_, err := WriteFull(w, header)
+	if err != nil {
+		return err
+	}
+
+	_, err = WriteFull(w, payload)
+	return err
+}
+
+// FullWriter is the io.Writer writing the whole data with WriteFull, e.g. under bufio.Writer, which fails
+// with io.ErrShortWrite on the first short write.
+type FullWriter struct {
+	W io.Writer
+}
+
+func (f FullWriter) Write(data []byte) (int, error) {
+	return WriteFull(f.W, data)
+}
@@ -0,0 +1,97 @@
+package internal
+
+import (
+	"bytes"
+	stderr "errors"
+	"io"
+	"net"
+	"testing"
+)
+
+// shortWriter accepts up to n bytes per write without an error
+type shortWriter struct {
+	buf bytes.Buffer
+	n   int
+}
+
+func (s *shortWriter) Write(data []byte) (int, error) {
+	return s.buf.Write(data[:min(len(data), s.n)])
+}
+
+func TestWriteFullShortWrites(t *testing.T) {
+	data := bytes.Repeat([]byte("frame"), 100)
+	w := &shortWriter{n: 7}
+
+	n, err := WriteFull(w, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != len(data) || !bytes.Equal(w.buf.Bytes(), data) {
+		t.Fatalf("written %d of %d bytes", n, len(data))
+	}
+}
+
+func TestWriteFullNoProgress(t *testing.T) {
+	n, err := WriteFull(&shortWriter{n: 0}, []byte("frame"))
+	if !stderr.Is(err, io.ErrShortWrite) || n != 0 {
+		t.Fatalf("expected io.ErrShortWrite, got %d, %v", n, err)
+	}
+}
+
+func TestWriteFrame(t *testing.T) {
+	header, payload := []byte("header12"), bytes.Repeat([]byte("frame"), 100)
+
+	buf := &bytes.Buffer{}
+	if err := WriteFrame(buf, header, payload); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), append(header, payload...)) {
+		t.Fatalf("written %d of %d bytes", buf.Len(), len(header)+len(payload))
+	}
+
+	// the vectored write of the TCP connection
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = ln.Close()
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	peer, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = peer.Close()
+	}()
+
+	if err = WriteFrame(conn, header, payload); err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.Close()
+
+	buf.Reset()
+	if _, err = buf.ReadFrom(peer); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buf.Bytes(), append(header, payload...)) {
+		t.Fatalf("received %d of %d bytes", buf.Len(), len(header)+len(payload))
+	}
+}
+
+func TestWriteFrameShortWrites(t *testing.T) {
+	header, payload := []byte("header12"), bytes.Repeat([]byte("frame"), 100)
+	w := &shortWriter{n: 7}
+
+	if err := WriteFrame(w, header, payload); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(w.buf.Bytes(), append(header, payload...)) {
+		t.Fatalf("written %d of %d bytes", w.buf.Len(), len(header)+len(payload))
+	}
+}
@@ -0,0 +1,368 @@
+package capture
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	stderr "errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/roadrunner-server/goridge/v3/pkg/relay"
+)
+
+const (
+	// DirectionReceived marks the frames received from the peer
+	DirectionReceived byte = 1
+	// DirectionSent marks the frames sent to the peer
+	DirectionSent byte = 2
+)
+
+// DefaultBufferSize is the default number of frames waiting to be written to the capture file
+const DefaultBufferSize = 1024
+
+// record header: direction byte + frame length (uint32 LE)
+const recordHeaderLen = 5
+
+// Config configures the capture relay
+type Config struct {
+	// Path of the capture file, rotated files are named Path.1, Path.2, ...
+	Path string
+	// MaxSize of the capture file in bytes before the rotation, 0 - no rotation
+	MaxSize int64
+	// CaptureSent enables capturing of the sent frames too
+	CaptureSent bool
+	// BufferSize is the number of frames waiting to be written, when the buffer is full the frames are dropped
+	// instead of slowing down the relay. Default - DefaultBufferSize.
+	BufferSize int
+}
+
+// Relay passes frames through the underlying relay and asynchronously appends them to the capture file.
+// Each record in the file is the direction byte, the frame length (uint32 LE) and the frame bytes.
+type Relay struct {
+	rl  relay.Relay
+	cfg Config
+
+	records chan []byte
+	done    chan struct{}
+	dropped atomic.Uint64
+
+	mu     sync.RWMutex
+	closed bool
+
+	file    *os.File
+	w       *bufio.Writer
+	size    int64
+	rotated int
+	err     error
+}
+
+// NewRelay creates the capture relay over rl, the capture file is opened in the append mode.
+func NewRelay(rl relay.Relay, cfg Config) (*Relay, error) {
+	const op = errors.Op("goridge_capture_new_relay")
+
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = DefaultBufferSize
+	}
+
+	c := &Relay{
+		rl:      rl,
+		cfg:     cfg,
+		records: make(chan []byte, cfg.BufferSize),
+		done:    make(chan struct{}),
+	}
+
+	err := c.open()
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	// continue the numbering of the already rotated files
+	for {
+		_, err = os.Stat(rotatedPath(cfg.Path, c.rotated+1))
+		if err != nil {
+			break
+		}
+		c.rotated++
+	}
+
+	go c.write()
+	return c, nil
+}
+
+// Send sends the frame to the underlying relay and captures it if Config.CaptureSent is set.
+func (c *Relay) Send(fr *frame.Frame) error {
+	err := c.rl.Send(fr)
+	if err == nil && c.cfg.CaptureSent {
+		c.capture(DirectionSent, fr)
+	}
+
+	return err
+}
+
+// Receive receives the frame from the underlying relay and captures it.
+func (c *Relay) Receive(fr *frame.Frame) error {
+	err := c.rl.Receive(fr)
+	if err == nil {
+		c.capture(DirectionReceived, fr)
+	}
+
+	return err
+}
+
+// ReceiveCtx receives the frame with the context if the underlying relay supports it and captures it.
+func (c *Relay) ReceiveCtx(ctx context.Context, fr *frame.Frame) error {
+	cr, ok := c.rl.(relay.ContextRelay)
+	if !ok {
+		return c.Receive(fr)
+	}
+
+	err := cr.ReceiveCtx(ctx, fr)
+	if err == nil {
+		c.capture(DirectionReceived, fr)
+	}
+
+	return err
+}
+
+// Dropped returns the number of frames dropped because the buffer was full.
+func (c *Relay) Dropped() uint64 {
+	return c.dropped.Load()
+}
+
+// Close closes the underlying relay, writes the buffered frames and closes the capture file.
+// Returns the first capture file error, if any.
+func (c *Relay) Close() error {
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return nil
+	}
+	c.closed = true
+	close(c.records)
+	c.mu.Unlock()
+
+	err := c.rl.Close()
+	<-c.done
+
+	if c.err != nil {
+		return c.err
+	}
+
+	return err
+}
+
+func (c *Relay) capture(direction byte, fr *frame.Frame) {
+	header, payload := fr.Header(), fr.Payload()
+	rec := make([]byte, recordHeaderLen, recordHeaderLen+len(header)+len(payload))
+	rec[0] = direction
+	binary.LittleEndian.PutUint32(rec[1:], uint32(len(header)+len(payload))) //nolint:gosec
+	rec = append(rec, header...)
+	rec = append(rec, payload...)
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.closed {
+		return
+	}
+
+	select {
+	case c.records <- rec:
+	default:
+		c.dropped.Add(1)
+	}
+}
+
+func (c *Relay) write() {
+	defer close(c.done)
+
+	for rec := range c.records {
+		if c.err != nil {
+			continue
+		}
+
+		if c.cfg.MaxSize > 0 && c.size > 0 && c.size+int64(len(rec)) > c.cfg.MaxSize {
+			c.err = c.rotate()
+			if c.err != nil {
+				continue
+			}
+		}
+
+		n, err := c.w.Write(rec)
+		c.size += int64(n)
+		if err != nil {
+			c.err = err
+			continue
+		}
+
+		// flush when there is nothing to batch
+		if len(c.records) == 0 {
+			c.err = c.w.Flush()
+		}
+	}
+
+	if c.err == nil {
+		c.err = c.w.Flush()
+	}
+
+	err := c.file.Close()
+	if c.err == nil {
+		c.err = err
+	}
+}
+
+func (c *Relay) open() error {
+	f, err := os.OpenFile(c.cfg.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+
+	st, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+
+	c.file, c.size = f, st.Size()
+	if c.w == nil {
+		c.w = bufio.NewWriter(f)
+	} else {
+		c.w.Reset(f)
+	}
+
+	return nil
+}
+
+func (c *Relay) rotate() error {
+	err := c.w.Flush()
+	if err != nil {
+		return err
+	}
+
+	err = c.file.Close()
+	if err != nil {
+		return err
+	}
+
+	c.rotated++
+	err = os.Rename(c.cfg.Path, rotatedPath(c.cfg.Path, c.rotated))
+	if err != nil {
+		return err
+	}
+
+	return c.open()
+}
+
+func rotatedPath(path string, n int) string {
+	return fmt.Sprintf("%s.%d", path, n)
+}
+
+// Files returns the capture files for the path in the order they were written: rotated files and the current one.
+func Files(path string) ([]string, error) {
+	var files []string
+	for n := 1; ; n++ {
+		_, err := os.Stat(rotatedPath(path, n))
+		if err != nil {
+			break
+		}
+		files = append(files, rotatedPath(path, n))
+	}
+
+	_, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(files, path), nil
+}
+
+// Reader reads the records written by the capture relay.
+type Reader struct {
+	r *bufio.Reader
+}
+
+// NewReader creates the capture file reader.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+// Next returns the direction and the frame of the next record, io.EOF at the end of the capture.
+func (r *Reader) Next() (byte, *frame.Frame, error) {
+	const op = errors.Op("goridge_capture_read")
+
+	var header [recordHeaderLen]byte
+	_, err := io.ReadFull(r.r, header[:])
+	if err != nil {
+		if stderr.Is(err, io.EOF) {
+			return 0, nil, io.EOF
+		}
+		return 0, nil, errors.E(op, err)
+	}
+
+	data := make([]byte, binary.LittleEndian.Uint32(header[1:]))
+	_, err = io.ReadFull(r.r, data)
+	if err != nil {
+		return 0, nil, errors.E(op, err)
+	}
+
+	if len(data) < 12 {
+		return 0, nil, errors.E(op, errors.Errorf("frame is too short: %d bytes", len(data)))
+	}
+
+	// frame.ReadFrame clears the stream byte of the frames without options, so the header is sliced here
+	hl := int(data[0]&0x0F) * frame.WORD
+	if hl < 12 || hl > len(data) {
+		return 0, nil, errors.E(op, errors.Errorf("invalid header length: %d", hl))
+	}
+
+	return header[0], frame.From(data[:hl:hl], data[hl:]), nil
+}
+
+// Replay sends all received frames from the capture files to the relay in the captured order.
+func Replay(rl relay.Relay, files ...string) error {
+	const op = errors.Op("goridge_capture_replay")
+
+	for _, path := range files {
+		err := replayFile(rl, path)
+		if err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	return nil
+}
+
+func replayFile(rl relay.Relay, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+	}()
+
+	r := NewReader(f)
+	for {
+		direction, fr, err := r.Next()
+		if err != nil {
+			if stderr.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+
+		if direction != DirectionReceived {
+			continue
+		}
+
+		err = rl.Send(fr)
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package capture
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/roadrunner-server/goridge/v3/pkg/pipe"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testFrame(seq uint32, flags byte, payload string) *frame.Frame {
+	fr := frame.NewFrame()
+	fr.WriteVersion(fr.Header(), frame.Version1)
+	fr.WriteFlags(fr.Header(), flags)
+	fr.WriteOptions(fr.HeaderPtr(), seq, 0)
+	fr.WritePayloadLen(fr.Header(), uint32(len(payload)))
+	fr.WritePayload([]byte(payload))
+	fr.WriteCRC(fr.Header())
+	return fr
+}
+
+func readAll(t *testing.T, files []string) ([]byte, []*frame.Frame) {
+	var directions []byte
+	var frames []*frame.Frame
+
+	for _, path := range files {
+		f, err := os.Open(path)
+		require.NoError(t, err)
+
+		r := NewReader(f)
+		for {
+			d, fr, err := r.Next()
+			if err != nil {
+				break
+			}
+			directions = append(directions, d)
+			frames = append(frames, fr)
+		}
+		_ = f.Close()
+	}
+
+	return directions, frames
+}
+
+func TestCaptureReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.bin")
+
+	peer, served := pipe.NewRelayPair()
+	c, err := NewRelay(served, Config{Path: path, MaxSize: 512, CaptureSent: true})
+	require.NoError(t, err)
+
+	const n = 20
+	go func() {
+		for i := uint32(0); i < n; i++ {
+			assert.NoError(t, peer.Send(testFrame(i, frame.CodecJSON, `{"request":`+strconv.Itoa(int(i))+`}`)))
+			assert.NoError(t, peer.Receive(frame.NewFrame()))
+		}
+	}()
+
+	var received []*frame.Frame
+	for i := uint32(0); i < n; i++ {
+		fr := frame.NewFrame()
+		require.NoError(t, c.Receive(fr))
+		received = append(received, fr)
+		require.NoError(t, c.Send(testFrame(i, frame.CodecRaw, "response")))
+	}
+
+	require.NoError(t, c.Close())
+	assert.Zero(t, c.Dropped())
+
+	files, err := Files(path)
+	require.NoError(t, err)
+	assert.Greater(t, len(files), 1, "capture file should be rotated")
+
+	for _, f := range files {
+		st, err := os.Stat(f)
+		require.NoError(t, err)
+		assert.LessOrEqual(t, st.Size(), int64(512))
+	}
+
+	directions, frames := readAll(t, files)
+	require.Len(t, frames, n*2)
+	for i := 0; i < n; i++ {
+		assert.Equal(t, DirectionReceived, directions[i*2])
+		assert.Equal(t, received[i].Bytes(), frames[i*2].Bytes())
+		assert.Equal(t, DirectionSent, directions[i*2+1])
+		assert.Equal(t, "response", string(frames[i*2+1].Payload()))
+	}
+
+	// replay the received frames to another server
+	client, server := pipe.NewRelayPair()
+	go func() {
+		assert.NoError(t, Replay(client, files...))
+	}()
+
+	for i := 0; i < n; i++ {
+		fr := frame.NewFrame()
+		require.NoError(t, server.Receive(fr))
+		assert.True(t, fr.VerifyCRC(fr.Header()))
+		assert.Equal(t, received[i].Bytes(), fr.Bytes())
+	}
+}
+
+func TestCapture_Append(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "capture.bin")
+
+	for i := uint32(0); i < 2; i++ {
+		peer, served := pipe.NewRelayPair()
+		c, err := NewRelay(served, Config{Path: path})
+		require.NoError(t, err)
+
+		go func() {
+			assert.NoError(t, peer.Send(testFrame(i, frame.CodecRaw, "payload")))
+		}()
+
+		require.NoError(t, c.Receive(frame.NewFrame()))
+		// not captured
+		go func() {
+			assert.NoError(t, peer.Receive(frame.NewFrame()))
+		}()
+		require.NoError(t, c.Send(testFrame(i, frame.CodecRaw, "response")))
+		require.NoError(t, c.Close())
+	}
+
+	directions, frames := readAll(t, []string{path})
+	require.Len(t, frames, 2)
+	assert.Equal(t, []byte{DirectionReceived, DirectionReceived}, directions)
+	assert.Equal(t, []uint32{1, 0}, frames[1].ReadOptions(frames[1].Header()))
+}
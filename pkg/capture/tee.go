@@ -0,0 +1,100 @@
+package capture
+
+import (
+	"context"
+	"io"
+	"sync"
+
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/roadrunner-server/goridge/v3/pkg/relay"
+)
+
+// TeeRelay passes the frames through the underlying relay and synchronously writes the raw bytes of every
+// sent and received frame (header and payload, as on the wire) to the writers, e.g. to replay them with
+// internal.ReceiveFrame. The frames and the results of the relay calls are never altered: the writer errors
+// are not returned by Send and Receive, see Err. TeeRelay is safe for the concurrent use.
+type TeeRelay struct {
+	rl       relay.Relay
+	sent     io.Writer
+	received io.Writer
+
+	mu  sync.Mutex
+	err error
+}
+
+// NewTeeRelay creates the tee relay over rl, nil writer disables the capture of the direction.
+// The same writer may be used for both directions.
+func NewTeeRelay(rl relay.Relay, sent, received io.Writer) *TeeRelay {
+	return &TeeRelay{
+		rl:       rl,
+		sent:     sent,
+		received: received,
+	}
+}
+
+// Send sends the frame to the underlying relay and writes it to the sent writer.
+func (t *TeeRelay) Send(fr *frame.Frame) error {
+	err := t.rl.Send(fr)
+	if err == nil {
+		t.tee(t.sent, fr)
+	}
+
+	return err
+}
+
+// Receive receives the frame from the underlying relay and writes it to the received writer.
+func (t *TeeRelay) Receive(fr *frame.Frame) error {
+	err := t.rl.Receive(fr)
+	if err == nil {
+		t.tee(t.received, fr)
+	}
+
+	return err
+}
+
+// ReceiveCtx receives the frame with the context if the underlying relay supports it and writes it to the received writer.
+func (t *TeeRelay) ReceiveCtx(ctx context.Context, fr *frame.Frame) error {
+	cr, ok := t.rl.(relay.ContextRelay)
+	if !ok {
+		return t.Receive(fr)
+	}
+
+	err := cr.ReceiveCtx(ctx, fr)
+	if err == nil {
+		t.tee(t.received, fr)
+	}
+
+	return err
+}
+
+// Err returns the first error of the writers, the frames are not written after it.
+func (t *TeeRelay) Err() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.err
+}
+
+// Close closes the underlying relay, the writers are owned by the caller.
+func (t *TeeRelay) Close() error {
+	return t.rl.Close()
+}
+
+func (t *TeeRelay) tee(w io.Writer, fr *frame.Frame) {
+	if w == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.err != nil {
+		return
+	}
+
+	// the header and the payload are written separately, so the payload is not copied
+	_, err := w.Write(fr.Header())
+	if err == nil {
+		_, err = w.Write(fr.Payload())
+	}
+	t.err = err
+}
@@ -0,0 +1,102 @@
+package capture
+
+import (
+	"bytes"
+	stderr "errors"
+	"io"
+	"strconv"
+	"testing"
+
+	"github.com/roadrunner-server/goridge/v3/internal"
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/roadrunner-server/goridge/v3/pkg/pipe"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type failWriter struct{}
+
+func (failWriter) Write([]byte) (int, error) {
+	return 0, io.ErrClosedPipe
+}
+
+func replay(t *testing.T, r io.Reader) []*frame.Frame {
+	var frames []*frame.Frame
+	for {
+		fr := frame.NewFrame()
+		err := internal.ReceiveFrame(r, fr)
+		if stderr.Is(err, io.EOF) {
+			return frames
+		}
+		require.NoError(t, err)
+		frames = append(frames, fr)
+	}
+}
+
+func TestTeeReplay(t *testing.T) {
+	peer, served := pipe.NewRelayPair()
+	sent, received := &bytes.Buffer{}, &bytes.Buffer{}
+	tee := NewTeeRelay(served, sent, received)
+
+	const n = 10
+	var requests []*frame.Frame
+	for i := uint32(0); i < n; i++ {
+		requests = append(requests, testFrame(i, frame.CodecJSON, `{"request":`+strconv.Itoa(int(i))+`}`))
+	}
+	go func() {
+		for _, fr := range requests {
+			assert.NoError(t, peer.Send(fr))
+			assert.NoError(t, peer.Receive(frame.NewFrame()))
+		}
+	}()
+
+	var responses []*frame.Frame
+	for i := uint32(0); i < n; i++ {
+		fr := frame.NewFrame()
+		require.NoError(t, tee.Receive(fr))
+		assert.Equal(t, []uint32{i, 0}, fr.ReadOptions(fr.Header()))
+
+		resp := testFrame(i, frame.CodecRaw, "response "+strconv.Itoa(int(i)))
+		responses = append(responses, resp)
+		require.NoError(t, tee.Send(resp))
+	}
+	require.NoError(t, tee.Err())
+
+	for name, tc := range map[string]struct {
+		captured *bytes.Buffer
+		frames   []*frame.Frame
+	}{
+		"received": {received, requests},
+		"sent":     {sent, responses},
+	} {
+		replayed := replay(t, tc.captured)
+		require.Len(t, replayed, n, name)
+		for i, fr := range replayed {
+			assert.True(t, fr.VerifyCRC(fr.Header()), name)
+			assert.Equal(t, tc.frames[i].Header(), fr.Header(), name)
+			assert.Equal(t, tc.frames[i].Payload(), fr.Payload(), name)
+		}
+	}
+
+	require.NoError(t, tee.Close())
+}
+
+func TestTeeWriterError(t *testing.T) {
+	peer, served := pipe.NewRelayPair()
+	tee := NewTeeRelay(served, nil, failWriter{})
+
+	go func() {
+		assert.NoError(t, peer.Send(testFrame(1, frame.CodecRaw, "request")))
+	}()
+
+	// the writer error is not returned and the frame is not altered
+	fr := frame.NewFrame()
+	require.NoError(t, tee.Receive(fr))
+	assert.Equal(t, []byte("request"), fr.Payload())
+	assert.ErrorIs(t, tee.Err(), io.ErrClosedPipe)
+
+	go func() {
+		assert.NoError(t, peer.Receive(frame.NewFrame()))
+	}()
+	require.NoError(t, tee.Send(testFrame(1, frame.CodecRaw, "response")))
+}
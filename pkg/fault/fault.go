@@ -0,0 +1,126 @@
+package fault
+
+import (
+	"sync"
+
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/roadrunner-server/goridge/v3/pkg/relay"
+)
+
+type kind uint8
+
+const (
+	kindError kind = iota
+	kindTruncate
+	kindCorruptCRC
+)
+
+type fault struct {
+	kind kind
+	err  error
+	size int
+}
+
+// Relay passes the frames through the underlying relay and injects the scripted failures into the Nth
+// Send or Receive call (counting from 1), to test the error handling of the codecs deterministically.
+// Every fault is injected once. Relay is safe for the concurrent use.
+type Relay struct {
+	rl relay.Relay
+
+	mu       sync.Mutex
+	sends    int
+	receives int
+	send     map[int]fault
+	receive  map[int]fault
+}
+
+// NewRelay creates the fault-injecting relay over rl, e.g. one of pipe.NewRelayPair.
+func NewRelay(rl relay.Relay) *Relay {
+	return &Relay{
+		rl:      rl,
+		send:    make(map[int]fault),
+		receive: make(map[int]fault),
+	}
+}
+
+// FailSend makes the Nth Send return the err, the frame is not sent.
+func (r *Relay) FailSend(n int, err error) {
+	r.mu.Lock()
+	r.send[n] = fault{kind: kindError, err: err}
+	r.mu.Unlock()
+}
+
+// FailReceive makes the Nth Receive return the err instead of receiving the frame. The frame stays in the
+// underlying relay, so the next Receive returns it. To simulate the connection closed mid-frame use
+// io.ErrUnexpectedEOF or a frame.TruncatedPayloadError.
+func (r *Relay) FailReceive(n int, err error) {
+	r.mu.Lock()
+	r.receive[n] = fault{kind: kindError, err: err}
+	r.mu.Unlock()
+}
+
+// TruncatePayload makes the Nth Receive return the frame with the payload cut to the size bytes,
+// while the header still declares the original payload length.
+func (r *Relay) TruncatePayload(n int, size int) {
+	r.mu.Lock()
+	r.receive[n] = fault{kind: kindTruncate, size: size}
+	r.mu.Unlock()
+}
+
+// CorruptCRC makes the Nth Receive return the frame with the corrupted header CRC. The frame is delivered, so
+// the receivers verifying the CRC themselves (like the rpc.ClientCodec) detect it.
+func (r *Relay) CorruptCRC(n int) {
+	r.mu.Lock()
+	r.receive[n] = fault{kind: kindCorruptCRC}
+	r.mu.Unlock()
+}
+
+// Send sends the frame through the underlying relay, unless the failure is scripted for the call.
+func (r *Relay) Send(fr *frame.Frame) error {
+	r.mu.Lock()
+	r.sends++
+	f, ok := r.send[r.sends]
+	delete(r.send, r.sends)
+	r.mu.Unlock()
+
+	if ok {
+		return f.err
+	}
+
+	return r.rl.Send(fr)
+}
+
+// Receive receives the frame from the underlying relay and applies the failure scripted for the call.
+func (r *Relay) Receive(fr *frame.Frame) error {
+	r.mu.Lock()
+	r.receives++
+	f, ok := r.receive[r.receives]
+	delete(r.receive, r.receives)
+	r.mu.Unlock()
+
+	if ok && f.kind == kindError {
+		return f.err
+	}
+
+	err := r.rl.Receive(fr)
+	if err != nil || !ok {
+		return err
+	}
+
+	switch f.kind {
+	case kindTruncate:
+		if f.size < len(fr.Payload()) {
+			fr.SetPayload(fr.Payload()[:f.size])
+		}
+	case kindCorruptCRC:
+		// bytes 6-9 of the header are the CRC
+		fr.Header()[6] ^= 0xFF
+	}
+
+	return nil
+}
+
+// Close closes the underlying relay.
+func (r *Relay) Close() error {
+	return r.rl.Close()
+}
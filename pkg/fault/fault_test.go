@@ -0,0 +1,108 @@
+package fault
+
+import (
+	"io"
+	"net/rpc"
+	"testing"
+
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/roadrunner-server/goridge/v3/pkg/pipe"
+	goridgeRpc "github.com/roadrunner-server/goridge/v3/pkg/rpc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testFrame(payload string) *frame.Frame {
+	fr := frame.NewFrame()
+	fr.WriteVersion(fr.Header(), frame.Version1)
+	fr.WriteFlags(fr.Header(), frame.CodecRaw)
+	fr.WritePayloadLen(fr.Header(), uint32(len(payload)))
+	fr.WritePayload([]byte(payload))
+	fr.WriteCRC(fr.Header())
+	return fr
+}
+
+func TestRelay_FailSend(t *testing.T) {
+	a, b := pipe.NewRelayPair()
+	rl := NewRelay(a)
+	injected := errors.Str("injected")
+	rl.FailSend(2, injected)
+
+	go func() {
+		for i := 0; i < 2; i++ {
+			fr := frame.NewFrame()
+			assert.NoError(t, b.Receive(fr))
+		}
+	}()
+
+	require.NoError(t, rl.Send(testFrame("first")))
+	assert.ErrorIs(t, rl.Send(testFrame("second")), injected)
+	require.NoError(t, rl.Send(testFrame("third")))
+}
+
+func TestRelay_FailReceive(t *testing.T) {
+	a, b := pipe.NewRelayPair()
+	codec := goridgeRpc.NewCodecWithRelay(func() *Relay {
+		rl := NewRelay(a)
+		rl.FailReceive(1, io.ErrUnexpectedEOF)
+		return rl
+	}())
+	client := goridgeRpc.NewClientCodecWithRelay(b)
+
+	go func() {
+		assert.NoError(t, client.WriteRequest(&rpc.Request{ServiceMethod: "test.Fault", Seq: 1}, "ping"))
+	}()
+
+	r := &rpc.Request{}
+	assert.ErrorIs(t, codec.ReadRequestHeader(r), io.ErrUnexpectedEOF)
+
+	// the frame is received by the next call
+	require.NoError(t, codec.ReadRequestHeader(r))
+	assert.Equal(t, "test.Fault", r.ServiceMethod)
+	var in string
+	require.NoError(t, codec.ReadRequestBody(&in))
+	assert.Equal(t, "ping", in)
+}
+
+func TestRelay_TruncatePayload(t *testing.T) {
+	a, b := pipe.NewRelayPair()
+	rl := NewRelay(a)
+	rl.TruncatePayload(1, len("test.Fault")+2)
+	codec := goridgeRpc.NewCodecWithRelay(rl)
+	client := goridgeRpc.NewClientCodecWithRelay(b)
+
+	go func() {
+		assert.NoError(t, client.WriteRequest(&rpc.Request{ServiceMethod: "test.Fault", Seq: 1}, "ping"))
+	}()
+
+	r := &rpc.Request{}
+	require.NoError(t, codec.ReadRequestHeader(r))
+	var in string
+	assert.ErrorIs(t, codec.ReadRequestBody(&in), io.ErrUnexpectedEOF)
+}
+
+func TestRelay_CorruptCRC(t *testing.T) {
+	a, b := pipe.NewRelayPair()
+	rl := NewRelay(b)
+	rl.CorruptCRC(1)
+	codec := goridgeRpc.NewCodecWithRelay(a)
+	client := goridgeRpc.NewClientCodecWithRelay(rl)
+
+	for seq := uint64(1); seq <= 2; seq++ {
+		go func() {
+			assert.NoError(t, codec.WriteResponse(&rpc.Response{ServiceMethod: "test.Fault", Seq: seq}, "pong"))
+		}()
+	}
+
+	resp := &rpc.Response{}
+	err := client.ReadResponseHeader(resp)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "CRC verification failed")
+
+	// only the first frame is corrupted
+	require.NoError(t, client.ReadResponseHeader(resp))
+	var out string
+	require.NoError(t, client.ReadResponseBody(&out))
+	assert.Equal(t, "pong", out)
+}
@@ -0,0 +1,115 @@
+package frame
+
+import (
+	"math"
+
+	"github.com/roadrunner-server/errors"
+)
+
+// maxOptions is the number of the option words which fit into OptionsMaxSize
+const maxOptions = OptionsMaxSize / WORD
+
+// Builder assembles the frame from the fields set in any order, Build writes them in the right order (version,
+// header length, flags, options, payload length and CRC) and validates the constraints. The builder is reusable:
+// Build doesn't change it, so the frames differing in a field might be built by changing only that field, and
+// Reset clears it. Not safe for the concurrent use.
+type Builder struct {
+	version byte
+	flags   byte
+	byte10  byte
+	byte11  byte
+	options []uint32
+	payload []byte
+	noCRC   bool
+}
+
+// NewBuilder creates the builder of the Version1 frames.
+func NewBuilder() *Builder {
+	return &Builder{version: Version1}
+}
+
+// Version sets the protocol version (4 bits), Version1 by default.
+func (b *Builder) Version(version byte) *Builder {
+	b.version = version
+	return b
+}
+
+// Flags sets the bits of the 1st byte (the codec, CONTROL and ERROR), combined with the previously set ones.
+func (b *Builder) Flags(flags ...byte) *Builder {
+	for _, f := range flags {
+		b.flags |= f
+	}
+	return b
+}
+
+// Bits sets the bits of the 10th (e.g. STREAM, PING) and 11th (e.g. NoMethodPrefix) bytes, combined with the
+// previously set ones.
+func (b *Builder) Bits(byte10, byte11 byte) *Builder {
+	b.byte10 |= byte10
+	b.byte11 |= byte11
+	return b
+}
+
+// Options appends the options, up to 10 in total.
+func (b *Builder) Options(options ...uint32) *Builder {
+	b.options = append(b.options, options...)
+	return b
+}
+
+// Payload sets the payload, the data is not copied and is owned by the built frames.
+func (b *Builder) Payload(payload []byte) *Builder {
+	b.payload = payload
+	return b
+}
+
+// DisableCRC sets the CRCDisabled bit instead of writing the header CRC, see CRCTrusted.
+func (b *Builder) DisableCRC() *Builder {
+	b.noCRC = true
+	return b
+}
+
+// Reset clears the builder to the state of NewBuilder, keeping the allocated options.
+func (b *Builder) Reset() {
+	*b = Builder{version: Version1, options: b.options[:0]}
+}
+
+// Build returns the new frame with the header allocated once with the exact size.
+func (b *Builder) Build() (*Frame, error) {
+	const op = errors.Op("goridge_frame_build")
+
+	switch {
+	case b.version == 0 || b.version > 15:
+		return nil, errors.E(op, errors.Errorf("version should be from 1 to 15, got: %d", b.version))
+	case len(b.options) > maxOptions:
+		return nil, errors.E(op, errors.Errorf("up to %d options are allowed, got: %d", maxOptions, len(b.options)))
+	case uint64(len(b.payload)) > math.MaxUint32:
+		return nil, errors.E(op, errors.Errorf("payload length %d doesn't fit into 32 bits", len(b.payload)))
+	case b.byte10&CompressedGzip != 0 && b.byte10&CompressedZstd != 0:
+		return nil, errors.E(op, errors.Str("gzip and zstd compression bits are mutually exclusive"))
+	}
+
+	fr := From(make([]byte, 12+len(b.options)*WORD), b.payload)
+	header := fr.Header()
+	fr.WriteVersion(header, b.version)
+	fr.writeHl(header, byte(3+len(b.options))) //nolint:gosec
+	fr.WriteFlags(header, b.flags)
+	fr.WritePayloadLen(header, uint32(len(b.payload))) //nolint:gosec
+
+	for i, o := range b.options {
+		j := 12 + i*WORD
+		header[j] = byte(o)
+		header[j+1] = byte(o >> 8)
+		header[j+2] = byte(o >> 16)
+		header[j+3] = byte(o >> 24)
+	}
+
+	header[10] = b.byte10
+	header[11] = b.byte11
+	if b.noCRC {
+		fr.SetCRCDisabled(header)
+	} else {
+		fr.WriteCRC(header)
+	}
+
+	return fr, nil
+}
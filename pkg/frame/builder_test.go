@@ -0,0 +1,84 @@
+package frame
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuilder(t *testing.T) {
+	// the fields are set in any order
+	fr, err := NewBuilder().
+		Payload([]byte(TestPayload)).
+		Options(323423432, 1213231).
+		Bits(STREAM, NoMethodPrefix).
+		Flags(CodecJSON).
+		Build()
+	require.NoError(t, err)
+
+	// the same frame built by hand
+	nf := NewFrame()
+	nf.WriteVersion(nf.Header(), Version1)
+	nf.WriteFlags(nf.Header(), CodecJSON)
+	nf.WriteOptions(nf.HeaderPtr(), 323423432, 1213231)
+	nf.SetStreamFlag(nf.Header())
+	nf.SetNoMethodPrefix(nf.Header())
+	nf.WritePayloadLen(nf.Header(), uint32(len(TestPayload)))
+	nf.WritePayload([]byte(TestPayload))
+	nf.WriteCRC(nf.Header())
+
+	assert.Equal(t, nf.Bytes(), fr.Bytes())
+	assert.True(t, fr.VerifyCRC(fr.Header()))
+	assert.NoError(t, fr.ValidateOptions(fr.Header()))
+}
+
+func TestBuilder_Reuse(t *testing.T) {
+	b := NewBuilder().Flags(CodecRaw).Options(1, 0).Payload([]byte("first"))
+	first, err := b.Build()
+	require.NoError(t, err)
+
+	second, err := b.Payload([]byte("second")).Build()
+	require.NoError(t, err)
+	assert.Equal(t, []byte("first"), first.Payload())
+	assert.Equal(t, uint32(5), first.ReadPayloadLen(first.Header()))
+	assert.Equal(t, uint32(6), second.ReadPayloadLen(second.Header()))
+	assert.Equal(t, []uint32{1, 0}, second.ReadOptions(second.Header()))
+
+	b.Reset()
+	fr, err := b.DisableCRC().Build()
+	require.NoError(t, err)
+	assert.Equal(t, Version1, fr.ReadVersion(fr.Header()))
+	assert.Nil(t, fr.ReadOptions(fr.Header()))
+	assert.True(t, fr.IsCRCDisabled(fr.Header()))
+	assert.False(t, fr.VerifyCRC(fr.Header()))
+}
+
+func TestBuilder_Validation(t *testing.T) {
+	_, err := NewBuilder().Options(make([]uint32, 11)...).Build()
+	assert.Error(t, err)
+
+	_, err = NewBuilder().Version(16).Build()
+	assert.Error(t, err)
+
+	_, err = NewBuilder().Version(0).Build()
+	assert.Error(t, err)
+
+	_, err = NewBuilder().Bits(CompressedGzip|CompressedZstd, 0).Build()
+	assert.Error(t, err)
+
+	_, err = NewBuilder().Options(make([]uint32, 10)...).Build()
+	assert.NoError(t, err)
+}
+
+func BenchmarkBuilder(b *testing.B) {
+	builder := NewBuilder().Flags(CodecJSON).Options(1, 12).Payload([]byte(TestPayload))
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		_, err := builder.Build()
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
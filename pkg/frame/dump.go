@@ -0,0 +1,121 @@
+package frame
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// DumpPayloadLimit is the number of the payload bytes rendered by DumpFrame, the rest is omitted
+const DumpPayloadLimit = 256
+
+type bitName struct {
+	bit  byte
+	name string
+}
+
+var (
+	flagNames = []bitName{ //nolint:gochecknoglobals
+		{CONTROL, "CONTROL"}, {CodecFlatbuffers, "FLATBUFFERS"}, {CodecBSON, "BSON"}, {CodecRaw, "RAW"}, {CodecJSON, "JSON"},
+		{CodecMsgpack, "MSGPACK"}, {CodecGob, "GOB"}, {ERROR, "ERROR"}, {CodecProto, "PROTO"},
+	}
+	byte10Names = []bitName{ //nolint:gochecknoglobals
+		{STREAM, "STREAM"}, {STOP, "STOP"}, {PING, "PING"}, {PONG, "PONG"}, {CompressedGzip, "GZIP"},
+		{CompressedZstd, "ZSTD"}, {CRCDisabled, "CRC_DISABLED"}, {CLOSE, "CLOSE"},
+	}
+	byte11Names = []bitName{ //nolint:gochecknoglobals
+		{NoMethodPrefix, "NO_METHOD_PREFIX"}, {TxCommit, "TX_COMMIT"}, {StructuredError, "STRUCTURED_ERROR"},
+		{Handshake, "HANDSHAKE"}, {Batch, "BATCH"}, {ErrorBody, "ERROR_BODY"}, {Push, "PUSH"},
+	}
+)
+
+// DumpFrame renders the header fields (version, header and payload length, decoded flags, options and CRC status)
+// and the hexdump of the header and the payload in the human-readable form, e.g. to report malformed frames.
+// Malformed headers are rendered as far as possible.
+func DumpFrame(fr *Frame) string {
+	var sb strings.Builder
+	_ = WriteDump(&sb, fr)
+	return sb.String()
+}
+
+// WriteDump writes the DumpFrame output to w.
+func WriteDump(w io.Writer, fr *Frame) error {
+	d := &dumper{w: w}
+	header := fr.Header()
+
+	if len(header) < 12 {
+		d.printf("malformed header: %d bytes, expected at least 12\n", len(header))
+		d.printf("header:\n%s", hex.Dump(header))
+		return d.err
+	}
+
+	hl := fr.ReadHL(header)
+	d.printf("version:        %d\n", fr.ReadVersion(header))
+	d.printf("header length:  %d words (%d bytes), received %d bytes\n", hl, int(hl)*WORD, len(header))
+	d.printf("payload length: %d bytes, received %d bytes\n", fr.ReadPayloadLen(header), len(fr.Payload()))
+	d.printf("flags:          %s\n", bitNames(header[1], flagNames))
+	d.printf("byte 10:        %s\n", bitNames(header[10], byte10Names))
+	d.printf("byte 11:        %s\n", bitNames(header[11], byte11Names))
+
+	if err := fr.ValidateOptions(header); err != nil {
+		d.printf("options:        %v\n", err)
+	} else {
+		d.printf("options:        %v\n", fr.ReadOptions(header))
+	}
+
+	crc := binary.LittleEndian.Uint32(header[6:10])
+	switch {
+	case fr.IsCRCDisabled(header):
+		d.printf("crc:            0x%08x (disabled)\n", crc)
+	case fr.VerifyCRC(header):
+		d.printf("crc:            0x%08x (valid)\n", crc)
+	default:
+		d.printf("crc:            0x%08x (invalid)\n", crc)
+	}
+
+	d.printf("header:\n%s", hex.Dump(header))
+
+	payload := fr.Payload()
+	if len(payload) > DumpPayloadLimit {
+		d.printf("payload (first %d of %d bytes):\n%s", DumpPayloadLimit, len(payload), hex.Dump(payload[:DumpPayloadLimit]))
+	} else {
+		d.printf("payload:\n%s", hex.Dump(payload))
+	}
+
+	return d.err
+}
+
+// bitNames renders the byte with the names of the set bits, unknown bits are rendered as hex
+func bitNames(b byte, names []bitName) string {
+	var set []string
+	rest := b
+	// the combined bits (CodecBSON) go before the single ones
+	for _, n := range names {
+		if rest&n.bit == n.bit {
+			set = append(set, n.name)
+			rest &^= n.bit
+		}
+	}
+
+	if rest != 0 {
+		set = append(set, fmt.Sprintf("0x%02x", rest))
+	}
+
+	return fmt.Sprintf("0x%02x [%s]", b, strings.Join(set, " "))
+}
+
+// dumper keeps the first write error
+type dumper struct {
+	w   io.Writer
+	err error
+}
+
+func (d *dumper) printf(format string, args ...any) {
+	if d.err != nil {
+		return
+	}
+
+	_, d.err = fmt.Fprintf(d.w, format, args...)
+}
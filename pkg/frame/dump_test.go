@@ -0,0 +1,64 @@
+package frame
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDumpFrame(t *testing.T) {
+	fr := NewFrame()
+	fr.WriteVersion(fr.Header(), Version1)
+	fr.WriteFlags(fr.Header(), CodecJSON, ERROR)
+	fr.SetStreamFlag(fr.Header())
+	fr.WriteOptions(fr.HeaderPtr(), 12, 4)
+	fr.WritePayloadLen(fr.Header(), 9)
+	fr.WritePayload([]byte("test{\"a\"}"))
+	fr.WriteCRC(fr.Header())
+
+	dump := DumpFrame(fr)
+	for _, field := range []string{
+		"version:        1\n",
+		"header length:  5 words (20 bytes), received 20 bytes\n",
+		"payload length: 9 bytes, received 9 bytes\n",
+		"flags:          0x48 [JSON ERROR]\n",
+		"byte 10:        0x01 [STREAM]\n",
+		"byte 11:        0x00 []\n",
+		"options:        [12 4]\n",
+		"(valid)\n",
+		`|test{"a"}|`,
+	} {
+		assert.Contains(t, dump, field)
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteDump(&buf, fr))
+	assert.Equal(t, dump, buf.String())
+
+	// corrupted CRC and unknown bits
+	fr.Header()[1] |= CodecFlatbuffers
+	fr.Header()[11] |= 0x80 | TxCommit
+	assert.Contains(t, DumpFrame(fr), "flags:          0x4a [FLATBUFFERS JSON ERROR]\n")
+	assert.Contains(t, DumpFrame(fr), "byte 11:        0x82 [TX_COMMIT 0x80]\n")
+	assert.Contains(t, DumpFrame(fr), "(invalid)\n")
+
+	fr.SetCRCDisabled(fr.Header())
+	assert.Contains(t, DumpFrame(fr), "(disabled)\n")
+
+	// long payloads are cut
+	fr.WritePayload([]byte(strings.Repeat("x", DumpPayloadLimit*2)))
+	assert.Contains(t, DumpFrame(fr), "payload (first 256 of 512 bytes):\n")
+}
+
+func TestDumpFrame_Malformed(t *testing.T) {
+	assert.Contains(t, DumpFrame(From([]byte{0x15, 0x08, 0x00}, nil)), "malformed header: 3 bytes")
+
+	// the header declares the options which weren't received
+	fr := NewFrame()
+	fr.WriteVersion(fr.Header(), Version1)
+	fr.writeHl(fr.Header(), 5)
+	assert.Contains(t, DumpFrame(fr), "options:        invalid header options")
+}
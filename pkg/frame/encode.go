@@ -0,0 +1,81 @@
+package frame
+
+import (
+	"io"
+	"math"
+
+	"github.com/roadrunner-server/errors"
+)
+
+// Encode serializes a complete goridge RPC frame (header with SEQ_ID and METHOD_LEN options and CRC, service method
+// and payload) ready to be written to any transport. The codec is one of the codec flags, e.g. CodecJSON.
+func Encode(seq uint64, method string, codec byte, payload []byte) ([]byte, error) {
+	const op = errors.Op("goridge_frame_encode")
+
+	if seq > math.MaxUint32 {
+		return nil, errors.E(op, errors.Errorf("sequence ID %d doesn't fit into 32 bits", seq))
+	}
+
+	pl := uint64(len(method)) + uint64(len(payload))
+	if pl > math.MaxUint32 {
+		return nil, errors.E(op, errors.Errorf("payload length %d doesn't fit into 32 bits", pl))
+	}
+
+	fr := NewFrame()
+	fr.WriteVersion(fr.Header(), Version1)
+	fr.WriteFlags(fr.Header(), codec)
+	fr.WriteOptions(fr.HeaderPtr(), uint32(seq), uint32(len(method))) //nolint:gosec
+	fr.WritePayloadLen(fr.Header(), uint32(pl))
+	fr.WriteCRC(fr.Header())
+
+	out := make([]byte, 0, uint64(len(fr.Header()))+pl)
+	out = append(out, fr.Header()...)
+	out = append(out, method...)
+	out = append(out, payload...)
+
+	return out, nil
+}
+
+// Decode parses the first frame produced by Encode from the data. It returns the service method, the codec flags,
+// the payload (a sub-slice of the data) and the number of consumed bytes. If the data doesn't contain the whole
+// frame yet, io.ErrUnexpectedEOF is returned, so the caller can read more bytes and retry.
+func Decode(data []byte) (method string, codec byte, payload []byte, consumed int, err error) {
+	const op = errors.Op("goridge_frame_decode")
+
+	if len(data) < 12 {
+		return "", 0, nil, 0, io.ErrUnexpectedEOF
+	}
+
+	hl := int(data[0] & 0x0F)
+	// 3 words of the header and up to 10 words of options
+	if hl < 3 || (hl-3)*WORD > OptionsMaxSize {
+		return "", 0, nil, 0, errors.E(op, errors.Errorf("invalid header length: %d", hl))
+	}
+
+	hlen := hl * WORD
+	if len(data) < hlen {
+		return "", 0, nil, 0, io.ErrUnexpectedEOF
+	}
+
+	fr := From(data[:hlen:hlen], nil)
+	if !fr.VerifyCRC(fr.Header()) {
+		return "", 0, nil, 0, errors.E(op, errors.Str("CRC verification failed"))
+	}
+
+	opts := fr.ReadOptions(fr.Header())
+	if len(opts) < 2 {
+		return "", 0, nil, 0, errors.E(op, errors.Str("should be at least 2 options. SEQ_ID and METHOD_LEN"))
+	}
+
+	pl := uint64(fr.ReadPayloadLen(fr.Header()))
+	if uint64(len(data)-hlen) < pl {
+		return "", 0, nil, 0, io.ErrUnexpectedEOF
+	}
+
+	body := data[hlen : uint64(hlen)+pl]
+	if uint64(opts[1]) > pl {
+		return "", 0, nil, 0, errors.E(op, errors.Str("method name offset is out of the payload bounds"))
+	}
+
+	return string(body[:opts[1]]), fr.ReadFlags(), body[opts[1]:], hlen + int(pl), nil //nolint:gosec
+}
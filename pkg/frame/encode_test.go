@@ -0,0 +1,91 @@
+package frame
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	data, err := Encode(42, "Service.Method", CodecJSON, []byte(`{"a":1}`))
+	require.NoError(t, err)
+
+	// the encoded frame is readable with the frame API
+	fr := ReadFrame(data)
+	assert.True(t, fr.VerifyCRC(fr.Header()))
+	assert.Equal(t, []uint32{42, 14}, fr.ReadOptions(fr.Header()))
+
+	// few frames in a row
+	second, err := Encode(43, "Service.Other", CodecRaw, nil)
+	require.NoError(t, err)
+	data = append(data, second...)
+
+	method, codec, payload, n, err := Decode(data)
+	require.NoError(t, err)
+	assert.Equal(t, "Service.Method", method)
+	assert.Equal(t, CodecJSON, codec)
+	assert.Equal(t, []byte(`{"a":1}`), payload)
+
+	method, codec, payload, m, err := Decode(data[n:])
+	require.NoError(t, err)
+	assert.Equal(t, "Service.Other", method)
+	assert.Equal(t, CodecRaw, codec)
+	assert.Empty(t, payload)
+	assert.Equal(t, len(data), n+m)
+}
+
+func TestEncode_SeqOverflow(t *testing.T) {
+	_, err := Encode(1<<32, "Service.Method", CodecRaw, nil)
+	assert.Error(t, err)
+}
+
+func TestDecode_Incomplete(t *testing.T) {
+	data, err := Encode(1, "Service.Method", CodecRaw, []byte("payload"))
+	require.NoError(t, err)
+
+	for i := 0; i < len(data); i++ {
+		_, _, _, _, err = Decode(data[:i])
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF, "length %d", i)
+	}
+}
+
+func TestDecode_Corrupted(t *testing.T) {
+	data, err := Encode(1, "Service.Method", CodecRaw, []byte("payload"))
+	require.NoError(t, err)
+
+	data[2]++
+	_, _, _, _, err = Decode(data)
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, io.ErrUnexpectedEOF)
+}
+
+func FuzzDecode(f *testing.F) {
+	data, _ := Encode(1, "Service.Method", CodecJSON, []byte(`{"a":1}`))
+	f.Add(data)
+	data, _ = Encode(0, "", CodecRaw, nil)
+	f.Add(data)
+	f.Add([]byte{})
+	f.Add(make([]byte, 60))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		method, codec, payload, n, err := Decode(data)
+		if err != nil {
+			return
+		}
+
+		require.LessOrEqual(t, n, len(data))
+
+		// a successfully decoded frame should survive the round trip
+		opts := ReadFrame(data[:n]).ReadOptions(data[:n])
+		out, err := Encode(uint64(opts[0]), method, codec, payload)
+		require.NoError(t, err)
+
+		method2, codec2, payload2, _, err := Decode(out)
+		require.NoError(t, err)
+		assert.Equal(t, method, method2)
+		assert.Equal(t, codec, codec2)
+		assert.Equal(t, payload, payload2)
+	})
+}
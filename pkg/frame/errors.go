@@ -0,0 +1,133 @@
+package frame
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/roadrunner-server/errors"
+)
+
+// ErrTruncatedPayload is returned when the peer sent fewer payload bytes than declared in the header
+var ErrTruncatedPayload = errors.Str("truncated payload") //nolint:gochecknoglobals
+
+// TruncatedPayloadError describes a payload which is shorter than the declared payload length.
+// It matches ErrTruncatedPayload and io.ErrUnexpectedEOF with errors.Is.
+type TruncatedPayloadError struct {
+	// Expected payload length from the header
+	Expected uint32
+	// Received bytes before the connection was closed
+	Received uint32
+}
+
+func (e *TruncatedPayloadError) Error() string {
+	return fmt.Sprintf("%s: expected %d bytes, received %d bytes", ErrTruncatedPayload.Error(), e.Expected, e.Received)
+}
+
+func (e *TruncatedPayloadError) Is(target error) bool {
+	return target == ErrTruncatedPayload
+}
+
+func (e *TruncatedPayloadError) Unwrap() error {
+	return io.ErrUnexpectedEOF
+}
+
+// ErrCRCMismatch is returned when the received header doesn't match its CRC
+var ErrCRCMismatch = errors.Str("header CRC mismatch") //nolint:gochecknoglobals
+
+// CRCMismatchError describes a frame header with an invalid CRC. It matches ErrCRCMismatch with errors.Is.
+type CRCMismatchError struct {
+	// Header is the received header (with options) which failed the validation
+	Header []byte
+	// Message is the human-readable description with the data received from the peer
+	Message string
+}
+
+func (e *CRCMismatchError) Error() string {
+	return e.Message
+}
+
+func (e *CRCMismatchError) Is(target error) bool {
+	return target == ErrCRCMismatch
+}
+
+// ErrCRCRequired is returned when the frame without the header CRC was received by the CRCRequired receiver
+var ErrCRCRequired = errors.Str("frame without CRC is not accepted, CRC is required") //nolint:gochecknoglobals
+
+// ErrNotGoridge is returned when the peer speaks another protocol, e.g. an HTTP client connected to the goridge port
+var ErrNotGoridge = errors.Str("not a goridge frame") //nolint:gochecknoglobals
+
+// NotGoridgeError describes the connection from a client of another protocol. It matches ErrNotGoridge with errors.Is.
+type NotGoridgeError struct {
+	// Protocol is the detected protocol, e.g. HTTP or TLS
+	Protocol string
+}
+
+func (e *NotGoridgeError) Error() string {
+	return fmt.Sprintf("%s: an %s client hit the goridge port", ErrNotGoridge.Error(), e.Protocol)
+}
+
+func (e *NotGoridgeError) Is(target error) bool {
+	return target == ErrNotGoridge
+}
+
+// ErrPHPOutput is returned when the PHP errors or warnings were written to the goridge channel (e.g. STDOUT)
+// instead of the frames
+var ErrPHPOutput = errors.Str("PHP output instead of the goridge frame") //nolint:gochecknoglobals
+
+// PHPOutputError carries the PHP error message leaked to the goridge channel, like "PHP Fatal error: ..." or
+// "Could not open input file: ...". It matches ErrPHPOutput with errors.Is. The relays return it as the Err
+// of the *errors.Error with the errors.FileNotFound kind.
+type PHPOutputError struct {
+	// Output is the captured output, starting from the received header bytes
+	Output string
+}
+
+func (e *PHPOutputError) Error() string {
+	return fmt.Sprintf("%s, see: https://docs.roadrunner.dev/error-codes/stdout-crc, output: %s",
+		ErrPHPOutput.Error(), e.Output)
+}
+
+func (e *PHPOutputError) Is(target error) bool {
+	return target == ErrPHPOutput
+}
+
+// ErrInvalidOptions is returned when the options region of the header is corrupted
+var ErrInvalidOptions = errors.Str("invalid header options") //nolint:gochecknoglobals
+
+// OptionsLengthError describes the options region which length is not a multiple of WORD, exceeds OptionsMaxSize
+// or doesn't match the header length. It matches ErrInvalidOptions with errors.Is.
+type OptionsLengthError struct {
+	// Length of the options region in bytes
+	Length int
+	// Declared options length in bytes by the header length
+	Declared int
+}
+
+func (e *OptionsLengthError) Error() string {
+	return fmt.Sprintf("%s: %d bytes, declared %d bytes, should be a multiple of %d bytes up to %d bytes",
+		ErrInvalidOptions.Error(), e.Length, e.Declared, WORD, OptionsMaxSize)
+}
+
+func (e *OptionsLengthError) Is(target error) bool {
+	return target == ErrInvalidOptions
+}
+
+// ErrFrameTooLarge is returned when the received frame exceeds the size limit of the receiver
+var ErrFrameTooLarge = errors.Str("frame is too large") //nolint:gochecknoglobals
+
+// FrameTooLargeError describes the frame which size (header, options and payload) declared by the header exceeds
+// the limit. The payload is not read, so the connection should be closed. It matches ErrFrameTooLarge with errors.Is.
+type FrameTooLargeError struct {
+	// Size of the frame in bytes
+	Size uint64
+	// Limit of the receiver in bytes
+	Limit uint64
+}
+
+func (e *FrameTooLargeError) Error() string {
+	return fmt.Sprintf("%s: %d bytes, the limit is %d bytes", ErrFrameTooLarge.Error(), e.Size, e.Limit)
+}
+
+func (e *FrameTooLargeError) Is(target error) bool {
+	return target == ErrFrameTooLarge
+}
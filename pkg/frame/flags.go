@@ -0,0 +1,66 @@
+package frame
+
+import (
+	"math/bits"
+)
+
+// Flags is the 1st byte of the header: the codec flag combined with the CONTROL and ERROR bits
+type Flags byte
+
+// Codec is the codec flag of the 1st byte, e.g. Codec(CodecJSON)
+type Codec byte
+
+// codecBits are the bits of the 1st byte used by the codecs
+const codecBits = ^(CONTROL | ERROR)
+
+// Flags returns the typed 1st byte of the header
+func (f *Frame) Flags() Flags {
+	return Flags(f.ReadFlags())
+}
+
+// SetFlags replaces the 1st byte of the header, e.g. fr.SetFlags(fr.Flags().WithError())
+func (f *Frame) SetFlags(flags Flags) {
+	f.Header()[1] = byte(flags)
+}
+
+// IsError reports whether the ERROR bit is set, the payload carries the error instead of the body
+func (f Flags) IsError() bool {
+	return byte(f)&ERROR != 0
+}
+
+// IsControl reports whether the CONTROL bit is set, the frame is the command rather than the message
+func (f Flags) IsControl() bool {
+	return byte(f)&CONTROL != 0
+}
+
+// Codec returns the codec bits without the CONTROL and ERROR bits, 0 if there are none. The codecs are
+// mutually exclusive (CodecBSON is a combination reserved as a codec of its own), see Codec.IsSingle.
+func (f Flags) Codec() Codec {
+	return Codec(byte(f) & codecBits)
+}
+
+// HasCodec reports whether all the bits of the codec are set
+func (f Flags) HasCodec(c Codec) bool {
+	return c != 0 && f.Codec()&c == c
+}
+
+// WithCodec returns the flags with the codec bits replaced by c, the CONTROL and ERROR bits are kept
+func (f Flags) WithCodec(c Codec) Flags {
+	return Flags(byte(f)&^codecBits | byte(c)&codecBits)
+}
+
+// WithError returns the flags with the ERROR bit
+func (f Flags) WithError() Flags {
+	return f | Flags(ERROR)
+}
+
+// String renders the flags with the names of the set bits, e.g. 0x48 [JSON ERROR]
+func (f Flags) String() string {
+	return bitNames(byte(f), flagNames)
+}
+
+// IsSingle reports whether exactly one codec bit is set or the codec is CodecBSON, other combinations
+// of the codecs can't be decoded
+func (c Codec) IsSingle() bool {
+	return byte(c)&^codecBits == 0 && (bits.OnesCount8(byte(c)) == 1 || byte(c) == CodecBSON)
+}
@@ -0,0 +1,53 @@
+package frame
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFlags_Codec(t *testing.T) {
+	f := Flags(CodecJSON | ERROR)
+	assert.True(t, f.IsError())
+	assert.False(t, f.IsControl())
+	assert.Equal(t, Codec(CodecJSON), f.Codec())
+	assert.True(t, f.HasCodec(Codec(CodecJSON)))
+	assert.False(t, f.HasCodec(Codec(CodecMsgpack)))
+	assert.False(t, f.HasCodec(0))
+	assert.Equal(t, "0x48 [JSON ERROR]", f.String())
+
+	// the codec is replaced, the other bits are kept
+	f = f.WithCodec(Codec(CodecProto))
+	assert.Equal(t, Flags(CodecProto|ERROR), f)
+	assert.False(t, f.HasCodec(Codec(CodecJSON)))
+
+	// the CONTROL and ERROR bits are not the codec bits
+	assert.Equal(t, Flags(CONTROL|CodecRaw), Flags(CONTROL).WithCodec(Codec(CodecRaw|ERROR)))
+	assert.Equal(t, Codec(0), Flags(CONTROL|ERROR).Codec())
+	assert.Equal(t, Flags(CodecGob|ERROR), Flags(CodecGob).WithError())
+
+	fr := NewFrame()
+	fr.WriteFlags(fr.Header(), CONTROL)
+	assert.True(t, fr.Flags().IsControl())
+	assert.Equal(t, Codec(0), fr.Flags().Codec())
+
+	fr.SetFlags(Flags(CodecJSON).WithError())
+	assert.Equal(t, CodecJSON|ERROR, fr.ReadFlags())
+}
+
+func TestFlags_CodecIsSingle(t *testing.T) {
+	for _, c := range []byte{CodecRaw, CodecJSON, CodecMsgpack, CodecGob, CodecProto, CodecFlatbuffers, CodecBSON} {
+		assert.True(t, Codec(c).IsSingle(), "codec 0x%02x", c)
+		assert.Equal(t, Codec(c), Flags(c|ERROR|CONTROL).Codec())
+	}
+
+	// the codecs are mutually exclusive
+	assert.False(t, Codec(CodecJSON|CodecMsgpack).IsSingle())
+	assert.False(t, Flags(CodecRaw|CodecGob).Codec().IsSingle())
+	assert.False(t, Codec(CodecBSON|CodecJSON).IsSingle())
+	assert.Equal(t, "0x14 [BSON]", Flags(CodecBSON).String())
+	assert.Equal(t, "0x1c [BSON JSON]", Flags(CodecBSON|CodecJSON).String())
+	assert.False(t, Codec(0).IsSingle())
+	assert.False(t, Codec(ERROR).IsSingle())
+	assert.False(t, Codec(CONTROL).IsSingle())
+}
@@ -120,6 +120,12 @@ func (*Frame) WriteFlags(header []byte, flags ...byte) {
 	}
 }
 
+// ResetFlags clears the 1st byte (the codec, CONTROL and ERROR flags), WriteFlags only adds the flags.
+func (*Frame) ResetFlags(header []byte) {
+	_ = header[1]
+	header[1] = 0
+}
+
 func (*Frame) SetStreamFlag(header []byte) {
 	_ = header[11]
 	header[10] |= STREAM
@@ -159,7 +165,128 @@ func (*Frame) IsStop(header []byte) bool {
 	return header[10]&STOP != 0
 }
 
+// SetCompressionFlag sets the compression algorithm (CompressedGzip or CompressedZstd) bit
+func (*Frame) SetCompressionFlag(header []byte, algorithm byte) {
+	_ = header[11]
+	header[10] |= algorithm & (CompressedGzip | CompressedZstd)
+}
+
+// ReadCompression returns the compression algorithm bit or 0 if the payload is not compressed
+func (*Frame) ReadCompression(header []byte) byte {
+	_ = header[11]
+	return header[10] & (CompressedGzip | CompressedZstd)
+}
+
+// SetCRCDisabled marks the frame as sent without the header CRC
+func (*Frame) SetCRCDisabled(header []byte) {
+	_ = header[11]
+	header[10] |= CRCDisabled
+}
+
+// IsCRCDisabled reports whether the frame was sent without the header CRC
+func (*Frame) IsCRCDisabled(header []byte) bool {
+	_ = header[11]
+	return header[10]&CRCDisabled != 0
+}
+
+// SetCloseBit marks the frame as the close reason frame
+func (*Frame) SetCloseBit(header []byte) {
+	_ = header[11]
+	header[10] |= CLOSE
+}
+
+// IsClose reports whether the frame is the close reason frame
+func (*Frame) IsClose(header []byte) bool {
+	_ = header[11]
+	return header[10]&CLOSE != 0
+}
+
+// SetNoMethodPrefix marks the payload as the body without the service method prefix
+func (*Frame) SetNoMethodPrefix(header []byte) {
+	_ = header[11]
+	header[11] |= NoMethodPrefix
+}
+
+// IsNoMethodPrefix reports whether the payload is the body without the service method prefix
+func (*Frame) IsNoMethodPrefix(header []byte) bool {
+	_ = header[11]
+	return header[11]&NoMethodPrefix != 0
+}
+
+// SetTxCommitBit marks the frame as the transaction commit marker
+func (*Frame) SetTxCommitBit(header []byte) {
+	_ = header[11]
+	header[11] |= TxCommit
+}
+
+// IsTxCommit reports whether the frame is the transaction commit marker
+func (*Frame) IsTxCommit(header []byte) bool {
+	_ = header[11]
+	return header[11]&TxCommit != 0
+}
+
+// SetStructuredErrorBit marks the payload of the error frame as the encoded error code and message
+func (*Frame) SetStructuredErrorBit(header []byte) {
+	_ = header[11]
+	header[11] |= StructuredError
+}
+
+// IsStructuredError reports whether the payload of the error frame is the encoded error code and message
+func (*Frame) IsStructuredError(header []byte) bool {
+	_ = header[11]
+	return header[11]&StructuredError != 0
+}
+
+// SetHandshakeBit marks the frame as the codec negotiation handshake
+func (*Frame) SetHandshakeBit(header []byte) {
+	_ = header[11]
+	header[11] |= Handshake
+}
+
+// IsHandshake reports whether the frame is the codec negotiation handshake
+func (*Frame) IsHandshake(header []byte) bool {
+	_ = header[11]
+	return header[11]&Handshake != 0
+}
+
+// SetBatchBit marks the frame as the batch of the frames carried in the payload
+func (*Frame) SetBatchBit(header []byte) {
+	_ = header[11]
+	header[11] |= Batch
+}
+
+// IsBatch reports whether the payload of the frame is the batch of the frames
+func (*Frame) IsBatch(header []byte) bool {
+	_ = header[11]
+	return header[11]&Batch != 0
+}
+
+// SetErrorBodyBit marks the payload of the error frame as the error body encoded with the frame codec
+func (*Frame) SetErrorBodyBit(header []byte) {
+	_ = header[11]
+	header[11] |= ErrorBody
+}
+
+// IsErrorBody reports whether the payload of the error frame is the error body encoded with the frame codec
+func (*Frame) IsErrorBody(header []byte) bool {
+	_ = header[11]
+	return header[11]&ErrorBody != 0
+}
+
+// SetPushBit marks the frame as pushed by the server without a request
+func (*Frame) SetPushBit(header []byte) {
+	_ = header[11]
+	header[11] |= Push
+}
+
+// IsPush reports whether the frame is pushed by the server without a request
+func (*Frame) IsPush(header []byte) bool {
+	_ = header[11]
+	return header[11]&Push != 0
+}
+
 // WriteOptions
+// Options are written little-endian, see SwapOptionsOrder
 // Options slice len should not be more than 10 (40 bytes)
 // we need a pointer to the header because we are reallocating the slice
 func (f *Frame) WriteOptions(header *[]byte, options ...uint32) {
@@ -386,6 +513,63 @@ done:
 	return options
 }
 
+// WriteOptionsSigned writes the signed options (e.g. negative status codes) as their two's complement
+// 32bit words, the limits of WriteOptions apply.
+func (f *Frame) WriteOptionsSigned(header *[]byte, options ...int32) {
+	if options == nil {
+		return
+	}
+
+	words := make([]uint32, len(options))
+	for i, o := range options {
+		words[i] = uint32(o) //nolint:gosec
+	}
+
+	f.WriteOptions(header, words...)
+}
+
+// ReadOptionsSigned returns the options written by WriteOptionsSigned, the unsigned options are reinterpreted
+// as int32.
+func (f *Frame) ReadOptionsSigned(header []byte) []int32 {
+	words := f.ReadOptions(header)
+	if words == nil {
+		return nil
+	}
+
+	options := make([]int32, len(words))
+	for i, w := range words {
+		options[i] = int32(w) //nolint:gosec
+	}
+
+	return options
+}
+
+// SwapOptionsOrder reverses the bytes of every option word in place, converting the little-endian options
+// written by WriteOptions to the big-endian order and back. The options are not covered by the header CRC,
+// so the CRC stays valid.
+func (f *Frame) SwapOptionsOrder(header []byte) {
+	hl := int(f.ReadHL(header)) * WORD
+	for j := 12; j+WORD <= hl && j+WORD <= len(header); j += WORD {
+		header[j], header[j+1], header[j+2], header[j+3] = header[j+3], header[j+2], header[j+1], header[j]
+	}
+}
+
+// ValidateOptions checks that the options region of the header is a multiple of WORD, is not bigger than
+// OptionsMaxSize and matches the header length, so ReadOptions can't panic or silently truncate the options.
+func (f *Frame) ValidateOptions(header []byte) error {
+	if len(header) < 12 {
+		return &OptionsLengthError{Length: len(header) - 12}
+	}
+
+	length := len(header) - 12
+	declared := (int(f.ReadHL(header)) - 3) * WORD
+	if length%WORD != 0 || length > OptionsMaxSize || length != declared {
+		return &OptionsLengthError{Length: length, Declared: declared}
+	}
+
+	return nil
+}
+
 // ReadPayloadLen
 // LE format used to write Payload
 // Using 4 bytes (2,3,4,5 bytes in the header)
@@ -471,6 +655,11 @@ func (f *Frame) WritePayload(data []byte) {
 	copy(f.payload, data)
 }
 
+// SetPayload sets the payload without copying, the frame owns the data after the call
+func (f *Frame) SetPayload(data []byte) {
+	f.payload = data
+}
+
 // Reset a frame
 func (f *Frame) Reset() {
 	f.header = make([]byte, 12)
@@ -11,6 +11,14 @@ const (
 	ERROR        byte = 0x40
 	CodecProto   byte = 0x80
 
+	// CodecFlatbuffers payload is a prebuilt FlatBuffers buffer, passed as is like CodecRaw. It takes the last free
+	// bit, the further codecs are selected with the rpc OptionContentType option instead of the flags
+	CodecFlatbuffers byte = 0x02
+	// CodecBSON payload is a BSON document. All the bits are taken, so it's the combination of the CodecRaw and
+	// CodecMsgpack bits, the receivers without BSON read such payloads as CodecRaw. The peers should negotiate it
+	// with the rpc Handshake
+	CodecBSON = CodecRaw | CodecMsgpack
+
 	// Version1 byte
 	Version1 byte = 0x01
 
@@ -26,4 +34,41 @@ const (
 	PING byte = 0x04
 	// PONG command
 	PONG byte = 0x08
+	// CompressedGzip payload (after the service method prefix) compressed with gzip
+	CompressedGzip byte = 0x10
+	// CompressedZstd payload (after the service method prefix) compressed with zstd
+	CompressedZstd byte = 0x20
+	// CRCDisabled header CRC was not written, the trusted receivers skip the verification
+	CRCDisabled byte = 0x40
+	// CLOSE command, the peer is going to close the connection, the options and payload carry the reason
+	CLOSE byte = 0x80
+
+	/*
+		11th byte
+	*/
+
+	// NoMethodPrefix payload carries only the body, the service method is conveyed by the options
+	NoMethodPrefix byte = 0x01
+	// TxCommit command, the frames of the transaction (options: transaction ID, number of frames) are complete
+	TxCommit byte = 0x02
+	// StructuredError payload of the ERROR frame is the error code and message encoded with the frame codec
+	StructuredError byte = 0x04
+	// Handshake command, the first option is the bitmask of the codec flags supported by the peer
+	Handshake byte = 0x08
+	// Batch command, the payload is the sequence of the complete frames (options: number of frames)
+	Batch byte = 0x10
+	// ErrorBody payload of the ERROR frame is the error body of the sender's type encoded with the frame codec
+	ErrorBody byte = 0x20
+	// Push frame is sent by the server without a request, the sequence ID is from the push sequence space
+	Push byte = 0x40
+)
+
+// CRCPolicy defines how the receiver treats the frames with the CRCDisabled bit
+type CRCPolicy uint8
+
+const (
+	// CRCRequired verifies every header CRC and rejects the frames with the CRCDisabled bit (default)
+	CRCRequired CRCPolicy = iota
+	// CRCTrusted skips the verification of the frames with the CRCDisabled bit, other frames are verified
+	CRCTrusted
 )
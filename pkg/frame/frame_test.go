@@ -1,7 +1,9 @@
 package frame
 
 import (
+	"encoding/binary"
 	"hash/crc32"
+	"math"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -155,6 +157,49 @@ func TestFrame_Options(t *testing.T) {
 	assert.Equal(t, rf.VerifyCRC(rf.Header()), true)
 }
 
+func TestFrame_OptionsSigned(t *testing.T) {
+	nf := NewFrame()
+	nf.WriteVersion(nf.Header(), 1)
+	nf.WriteFlags(nf.Header(), CodecRaw)
+	nf.WritePayloadLen(nf.Header(), uint32(len([]byte(TestPayload))))
+	nf.WriteOptionsSigned(nf.HeaderPtr(), -404, 0, math.MaxInt32, math.MinInt32)
+	nf.WritePayload([]byte(TestPayload))
+	nf.WriteCRC(nf.Header())
+
+	rf := ReadFrame(nf.Bytes())
+	assert.True(t, rf.VerifyCRC(rf.Header()))
+	assert.Equal(t, []int32{-404, 0, math.MaxInt32, math.MinInt32}, rf.ReadOptionsSigned(rf.Header()))
+	// the unsigned view keeps the two's complement bits
+	assert.Equal(t, uint32(0xFFFFFE6C), rf.ReadOptions(rf.Header())[0])
+
+	assert.Nil(t, NewFrame().ReadOptionsSigned(NewFrame().Header()))
+}
+
+func TestFrame_SwapOptionsOrder(t *testing.T) {
+	nf := NewFrame()
+	nf.WriteVersion(nf.Header(), 1)
+	nf.WriteFlags(nf.Header(), CodecRaw)
+	nf.WriteOptions(nf.HeaderPtr(), 0x01020304, 42)
+	nf.WriteCRC(nf.Header())
+	assert.Equal(t, []byte{0x04, 0x03, 0x02, 0x01}, nf.Header()[12:16])
+
+	nf.SwapOptionsOrder(nf.Header())
+	assert.Equal(t, []byte{0x01, 0x02, 0x03, 0x04}, nf.Header()[12:16])
+	assert.Equal(t, uint32(42), binary.BigEndian.Uint32(nf.Header()[16:20]))
+	assert.True(t, nf.VerifyCRC(nf.Header()))
+
+	// the big-endian options read by the little-endian receiver are wrong until swapped back
+	rf := ReadFrame(nf.Bytes())
+	assert.NotEqual(t, []uint32{0x01020304, 42}, rf.ReadOptions(rf.Header()))
+	rf.SwapOptionsOrder(rf.Header())
+	assert.Equal(t, []uint32{0x01020304, 42}, rf.ReadOptions(rf.Header()))
+
+	// no options, nothing to swap
+	fr := NewFrame()
+	fr.SwapOptionsOrder(fr.Header())
+	assert.Nil(t, fr.ReadOptions(fr.Header()))
+}
+
 func TestFrame_Stream(t *testing.T) {
 	nf := NewFrame()
 	nf.WriteVersion(nf.Header(), 1)
@@ -415,3 +460,177 @@ func BenchmarkFrame(b *testing.B) {
 		}
 	}
 }
+
+func TestFrame_Compression(t *testing.T) {
+	nf := NewFrame()
+	nf.WriteVersion(nf.Header(), Version1)
+	assert.Equal(t, byte(0), nf.ReadCompression(nf.Header()))
+
+	nf.SetStreamFlag(nf.Header())
+	nf.SetCompressionFlag(nf.Header(), CompressedZstd)
+	nf.WriteCRC(nf.Header())
+
+	rf := ReadHeader(nf.Bytes())
+	assert.Equal(t, CompressedZstd, rf.ReadCompression(rf.Header()))
+	assert.True(t, rf.IsStream(rf.Header()))
+}
+
+func TestFrame_ValidateOptions(t *testing.T) {
+	nf := NewFrame()
+	nf.WriteOptions(nf.HeaderPtr(), 1, 2)
+	assert.NoError(t, nf.ValidateOptions(nf.Header()))
+	assert.NoError(t, NewFrame().ValidateOptions(NewFrame().Header()))
+
+	// header declares 2 options, but the options region is not word-aligned
+	header := append([]byte{}, nf.Header()[:18]...)
+	err := nf.ValidateOptions(header)
+	assert.ErrorIs(t, err, ErrInvalidOptions)
+	var ol *OptionsLengthError
+	if assert.ErrorAs(t, err, &ol) {
+		assert.Equal(t, 6, ol.Length)
+		assert.Equal(t, 8, ol.Declared)
+	}
+
+	// more options than allowed
+	header = make([]byte, 12+44)
+	copy(header, nf.Header())
+	nf.writeHl(header, 14)
+	assert.ErrorIs(t, nf.ValidateOptions(header), ErrInvalidOptions)
+}
+
+func TestFrame_Close(t *testing.T) {
+	nf := NewFrame()
+	nf.WriteVersion(nf.Header(), 1)
+	nf.WriteFlags(nf.Header(), CONTROL)
+	nf.WriteOptions(nf.HeaderPtr(), 1)
+	assert.False(t, nf.IsClose(nf.Header()))
+
+	nf.SetCloseBit(nf.Header())
+	nf.WriteCRC(nf.Header())
+
+	rf := ReadFrame(nf.Bytes())
+	assert.True(t, rf.IsClose(rf.Header()))
+	assert.True(t, rf.VerifyCRC(rf.Header()))
+	assert.False(t, rf.IsStream(rf.Header()))
+}
+
+func TestFrame_NoMethodPrefix(t *testing.T) {
+	nf := NewFrame()
+	nf.WriteVersion(nf.Header(), 1)
+	nf.WriteOptions(nf.HeaderPtr(), 1, 0)
+	assert.False(t, nf.IsNoMethodPrefix(nf.Header()))
+
+	nf.SetNoMethodPrefix(nf.Header())
+	nf.WriteCRC(nf.Header())
+
+	rf := ReadFrame(nf.Bytes())
+	assert.True(t, rf.IsNoMethodPrefix(rf.Header()))
+	assert.False(t, rf.IsStream(rf.Header()))
+}
+
+func TestFrame_TxCommit(t *testing.T) {
+	nf := NewFrame()
+	nf.WriteVersion(nf.Header(), 1)
+	nf.WriteFlags(nf.Header(), CONTROL)
+	nf.WriteOptions(nf.HeaderPtr(), 1, 2)
+	assert.False(t, nf.IsTxCommit(nf.Header()))
+
+	nf.SetTxCommitBit(nf.Header())
+	nf.SetNoMethodPrefix(nf.Header())
+	nf.WriteCRC(nf.Header())
+
+	rf := ReadFrame(nf.Bytes())
+	assert.True(t, rf.IsTxCommit(rf.Header()))
+	assert.True(t, rf.IsNoMethodPrefix(rf.Header()))
+}
+
+func TestFrame_StructuredError(t *testing.T) {
+	nf := NewFrame()
+	nf.WriteVersion(nf.Header(), 1)
+	nf.WriteFlags(nf.Header(), ERROR, CodecJSON)
+	nf.WriteOptions(nf.HeaderPtr(), 1, 2)
+	assert.False(t, nf.IsStructuredError(nf.Header()))
+
+	nf.SetStructuredErrorBit(nf.Header())
+	nf.WriteCRC(nf.Header())
+
+	rf := ReadFrame(nf.Bytes())
+	assert.True(t, rf.IsStructuredError(rf.Header()))
+	assert.False(t, rf.IsTxCommit(rf.Header()))
+}
+
+func TestFrame_Handshake(t *testing.T) {
+	nf := NewFrame()
+	nf.WriteVersion(nf.Header(), 1)
+	nf.WriteFlags(nf.Header(), CONTROL)
+	nf.WriteOptions(nf.HeaderPtr(), uint32(CodecJSON|CodecProto))
+	assert.False(t, nf.IsHandshake(nf.Header()))
+
+	nf.SetHandshakeBit(nf.Header())
+	nf.WriteCRC(nf.Header())
+
+	rf := ReadFrame(nf.Bytes())
+	assert.True(t, rf.IsHandshake(rf.Header()))
+	assert.Equal(t, []uint32{uint32(CodecJSON | CodecProto)}, rf.ReadOptions(rf.Header()))
+}
+
+func TestFrame_Batch(t *testing.T) {
+	nf := NewFrame()
+	nf.WriteVersion(nf.Header(), 1)
+	nf.WriteFlags(nf.Header(), CONTROL)
+	nf.WriteOptions(nf.HeaderPtr(), 3)
+	assert.False(t, nf.IsBatch(nf.Header()))
+
+	nf.SetBatchBit(nf.Header())
+	nf.WriteCRC(nf.Header())
+
+	rf := ReadFrame(nf.Bytes())
+	assert.True(t, rf.IsBatch(rf.Header()))
+	assert.False(t, rf.IsHandshake(rf.Header()))
+	assert.Equal(t, []uint32{3}, rf.ReadOptions(rf.Header()))
+}
+
+func TestFrame_ErrorBody(t *testing.T) {
+	nf := NewFrame()
+	nf.WriteVersion(nf.Header(), 1)
+	nf.WriteFlags(nf.Header(), ERROR, CodecJSON)
+	nf.WriteOptions(nf.HeaderPtr(), 1, 0)
+	assert.False(t, nf.IsErrorBody(nf.Header()))
+
+	nf.SetErrorBodyBit(nf.Header())
+	nf.WriteCRC(nf.Header())
+
+	rf := ReadFrame(nf.Bytes())
+	assert.True(t, rf.IsErrorBody(rf.Header()))
+	assert.False(t, rf.IsStructuredError(rf.Header()))
+	assert.Equal(t, Codec(CodecJSON), rf.Flags().Codec())
+}
+
+func TestFrame_Push(t *testing.T) {
+	nf := NewFrame()
+	nf.WriteVersion(nf.Header(), 1)
+	nf.WriteFlags(nf.Header(), CodecJSON)
+	nf.WriteOptions(nf.HeaderPtr(), 0xFFFFFFFF, 4)
+	assert.False(t, nf.IsPush(nf.Header()))
+
+	nf.SetPushBit(nf.Header())
+	nf.WriteCRC(nf.Header())
+
+	rf := ReadFrame(nf.Bytes())
+	assert.True(t, rf.IsPush(rf.Header()))
+	assert.False(t, rf.IsErrorBody(rf.Header()))
+	assert.Equal(t, []uint32{0xFFFFFFFF, 4}, rf.ReadOptions(rf.Header()))
+}
+
+func TestFrame_ResetFlags(t *testing.T) {
+	nf := NewFrame()
+	nf.WriteVersion(nf.Header(), 1)
+	nf.WriteFlags(nf.Header(), CodecJSON)
+	nf.ResetFlags(nf.Header())
+	nf.WriteFlags(nf.Header(), ERROR, CodecMsgpack)
+	nf.WriteCRC(nf.Header())
+
+	rf := ReadFrame(nf.Bytes())
+	assert.True(t, rf.Flags().IsError())
+	assert.Equal(t, Codec(CodecMsgpack), rf.Flags().Codec())
+}
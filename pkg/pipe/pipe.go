@@ -13,6 +13,8 @@ import (
 type Relay struct {
 	in  io.ReadCloser
 	out io.WriteCloser
+	// header CRC verification policy and payload alignment
+	receive internal.ReceiveConfig
 }
 
 // NewPipeRelay creates new pipe based data relay.
@@ -21,10 +23,20 @@ func NewPipeRelay(in io.ReadCloser, out io.WriteCloser) *Relay {
 	return &Relay{in: in, out: out}
 }
 
+// NewRelayPair creates two in-memory relays connected to each other, frames sent to one of them are received
+// from another. Useful to wire codecs in tests without OS pipes or sockets. Send blocks until the peer receives
+// the frame. Closing a relay unblocks the peer with io.EOF on Receive and io.ErrClosedPipe on Send.
+func NewRelayPair() (*Relay, *Relay) {
+	ar, bw := io.Pipe()
+	br, aw := io.Pipe()
+
+	return NewPipeRelay(ar, aw), NewPipeRelay(br, bw)
+}
+
 // Send signed (prefixed) data to underlying process.
 func (rl *Relay) Send(frame *frame.Frame) error {
 	const op = errors.Op("pipes frame send")
-	_, err := rl.out.Write(frame.Bytes())
+	err := internal.WriteFrame(rl.out, frame.Header(), frame.Payload())
 	if err != nil {
 		return errors.E(op, err)
 	}
@@ -35,7 +47,7 @@ func (rl *Relay) Receive(frame *frame.Frame) error {
 	if frame == nil {
 		return errors.Str("nil frame")
 	}
-	return internal.ReceiveFrame(rl.in, frame)
+	return internal.ReceiveFrameWithConfig(rl.in, frame, rl.receive)
 }
 
 // Close the connection
@@ -44,3 +56,29 @@ func (rl *Relay) Close() error {
 	_ = rl.in.Close()
 	return nil
 }
+
+// SetCRCPolicy sets how the frames without the header CRC are received, default frame.CRCRequired.
+// Should be called before the relay is used.
+func (rl *Relay) SetCRCPolicy(policy frame.CRCPolicy) {
+	rl.receive.Policy = policy
+}
+
+// SetMaxFrameSize sets the limit of the received frame size (header, options and payload) in bytes, the bigger
+// frames are rejected with frame.FrameTooLargeError before the payload is read, 0 disables the limit.
+// Should be called before the relay is used.
+func (rl *Relay) SetMaxFrameSize(n int) {
+	rl.receive.MaxFrameSize = n
+}
+
+// SetPayloadAlignment makes the received payloads start at the address aligned to n bytes (a power of two),
+// so they can be reinterpreted in place. Such payloads aren't taken from the buffer pool, 0 disables the alignment.
+// Should be called before the relay is used.
+func (rl *Relay) SetPayloadAlignment(n int) error {
+	err := internal.ValidateAlignment(n)
+	if err != nil {
+		return err
+	}
+
+	rl.receive.Alignment = n
+	return nil
+}
@@ -3,9 +3,11 @@ package pipe
 import (
 	"io"
 	"testing"
+	"unsafe"
 
 	"github.com/roadrunner-server/goridge/v3/pkg/frame"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 const TestPayload = `alsdjf;lskjdgljasg;lkjsalfkjaskldjflkasjdf;lkasjfdalksdjflkajsdf;lfasdgnslsnblna;sldjjfawlkejr;lwjenlksndlfjawl;ejr;lwjelkrjaldfjl;sdjf`
@@ -112,3 +114,190 @@ func TestPipeCRC_Failed(t *testing.T) {
 
 	assert.Empty(t, fr.Payload())
 }
+
+func TestPipeTruncatedPayload(t *testing.T) {
+	pr, pw := io.Pipe()
+
+	relay := NewPipeRelay(pr, pw)
+
+	nf := frame.NewFrame()
+	nf.WriteVersion(nf.Header(), frame.Version1)
+	nf.WriteFlags(nf.Header(), frame.CONTROL, frame.CodecGob)
+	// declare the full payload, but send only 10 bytes of it
+	nf.WritePayloadLen(nf.Header(), uint32(len([]byte(TestPayload))))
+	nf.WritePayload([]byte(TestPayload)[:10])
+	nf.WriteCRC(nf.Header())
+
+	go func() {
+		_, err := pw.Write(nf.Bytes())
+		assert.NoError(t, err)
+		_ = pw.Close()
+	}()
+
+	fr := frame.NewFrame()
+	err := relay.Receive(fr)
+	assert.ErrorIs(t, err, frame.ErrTruncatedPayload)
+	assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	assert.NotErrorIs(t, err, io.EOF)
+
+	var te *frame.TruncatedPayloadError
+	assert.ErrorAs(t, err, &te)
+	assert.Equal(t, uint32(len(TestPayload)), te.Expected)
+	assert.Equal(t, uint32(10), te.Received)
+}
+
+func TestPipeCRCMismatch(t *testing.T) {
+	pr, pw := io.Pipe()
+
+	relay := NewPipeRelay(pr, pw)
+
+	nf := frame.NewFrame()
+	nf.WriteVersion(nf.Header(), frame.Version1)
+	nf.WriteFlags(nf.Header(), frame.CONTROL, frame.CodecGob)
+	nf.WritePayloadLen(nf.Header(), uint32(len([]byte(TestPayload))))
+	nf.WritePayload([]byte(TestPayload))
+	nf.WriteCRC(nf.Header())
+	// corrupt the CRC
+	nf.Header()[6]++
+
+	go func() {
+		_, err := pw.Write(nf.Bytes())
+		assert.NoError(t, err)
+	}()
+
+	fr := frame.NewFrame()
+	err := relay.Receive(fr)
+	assert.ErrorIs(t, err, frame.ErrCRCMismatch)
+	assert.Contains(t, err.Error(), "validation failed on the message sent to STDOUT")
+
+	var ce *frame.CRCMismatchError
+	assert.ErrorAs(t, err, &ce)
+	assert.Equal(t, nf.Header(), ce.Header)
+}
+
+func TestRelayPair(t *testing.T) {
+	a, b := NewRelayPair()
+
+	codecs := []byte{frame.CodecJSON, frame.CodecProto, frame.CodecRaw, frame.CodecMsgpack, frame.CodecGob}
+
+	go func() {
+		for i, codec := range codecs {
+			nf := frame.NewFrame()
+			nf.WriteVersion(nf.Header(), frame.Version1)
+			nf.WriteFlags(nf.Header(), codec)
+			nf.WriteOptions(nf.HeaderPtr(), uint32(i), 4) //nolint:gosec
+			nf.WritePayloadLen(nf.Header(), uint32(len([]byte(TestPayload))))
+			nf.WritePayload([]byte(TestPayload))
+			nf.WriteCRC(nf.Header())
+			assert.NoError(t, a.Send(nf))
+		}
+	}()
+
+	for i, codec := range codecs {
+		fr := frame.NewFrame()
+		require.NoError(t, b.Receive(fr))
+		assert.Equal(t, codec, fr.ReadFlags())
+		assert.Equal(t, []uint32{uint32(i), 4}, fr.ReadOptions(fr.Header())) //nolint:gosec
+		assert.Equal(t, TestPayload, string(fr.Payload()))
+	}
+
+	// and back
+	go func() {
+		nf := frame.NewFrame()
+		nf.WriteVersion(nf.Header(), frame.Version1)
+		nf.WriteFlags(nf.Header(), frame.CONTROL)
+		nf.WriteCRC(nf.Header())
+		assert.NoError(t, b.Send(nf))
+	}()
+
+	fr := frame.NewFrame()
+	require.NoError(t, a.Receive(fr))
+	assert.Equal(t, frame.CONTROL, fr.ReadFlags())
+
+	require.NoError(t, a.Close())
+	assert.ErrorIs(t, b.Receive(frame.NewFrame()), io.EOF)
+	assert.Error(t, b.Send(frame.NewFrame()))
+}
+
+func TestPipeCRCPolicy(t *testing.T) {
+	nf := frame.NewFrame()
+	nf.WriteVersion(nf.Header(), frame.Version1)
+	nf.WriteFlags(nf.Header(), frame.CodecRaw)
+	nf.WritePayloadLen(nf.Header(), uint32(len([]byte(TestPayload))))
+	nf.WritePayload([]byte(TestPayload))
+	// no CRC written
+	nf.SetCRCDisabled(nf.Header())
+
+	for _, policy := range []frame.CRCPolicy{frame.CRCRequired, frame.CRCTrusted} {
+		a, b := NewRelayPair()
+		b.SetCRCPolicy(policy)
+
+		go func() {
+			_ = a.Send(nf)
+		}()
+
+		fr := frame.NewFrame()
+		err := b.Receive(fr)
+		if policy == frame.CRCTrusted {
+			require.NoError(t, err)
+			assert.True(t, fr.IsCRCDisabled(fr.Header()))
+			assert.Equal(t, []byte(TestPayload), fr.Payload())
+		} else {
+			assert.ErrorIs(t, err, frame.ErrCRCRequired)
+		}
+
+		_ = a.Close()
+		_ = b.Close()
+	}
+}
+
+func TestPipeNotGoridge(t *testing.T) {
+	inputs := map[string][]byte{
+		"HTTP": []byte("GET / HTTP/1.1\r\n\r\n"),
+		// TLS ClientHello record header and the beginning of the handshake
+		"TLS": {0x16, 0x03, 0x01, 0x02, 0x00, 0x01, 0x00, 0x01, 0xfc, 0x03, 0x03, 0x8e, 0x21, 0x44},
+	}
+
+	for protocol, data := range inputs {
+		pr, pw := io.Pipe()
+		relay := NewPipeRelay(pr, pw)
+
+		go func() {
+			_, _ = pw.Write(data)
+		}()
+
+		err := relay.Receive(frame.NewFrame())
+		assert.ErrorIs(t, err, frame.ErrNotGoridge)
+
+		var ne *frame.NotGoridgeError
+		require.ErrorAs(t, err, &ne)
+		assert.Equal(t, protocol, ne.Protocol)
+		assert.Contains(t, err.Error(), "an "+protocol+" client hit the goridge port")
+
+		_ = relay.Close()
+	}
+}
+
+func TestPipeReceiveAligned(t *testing.T) {
+	pr, pw := io.Pipe()
+
+	relay := NewPipeRelay(pr, pw)
+	require.Error(t, relay.SetPayloadAlignment(6))
+	require.NoError(t, relay.SetPayloadAlignment(8))
+
+	nf := frame.NewFrame()
+	nf.WriteVersion(nf.Header(), frame.Version1)
+	nf.WriteFlags(nf.Header(), frame.CONTROL, frame.CodecRaw)
+	nf.WritePayloadLen(nf.Header(), uint32(len([]byte(TestPayload))))
+	nf.WritePayload([]byte(TestPayload))
+	nf.WriteCRC(nf.Header())
+
+	go func() {
+		assert.NoError(t, relay.Send(nf))
+	}()
+
+	fr := frame.NewFrame()
+	require.NoError(t, relay.Receive(fr))
+	assert.Equal(t, []byte(TestPayload), fr.Payload())
+	assert.Zero(t, uintptr(unsafe.Pointer(&fr.Payload()[0]))%8)
+}
@@ -0,0 +1,183 @@
+package queue
+
+import (
+	"context"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/roadrunner-server/goridge/v3/pkg/relay"
+)
+
+// DefaultSize is the default number of frames waiting to be sent
+const DefaultSize = 64
+
+// DefaultDrainTimeout is the default time Close waits for the queued frames to be sent
+const DefaultDrainTimeout = 5 * time.Second
+
+// ErrQueueFull is returned by Send of the non-blocking relay when the queue is full
+var ErrQueueFull = errors.Str("send queue is full") //nolint:gochecknoglobals
+
+// Config configures the queue relay
+type Config struct {
+	// Size is the number of frames waiting to be sent. Default - DefaultSize.
+	Size int
+	// NonBlocking makes Send return ErrQueueFull when the queue is full instead of waiting for a free slot
+	NonBlocking bool
+	// DrainTimeout is the time Close waits for the queued frames to be sent, e.g. to the peer which doesn't read,
+	// before closing the underlying relay. Default - DefaultDrainTimeout.
+	DrainTimeout time.Duration
+}
+
+// Relay sends the frames through the underlying relay from the background goroutine, the frames are queued
+// in the bounded queue in the order of the Send calls. When the queue is full Send blocks (or returns
+// ErrQueueFull), so the bursty writers are slowed down instead of buffering the frames unboundedly.
+// The frames are copied, so the caller might reuse them right after Send.
+type Relay struct {
+	rl  relay.Relay
+	cfg Config
+
+	frames chan *frame.Frame
+	// closed by Close to unblock the waiting senders
+	stop chan struct{}
+	// closed when the writer has sent all the queued frames
+	done chan struct{}
+
+	mu     sync.RWMutex
+	closed bool
+	once   sync.Once
+	// the first error of the underlying relay Send
+	err atomic.Pointer[error]
+	// the error of the underlying relay Close, returned by every Close
+	closeErr error
+}
+
+// NewRelay creates the queue relay over rl and starts the writer goroutine.
+func NewRelay(rl relay.Relay, cfg Config) *Relay {
+	if cfg.Size <= 0 {
+		cfg.Size = DefaultSize
+	}
+	if cfg.DrainTimeout <= 0 {
+		cfg.DrainTimeout = DefaultDrainTimeout
+	}
+
+	r := &Relay{
+		rl:     rl,
+		cfg:    cfg,
+		frames: make(chan *frame.Frame, cfg.Size),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	go r.write()
+	return r
+}
+
+// Send queues the copy of the frame. The error of the underlying relay is returned by the next Send calls,
+// the frames queued after the failed one are dropped.
+func (r *Relay) Send(fr *frame.Frame) error {
+	const op = errors.Op("goridge_queue_send")
+	if err := r.err.Load(); err != nil {
+		return errors.E(op, *err)
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	if r.closed {
+		return errors.E(op, io.ErrClosedPipe)
+	}
+
+	f := copyFrame(fr)
+	if r.cfg.NonBlocking {
+		select {
+		case r.frames <- f:
+			return nil
+		default:
+			return ErrQueueFull
+		}
+	}
+
+	select {
+	case r.frames <- f:
+		return nil
+	case <-r.stop:
+		return errors.E(op, io.ErrClosedPipe)
+	}
+}
+
+// Receive receives the frame from the underlying relay.
+func (r *Relay) Receive(fr *frame.Frame) error {
+	return r.rl.Receive(fr)
+}
+
+// ReceiveCtx receives the frame with the context if the underlying relay supports it.
+func (r *Relay) ReceiveCtx(ctx context.Context, fr *frame.Frame) error {
+	cr, ok := r.rl.(relay.ContextRelay)
+	if !ok {
+		return r.Receive(fr)
+	}
+
+	return cr.ReceiveCtx(ctx, fr)
+}
+
+// Len returns the number of the frames waiting to be sent.
+func (r *Relay) Len() int {
+	return len(r.frames)
+}
+
+// Close stops accepting the frames, waits until the queued frames are sent (up to Config.DrainTimeout, the rest
+// of the frames is dropped) and closes the underlying relay. The underlying relay is closed once, the later calls
+// wait for it and return the same error.
+func (r *Relay) Close() error {
+	r.once.Do(func() {
+		close(r.stop)
+
+		// waits for the senders which are putting the frames to the queue
+		r.mu.Lock()
+		r.closed = true
+		close(r.frames)
+		r.mu.Unlock()
+
+		timer := time.NewTimer(r.cfg.DrainTimeout)
+		defer timer.Stop()
+
+		select {
+		case <-r.done:
+			r.closeErr = r.rl.Close()
+		case <-timer.C:
+			// unblocks the writer stuck in the underlying relay Send
+			r.closeErr = r.rl.Close()
+			<-r.done
+		}
+	})
+
+	return r.closeErr
+}
+
+func (r *Relay) write() {
+	defer close(r.done)
+
+	for f := range r.frames {
+		// drop the queued frames after the failure
+		if r.err.Load() != nil {
+			continue
+		}
+
+		err := r.rl.Send(f)
+		if err != nil {
+			r.err.Store(&err)
+		}
+	}
+}
+
+func copyFrame(fr *frame.Frame) *frame.Frame {
+	header := make([]byte, len(fr.Header()))
+	copy(header, fr.Header())
+	payload := make([]byte, len(fr.Payload()))
+	copy(payload, fr.Payload())
+
+	return frame.From(header, payload)
+}
@@ -0,0 +1,166 @@
+package queue
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/roadrunner-server/goridge/v3/pkg/pipe"
+	"github.com/roadrunner-server/goridge/v3/pkg/relay"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testFrame(payload string) *frame.Frame {
+	fr := frame.NewFrame()
+	fr.WriteVersion(fr.Header(), frame.Version1)
+	fr.WriteFlags(fr.Header(), frame.CodecRaw)
+	fr.WritePayloadLen(fr.Header(), uint32(len(payload)))
+	fr.WritePayload([]byte(payload))
+	fr.WriteCRC(fr.Header())
+	return fr
+}
+
+func receiveAll(t *testing.T, rl interface{ Receive(*frame.Frame) error }, n int) []string {
+	out := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		fr := frame.NewFrame()
+		if !assert.NoError(t, rl.Receive(fr)) {
+			break
+		}
+		out = append(out, string(fr.Payload()))
+	}
+
+	return out
+}
+
+func TestRelay_QueueFull(t *testing.T) {
+	a, b := pipe.NewRelayPair()
+	rl := NewRelay(a, Config{Size: 2, NonBlocking: true})
+	t.Cleanup(func() {
+		_ = b.Close()
+		_ = rl.Close()
+	})
+
+	// the writer takes the first frame and blocks in the pipe until the peer receives it
+	require.NoError(t, rl.Send(testFrame("0")))
+	require.Eventually(t, func() bool {
+		return rl.Len() == 0
+	}, time.Second, time.Millisecond)
+
+	require.NoError(t, rl.Send(testFrame("1")))
+	require.NoError(t, rl.Send(testFrame("2")))
+	assert.Equal(t, 2, rl.Len())
+	assert.ErrorIs(t, rl.Send(testFrame("3")), ErrQueueFull)
+
+	assert.Equal(t, []string{"0", "1", "2"}, receiveAll(t, b, 3))
+}
+
+func TestRelay_Blocking(t *testing.T) {
+	a, b := pipe.NewRelayPair()
+	rl := NewRelay(a, Config{Size: 2})
+	t.Cleanup(func() {
+		_ = b.Close()
+		_ = rl.Close()
+	})
+
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+		for i := 0; i < 10; i++ {
+			// the frames are copied, so the frame is reused
+			fr := testFrame(fmt.Sprintf("%d", i))
+			assert.NoError(t, rl.Send(fr))
+			fr.Reset()
+		}
+	}()
+
+	// 1 frame in the writer and 2 in the queue, the rest waits for the free slots
+	select {
+	case <-sent:
+		t.Fatal("Send should block when the queue is full")
+	case <-time.After(time.Millisecond * 50):
+	}
+	assert.Equal(t, 2, rl.Len())
+
+	expected := make([]string, 0, 10)
+	for i := 0; i < 10; i++ {
+		expected = append(expected, fmt.Sprintf("%d", i))
+	}
+	assert.Equal(t, expected, receiveAll(t, b, 10))
+	<-sent
+}
+
+func TestRelay_CloseFlushes(t *testing.T) {
+	a, b := pipe.NewRelayPair()
+	rl := NewRelay(a, Config{Size: 4})
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, rl.Send(testFrame(fmt.Sprintf("%d", i))))
+	}
+
+	received := make(chan []string, 1)
+	go func() {
+		received <- receiveAll(t, b, 3)
+	}()
+
+	require.NoError(t, rl.Close())
+	assert.Equal(t, []string{"0", "1", "2"}, <-received)
+	assert.Error(t, rl.Send(testFrame("closed")))
+}
+
+// closeCounter counts the Close calls of the relay
+type closeCounter struct {
+	relay.Relay
+	closes atomic.Int32
+}
+
+func (c *closeCounter) Close() error {
+	c.closes.Add(1)
+	_ = c.Relay.Close()
+	return errors.New("close failed")
+}
+
+func TestRelay_CloseOnce(t *testing.T) {
+	a, b := pipe.NewRelayPair()
+	t.Cleanup(func() {
+		_ = b.Close()
+	})
+	cc := &closeCounter{Relay: a}
+	rl := NewRelay(cc, Config{})
+
+	// the underlying relay is closed once, every call returns its error
+	for range 3 {
+		assert.EqualError(t, rl.Close(), "close failed")
+	}
+	assert.Equal(t, int32(1), cc.closes.Load())
+}
+
+func TestRelay_CloseDrainTimeout(t *testing.T) {
+	a, b := pipe.NewRelayPair()
+	t.Cleanup(func() {
+		_ = b.Close()
+	})
+	rl := NewRelay(a, Config{Size: 4, DrainTimeout: 50 * time.Millisecond})
+
+	// the peer never reads, the writer is blocked in the pipe
+	for i := 0; i < 3; i++ {
+		require.NoError(t, rl.Send(testFrame(fmt.Sprintf("%d", i))))
+	}
+
+	closed := make(chan error, 1)
+	go func() {
+		closed <- rl.Close()
+	}()
+
+	select {
+	case err := <-closed:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Close is blocked by the writer")
+	}
+	assert.Error(t, rl.Send(testFrame("closed")))
+}
@@ -0,0 +1,251 @@
+package reconnect
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/roadrunner-server/goridge/v3/pkg/relay"
+)
+
+const (
+	// DefaultMaxAttempts is the default number of the dial attempts per reconnect
+	DefaultMaxAttempts = 5
+	// DefaultInitialBackoff is the default delay before the second dial attempt, doubled after every failed one
+	DefaultInitialBackoff = time.Millisecond * 50
+	// DefaultMaxBackoff is the default max delay between the dial attempts
+	DefaultMaxBackoff = time.Second * 5
+)
+
+// Config configures the reconnecting relay
+type Config struct {
+	// Dial establishes the new connection, e.g. dials the socket and wraps it with socket.NewSocketRelay
+	Dial func(ctx context.Context) (relay.Relay, error)
+	// MaxAttempts is the number of the dial attempts per reconnect. Default - DefaultMaxAttempts.
+	MaxAttempts int
+	// InitialBackoff and MaxBackoff bound the exponential delay between the dial attempts.
+	// Defaults - DefaultInitialBackoff and DefaultMaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Reconnectable reports whether the error of Send or Receive means the dropped connection.
+	// Default - any error, the frames stream is out of sync after the protocol errors too.
+	Reconnectable func(err error) bool
+}
+
+// Relay redials the connection when Send or Receive fails and retries the call over the new connection.
+// Send is retried with the same frame, Receive waits for the frame from the new connection, so the responses
+// to the requests sent over the dropped connection are lost. Relay is safe for the concurrent Send and Receive.
+type Relay struct {
+	cfg Config
+
+	mu sync.Mutex
+	rl relay.Relay
+	// incremented on every reconnect, so the concurrent failures of the same connection reconnect once
+	gen uint64
+	// reconnect in progress (the lock is not held while dialing), nil - none
+	dialing *dialing
+	closed  bool
+	done    chan struct{}
+	stop    sync.Once
+}
+
+// dialing is the reconnect in progress, the concurrent failures of the same connection wait for it
+type dialing struct {
+	done chan struct{}
+	err  error
+}
+
+// NewRelay dials the first connection (with the retries) and creates the reconnecting relay.
+func NewRelay(cfg Config) (*Relay, error) {
+	const op = errors.Op("goridge_reconnect_new_relay")
+	if cfg.Dial == nil {
+		return nil, errors.E(op, errors.Str("dial function is required"))
+	}
+	if cfg.MaxAttempts <= 0 {
+		cfg.MaxAttempts = DefaultMaxAttempts
+	}
+	if cfg.InitialBackoff <= 0 {
+		cfg.InitialBackoff = DefaultInitialBackoff
+	}
+	if cfg.MaxBackoff <= 0 {
+		cfg.MaxBackoff = DefaultMaxBackoff
+	}
+	if cfg.Reconnectable == nil {
+		cfg.Reconnectable = func(error) bool {
+			return true
+		}
+	}
+
+	r := &Relay{cfg: cfg, done: make(chan struct{})}
+	rl, err := r.dial()
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	r.rl = rl
+	return r, nil
+}
+
+// Send sends the frame, the frame is sent again over the new connection if the current one is dropped.
+func (r *Relay) Send(fr *frame.Frame) error {
+	rl, gen, err := r.current()
+	if err != nil {
+		return err
+	}
+
+	err = rl.Send(fr)
+	if err == nil || !r.cfg.Reconnectable(err) {
+		return err
+	}
+
+	rl, err = r.reconnect(gen, err)
+	if err != nil {
+		return err
+	}
+
+	return rl.Send(fr)
+}
+
+// Receive receives the frame, waiting for the frame from the new connection if the current one is dropped.
+func (r *Relay) Receive(fr *frame.Frame) error {
+	rl, gen, err := r.current()
+	if err != nil {
+		return err
+	}
+
+	err = rl.Receive(fr)
+	if err == nil || !r.cfg.Reconnectable(err) {
+		return err
+	}
+
+	rl, err = r.reconnect(gen, err)
+	if err != nil {
+		return err
+	}
+
+	fr.Reset()
+	return rl.Receive(fr)
+}
+
+// Close closes the current connection and stops the reconnects in progress.
+func (r *Relay) Close() error {
+	// interrupts the dial of the reconnect in progress
+	r.stop.Do(func() {
+		close(r.done)
+	})
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil
+	}
+
+	r.closed = true
+	return r.rl.Close()
+}
+
+func (r *Relay) current() (relay.Relay, uint64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil, 0, io.ErrClosedPipe
+	}
+
+	return r.rl, r.gen, nil
+}
+
+// reconnect replaces the connection of the generation, the connection which is already replaced
+// by the concurrent call is returned as is. The lock is released while dialing, the concurrent calls
+// wait for the reconnect in progress.
+func (r *Relay) reconnect(gen uint64, cause error) (relay.Relay, error) {
+	const op = errors.Op("goridge_reconnect")
+
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil, io.ErrClosedPipe
+	}
+	if r.gen != gen {
+		rl := r.rl
+		r.mu.Unlock()
+		return rl, nil
+	}
+	if d := r.dialing; d != nil {
+		r.mu.Unlock()
+		<-d.done
+		if d.err != nil {
+			return nil, errors.E(op, errors.Errorf("connection dropped: %v, reconnect failed: %v", cause, d.err))
+		}
+
+		rl, _, err := r.current()
+		return rl, err
+	}
+
+	d := &dialing{done: make(chan struct{})}
+	r.dialing = d
+	old := r.rl
+	r.mu.Unlock()
+
+	_ = old.Close()
+	rl, err := r.dial()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.dialing = nil
+	defer close(d.done)
+
+	switch {
+	case err != nil:
+		d.err = err
+		return nil, errors.E(op, errors.Errorf("connection dropped: %v, reconnect failed: %v", cause, err))
+	case r.closed || r.gen != gen:
+		// the relay was closed (or the connection replaced) while dialing, the new connection is stale
+		_ = rl.Close()
+		d.err = io.ErrClosedPipe
+		return nil, io.ErrClosedPipe
+	}
+
+	r.rl = rl
+	r.gen++
+	return rl, nil
+}
+
+// dial calls the dial function with the exponential backoff between the attempts
+func (r *Relay) dial() (relay.Relay, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-r.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	backoff := r.cfg.InitialBackoff
+	var err error
+	for attempt := 0; attempt < r.cfg.MaxAttempts; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-time.After(backoff):
+			case <-ctx.Done():
+				return nil, io.ErrClosedPipe
+			}
+
+			backoff = min(backoff*2, r.cfg.MaxBackoff)
+		}
+
+		var rl relay.Relay
+		rl, err = r.cfg.Dial(ctx)
+		if err == nil {
+			return rl, nil
+		}
+	}
+
+	return nil, errors.Errorf("%d dial attempts failed, last error: %v", r.cfg.MaxAttempts, err)
+}
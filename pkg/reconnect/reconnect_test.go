@@ -0,0 +1,176 @@
+package reconnect
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/roadrunner-server/goridge/v3/pkg/pipe"
+	"github.com/roadrunner-server/goridge/v3/pkg/relay"
+	"github.com/roadrunner-server/goridge/v3/pkg/socket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testFrame(payload string) *frame.Frame {
+	fr := frame.NewFrame()
+	fr.WriteVersion(fr.Header(), frame.Version1)
+	fr.WriteFlags(fr.Header(), frame.CodecRaw)
+	fr.WritePayloadLen(fr.Header(), uint32(len(payload)))
+	fr.WritePayload([]byte(payload))
+	fr.WriteCRC(fr.Header())
+	return fr
+}
+
+// echoServer greets every connection with its number, echoes the frames and drops the connection on "drop"
+func echoServer(t *testing.T) string {
+	ln, err := net.Listen("unix", filepath.Join(t.TempDir(), "rpc.sock"))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = ln.Close()
+	})
+
+	go func() {
+		for n := 1; ; n++ {
+			conn, errA := ln.Accept()
+			if errA != nil {
+				return
+			}
+
+			go func() {
+				rl := socket.NewSocketRelay(conn)
+				defer func() {
+					_ = rl.Close()
+				}()
+
+				if rl.Send(testFrame(fmt.Sprintf("hello %d", n))) != nil {
+					return
+				}
+
+				for {
+					fr := frame.NewFrame()
+					if rl.Receive(fr) != nil || string(fr.Payload()) == "drop" {
+						return
+					}
+
+					if rl.Send(testFrame(string(fr.Payload()))) != nil {
+						return
+					}
+				}
+			}()
+		}
+	}()
+
+	return ln.Addr().String()
+}
+
+func receive(t *testing.T, rl relay.Relay) string {
+	fr := frame.NewFrame()
+	require.NoError(t, rl.Receive(fr))
+	return string(fr.Payload())
+}
+
+func TestRelay_Reconnect(t *testing.T) {
+	addr := echoServer(t)
+	var dials atomic.Int32
+	rl, err := NewRelay(Config{
+		Dial: func(ctx context.Context) (relay.Relay, error) {
+			dials.Add(1)
+			conn, errD := (&net.Dialer{}).DialContext(ctx, "unix", addr)
+			if errD != nil {
+				return nil, errD
+			}
+
+			return socket.NewSocketRelay(conn), nil
+		},
+		InitialBackoff: time.Millisecond,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = rl.Close()
+	})
+
+	assert.Equal(t, "hello 1", receive(t, rl))
+	require.NoError(t, rl.Send(testFrame("ping")))
+	assert.Equal(t, "ping", receive(t, rl))
+
+	// the server drops the connection, Receive redials and waits for the frame from the new one
+	require.NoError(t, rl.Send(testFrame("drop")))
+	assert.Equal(t, "hello 2", receive(t, rl))
+	assert.Equal(t, int32(2), dials.Load())
+
+	require.NoError(t, rl.Send(testFrame("pong")))
+	assert.Equal(t, "pong", receive(t, rl))
+
+	require.NoError(t, rl.Close())
+	assert.Error(t, rl.Send(testFrame("closed")))
+}
+
+func TestRelay_MaxAttempts(t *testing.T) {
+	var dials atomic.Int32
+	_, err := NewRelay(Config{
+		Dial: func(context.Context) (relay.Relay, error) {
+			dials.Add(1)
+			return nil, errors.Str("connection refused")
+		},
+		MaxAttempts:    3,
+		InitialBackoff: time.Millisecond,
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "connection refused")
+	assert.Equal(t, int32(3), dials.Load())
+}
+
+func TestRelay_CloseWhileDialing(t *testing.T) {
+	a1, b1 := pipe.NewRelayPair()
+	a2, b2 := pipe.NewRelayPair()
+	release := make(chan struct{})
+	var dials atomic.Int32
+	rl, err := NewRelay(Config{
+		// the second dial ignores the context and blocks until released
+		Dial: func(context.Context) (relay.Relay, error) {
+			if dials.Add(1) == 1 {
+				return a1, nil
+			}
+
+			<-release
+			return a2, nil
+		},
+		InitialBackoff: time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	received := make(chan error, 1)
+	go func() {
+		received <- rl.Receive(frame.NewFrame())
+	}()
+
+	// the peer drops the connection, Receive is redialing
+	require.NoError(t, b1.Close())
+	require.Eventually(t, func() bool {
+		return dials.Load() == 2
+	}, time.Second, time.Millisecond)
+
+	closed := make(chan error, 1)
+	go func() {
+		closed <- rl.Close()
+	}()
+
+	select {
+	case <-closed:
+	case <-time.After(time.Second):
+		t.Fatal("Close is blocked by the dial")
+	}
+
+	// the connection dialed after Close is stale and closed
+	close(release)
+	require.ErrorIs(t, <-received, io.ErrClosedPipe)
+	require.Error(t, b2.Receive(frame.NewFrame()))
+}
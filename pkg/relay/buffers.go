@@ -0,0 +1,52 @@
+package relay
+
+import (
+	"github.com/roadrunner-server/goridge/v3/internal"
+)
+
+// SetAdaptiveBufferSizing switches the payload buffers of the socket and pipe relays (process-wide) from the fixed
+// 1/5/10MB buckets to the buffers sized by the recently observed payload sizes. Disabled by default.
+func SetAdaptiveBufferSizing(enabled bool) {
+	internal.SetAdaptiveSizing(enabled)
+}
+
+// BufferBucket contains the counters of a payload buffer bucket.
+type BufferBucket struct {
+	// Size - the largest payload served by the bucket, in bytes
+	Size uint32
+	// Hits - buffers reused from the bucket
+	Hits uint64
+	// Misses - buffers allocated because the bucket was empty
+	Misses uint64
+}
+
+// BufferStats contains the counters of the fixed payload buffer buckets.
+type BufferStats struct {
+	Buckets []BufferBucket
+	// Oversized - buffers allocated for the payloads larger than the largest bucket, never reused
+	Oversized uint64
+}
+
+// SetBufferBuckets replaces the fixed payload buffer buckets of the socket and pipe relays (process-wide).
+// Each size is the largest payload served by the bucket in bytes, payloads above the largest size are allocated
+// on every read. Defaults are 1, 5 and 10MB. Should be called before the relays are used.
+func SetBufferBuckets(sizes ...uint32) error {
+	return internal.SetBuckets(sizes...)
+}
+
+// GetBufferStats returns the counters of the fixed payload buffer buckets.
+func GetBufferStats() BufferStats {
+	buckets, oversized := internal.Stats()
+
+	st := BufferStats{Buckets: make([]BufferBucket, 0, len(buckets)), Oversized: oversized}
+	for _, b := range buckets {
+		var hits uint64
+		if b.Gets > b.Misses {
+			hits = b.Gets - b.Misses
+		}
+
+		st.Buckets = append(st.Buckets, BufferBucket{Size: b.Size, Hits: hits, Misses: b.Misses})
+	}
+
+	return st
+}
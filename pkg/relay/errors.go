@@ -0,0 +1,8 @@
+package relay
+
+import (
+	"github.com/roadrunner-server/errors"
+)
+
+// ErrConnLimitExceeded is returned by Receive when the connection has already received its byte limit
+var ErrConnLimitExceeded = errors.Str("connection byte limit exceeded") //nolint:gochecknoglobals
@@ -1,6 +1,11 @@
 package relay
 
-import "github.com/roadrunner-server/goridge/v3/pkg/frame"
+import (
+	"context"
+	"net"
+
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+)
 
 // Relay provide IPC over signed payloads.
 type Relay interface {
@@ -13,3 +18,24 @@ type Relay interface {
 	// Close the connection.
 	Close() error
 }
+
+// ContextRelay is a Relay which is able to abort a blocked Receive when the context is done.
+type ContextRelay interface {
+	Relay
+
+	// ReceiveCtx receives data like Receive, but returns context.Canceled or context.DeadlineExceeded
+	// when the context is done before the frame is received.
+	ReceiveCtx(ctx context.Context, frame *frame.Frame) error
+}
+
+// AddrRelay is a Relay over a network connection which reports the addresses of the connection, e.g. for
+// the per-connection logging or authorization. Relays implement it optionally, check it with a type assertion.
+type AddrRelay interface {
+	Relay
+
+	// LocalAddr returns the local address of the connection, nil if the connection has none.
+	LocalAddr() net.Addr
+
+	// RemoteAddr returns the remote address of the connection, nil if the connection has none.
+	RemoteAddr() net.Addr
+}
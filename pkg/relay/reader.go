@@ -0,0 +1,109 @@
+package relay
+
+import (
+	stderr "errors"
+	"io"
+	"sync"
+
+	"github.com/roadrunner-server/goridge/v3/internal"
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+)
+
+// DefaultFrameReaderBuffer is the default number of the received frames waiting to be consumed
+const DefaultFrameReaderBuffer = 64
+
+// FrameReader receives the frames in its own goroutine and emits them on the channel, e.g. for the custom
+// dispatchers or the debugging tools which don't use net/rpc.
+type FrameReader struct {
+	frames  chan *frame.Frame
+	done    chan struct{}
+	stopped sync.Once
+	pool    sync.Pool
+	err     error
+}
+
+// NewFrameReader starts reading the frames from r with the header CRC verification. The buffer is the number
+// of the frames waiting to be consumed, 0 - DefaultFrameReaderBuffer.
+func NewFrameReader(r io.Reader, buffer int) *FrameReader {
+	fr := newFrameReader(buffer)
+	go fr.run(func(f *frame.Frame) error {
+		return internal.ReceiveFrame(r, f)
+	})
+
+	return fr
+}
+
+// NewRelayFrameReader starts reading the frames from the relay, like NewFrameReader.
+func NewRelayFrameReader(rl Relay, buffer int) *FrameReader {
+	fr := newFrameReader(buffer)
+	go fr.run(rl.Receive)
+
+	return fr
+}
+
+func newFrameReader(buffer int) *FrameReader {
+	if buffer <= 0 {
+		buffer = DefaultFrameReaderBuffer
+	}
+
+	return &FrameReader{
+		frames: make(chan *frame.Frame, buffer),
+		done:   make(chan struct{}),
+		pool: sync.Pool{New: func() any {
+			return frame.NewFrame()
+		}},
+	}
+}
+
+// Frames returns the channel of the received frames in the order of receiving. The channel is closed
+// when the source returns an error (see Err) or after Stop.
+func (r *FrameReader) Frames() <-chan *frame.Frame {
+	return r.frames
+}
+
+// Err returns the error which stopped the reading, nil for io.EOF and Stop.
+// Should be called after the Frames channel is closed.
+func (r *FrameReader) Err() error {
+	return r.err
+}
+
+// Release returns the consumed frame to the reader for the reuse, the frame should not be used after the call.
+// Releasing the frames is optional.
+func (r *FrameReader) Release(f *frame.Frame) {
+	f.Reset()
+	r.pool.Put(f)
+}
+
+// Stop stops emitting the frames. The read which is already in progress returns only when the source
+// returns, so close the source to unblock it.
+func (r *FrameReader) Stop() {
+	r.stopped.Do(func() {
+		close(r.done)
+	})
+}
+
+func (r *FrameReader) run(receive func(*frame.Frame) error) {
+	defer close(r.frames)
+
+	for {
+		f := r.pool.Get().(*frame.Frame)
+		err := receive(f)
+		if err != nil {
+			r.pool.Put(f)
+			select {
+			case <-r.done:
+			default:
+				if !stderr.Is(err, io.EOF) {
+					r.err = err
+				}
+			}
+			return
+		}
+
+		select {
+		case r.frames <- f:
+		case <-r.done:
+			return
+		}
+	}
+}
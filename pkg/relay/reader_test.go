@@ -0,0 +1,129 @@
+package relay
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"testing"
+
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testFrame(seq uint32, payload string) *frame.Frame {
+	fr := frame.NewFrame()
+	fr.WriteVersion(fr.Header(), frame.Version1)
+	fr.WriteFlags(fr.Header(), frame.CodecRaw)
+	fr.WriteOptions(fr.HeaderPtr(), seq, 0)
+	fr.WritePayloadLen(fr.Header(), uint32(len(payload)))
+	fr.WritePayload([]byte(payload))
+	fr.WriteCRC(fr.Header())
+	return fr
+}
+
+func TestFrameReader(t *testing.T) {
+	pr, pw := io.Pipe()
+	go func() {
+		for i := 0; i < 100; i++ {
+			_, err := pw.Write(testFrame(uint32(i), "payload-"+strconv.Itoa(i)).Bytes())
+			assert.NoError(t, err)
+		}
+		_ = pw.Close()
+	}()
+
+	// smaller buffer than the number of frames
+	r := NewFrameReader(pr, 4)
+	i := 0
+	for fr := range r.Frames() {
+		assert.Equal(t, []uint32{uint32(i), 0}, fr.ReadOptions(fr.Header()))
+		assert.Equal(t, "payload-"+strconv.Itoa(i), string(fr.Payload()))
+		r.Release(fr)
+		i++
+	}
+
+	assert.Equal(t, 100, i)
+	assert.NoError(t, r.Err())
+}
+
+func TestFrameReader_Error(t *testing.T) {
+	data := testFrame(1, "valid").Bytes()
+	corrupted := testFrame(2, "corrupted").Bytes()
+	corrupted[7] ^= 0xFF
+
+	r := NewFrameReader(bytes.NewReader(append(data, corrupted...)), 0)
+	var frames []*frame.Frame
+	for fr := range r.Frames() {
+		frames = append(frames, fr)
+	}
+
+	require.Len(t, frames, 1)
+	assert.Equal(t, "valid", string(frames[0].Payload()))
+	assert.ErrorIs(t, r.Err(), frame.ErrCRCMismatch)
+}
+
+// sliceRelay receives the frames one by one and fails after the last one
+type sliceRelay struct {
+	frames []*frame.Frame
+	err    error
+}
+
+func (s *sliceRelay) Send(*frame.Frame) error {
+	return nil
+}
+
+func (s *sliceRelay) Receive(fr *frame.Frame) error {
+	if len(s.frames) == 0 {
+		return s.err
+	}
+
+	*fr.HeaderPtr() = append((*fr.HeaderPtr())[:0], s.frames[0].Header()...)
+	fr.WritePayload(s.frames[0].Payload())
+	s.frames = s.frames[1:]
+	return nil
+}
+
+func (s *sliceRelay) Close() error {
+	return nil
+}
+
+func TestRelayFrameReader(t *testing.T) {
+	broken := errors.Str("broken relay")
+	rl := &sliceRelay{frames: []*frame.Frame{testFrame(1, "a"), testFrame(2, "b"), testFrame(3, "c")}, err: broken}
+
+	r := NewRelayFrameReader(rl, 1)
+	var payloads []string
+	for fr := range r.Frames() {
+		payloads = append(payloads, string(fr.Payload()))
+	}
+
+	assert.Equal(t, []string{"a", "b", "c"}, payloads)
+	assert.ErrorIs(t, r.Err(), broken)
+}
+
+func TestFrameReader_Stop(t *testing.T) {
+	pr, pw := io.Pipe()
+	go func() {
+		for i := 0; ; i++ {
+			_, err := pw.Write(testFrame(uint32(i), "payload").Bytes())
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	r := NewFrameReader(pr, 1)
+	<-r.Frames()
+	r.Stop()
+	r.Stop()
+	_ = pr.Close()
+
+	// the channel is closed, the rest of the frames are dropped
+	n := 0
+	for range r.Frames() {
+		n++
+	}
+	assert.LessOrEqual(t, n, 2)
+	assert.NoError(t, r.Err())
+}
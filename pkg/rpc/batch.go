@@ -0,0 +1,276 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"time"
+
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+)
+
+const (
+	// DefaultBatchMaxMessages is the default number of the responses in a batch
+	DefaultBatchMaxMessages = 16
+	// DefaultBatchMaxBytes is the default size of the batch payload
+	DefaultBatchMaxBytes = 64 * 1024
+	// DefaultBatchMaxDelay is the default time the first response of a batch waits for the others
+	DefaultBatchMaxDelay = time.Millisecond
+)
+
+// BatchConfig configures the batching of the responses, see SetBatching.
+type BatchConfig struct {
+	// MaxMessages is the number of the responses which flushes the batch, default - DefaultBatchMaxMessages
+	MaxMessages int
+	// MaxBytes is the size of the batch payload which flushes the batch, the bigger responses are sent as is,
+	// default - DefaultBatchMaxBytes
+	MaxBytes int
+	// MaxDelay is the time the first response of the batch waits for the others, default - DefaultBatchMaxDelay
+	MaxDelay time.Duration
+}
+
+// batch holds the frames of the responses waiting to be sent, guarded by Codec.sendMu
+type batch struct {
+	cfg BatchConfig
+	// the complete frames (header and payload) one after another, the sizes and events of them
+	buf    bytes.Buffer
+	frames []batched
+	timer  *time.Timer
+	// error of the failed flush, the codec is closed and the later sends return it
+	failed error
+}
+
+// batched is the frame of the batch, it's counted and reported when the batch is sent
+type batched struct {
+	size int
+	ev   Event
+}
+
+// SetBatching enables the batching of the responses: the small responses written by WriteResponse within
+// MaxDelay are coalesced into one frame with the frame.Batch bit, the client splits it back into the responses
+// with their own codec flags and options. The control frames (pings, transaction markers, close reasons)
+// and the responses bigger than MaxBytes flush the batch and are sent as is. The batched responses are counted
+// in the stats and reported with EventFrameSent when the batch is sent. A failed flush closes the codec,
+// the later responses and FlushBatch return its error. nil disables the batching (the default).
+// Should be called before the codec is used.
+func (c *Codec) SetBatching(cfg *BatchConfig) {
+	if cfg == nil {
+		c.batch = nil
+		return
+	}
+
+	b := &batch{cfg: *cfg}
+	if b.cfg.MaxMessages <= 0 {
+		b.cfg.MaxMessages = DefaultBatchMaxMessages
+	}
+	if b.cfg.MaxBytes <= 0 {
+		b.cfg.MaxBytes = DefaultBatchMaxBytes
+	}
+	if b.cfg.MaxDelay <= 0 {
+		b.cfg.MaxDelay = DefaultBatchMaxDelay
+	}
+
+	c.batch = b
+}
+
+// FlushBatch sends the pending batch of the responses, if any.
+func (c *Codec) FlushBatch() error {
+	const op = errors.Op("goridge_flush_batch")
+	if c.batch == nil {
+		return nil
+	}
+
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+
+	err := c.flushBatchLocked()
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// sendBatched appends the frame to the batch or flushes the batch and sends the frame as is, holding sendMu.
+// Returns true if the frame was appended to the batch.
+func (c *Codec) sendBatched(fr *frame.Frame, ev Event) (bool, error) {
+	b := c.batch
+	if b.failed != nil {
+		return false, b.failed
+	}
+
+	size := len(fr.Header()) + len(fr.Payload())
+	if fr.Flags().IsControl() || size > b.cfg.MaxBytes {
+		err := c.flushBatchLocked()
+		if err != nil {
+			return false, err
+		}
+
+		return false, c.sendWithDeadline(fr)
+	}
+
+	if b.buf.Len()+size > b.cfg.MaxBytes {
+		err := c.flushBatchLocked()
+		if err != nil {
+			return false, err
+		}
+	}
+
+	// the payload of the frame is the pooled buffer, it's copied
+	swapOrder(fr, c.swapOptions)
+	b.buf.Write(fr.Header())
+	swapOrder(fr, c.swapOptions)
+	b.buf.Write(fr.Payload())
+	b.frames = append(b.frames, batched{size: size, ev: ev})
+
+	if len(b.frames) >= b.cfg.MaxMessages {
+		return true, c.flushBatchLocked()
+	}
+
+	if b.timer == nil {
+		b.timer = time.AfterFunc(b.cfg.MaxDelay, func() {
+			c.sendMu.Lock()
+			defer c.sendMu.Unlock()
+
+			// the error is kept by the batch and returned by the later sends
+			if c.batch == b && c.flushBatchLocked() != nil {
+				c.stats.errors.Add(1)
+			}
+		})
+	}
+
+	return true, nil
+}
+
+// flushBatchLocked sends the pending frames as one batch frame, holding sendMu. The failed flush closes the codec.
+func (c *Codec) flushBatchLocked() error {
+	b := c.batch
+	if b == nil {
+		return nil
+	}
+
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+
+	if b.failed != nil {
+		return b.failed
+	}
+
+	if len(b.frames) == 0 {
+		return nil
+	}
+
+	fr := frame.NewFrame()
+	fr.WriteVersion(fr.Header(), frame.Version1)
+	fr.WriteFlags(fr.Header(), frame.CONTROL)
+	fr.SetBatchBit(fr.Header())
+	fr.WriteOptions(fr.HeaderPtr(), uint32(len(b.frames))) //nolint:gosec
+	fr.WritePayloadLen(fr.Header(), uint32(b.buf.Len()))   //nolint:gosec
+	fr.SetPayload(b.buf.Bytes())
+	writeCRC(fr, c.crcDisabled)
+
+	err := c.sendWithDeadline(fr)
+	if err != nil {
+		// the responses of the batch are lost, the peer can't tell which of them were received
+		b.failed = err
+		if c.closed.CompareAndSwap(false, true) {
+			c.closing()
+			_ = c.relay.Close()
+		}
+	} else {
+		for _, bf := range b.frames {
+			c.frameSent(bf.size, bf.ev)
+		}
+	}
+
+	b.buf.Reset()
+	b.frames = b.frames[:0]
+	return err
+}
+
+// reset drops the pending frames of the previous connection
+func (b *batch) reset() {
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+
+	b.buf.Reset()
+	b.frames = b.frames[:0]
+	b.failed = nil
+}
+
+// receiveFrame returns the next received frame, the batches are split into the frames they carry
+func (c *ClientCodec) receiveFrame() (*frame.Frame, error) {
+	for {
+		if len(c.batchReady) > 0 {
+			fr := c.batchReady[0]
+			c.batchReady = c.batchReady[1:]
+			return fr, nil
+		}
+
+		fr := c.getFrame()
+		err := c.relay.Receive(fr)
+		if err != nil {
+			return nil, err
+		}
+
+		swapOrder(fr, c.swapOptions)
+		if !fr.IsBatch(fr.Header()) {
+			return fr, nil
+		}
+
+		frames, err := c.splitBatch(fr)
+		c.putFrame(fr)
+		if err != nil {
+			return nil, err
+		}
+
+		c.batchReady = frames
+	}
+}
+
+// splitBatch returns the frames carried by the batch frame, their CRCs are verified by the caller
+func (c *ClientCodec) splitBatch(fr *frame.Frame) ([]*frame.Frame, error) {
+	const op = errors.Op("goridge_split_batch")
+	if !fr.IsCRCDisabled(fr.Header()) && !fr.VerifyCRC(fr.Header()) {
+		return nil, errors.E(op, errors.Str("CRC verification failed"))
+	}
+
+	opts := fr.ReadOptions(fr.Header())
+	if len(opts) < 1 {
+		return nil, errors.E(op, errors.Str("batch frame should carry the number of frames option"))
+	}
+
+	payload := fr.Payload()
+	var frames []*frame.Frame
+	for len(payload) > 0 {
+		if len(payload) < 12 {
+			return nil, errors.E(op, errors.Errorf("truncated frame header: %d bytes", len(payload)))
+		}
+
+		hl := int(payload[0]&0x0F) * frame.WORD
+		if hl < 12 || hl > len(payload) {
+			return nil, errors.E(op, errors.Errorf("frame header length %d is out of the batch bounds", hl))
+		}
+
+		end := hl + int(binary.LittleEndian.Uint32(payload[2:6]))
+		if end > len(payload) {
+			return nil, errors.E(op, errors.Errorf("frame payload length %d is out of the batch bounds", end-hl))
+		}
+
+		// the frames outlive the batch frame returned to the pool
+		inner := frame.From(bytes.Clone(payload[:hl]), bytes.Clone(payload[hl:end]))
+		swapOrder(inner, c.swapOptions)
+		frames = append(frames, inner)
+		payload = payload[end:]
+	}
+
+	if len(frames) != int(opts[0]) {
+		return nil, errors.E(op, errors.Errorf("batch is incomplete: received %d of %d frames", len(frames), opts[0]))
+	}
+
+	return frames, nil
+}
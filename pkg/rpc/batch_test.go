@@ -0,0 +1,170 @@
+package rpc
+
+import (
+	"io"
+	"net/rpc"
+	"testing"
+	"time"
+
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestCodec_Batching(t *testing.T) {
+	c, rl := pipeCodec(t)
+	c.SetBatching(&BatchConfig{MaxMessages: 3, MaxDelay: time.Hour})
+
+	msgpackBody, err := msgpack.Marshal("two")
+	require.NoError(t, err)
+
+	requests := []struct {
+		flags byte
+		body  []byte
+	}{
+		{frame.CodecJSON, []byte(`"one"`)},
+		{frame.CodecMsgpack, msgpackBody},
+		{frame.CodecRaw, []byte("three")},
+	}
+	for i, req := range requests {
+		go func() {
+			_ = rl.Send(requestFrame(uint32(i+1), "test.Batch", req.flags, req.body)) //nolint:gosec
+		}()
+
+		r := &rpc.Request{}
+		require.NoError(t, c.ReadRequestHeader(r))
+		require.NoError(t, c.ReadRequestBody(nil))
+	}
+
+	// the first two responses wait for the third one, which flushes the batch
+	require.NoError(t, c.WriteResponse(&rpc.Response{ServiceMethod: "test.Batch", Seq: 1}, "one"))
+	require.NoError(t, c.WriteResponse(&rpc.Response{ServiceMethod: "test.Batch", Seq: 2}, "two"))
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.WriteResponse(&rpc.Response{ServiceMethod: "test.Batch", Seq: 3}, []byte("three"))
+	}()
+
+	client := NewClientCodecWithRelay(rl)
+	for i, req := range requests {
+		r := &rpc.Response{}
+		require.NoError(t, client.ReadResponseHeader(r))
+		assert.Equal(t, uint64(i+1), r.Seq) //nolint:gosec
+		assert.Equal(t, "test.Batch", r.ServiceMethod)
+		// every response keeps its own codec
+		assert.Equal(t, req.flags, client.frame.ReadFlags())
+		// the rest of the batch is already received
+		assert.Len(t, client.batchReady, 2-i)
+
+		if req.flags == frame.CodecRaw {
+			var out []byte
+			require.NoError(t, client.ReadResponseBody(&out))
+			assert.Equal(t, []byte("three"), out)
+			continue
+		}
+
+		var out string
+		require.NoError(t, client.ReadResponseBody(&out))
+		assert.Equal(t, []string{"one", "two"}[i], out)
+	}
+	require.NoError(t, <-errCh)
+}
+
+func TestCodec_BatchingDelay(t *testing.T) {
+	c, rl := pipeCodec(t)
+	c.SetBatching(&BatchConfig{MaxDelay: 10 * time.Millisecond})
+
+	go func() {
+		_ = rl.Send(requestFrame(1, "test.Batch", frame.CodecJSON, []byte(`"one"`)))
+	}()
+
+	r := &rpc.Request{}
+	require.NoError(t, c.ReadRequestHeader(r))
+	require.NoError(t, c.ReadRequestBody(nil))
+
+	// the single response is sent after the delay
+	require.NoError(t, c.WriteResponse(&rpc.Response{ServiceMethod: "test.Batch", Seq: 1}, "one"))
+
+	fr := frame.NewFrame()
+	require.NoError(t, rl.Receive(fr))
+	assert.True(t, fr.IsBatch(fr.Header()))
+	assert.Equal(t, []uint32{1}, fr.ReadOptions(fr.Header()))
+
+	// the control frames are never batched
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.Ping()
+	}()
+
+	fr = frame.NewFrame()
+	require.NoError(t, rl.Receive(fr))
+	assert.True(t, fr.IsPing(fr.Header()))
+	assert.False(t, fr.IsBatch(fr.Header()))
+	require.NoError(t, <-errCh)
+}
+
+func TestCodec_BatchingStats(t *testing.T) {
+	c, rl := pipeCodec(t)
+	c.SetBatching(&BatchConfig{MaxMessages: 2, MaxDelay: time.Hour})
+
+	var sent []uint64
+	c.SetEventSink(func(ev Event) {
+		if ev.Type == EventFrameSent {
+			sent = append(sent, ev.Seq)
+		}
+	})
+
+	for i := range 2 {
+		go func() {
+			_ = rl.Send(requestFrame(uint32(i+1), "test.Batch", frame.CodecJSON, []byte(`"one"`))) //nolint:gosec
+		}()
+
+		r := &rpc.Request{}
+		require.NoError(t, c.ReadRequestHeader(r))
+		require.NoError(t, c.ReadRequestBody(nil))
+	}
+
+	// the buffered response is not sent yet
+	require.NoError(t, c.WriteResponse(&rpc.Response{ServiceMethod: "test.Batch", Seq: 1}, "one"))
+	assert.Zero(t, c.Stats().FramesOut)
+	assert.Empty(t, sent)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.WriteResponse(&rpc.Response{ServiceMethod: "test.Batch", Seq: 2}, "two")
+	}()
+
+	fr := frame.NewFrame()
+	require.NoError(t, rl.Receive(fr))
+	require.NoError(t, <-errCh)
+	assert.Equal(t, uint64(2), c.Stats().FramesOut)
+	assert.Equal(t, []uint64{1, 2}, sent)
+}
+
+func TestCodec_BatchingFailedFlush(t *testing.T) {
+	c, rl := pipeCodec(t)
+	c.SetBatching(&BatchConfig{MaxDelay: 10 * time.Millisecond})
+
+	for i := range 2 {
+		go func() {
+			_ = rl.Send(requestFrame(uint32(i+1), "test.Batch", frame.CodecJSON, []byte(`"one"`))) //nolint:gosec
+		}()
+
+		r := &rpc.Request{}
+		require.NoError(t, c.ReadRequestHeader(r))
+		require.NoError(t, c.ReadRequestBody(nil))
+	}
+
+	// the peer is gone, the flush by the timer fails
+	require.NoError(t, rl.Close())
+	require.NoError(t, c.WriteResponse(&rpc.Response{ServiceMethod: "test.Batch", Seq: 1}, "one"))
+	assert.Eventually(t, c.closed.Load, time.Second, time.Millisecond)
+
+	// the codec is failed, every later call returns the error of the flush
+	for range 2 {
+		assert.ErrorContains(t, c.WriteResponse(&rpc.Response{ServiceMethod: "test.Batch", Seq: 2}, "two"), io.ErrClosedPipe.Error())
+		assert.ErrorContains(t, c.FlushBatch(), io.ErrClosedPipe.Error())
+	}
+	assert.Zero(t, c.Stats().FramesOut)
+}
@@ -0,0 +1,31 @@
+package rpc
+
+import (
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+)
+
+// SetBorrowRawPayload enables the borrowed raw bodies: ReadRequestBody points the *[]byte of the Raw codec
+// requests to the received payload instead of appending the payload to it, so the large bodies are not copied.
+// The borrowed slice is valid until the next ReadRequestHeader, it should not be modified, retained or passed
+// to other goroutines after that; copy it, if needed. Note that rpc.Server reads the next request while
+// the method is running, use the borrowed bodies with the codecs driven by own loop (e.g. ReadRequestHeader,
+// ReadRequestBody, WriteResponse in sequence). Should be called before the codec is used.
+func (c *Codec) SetBorrowRawPayload(enabled bool) {
+	c.borrowRaw = enabled
+}
+
+// borrowRaw points the raw out slice to the payload, reports whether the body was borrowed
+func borrowRaw(flags byte, payload []byte, out any) bool {
+	if flags != frame.CodecRaw {
+		return false
+	}
+
+	raw, ok := out.(*[]byte)
+	if !ok {
+		return false
+	}
+
+	// appends to the borrowed slice reallocate it
+	*raw = payload[:len(payload):len(payload)]
+	return true
+}
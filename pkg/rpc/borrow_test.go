@@ -0,0 +1,81 @@
+package rpc
+
+import (
+	"bytes"
+	"net/rpc"
+	"testing"
+
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// payloadRelay is loopRelay which keeps the last received payload
+type payloadRelay struct {
+	loopRelay
+	payload []byte
+}
+
+func (p *payloadRelay) Receive(fr *frame.Frame) error {
+	err := p.loopRelay.Receive(fr)
+	p.payload = fr.Payload()
+	return err
+}
+
+func TestCodec_BorrowRawPayload(t *testing.T) {
+	body := bytes.Repeat([]byte("goridge"), 100)
+	rl := &payloadRelay{loopRelay: loopRelay{req: requestFrame(1, "test.Raw", frame.CodecRaw, body)}}
+	c := NewCodecWithRelay(rl)
+
+	// the body is appended to the slice by default
+	r := &rpc.Request{}
+	require.NoError(t, c.ReadRequestHeader(r))
+	out := []byte("prefix:")
+	require.NoError(t, c.ReadRequestBody(&out))
+	assert.Equal(t, append([]byte("prefix:"), body...), out)
+
+	c.SetBorrowRawPayload(true)
+	for i := 0; i < 2; i++ {
+		require.NoError(t, c.ReadRequestHeader(r))
+		out = []byte("prefix:")
+		require.NoError(t, c.ReadRequestBody(&out))
+
+		// the slice points to the received payload
+		assert.Equal(t, body, out)
+		assert.Same(t, &rl.payload[len("test.Raw")], &out[0])
+		assert.Equal(t, len(out), cap(out))
+	}
+
+	// other codecs are decoded as usual
+	rl.req = requestFrame(2, "test.JSON", frame.CodecJSON, []byte(`"goridge"`))
+	require.NoError(t, c.ReadRequestHeader(r))
+	var s string
+	require.NoError(t, c.ReadRequestBody(&s))
+	assert.Equal(t, "goridge", s)
+}
+
+func BenchmarkCodec_BorrowRawPayload(b *testing.B) {
+	body := bytes.Repeat([]byte{0xAB}, 1024*1024)
+
+	for _, borrowed := range []bool{false, true} {
+		name := "copied"
+		if borrowed {
+			name = "borrowed"
+		}
+
+		b.Run(name, func(b *testing.B) {
+			c := NewCodecWithRelay(&loopRelay{req: requestFrame(1, "test.Raw", frame.CodecRaw, body)})
+			c.SetBorrowRawPayload(borrowed)
+
+			r := &rpc.Request{}
+			b.ReportAllocs()
+			b.SetBytes(int64(len(body)))
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_ = c.ReadRequestHeader(r)
+				var out []byte
+				_ = c.ReadRequestBody(&out)
+			}
+		})
+	}
+}
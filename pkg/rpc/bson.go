@@ -0,0 +1,18 @@
+package rpc
+
+import (
+	"bytes"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// encodeBSON marshals the document (a struct, a map, bson.D or bson.Raw), BSON has no top-level scalars
+func encodeBSON(body any, buf *bytes.Buffer) error {
+	data, err := bson.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	buf.Write(data)
+	return nil
+}
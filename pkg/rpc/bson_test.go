@@ -0,0 +1,87 @@
+package rpc
+
+import (
+	"bytes"
+	"net/rpc"
+	"testing"
+	"time"
+
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/roadrunner-server/goridge/v3/pkg/pipe"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type bsonDocument struct {
+	ID      primitive.ObjectID   `bson:"_id"`
+	Data    primitive.Binary     `bson:"data"`
+	Created primitive.DateTime   `bson:"created"`
+	Price   primitive.Decimal128 `bson:"price"`
+}
+
+func TestCodec_BSON(t *testing.T) {
+	srv, cl := pipe.NewRelayPair()
+	server := NewCodecWithRelay(srv)
+	client := NewClientCodecWithRelay(cl)
+	t.Cleanup(func() {
+		_ = server.Close()
+		_ = client.Close()
+	})
+
+	price, err := primitive.ParseDecimal128("1234.5678901234567890")
+	require.NoError(t, err)
+	doc := bsonDocument{
+		ID:      primitive.NewObjectID(),
+		Data:    primitive.Binary{Subtype: bson.TypeBinaryUUID, Data: []byte("0123456789abcdef")},
+		Created: primitive.NewDateTimeFromTime(time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)),
+		Price:   price,
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- client.WriteRequest(&rpc.Request{ServiceMethod: "test.BSON", Seq: 1}, WithCodec(doc, frame.CodecBSON))
+	}()
+
+	r := &rpc.Request{}
+	require.NoError(t, server.ReadRequestHeader(r))
+	assert.Equal(t, "test.BSON", r.ServiceMethod)
+	assert.Equal(t, frame.CodecBSON, server.frame.ReadFlags())
+
+	in := bsonDocument{}
+	require.NoError(t, server.ReadRequestBody(&in))
+	require.NoError(t, <-errCh)
+	assert.Equal(t, doc, in)
+
+	// the response is encoded with the codec of the request
+	go func() {
+		errCh <- server.WriteResponse(&rpc.Response{ServiceMethod: r.ServiceMethod, Seq: r.Seq}, bson.D{
+			{Key: "data", Value: in.Data},
+			{Key: "created", Value: in.Created},
+		})
+	}()
+
+	resp := &rpc.Response{}
+	require.NoError(t, client.ReadResponseHeader(resp))
+	assert.Equal(t, frame.CodecBSON, client.frame.ReadFlags())
+
+	var out bson.M
+	require.NoError(t, client.ReadResponseBody(&out))
+	require.NoError(t, <-errCh)
+	assert.Equal(t, doc.Data, out["data"])
+	assert.Equal(t, doc.Created, out["created"])
+}
+
+func TestCodec_BSONLookup(t *testing.T) {
+	entry, ok := lookupCodec(frame.CodecBSON)
+	require.True(t, ok)
+	assert.Equal(t, frame.CodecBSON, entry.flag)
+	// BSON has no top-level scalars
+	assert.Error(t, entry.enc.Encode("scalar", &bytes.Buffer{}))
+
+	// the bits of CodecBSON combined with others don't select it
+	entry, ok = lookupCodec(frame.CodecBSON | frame.CodecGob)
+	require.True(t, ok)
+	assert.Equal(t, frame.CodecRaw, entry.flag)
+}
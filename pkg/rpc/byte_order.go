@@ -0,0 +1,55 @@
+package rpc
+
+import (
+	"encoding/binary"
+
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+)
+
+// SetOptionsByteOrder sets the byte order of the header options on the wire: binary.LittleEndian (the default)
+// or binary.BigEndian for the peers reading the options big-endian. The options are converted when the frames
+// are sent and received, including the frames of the batches. Both sides should use the same order, otherwise
+// the sequence IDs and the method lengths are read wrong.
+// Should be called before the codec is used.
+func (c *Codec) SetOptionsByteOrder(order binary.ByteOrder) error {
+	swap, err := swapOptions(order)
+	if err != nil {
+		return err
+	}
+
+	c.swapOptions = swap
+	return nil
+}
+
+// SetOptionsByteOrder sets the byte order of the header options on the wire, see Codec.SetOptionsByteOrder.
+// Should be called before the codec is used.
+func (c *ClientCodec) SetOptionsByteOrder(order binary.ByteOrder) error {
+	swap, err := swapOptions(order)
+	if err != nil {
+		return err
+	}
+
+	c.swapOptions = swap
+	return nil
+}
+
+// swapOptions reports whether the options written little-endian should be swapped for the order
+func swapOptions(order binary.ByteOrder) (bool, error) {
+	const op = errors.Op("goridge_set_options_byte_order")
+	switch order {
+	case binary.LittleEndian:
+		return false, nil
+	case binary.BigEndian:
+		return true, nil
+	default:
+		return false, errors.E(op, errors.Errorf("unsupported byte order: %v", order))
+	}
+}
+
+// swapOrder converts the options of the frame between the little-endian and the wire order
+func swapOrder(fr *frame.Frame, swap bool) {
+	if swap {
+		fr.SwapOptionsOrder(fr.Header())
+	}
+}
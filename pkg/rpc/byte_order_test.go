@@ -0,0 +1,76 @@
+package rpc
+
+import (
+	"encoding/binary"
+	"net/rpc"
+	"testing"
+
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/roadrunner-server/goridge/v3/pkg/pipe"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodec_OptionsByteOrder(t *testing.T) {
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("pair", new(panicService)))
+
+	srv, cl := pipe.NewRelayPair()
+	codec := NewCodecWithRelay(srv)
+	require.NoError(t, codec.SetOptionsByteOrder(binary.BigEndian))
+	// the frames carried by the batches are converted too
+	codec.SetBatching(&BatchConfig{MaxMessages: 2})
+	go func() {
+		_ = ServeConn(server, codec, nil)
+	}()
+
+	cc := NewClientCodecWithRelay(cl)
+	require.NoError(t, cc.SetOptionsByteOrder(binary.BigEndian))
+	client := rpc.NewClientWithCodec(cc)
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	// the sequence IDs and the method lengths survive the round trip
+	for _, msg := range []string{"a", "bb", "ccc"} {
+		var out string
+		require.NoError(t, client.Call("pair.Echo", WithCodec(msg, frame.CodecJSON), &out))
+		assert.Equal(t, msg, out)
+	}
+
+	assert.Error(t, codec.SetOptionsByteOrder(binary.NativeEndian))
+	assert.Error(t, cc.SetOptionsByteOrder(binary.NativeEndian))
+}
+
+func TestCodec_OptionsByteOrderWire(t *testing.T) {
+	c, rl := pipeCodec(t)
+	require.NoError(t, c.SetOptionsByteOrder(binary.BigEndian))
+
+	// the peer writes the options big-endian
+	req := requestFrame(0x01020304, "test.Method", frame.CodecJSON, []byte(`"body"`), OptionTraceID, 7)
+	req.SwapOptionsOrder(req.Header())
+	go func() {
+		assert.NoError(t, rl.Send(req))
+	}()
+
+	r := &rpc.Request{}
+	require.NoError(t, c.ReadRequestHeader(r))
+	assert.Equal(t, uint64(0x01020304), r.Seq)
+	assert.Equal(t, "test.Method", r.ServiceMethod)
+	var body string
+	require.NoError(t, c.ReadRequestBody(&body))
+	assert.Equal(t, "body", body)
+	tid, ok := c.TraceID(r.Seq)
+	assert.True(t, ok)
+	assert.Equal(t, uint32(7), tid)
+
+	go func() {
+		_ = c.WriteResponse(&rpc.Response{ServiceMethod: r.ServiceMethod, Seq: r.Seq}, "ok")
+	}()
+
+	fr := frame.NewFrame()
+	require.NoError(t, rl.Receive(fr))
+	assert.True(t, fr.VerifyCRC(fr.Header()))
+	assert.Equal(t, uint32(0x01020304), binary.BigEndian.Uint32(fr.Header()[12:16]))
+	assert.Equal(t, uint32(len("test.Method")), binary.BigEndian.Uint32(fr.Header()[16:20]))
+}
@@ -0,0 +1,130 @@
+package rpc
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"hash/crc64"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+)
+
+// OptionPayloadChecksum carries the checksum of the frame payload (as sent, after the compression),
+// see SetPayloadChecksum
+const OptionPayloadChecksum uint32 = 8
+
+// Checksum calculates the 32bit checksum of the payload. The header CRC protects only the header,
+// the payload checksum is optional, e.g. for the big transfers over the unreliable transports.
+type Checksum interface {
+	Sum(payload []byte) uint32
+}
+
+// ChecksumFunc is an adapter to use the ordinary functions as the Checksum, e.g. the other hashes truncated to 32 bits.
+type ChecksumFunc func(payload []byte) uint32
+
+func (f ChecksumFunc) Sum(payload []byte) uint32 {
+	return f(payload)
+}
+
+var (
+	// ChecksumIEEE is the CRC32 with the IEEE polynomial, the one used for the header CRC
+	ChecksumIEEE Checksum = ChecksumFunc(crc32.ChecksumIEEE) //nolint:gochecknoglobals
+	// ChecksumCastagnoli is the CRC32C (Castagnoli polynomial), e.g. for the peers using the crc32c libraries
+	ChecksumCastagnoli Checksum = ChecksumFunc(func(payload []byte) uint32 { //nolint:gochecknoglobals
+		return crc32.Checksum(payload, castagnoli)
+	})
+	// ChecksumXXHash is the lower 32 bits of the 64bit xxhash (XXH64, seed 0), the fastest one for the big payloads
+	ChecksumXXHash Checksum = ChecksumFunc(func(payload []byte) uint32 { //nolint:gochecknoglobals
+		return uint32(xxhash.Sum64(payload)) //nolint:gosec
+	})
+	// ChecksumCRC64 is the lower 32 bits of the CRC64 with the ECMA polynomial
+	ChecksumCRC64 Checksum = ChecksumFunc(func(payload []byte) uint32 { //nolint:gochecknoglobals
+		return uint32(crc64.Checksum(payload, ecma)) //nolint:gosec
+	})
+
+	castagnoli = crc32.MakeTable(crc32.Castagnoli) //nolint:gochecknoglobals
+	ecma       = crc64.MakeTable(crc64.ECMA)       //nolint:gochecknoglobals
+)
+
+// ErrPayloadChecksum is returned when the received payload doesn't match its checksum
+var ErrPayloadChecksum = errors.Str("payload checksum mismatch") //nolint:gochecknoglobals
+
+// PayloadChecksumError describes the payload which doesn't match the OptionPayloadChecksum option.
+// It matches ErrPayloadChecksum with errors.Is.
+type PayloadChecksumError struct {
+	// Expected checksum from the option and the Actual one of the received payload
+	Expected uint32
+	Actual   uint32
+	// Missing is set when the frame doesn't carry the OptionPayloadChecksum option
+	Missing bool
+}
+
+func (e *PayloadChecksumError) Error() string {
+	if e.Missing {
+		return fmt.Sprintf("%s: the frame doesn't carry the checksum option", ErrPayloadChecksum.Error())
+	}
+
+	return fmt.Sprintf("%s: expected 0x%08x, actual 0x%08x", ErrPayloadChecksum.Error(), e.Expected, e.Actual)
+}
+
+func (e *PayloadChecksumError) Is(target error) bool {
+	return target == ErrPayloadChecksum
+}
+
+// SetPayloadChecksum enables the payload checksum of the sent responses (OptionPayloadChecksum option) and
+// the verification of the received requests, the client should use the same algorithm. The requests without
+// the option are rejected, as well as the responses without the room for it. Nil disables the checksum.
+// Should be called before the codec is used.
+func (c *Codec) SetPayloadChecksum(cs Checksum) {
+	c.checksum = cs
+}
+
+// SetPayloadChecksum enables the payload checksum of the sent requests and the verification of the received
+// responses, see Codec.SetPayloadChecksum. Should be called before the codec is used.
+func (c *ClientCodec) SetPayloadChecksum(cs Checksum) {
+	c.checksum = cs
+}
+
+// checksumOption reserves the OptionPayloadChecksum option of the response, the value is written
+// by writeChecksum when the payload is ready. Fails if the option doesn't fit into the header.
+func (c *Codec) checksumOption(opts []uint32) ([]uint32, error) {
+	return appendOptions(opts, OptionPayloadChecksum, 0)
+}
+
+// writeChecksum writes the checksum of the payload to the reserved OptionPayloadChecksum option
+func (c *Codec) writeChecksum(fr *frame.Frame) {
+	if c.checksum == nil {
+		return
+	}
+
+	header := fr.Header()
+	opts := fr.ReadOptions(header)
+	for i := 2; i+1 < len(opts); i += 2 {
+		if opts[i] == OptionPayloadChecksum {
+			// options start right after the 12 bytes of the header
+			binary.LittleEndian.PutUint32(header[12+(i+1)*frame.WORD:], c.checksum.Sum(fr.Payload()))
+			return
+		}
+	}
+}
+
+// verifyChecksum verifies the payload against the OptionPayloadChecksum option, the frames without the option
+// are rejected
+func verifyChecksum(cs Checksum, opts []uint32, payload []byte) error {
+	if cs == nil {
+		return nil
+	}
+
+	expected, ok := lookupOption(opts, OptionPayloadChecksum)
+	if !ok {
+		return &PayloadChecksumError{Missing: true}
+	}
+
+	if actual := cs.Sum(payload); actual != expected {
+		return &PayloadChecksumError{Expected: expected, Actual: actual}
+	}
+
+	return nil
+}
@@ -0,0 +1,191 @@
+package rpc
+
+import (
+	"hash/crc64"
+	"net/rpc"
+	"testing"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/roadrunner-server/goridge/v3/pkg/pipe"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodec_PayloadChecksum(t *testing.T) {
+	srv, cl := pipe.NewRelayPair()
+	server := NewCodecWithRelay(srv)
+	client := NewClientCodecWithRelay(cl)
+	server.SetPayloadChecksum(ChecksumCastagnoli)
+	client.SetPayloadChecksum(ChecksumCastagnoli)
+	t.Cleanup(func() {
+		_ = server.Close()
+		_ = client.Close()
+	})
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- client.WriteRequest(&rpc.Request{ServiceMethod: "test.Checksum", Seq: 1}, "ping")
+	}()
+
+	r := &rpc.Request{}
+	require.NoError(t, server.ReadRequestHeader(r))
+	v, ok := lookupOption(append([]uint32{1, 0}, server.RequestOptions(r.Seq)...), OptionPayloadChecksum)
+	require.True(t, ok)
+	var in string
+	require.NoError(t, server.ReadRequestBody(&in))
+	require.NoError(t, <-errCh)
+	assert.Equal(t, "ping", in)
+	assert.NotZero(t, v)
+
+	go func() {
+		errCh <- server.WriteResponse(&rpc.Response{ServiceMethod: r.ServiceMethod, Seq: r.Seq}, "pong")
+	}()
+
+	resp := &rpc.Response{}
+	require.NoError(t, client.ReadResponseHeader(resp))
+	_, ok = lookupOption(append([]uint32{1, 0}, client.ResponseOptions()...), OptionPayloadChecksum)
+	assert.True(t, ok)
+	var out string
+	require.NoError(t, client.ReadResponseBody(&out))
+	require.NoError(t, <-errCh)
+	assert.Equal(t, "pong", out)
+}
+
+func TestCodec_PayloadChecksumMismatch(t *testing.T) {
+	c, rl := pipeCodec(t)
+	c.SetPayloadChecksum(ChecksumCastagnoli)
+
+	// the checksum of another payload
+	body := []byte(`"corrupted"`)
+	sum := ChecksumCastagnoli.Sum([]byte(`test.Method"original"`))
+	go func() {
+		_ = rl.Send(requestFrame(1, "test.Method", frame.CodecJSON, body, OptionPayloadChecksum, sum))
+	}()
+
+	r := &rpc.Request{}
+	require.NoError(t, c.ReadRequestHeader(r))
+	var in string
+	err := c.ReadRequestBody(&in)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), ErrPayloadChecksum.Error())
+
+	// the frames without the option are rejected
+	go func() {
+		_ = rl.Send(requestFrame(2, "test.Method", frame.CodecJSON, body))
+	}()
+
+	require.NoError(t, c.ReadRequestHeader(r))
+	err = c.ReadRequestBody(&in)
+	require.ErrorIs(t, err, ErrPayloadChecksum)
+	assert.Contains(t, err.Error(), "the frame doesn't carry the checksum option")
+}
+
+func TestCodec_PayloadChecksumNoRoom(t *testing.T) {
+	c, rl := pipeCodec(t)
+	c.SetPayloadChecksum(ChecksumCastagnoli)
+
+	// SEQ_ID, METHOD_LEN and 8 options take the whole header
+	require.NoError(t, c.SetResponseOptions(1, 100, 1, 101, 2, 102, 3, 103, 4))
+	err := c.WriteResponse(&rpc.Response{ServiceMethod: "test.Method", Seq: 1}, "pong")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "options don't fit into the header")
+
+	// the optional options give the room to the checksum
+	c.SetEchoTimestamps(true)
+	require.NoError(t, c.SetResponseOptions(2, 100, 1, 101, 2, 102, 3))
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.WriteResponse(&rpc.Response{ServiceMethod: "test.Method", Seq: 2}, "pong")
+	}()
+
+	fr := frame.NewFrame()
+	require.NoError(t, rl.Receive(fr))
+	require.NoError(t, <-errCh)
+	opts := fr.ReadOptions(fr.Header())
+	sum, ok := lookupOption(opts, OptionPayloadChecksum)
+	require.True(t, ok)
+	assert.Equal(t, ChecksumCastagnoli.Sum(fr.Payload()), sum)
+	assert.Len(t, opts, 10)
+}
+
+func TestVerifyChecksum(t *testing.T) {
+	payload := []byte("payload")
+	opts := []uint32{1, 0, OptionPayloadChecksum, ChecksumIEEE.Sum(payload)}
+	require.NoError(t, verifyChecksum(ChecksumIEEE, opts, payload))
+
+	err := verifyChecksum(ChecksumCastagnoli, opts, payload)
+	require.ErrorIs(t, err, ErrPayloadChecksum)
+	var ce *PayloadChecksumError
+	require.ErrorAs(t, err, &ce)
+	assert.Equal(t, ChecksumIEEE.Sum(payload), ce.Expected)
+	assert.Equal(t, ChecksumCastagnoli.Sum(payload), ce.Actual)
+}
+
+func TestChecksumAlgorithms(t *testing.T) {
+	payload := []byte("payload")
+	assert.Equal(t, uint32(xxhash.Sum64(payload)), ChecksumXXHash.Sum(payload))                               //nolint:gosec
+	assert.Equal(t, uint32(crc64.Checksum(payload, crc64.MakeTable(crc64.ECMA))), ChecksumCRC64.Sum(payload)) //nolint:gosec
+	assert.NotEqual(t, ChecksumXXHash.Sum(payload), ChecksumXXHash.Sum([]byte("payloaD")))
+	assert.NotEqual(t, ChecksumCRC64.Sum(payload), ChecksumCRC64.Sum([]byte("payloaD")))
+}
+
+// every optional request option at once doesn't fit into the header, the request fails instead of panicking
+func TestClientCodec_AllOptions(t *testing.T) {
+	srv, cl := pipe.NewRelayPair()
+	client := NewClientCodecWithRelay(cl)
+	client.SetNoMethodPrefix(true)
+	client.SetSendTimestamps(true)
+	client.SetPayloadChecksum(ChecksumXXHash)
+	t.Cleanup(func() {
+		_ = srv.Close()
+		_ = client.Close()
+	})
+
+	err := client.WriteRequest(&rpc.Request{ServiceMethod: "test.Options", Seq: 1}, WithTraceID("ping", 7))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "options don't fit into the header")
+
+	// without the trace ID the options fit
+	server := NewCodecWithRelay(srv)
+	require.NoError(t, server.RegisterMethodID("test.Options"))
+	server.SetPayloadChecksum(ChecksumXXHash)
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- client.WriteRequest(&rpc.Request{ServiceMethod: "test.Options", Seq: 2}, "ping")
+	}()
+
+	r := &rpc.Request{}
+	require.NoError(t, server.ReadRequestHeader(r))
+	assert.Equal(t, uint64(2), r.Seq)
+	var in string
+	require.NoError(t, server.ReadRequestBody(&in))
+	require.NoError(t, <-errCh)
+	assert.Equal(t, "ping", in)
+}
+
+func BenchmarkChecksum(b *testing.B) {
+	algorithms := []struct {
+		name string
+		cs   Checksum
+	}{
+		{"ieee", ChecksumIEEE},
+		{"castagnoli", ChecksumCastagnoli},
+		{"xxhash", ChecksumXXHash},
+		{"crc64", ChecksumCRC64},
+	}
+
+	payload := make([]byte, 1<<20)
+	for i := range payload {
+		payload[i] = byte(i)
+	}
+
+	for _, alg := range algorithms {
+		b.Run(alg.name, func(b *testing.B) {
+			b.SetBytes(int64(len(payload)))
+			for i := 0; i < b.N; i++ {
+				_ = alg.cs.Sum(payload)
+			}
+		})
+	}
+}
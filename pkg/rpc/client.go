@@ -2,18 +2,16 @@ package rpc
 
 import (
 	"bytes"
-	"encoding/gob"
 	"io"
 	"net/rpc"
 	"sync"
+	"sync/atomic"
+	"time"
 
-	"github.com/goccy/go-json"
 	"github.com/roadrunner-server/errors"
 	"github.com/roadrunner-server/goridge/v3/pkg/frame"
 	"github.com/roadrunner-server/goridge/v3/pkg/relay"
 	"github.com/roadrunner-server/goridge/v3/pkg/socket"
-	"github.com/vmihailenco/msgpack/v5"
-	"google.golang.org/protobuf/proto"
 )
 
 // ClientCodec is codec for goridge connection.
@@ -25,6 +23,38 @@ type ClientCodec struct {
 	relay  relay.Relay
 	closed bool
 	frame  *frame.Frame
+	// the header CRC is not written, see SetCRCDisabled
+	crcDisabled bool
+	// JSON implementation, nil - default
+	json JSONCodec
+	// payload checksum of the requests and the responses, nil - disabled
+	checksum Checksum
+	// msgpack encoder and decoder options, nil - default
+	msgpack *MsgpackOptions
+	// codec of the bodies which codec isn't inferred from the type, 0 - the inference is disabled, see SetAutoCodec
+	defaultCodec byte
+	// codecs supported by both sides after Handshake, 0 - no handshake
+	negotiated uint32
+	// serializes RoundTrip calls and the last sequence sent by RoundTrip
+	roundTripMu  sync.Mutex
+	roundTripSeq atomic.Uint64
+	// reason sent by the server before closing the connection
+	closeReason atomic.Pointer[CloseReason]
+	// send the timestamp options in the requests
+	sendTimestamps bool
+	// send the method ID option instead of the method prefix
+	noPrefix bool
+	// frames of the uncommitted transactions by the transaction ID and the frames of the committed ones
+	txPending map[uint32][]*frame.Frame
+	txReady   []*frame.Frame
+	// frames of the received batch not delivered yet
+	batchReady []*frame.Frame
+	// handler of the frames pushed by the server, nil - the pushes are dropped
+	push PushHandler
+	// the options are big-endian on the wire, see SetOptionsByteOrder
+	swapOptions bool
+	// size limit of the decompressed responses, 0 - unlimited
+	decompressLimit int
 }
 
 // NewClientCodec initiates new server rpc codec over socket connection.
@@ -38,7 +68,24 @@ func NewClientCodec(rwc io.ReadWriteCloser) *ClientCodec {
 			return frame.NewFrame()
 		}},
 
-		relay: socket.NewSocketRelay(rwc),
+		relay:           socket.NewSocketRelay(rwc),
+		decompressLimit: DefaultDecompressionLimit,
+	}
+}
+
+// NewClientCodecWithRelay initiates new client rpc codec over the relay, e.g. pipe.NewRelayPair in tests.
+func NewClientCodecWithRelay(relay relay.Relay) *ClientCodec {
+	return &ClientCodec{
+		bPool: sync.Pool{New: func() any {
+			return new(bytes.Buffer)
+		}},
+
+		fPool: sync.Pool{New: func() any {
+			return frame.NewFrame()
+		}},
+
+		relay:           relay,
+		decompressLimit: DefaultDecompressionLimit,
 	}
 }
 
@@ -72,73 +119,128 @@ func (c *ClientCodec) WriteRequest(r *rpc.Request, body any) error {
 	buf := c.get()
 	defer c.put(buf)
 
-	// writeServiceMethod to the buffer
-	buf.WriteString(r.ServiceMethod)
-	// use fallback as gob
-	fr.WriteFlags(fr.Header(), frame.CodecGob)
+	// writeServiceMethod to the buffer, unless it's sent as the method ID
+	methodLen := len(r.ServiceMethod)
+	if c.noPrefix {
+		methodLen = 0
+		fr.SetNoMethodPrefix(fr.Header())
+	} else {
+		buf.WriteString(r.ServiceMethod)
+	}
+	traceID, traced := requestTraceID(body)
+	// the codec is inferred from the body type, see WithCodec and SetAutoCodec
+	codec, body := c.requestCodec(body)
+	fr.WriteFlags(fr.Header(), codec)
 
 	if body != nil {
-		// if body is proto message, use proto codec
-		switch m := body.(type) {
-		// check if message is PROTO
-		case proto.Message:
-			fr.WriteFlags(fr.Header(), frame.CodecProto)
-			b, err := proto.Marshal(m)
-			if err != nil {
-				return errors.E(op, err)
-			}
-			buf.Write(b)
-		default:
-			enc := gob.NewEncoder(buf)
-			// write data to the gob
-			err := enc.Encode(body)
-			if err != nil {
-				return errors.E(op, err)
-			}
+		entry, ok := lookupCodecFor(codec, c.json, c.msgpack)
+		if !ok {
+			return errors.E(op, errors.Errorf("unknown codec: %d", codec))
+		}
+
+		err := entry.enc.Encode(body, buf)
+		if err != nil {
+			return errors.E(op, err)
 		}
 	}
 
-	// SEQ_ID + METHOD_NAME_LEN
-	fr.WriteOptions(fr.HeaderPtr(), uint32(r.Seq), uint32(len(r.ServiceMethod)))
+	// SEQ_ID + METHOD_NAME_LEN + extra options
+	if c.noPrefix || c.sendTimestamps || traced || c.checksum != nil {
+		opts := []uint32{uint32(r.Seq), uint32(methodLen)} //nolint:gosec
+		var err error
+		if c.noPrefix {
+			opts, err = appendOptions(opts, OptionMethodID, MethodID(r.ServiceMethod))
+		}
+		if traced && err == nil {
+			opts, err = appendOptions(opts, OptionTraceID, traceID)
+		}
+		if c.sendTimestamps && err == nil {
+			opts, err = appendOptions(opts, TimestampOptions(time.Now())...)
+		}
+		if c.checksum != nil && err == nil {
+			opts, err = appendOptions(opts, OptionPayloadChecksum, c.checksum.Sum(buf.Bytes()))
+		}
+		if err != nil {
+			return errors.E(op, err)
+		}
+		fr.WriteOptions(fr.HeaderPtr(), opts...)
+	} else {
+		fr.WriteOptions(fr.HeaderPtr(), uint32(r.Seq), uint32(len(r.ServiceMethod)))
+	}
 	fr.WriteVersion(fr.Header(), frame.Version1)
 
 	fr.WritePayloadLen(fr.Header(), uint32(buf.Len()))
-	fr.WritePayload(buf.Bytes())
-	fr.WriteCRC(fr.Header())
+	// the frame is sent before the buffer is returned to the pool, so the buffer is not copied
+	fr.SetPayload(buf.Bytes())
+	writeCRC(fr, c.crcDisabled)
 
-	err := c.relay.Send(fr)
+	err := c.send(fr)
 	if err != nil {
 		return errors.E(op, err)
 	}
 	return nil
 }
 
+// send sends the frame to the relay, the buffered relays (like socket.BufferedRelay) are flushed after every frame,
+// otherwise the small requests would wait in the buffer for the response forever
+func (c *ClientCodec) send(fr *frame.Frame) error {
+	swapOrder(fr, c.swapOptions)
+	err := c.relay.Send(fr)
+	if err != nil {
+		return err
+	}
+
+	type flusher interface {
+		Flush() error
+	}
+
+	if f, ok := c.relay.(flusher); ok {
+		return f.Flush()
+	}
+
+	return nil
+}
+
 // ReadResponseHeader reads response from the connection.
 func (c *ClientCodec) ReadResponseHeader(r *rpc.Response) error {
 	const op = errors.Op("client_read_response_header")
 
-	// get a frame from sync.Pool
-	fr := c.getFrame()
-
-	err := c.relay.Receive(fr)
+	fr, err := c.receiveResponse()
 	if err != nil {
-		return errors.E(op, err)
+		return err
 	}
-	if !fr.VerifyCRC(fr.Header()) {
-		return errors.E(op, errors.Str("CRC verification failed"))
+
+	// the server closes the connection, net/rpc treats io.EOF as a clean shutdown
+	if fr.IsClose(fr.Header()) {
+		c.closeReason.Store(readCloseReason(fr))
+		c.putFrame(fr)
+		return io.EOF
 	}
 
 	// save the frame after CRC verification
 	c.frame = fr
 
 	opts := fr.ReadOptions(fr.Header())
-	if len(opts) != 2 {
-		return errors.E(op, errors.Str("should be 2 options. SEQ_ID and METHOD_LEN"))
+	if len(opts) < 2 {
+		return errors.E(op, errors.Str("should be at least 2 options. SEQ_ID and METHOD_LEN"))
+	}
+	if int(opts[1]) > len(fr.Payload()) {
+		return errors.E(op, errors.Str("method name offset is out of the payload bounds"))
 	}
 
 	// check for error
-	if fr.ReadFlags()&frame.ERROR != 0 {
+	if fr.Flags().IsError() {
 		r.Error = string(fr.Payload()[opts[1]:])
+		if fr.IsStructuredError(fr.Header()) {
+			if e, ok := c.readStructuredError(fr, fr.Payload()[opts[1]:]); ok {
+				r.Error = e
+			}
+		}
+		if fr.IsErrorBody(fr.Header()) {
+			if e, ok := c.readErrorBody(); ok {
+				r.Error = e
+			}
+		}
 	}
 
 	r.Seq = uint64(opts[0])
@@ -147,6 +249,40 @@ func (c *ClientCodec) ReadResponseHeader(r *rpc.Response) error {
 	return nil
 }
 
+// receiveResponse receives the next frame which is not pushed by the server, the pushes are passed
+// to the push handler
+func (c *ClientCodec) receiveResponse() (*frame.Frame, error) {
+	const op = errors.Op("client_read_response_header")
+
+	for {
+		// the frames of the transactions are delivered after the commit marker
+		fr, err := c.receiveTx()
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+		// frames without CRC are accepted only by the relays with the frame.CRCTrusted policy
+		if !fr.IsCRCDisabled(fr.Header()) && !fr.VerifyCRC(fr.Header()) {
+			return nil, errors.E(op, errors.Str("CRC verification failed"))
+		}
+
+		err = checkVersion(fr)
+		if err != nil {
+			c.putFrame(fr)
+			return nil, err
+		}
+
+		if !fr.IsPush(fr.Header()) {
+			return fr, nil
+		}
+
+		err = c.handlePush(fr)
+		c.putFrame(fr)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+	}
+}
+
 // ReadResponseBody response from the connection.
 func (c *ClientCodec) ReadResponseBody(out any) error {
 	const op = errors.Op("client_read_response_body")
@@ -158,91 +294,103 @@ func (c *ClientCodec) ReadResponseBody(out any) error {
 		return nil
 	}
 
-	flags := c.frame.ReadFlags()
+	// the checksum covers the whole payload as sent, the error is matched with errors.Is
+	err := verifyChecksum(c.checksum, c.frame.ReadOptions(c.frame.Header()), c.frame.Payload())
+	if err != nil {
+		return err
+	}
 
-	switch { //nolint:dupl
-	case flags&frame.CodecProto != 0:
-		opts := c.frame.ReadOptions(c.frame.Header())
-		if len(opts) != 2 {
-			return errors.E(op, errors.Str("should be 2 options. SEQ_ID and METHOD_LEN"))
-		}
-		payload := c.frame.Payload()[opts[1]:]
-		if len(payload) == 0 {
-			return nil
-		}
+	// the decompression errors are matched with errors.Is
+	payload, err := c.payload()
+	if err != nil {
+		return err
+	}
 
-		// check if the out message is a correct proto.Message
-		// instead send an error
-		if pOut, ok := out.(proto.Message); ok {
-			err := proto.Unmarshal(payload, pOut)
-			if err != nil {
-				return errors.E(op, err)
-			}
-			return nil
+	flags := c.frame.ReadFlags()
+	// the array elements are decoded by the caller one by one
+	if stream, ok := out.(*MsgpackStream); ok {
+		err = stream.reset(flags, payload, c.msgpack)
+		if err != nil {
+			return errors.E(op, err)
 		}
+		return nil
+	}
 
-		return errors.E(op, errors.Str("message type is not a proto"))
-	case flags&frame.CodecJSON != 0:
-		opts := c.frame.ReadOptions(c.frame.Header())
-		if len(opts) != 2 {
-			return errors.E(op, errors.Str("should be 2 options. SEQ_ID and METHOD_LEN"))
-		}
-		payload := c.frame.Payload()[opts[1]:]
-		if len(payload) == 0 {
-			return nil
-		}
-		return json.Unmarshal(payload, out)
-	case flags&frame.CodecGob != 0:
-		opts := c.frame.ReadOptions(c.frame.Header())
-		if len(opts) != 2 {
-			return errors.E(op, errors.Str("should be 2 options. SEQ_ID and METHOD_LEN"))
-		}
-		payload := c.frame.Payload()[opts[1]:]
-		if len(payload) == 0 {
-			return nil
-		}
+	entry, ok := lookupCodecFor(flags, c.json, c.msgpack)
+	if !ok {
+		return errors.E(op, errors.Str("unknown decoder used in frame"))
+	}
 
-		buf := c.get()
-		defer c.put(buf)
+	if len(payload) == 0 {
+		return nil
+	}
 
-		dec := gob.NewDecoder(buf)
-		buf.Write(payload)
+	err = entry.dec.Decode(payload, out)
+	if err != nil {
+		return errors.E(op, err)
+	}
 
-		err := dec.Decode(out)
-		if err != nil {
-			return errors.E(op, err)
-		}
+	return nil
+}
 
+// ReadResponseBodyWith reads the response body with a custom decode function instead of the codec from the frame flags.
+// decode receives the response payload without the service method prefix.
+func (c *ClientCodec) ReadResponseBodyWith(out any, decode func([]byte, any) error) error {
+	const op = errors.Op("client_read_response_body_with")
+
+	// put frame after response was sent
+	defer c.putFrame(c.frame)
+	// if there is no out interface to unmarshall the body, skip
+	if out == nil {
 		return nil
-	case flags&frame.CodecRaw != 0:
-		opts := c.frame.ReadOptions(c.frame.Header())
-		if len(opts) != 2 {
-			return errors.E(op, errors.Str("should be 2 options. SEQ_ID and METHOD_LEN"))
-		}
-		payload := c.frame.Payload()[opts[1]:]
-		if len(payload) == 0 {
-			return nil
-		}
+	}
 
-		if raw, ok := out.(*[]byte); ok {
-			*raw = append(*raw, payload...)
-		}
+	// the decompression errors are matched with errors.Is
+	payload, err := c.payload()
+	if err != nil {
+		return err
+	}
+
+	err = decode(payload, out)
+	if err != nil {
+		return errors.E(op, err)
+	}
 
+	return nil
+}
+
+// ResponseOptions returns the options of the response following SEQ_ID and METHOD_LEN, if any.
+// Should be called between ReadResponseHeader and ReadResponseBody.
+func (c *ClientCodec) ResponseOptions() []uint32 {
+	if c.frame == nil {
 		return nil
-	case flags&frame.CodecMsgpack != 0:
-		opts := c.frame.ReadOptions(c.frame.Header())
-		if len(opts) != 2 {
-			return errors.E(op, errors.Str("should be 2 options. SEQ_ID and METHOD_LEN"))
-		}
-		payload := c.frame.Payload()[opts[1]:]
-		if len(payload) == 0 {
-			return nil
-		}
+	}
 
-		return msgpack.Unmarshal(payload, out)
-	default:
-		return errors.E(op, errors.Str("unknown decoder used in frame"))
+	opts := c.frame.ReadOptions(c.frame.Header())
+	if len(opts) <= 2 {
+		return nil
 	}
+
+	return opts[2:]
+}
+
+// payload returns the received frame payload without the service method prefix, decompressed if needed
+func (c *ClientCodec) payload() ([]byte, error) {
+	const op = errors.Op("client_read_response_payload")
+	opts := c.frame.ReadOptions(c.frame.Header())
+	if len(opts) < 2 {
+		return nil, errors.E(op, errors.Str("should be at least 2 options. SEQ_ID and METHOD_LEN"))
+	}
+	if int(opts[1]) > len(c.frame.Payload()) {
+		return nil, errors.E(op, errors.Str("method name offset is out of the payload bounds"))
+	}
+
+	payload := c.frame.Payload()[opts[1]:]
+	if algorithm := c.frame.ReadCompression(c.frame.Header()); algorithm != 0 && len(payload) > 0 {
+		return decompress(algorithm, payload, c.decompressLimit)
+	}
+
+	return payload, nil
 }
 
 // Close closes the client connection.
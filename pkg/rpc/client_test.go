@@ -0,0 +1,93 @@
+package rpc
+
+import (
+	"net"
+	"net/rpc"
+	"strings"
+	"testing"
+
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/roadrunner-server/goridge/v3/pkg/pipe"
+	"github.com/roadrunner-server/goridge/v3/pkg/socket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pipeClientCodec returns ClientCodec with a frame ready to be read as a response
+func pipeClientCodec(t *testing.T, fr *frame.Frame) *ClientCodec {
+	conn, _ := net.Pipe()
+	c := NewClientCodec(conn)
+	c.frame = fr
+
+	t.Cleanup(func() {
+		_ = c.Close()
+	})
+
+	return c
+}
+
+func TestClientCodec_ReadResponseBodyWith(t *testing.T) {
+	c := pipeClientCodec(t, requestFrame(1, "test.Method", frame.CodecRaw, []byte("key=value")))
+
+	var got []byte
+	out := map[string]string{}
+	require.NoError(t, c.ReadResponseBodyWith(out, func(data []byte, v any) error {
+		got = append(got, data...)
+		kv := strings.SplitN(string(data), "=", 2)
+		v.(map[string]string)[kv[0]] = kv[1]
+		return nil
+	}))
+
+	assert.Equal(t, []byte("key=value"), got)
+	assert.Equal(t, "value", out["key"])
+
+	c = pipeClientCodec(t, requestFrame(1, "test.Method", frame.CodecRaw, []byte("key=value")))
+	assert.Error(t, c.ReadResponseBodyWith(out, func([]byte, any) error {
+		return errors.Str("decode error")
+	}))
+}
+
+func TestClientCodec_RelayPair(t *testing.T) {
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("pair", new(panicService)))
+
+	srv, cl := pipe.NewRelayPair()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- ServeConn(server, NewCodecWithRelay(srv), nil)
+	}()
+
+	client := rpc.NewClientWithCodec(NewClientCodecWithRelay(cl))
+
+	var out string
+	require.NoError(t, client.Call("pair.Echo", "in memory", &out))
+	assert.Equal(t, "in memory", out)
+
+	require.NoError(t, client.Close())
+	require.NoError(t, <-done)
+}
+
+// the small requests don't wait in the buffer of the client relay
+func TestClientCodec_BufferedRelay(t *testing.T) {
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("pair", new(panicService)))
+
+	serverConn, clientConn := net.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- ServeConn(server, NewCodecWithRelay(socket.NewBufferedSocketRelay(serverConn, 0)), nil)
+	}()
+
+	client := rpc.NewClientWithCodec(NewClientCodecWithRelay(socket.NewBufferedSocketRelay(clientConn, 0)))
+
+	for _, msg := range []string{"a", "bb", "ccc"} {
+		var out string
+		require.NoError(t, client.Call("pair.Echo", msg, &out))
+		assert.Equal(t, msg, out)
+	}
+
+	require.NoError(t, client.Close())
+	require.NoError(t, <-done)
+}
@@ -0,0 +1,93 @@
+package rpc
+
+import (
+	stderr "errors"
+	"io"
+	"time"
+
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+)
+
+// ErrCodecClosing is returned by ReadRequestHeader after CloseGraceful was called
+var ErrCodecClosing = errors.Str("codec is closing") //nolint:gochecknoglobals
+
+// drainInterval is how often CloseGraceful checks the in-flight sequences
+const drainInterval = time.Millisecond * 10
+
+// CloseGraceful stops accepting new requests (ReadRequestHeader returns ErrCodecClosing), waits until the responses
+// for all in-flight sequences are written and closes the codec. If the sequences are not drained within the timeout,
+// the codec is closed anyway and the error with the errors.TimeOut kind is returned.
+func (c *Codec) CloseGraceful(timeout time.Duration) error {
+	const op = errors.Op("goridge_close_graceful")
+	c.draining.Store(true)
+
+	deadline := time.Now().Add(timeout)
+	for c.inFlightSequences() > 0 {
+		if !time.Now().Before(deadline) {
+			_ = c.Close()
+			return errors.E(op, errors.TimeOut, errors.Errorf("%d in-flight sequences were not drained", c.inFlightSequences()))
+		}
+
+		time.Sleep(drainInterval)
+	}
+
+	return c.Close()
+}
+
+// inFlightSequences returns the number of sequences waiting for the response
+func (c *Codec) inFlightSequences() int {
+	n := 0
+	c.codec.Range(func(_, _ any) bool {
+		n++
+		return true
+	})
+
+	return n
+}
+
+// DrainAndClose stops accepting new requests, reads and discards the frames the peer is still sending until it closes
+// the connection (clean io.EOF) or the timeout expires, and closes the codec. The peer finishes sending instead of
+// getting the connection reset. Should not be called concurrently with ReadRequestHeader. If the peer is still
+// sending after the timeout, the codec is closed anyway and the error with the errors.TimeOut kind is returned.
+func (c *Codec) DrainAndClose(timeout time.Duration) error {
+	const op = errors.Op("goridge_drain_and_close")
+	c.draining.Store(true)
+
+	done := make(chan error, 1)
+	go func() {
+		fr := frame.NewFrame()
+		for {
+			// the socket and pipe relays discard the frames with the regular receive path
+			err := c.relay.Receive(fr)
+			if err != nil {
+				done <- err
+				return
+			}
+
+			c.stats.framesIn.Add(1)
+			c.stats.bytesIn.Add(uint64(len(fr.Header()) + len(fr.Payload())))
+			fr.Reset()
+		}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-done:
+		errC := c.Close()
+		if !stderr.Is(err, io.EOF) {
+			return errors.E(op, err)
+		}
+		if errC != nil {
+			return errors.E(op, errC)
+		}
+
+		return nil
+	case <-timer.C:
+		// closing the relay unblocks the receive
+		_ = c.Close()
+		return errors.E(op, errors.TimeOut, errors.Errorf("the peer was still sending after %s", timeout))
+	}
+}
@@ -0,0 +1,109 @@
+package rpc
+
+import (
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/roadrunner-server/goridge/v3/pkg/relay"
+)
+
+// CloseCode describes why the peer closed the connection
+type CloseCode uint32
+
+const (
+	// CloseNormal - the work is done
+	CloseNormal CloseCode = iota
+	// CloseShuttingDown - the process is shutting down
+	CloseShuttingDown
+	// CloseProtocolError - the peer violated the protocol
+	CloseProtocolError
+	// CloseIdleTimeout - the connection was idle for too long
+	CloseIdleTimeout
+)
+
+// CloseReason is sent by the peer with CloseWithReason before closing the connection
+type CloseReason struct {
+	Code    CloseCode
+	Message string
+}
+
+// CloseWithReason sends the close reason frame and closes the codec. The peer codec stops reading with io.EOF
+// and exposes the reason with CloseReason.
+func (c *Codec) CloseWithReason(code CloseCode, message string) error {
+	const op = errors.Op("goridge_close_with_reason")
+
+	c.sendMu.Lock()
+	// the batched responses are sent before the close reason
+	err := c.flushBatchLocked()
+	if err == nil {
+		err = sendCloseReason(c.relay, code, message, c.swapOptions)
+	}
+	c.sendMu.Unlock()
+	if err != nil {
+		c.stats.errors.Add(1)
+		_ = c.Close()
+		return errors.E(op, err)
+	}
+
+	c.stats.framesOut.Add(1)
+	return c.Close()
+}
+
+// CloseReason returns the reason sent by the peer before it closed the connection, nil if there was none.
+func (c *Codec) CloseReason() *CloseReason {
+	return c.closeReason.Load()
+}
+
+// CloseWithReason sends the close reason frame and closes the client codec.
+func (c *ClientCodec) CloseWithReason(code CloseCode, message string) error {
+	const op = errors.Op("goridge_client_close_with_reason")
+
+	err := sendCloseReason(c.relay, code, message, c.swapOptions)
+	if err != nil {
+		_ = c.Close()
+		return errors.E(op, err)
+	}
+
+	return c.Close()
+}
+
+// CloseReason returns the reason sent by the server before it closed the connection, nil if there was none.
+func (c *ClientCodec) CloseReason() *CloseReason {
+	return c.closeReason.Load()
+}
+
+func sendCloseReason(rl relay.Relay, code CloseCode, message string, swap bool) error {
+	fr := frame.NewFrame()
+	fr.WriteVersion(fr.Header(), frame.Version1)
+	fr.WriteFlags(fr.Header(), frame.CONTROL)
+	fr.SetCloseBit(fr.Header())
+	fr.WriteOptions(fr.HeaderPtr(), uint32(code))
+	fr.WritePayloadLen(fr.Header(), uint32(len(message))) //nolint:gosec
+	fr.WritePayload([]byte(message))
+	fr.WriteCRC(fr.Header())
+	swapOrder(fr, swap)
+
+	// the frame should reach the peer before the connection is closed
+	type flusher interface {
+		Flush() error
+	}
+
+	err := rl.Send(fr)
+	if err != nil {
+		return err
+	}
+	if f, ok := rl.(flusher); ok {
+		return f.Flush()
+	}
+
+	return nil
+}
+
+// readCloseReason parses the close reason frame
+func readCloseReason(fr *frame.Frame) *CloseReason {
+	reason := &CloseReason{Message: string(fr.Payload())}
+	if opts := fr.ReadOptions(fr.Header()); len(opts) > 0 {
+		reason.Code = CloseCode(opts[0])
+	}
+
+	return reason
+}
@@ -0,0 +1,48 @@
+package rpc
+
+import (
+	"io"
+	"net/rpc"
+	"testing"
+
+	"github.com/roadrunner-server/goridge/v3/pkg/pipe"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodec_CloseReason(t *testing.T) {
+	c, rl := pipeCodec(t)
+	assert.Nil(t, c.CloseReason())
+
+	go func() {
+		_ = sendCloseReason(rl, CloseIdleTimeout, "idle timeout", false)
+		_ = rl.Close()
+	}()
+
+	err := c.ReadRequestHeader(&rpc.Request{})
+	assert.ErrorIs(t, err, io.EOF)
+
+	require.NoError(t, c.Close())
+	require.NotNil(t, c.CloseReason())
+	assert.Equal(t, CloseReason{Code: CloseIdleTimeout, Message: "idle timeout"}, *c.CloseReason())
+}
+
+func TestClientCodec_CloseReason(t *testing.T) {
+	srv, cl := pipe.NewRelayPair()
+	server := NewCodecWithRelay(srv)
+	client := NewClientCodecWithRelay(cl)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.CloseWithReason(CloseShuttingDown, "shutting down")
+	}()
+
+	err := client.ReadResponseHeader(&rpc.Response{})
+	assert.ErrorIs(t, err, io.EOF)
+	require.NoError(t, <-errCh)
+	require.NoError(t, client.Close())
+
+	require.NotNil(t, client.CloseReason())
+	assert.Equal(t, CloseShuttingDown, client.CloseReason().Code)
+	assert.Equal(t, "shutting down", client.CloseReason().Message)
+}
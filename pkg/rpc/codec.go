@@ -2,91 +2,270 @@ package rpc
 
 import (
 	"bytes"
-	"encoding/gob"
+	"context"
 	stderr "errors"
 	"io"
 	"net/rpc"
 	"sync"
+	"sync/atomic"
+	"time"
 
-	"github.com/goccy/go-json"
 	"github.com/roadrunner-server/errors"
 	"github.com/roadrunner-server/goridge/v3/pkg/frame"
 	"github.com/roadrunner-server/goridge/v3/pkg/relay"
 	"github.com/roadrunner-server/goridge/v3/pkg/socket"
-	"github.com/vmihailenco/msgpack/v5"
 	"google.golang.org/protobuf/proto"
 )
 
 // Codec represent net/rpc bridge over Goridge socket relay.
 type Codec struct {
 	relay  relay.Relay
-	closed bool
+	closed atomic.Bool
 	frame  *frame.Frame
 	codec  sync.Map
-
-	bPool sync.Pool
-	fPool sync.Pool
+	// serializes the frames sent by the concurrent WriteResponse calls
+	sendMu sync.Mutex
+	// the header CRC is not written, see SetCRCDisabled
+	crcDisabled bool
+
+	// buffers and frames, might be shared with other codecs
+	pool *Pool
+
+	// compression algorithm for the responses, 0 - disabled
+	compression          byte
+	compressionThreshold int
+	// minimal savings in percents, otherwise the body is sent uncompressed
+	compressionMinSavings int
+
+	// protocol lifecycle events, nil - disabled
+	sink func(Event)
+	// read timeout for every received frame, 0 - no timeout
+	readTimeout time.Duration
+	// size limit of the received frames, 0 - unlimited
+	readLimit int
+	// write timeout for every sent frame, 0 - no timeout
+	writeTimeout time.Duration
+	// max body size of a single frame in WriteStream
+	streamChunkSize int
+	// slots of the requests awaiting the response, nil - unlimited
+	inFlight chan struct{}
+	// lifetime of the sequences awaiting the response, 0 - unlimited, the read time of the sequences
+	// (unix nanoseconds) and the time of the last eviction
+	seqTTL     time.Duration
+	seqStarted sync.Map
+	lastExpire atomic.Int64
+	// eviction time of the expired sequences (unix nanoseconds), their late responses are dropped
+	seqExpired sync.Map
+	// JSON implementation, nil - default
+	json JSONCodec
+	// msgpack encoder and decoder options, nil - default
+	msgpack *MsgpackOptions
+	// frames and bytes counters
+	stats stats
+	// request hook, nil - disabled, and the response callbacks by the sequence ID
+	hook  RequestHook
+	hooks sync.Map
+	// protocol version of the written frames, 0 - frame.Version1
+	version byte
+	// request types for the *any placeholders, nil - disabled
+	types *TypeRegistry
+	// pool of the proto request messages, nil - disabled, and the pooled messages by the sequence ID
+	// (a map, not sync.Map, to not allocate per request)
+	protoPool ProtoPool
+	pooledMu  sync.Mutex
+	pooled    map[uint64]proto.Message
+	// message types of the proto requests without the registered types, nil - disabled
+	protoResolver ProtoResolver
+	// decoders tried when the flagged codec fails, nil - disabled
+	fallback *FallbackConfig
+	// extra options (after SEQ_ID and METHOD_LEN) of the requests and responses by the sequence ID
+	reqOpts  sync.Map
+	respOpts sync.Map
+	// contexts of the requests by the sequence ID, see SetRequestContexts
+	requestContexts bool
+	reqCtx          sync.Map
+	// set by CloseGraceful, new requests are rejected
+	draining atomic.Bool
+	// reason sent by the peer before closing the connection
+	closeReason atomic.Pointer[CloseReason]
+	// echo the request timestamp options in the responses
+	echoTimestamps bool
+	// copy the request trace ID to the response
+	propagateTrace bool
+	// point the raw request bodies to the received payload instead of copying
+	borrowRaw bool
+	// payload checksum of the responses and the requests, nil - disabled
+	checksum Checksum
+	// responses in the order of the requests, nil - disabled
+	ordered *ordered
+	// responses waiting to be sent in one frame, nil - disabled
+	batch *batch
+	// codecs supported by both sides after Handshake, 0 - no handshake
+	negotiated uint32
+	// sequences of the requests without the method prefix and the registered method IDs
+	noPrefix  sync.Map
+	methodIDs sync.Map
+	// the options are big-endian on the wire, see SetOptionsByteOrder
+	swapOptions bool
+	// the last push sequence counted down from math.MaxUint32, see Push
+	pushSeq atomic.Uint32
+	// payload of the error responses, nil - the error string
+	errorEncoder ErrorEncoder
+	// response for the methods not in the known methods, nil - the net/rpc error, and the methods
+	// of the unknown requests by the sequence ID
+	unknownMethod UnknownMethodHandler
+	knownMethods  map[string]struct{}
+	unknownSeqs   sync.Map
+	// open transaction, ID of the open transaction (0 - none) and the last transaction ID
+	txMu   sync.Mutex
+	tx     *tx
+	txOpen atomic.Uint32
+	txSeq  atomic.Uint32
 }
 
 // NewCodec initiates new server rpc codec over socket connection.
 func NewCodec(rwc io.ReadWriteCloser) *Codec {
+	return newCodec(socket.NewSocketRelay(rwc), NewPool())
+}
+
+// NewCodecWithPool initiates new server rpc codec over socket connection, which takes the buffers and frames
+// from the pool shared with other codecs.
+func NewCodecWithPool(rwc io.ReadWriteCloser, pool *Pool) *Codec {
+	return newCodec(socket.NewSocketRelay(rwc), pool)
+}
+
+// NewCodecWithRelay initiates new server rpc codec with a relay of choice.
+func NewCodecWithRelay(relay relay.Relay) *Codec {
+	return newCodec(relay, NewPool())
+}
+
+func newCodec(relay relay.Relay, pool *Pool) *Codec {
+	setReadLimit(relay, DefaultReadLimit)
 	return &Codec{
-		relay: socket.NewSocketRelay(rwc),
-		codec: sync.Map{},
+		relay:     relay,
+		codec:     sync.Map{},
+		pool:      pool,
+		readLimit: DefaultReadLimit,
+	}
+}
 
-		bPool: sync.Pool{New: func() any {
-			return new(bytes.Buffer)
-		}},
+// SetCompression enables compression of the response bodies bigger than threshold (in bytes) and the decompression
+// of the compressed requests, the requests decompressed to more than the read limit (see SetReadLimit) are rejected
+// with frame.FrameTooLargeError. Without the compression the compressed requests are rejected with
+// ErrCompressionDisabled. Algorithm should be frame.CompressedGzip or frame.CompressedZstd, 0 disables the compression.
+// Should be called before the codec is used.
+func (c *Codec) SetCompression(algorithm byte, threshold int) error {
+	const op = errors.Op("goridge_set_compression")
+	switch algorithm {
+	case 0, frame.CompressedGzip, frame.CompressedZstd:
+	default:
+		return errors.E(op, errors.Errorf("unknown compression algorithm: %d", algorithm))
+	}
+
+	c.compression = algorithm
+	c.compressionThreshold = threshold
+	return nil
+}
 
-		fPool: sync.Pool{New: func() any {
-			return frame.NewFrame()
-		}},
+// SetCompressionMinSavings sets how many percents (0-99) the compressed body should be smaller than the original.
+// If compression doesn't save enough, the body is sent uncompressed. Default 0 - compressed body should not be bigger.
+func (c *Codec) SetCompressionMinSavings(percent int) error {
+	const op = errors.Op("goridge_set_compression_min_savings")
+	if percent < 0 || percent > 99 {
+		return errors.E(op, errors.Errorf("savings should be in the 0-99 range, got: %d", percent))
 	}
+
+	c.compressionMinSavings = percent
+	return nil
 }
 
-// NewCodecWithRelay initiates new server rpc codec with a relay of choice.
-func NewCodecWithRelay(relay relay.Relay) *Codec {
-	return &Codec{relay: relay}
+// SetReadTimeout sets the timeout applied to every ReadRequestHeader call, 0 disables the timeout.
+// The relay should support read deadlines (like the socket relay over net.Conn), otherwise the timeout is ignored.
+// When the timeout fires, the returned error has the errors.TimeOut kind.
+func (c *Codec) SetReadTimeout(timeout time.Duration) {
+	c.readTimeout = timeout
+}
+
+// SetMaxInFlight limits the number of requests read and awaiting the response on the connection, 0 - unlimited.
+// When the limit is reached, ReadRequestHeader blocks until WriteResponse frees a slot.
+// Should be called before the codec is used.
+func (c *Codec) SetMaxInFlight(limit int) error {
+	const op = errors.Op("goridge_set_max_in_flight")
+	if limit < 0 {
+		return errors.E(op, errors.Errorf("limit should not be negative, got: %d", limit))
+	}
+
+	c.inFlight = nil
+	if limit > 0 {
+		c.inFlight = make(chan struct{}, limit)
+	}
+
+	return nil
+}
+
+// release frees the in-flight slot taken in ReadRequestHeaderCtx
+func (c *Codec) release() {
+	if c.inFlight != nil {
+		<-c.inFlight
+	}
+}
+
+// SetProtocolVersion sets the protocol version of all frames written by the codec.
+// Only the versions supported by the codec (see SupportedVersions) are accepted.
+// Should be called before the codec is used.
+func (c *Codec) SetProtocolVersion(version byte) error {
+	const op = errors.Op("goridge_set_protocol_version")
+	for _, v := range SupportedVersions() {
+		if v == version {
+			c.version = version
+			return nil
+		}
+	}
+
+	return errors.E(op, errors.Errorf("unsupported protocol version: %d", version))
+}
+
+// SupportedVersions returns the protocol versions the codec is able to read and write.
+func SupportedVersions() []byte {
+	return []byte{frame.Version1}
 }
 
 func (c *Codec) get() *bytes.Buffer {
-	return c.bPool.Get().(*bytes.Buffer)
+	return c.pool.getBuffer()
 }
 
 func (c *Codec) put(b *bytes.Buffer) {
-	b.Reset()
-	c.bPool.Put(b)
+	c.pool.putBuffer(b)
 }
 
 func (c *Codec) getFrame() *frame.Frame {
-	return c.fPool.Get().(*frame.Frame)
+	return c.pool.frames.Get()
 }
 
 func (c *Codec) putFrame(f *frame.Frame) {
-	f.Reset()
-	c.fPool.Put(f)
+	c.pool.frames.Put(f)
 }
 
-// WriteResponse marshals response, byte slice or error to remote party.
-func (c *Codec) WriteResponse(r *rpc.Response, body any) error { //nolint:funlen
+// WriteResponse marshals response, byte slice or error to remote party. The nil body is written as the empty
+// payload (only the service method prefix) with any codec.
+// Safe for the concurrent use, every frame is written to the relay atomically.
+func (c *Codec) WriteResponse(r *rpc.Response, body any) error {
 	const op = errors.Op("goridge_write_response")
-	fr := c.getFrame()
-	defer c.putFrame(fr)
-
-	// SEQ_ID + METHOD_NAME_LEN
-	fr.WriteOptions(fr.HeaderPtr(), uint32(r.Seq), uint32(len(r.ServiceMethod)))
-	// Write protocol version
-	fr.WriteVersion(fr.Header(), frame.Version1)
+	r = c.noPrefixResponse(r)
+	// the error is matched with errors.Is
+	codec, err := c.loadCodec(r)
+	if err != nil {
+		return err
+	}
 
-	// load and delete associated codec to not waste memory
-	// because we write it to the fr and don't need more information about it
-	codec, ok := c.codec.LoadAndDelete(r.Seq)
-	if !ok {
-		// fallback codec
-		fr.WriteFlags(fr.Header(), frame.CodecGob)
-	} else {
-		fr.WriteFlags(fr.Header(), codec.(byte))
+	fr, err := c.responseFrame(r)
+	if err != nil {
+		return errors.E(op, err)
 	}
+	defer c.putFrame(fr)
+
+	r, body, codec = c.handleUnknownMethod(r, body, codec)
+	fr.WriteFlags(fr.Header(), codec)
 
 	// if error returned, we sending it via relay and return error from WriteResponse
 	if r.Error != "" {
@@ -94,122 +273,249 @@ func (c *Codec) WriteResponse(r *rpc.Response, body any) error { //nolint:funlen
 		return c.handleError(r, fr, r.Error)
 	}
 
-	switch {
-	case codec.(byte)&frame.CodecProto != 0:
-		d, err := proto.Marshal(body.(proto.Message))
+	entry, ok := lookupCodecFor(codec, c.json, c.msgpack)
+	if !ok {
+		return c.handleError(r, fr, errors.E(op, errors.Str("unknown codec")).Error())
+	}
+
+	// initialize buffer
+	buf := c.get()
+	defer c.put(buf)
+
+	// writeServiceMethod to the buffer
+	buf.WriteString(r.ServiceMethod)
+	// no body - an empty payload with the codec flag, whatever the codec would encode nil to
+	if body == nil {
+		return c.send(r, fr, buf)
+	}
+
+	err = entry.enc.Encode(body, buf)
+	if err != nil {
+		return c.handleError(r, fr, err.Error())
+	}
+
+	// send buffer
+	return c.send(r, fr, buf)
+}
+
+// responseFrame returns a frame from the pool with the response options and protocol version.
+// Options are SEQ_ID, METHOD_LEN, the passed options, the options set with SetResponseOptions, the transaction ID
+// of the open transaction and the checksum, the response fails if they don't fit into the header. The echoed
+// timestamp and the trace ID are added only if there is room left.
+func (c *Codec) responseFrame(r *rpc.Response, options ...uint32) (*frame.Frame, error) {
+	fr := c.getFrame()
+	// SEQ_ID + METHOD_NAME_LEN + extra options
+	extra, ok := c.respOpts.Load(r.Seq)
+	if ok || len(options) > 0 || c.echoTimestamps || c.propagateTrace || c.checksum != nil || c.txOpen.Load() != 0 {
+		opts, err := appendOptions([]uint32{uint32(r.Seq), uint32(len(r.ServiceMethod))}, options...)
+		if ok && err == nil {
+			opts, err = appendOptions(opts, extra.([]uint32)...)
+		}
+		// the response without the transaction ID would be delivered outside the transaction
+		if id := c.txOpen.Load(); id != 0 && err == nil {
+			opts, err = appendOptions(opts, OptionTxID, id)
+		}
+		// the checksum is verified by the peer, so it takes the room before the optional options
+		if c.checksum != nil && err == nil {
+			opts, err = c.checksumOption(opts)
+		}
 		if err != nil {
-			return c.handleError(r, fr, err.Error())
+			c.putFrame(fr)
+			return nil, err
+		}
+		if c.echoTimestamps {
+			opts = append(opts, c.echoTimestamp(r.Seq, len(opts))...)
 		}
+		if c.propagateTrace {
+			opts = append(opts, c.propagatedTraceID(r.Seq, opts)...)
+		}
+		fr.WriteOptions(fr.HeaderPtr(), opts...)
+	} else {
+		fr.WriteOptions(fr.HeaderPtr(), uint32(r.Seq), uint32(len(r.ServiceMethod)))
+	}
+	// Write protocol version
+	version := c.version
+	if version == 0 {
+		version = frame.Version1
+	}
+	fr.WriteVersion(fr.Header(), version)
+	if _, ok := c.noPrefix.Load(r.Seq); ok {
+		fr.SetNoMethodPrefix(fr.Header())
+	}
+	return fr, nil
+}
 
-		// initialize buffer
-		buf := c.get()
-		defer c.put(buf)
-
-		buf.Grow(len(d) + len(r.ServiceMethod))
-		// writeServiceMethod to the buffer
-		buf.WriteString(r.ServiceMethod)
-		buf.Write(d)
-
-		fr.WritePayloadLen(fr.Header(), uint32(buf.Len()))
-		// copy inside
-		fr.WritePayload(buf.Bytes())
-		fr.WriteCRC(fr.Header())
-		// send buffer
-		return c.relay.Send(fr)
-	case codec.(byte)&frame.CodecRaw != 0:
-		// initialize buffer
-		buf := c.get()
-		defer c.put(buf)
-
-		switch data := body.(type) {
-		case []byte:
-			buf.Grow(len(data) + len(r.ServiceMethod))
-			// writeServiceMethod to the buffer
-			buf.WriteString(r.ServiceMethod)
-			buf.Write(data)
-
-			fr.WritePayloadLen(fr.Header(), uint32(buf.Len()))
-			fr.WritePayload(buf.Bytes())
-		case *[]byte:
-			buf.Grow(len(*data) + len(r.ServiceMethod))
-			// writeServiceMethod to the buffer
-			buf.WriteString(r.ServiceMethod)
-			buf.Write(*data)
-
-			fr.WritePayloadLen(fr.Header(), uint32(buf.Len()))
-			fr.WritePayload(buf.Bytes())
-		default:
-			return c.handleError(r, fr, "unknown Raw payload type")
+// loadCodec loads and deletes associated codec to not waste memory
+// because we write it to the frame and don't need more information about it.
+// Returns ErrSequenceExpired for the late response of the expired sequence.
+func (c *Codec) loadCodec(r *rpc.Response) (byte, error) {
+	codec, ok := c.codec.LoadAndDelete(r.Seq)
+	if !ok {
+		if _, expired := c.seqExpired.LoadAndDelete(r.Seq); expired {
+			return 0, ErrSequenceExpired
 		}
 
-		// send buffer
-		fr.WriteCRC(fr.Header())
-		return c.relay.Send(fr)
+		// fallback codec, the header for this sequence was never read
+		return frame.CodecGob, nil
+	}
+
+	if c.seqTTL > 0 {
+		c.seqStarted.Delete(r.Seq)
+	}
+
+	c.release()
+	if c.sink != nil {
+		c.sink(Event{Type: EventCodecEvicted, Seq: r.Seq, Method: r.ServiceMethod, Flags: codec.(byte)})
+	}
+
+	return codec.(byte), nil
+}
 
-	case codec.(byte)&frame.CodecJSON != 0:
-		data, err := json.Marshal(body)
+// send writes the buffer (service method prefix + body) to the frame payload and sends the frame.
+// The body is compressed when compression is enabled and the body is bigger than the threshold.
+func (c *Codec) send(r *rpc.Response, fr *frame.Frame, buf *bytes.Buffer) error {
+	if c.compression != 0 && buf.Len()-len(r.ServiceMethod) > c.compressionThreshold {
+		cbuf := c.get()
+		defer c.put(cbuf)
+
+		// service method prefix is never compressed
+		cbuf.WriteString(r.ServiceMethod)
+		body := buf.Bytes()[len(r.ServiceMethod):]
+		err := compress(c.compression, cbuf, body)
 		if err != nil {
-			return c.handleError(r, fr, err.Error())
+			return err
 		}
 
-		// initialize buffer
-		buf := c.get()
-		defer c.put(buf)
+		// already compressed or tiny bodies might not shrink, send them as is
+		compressed := cbuf.Len() - len(r.ServiceMethod)
+		if compressed*100 <= len(body)*(100-c.compressionMinSavings) {
+			fr.SetCompressionFlag(fr.Header(), c.compression)
+			buf = cbuf
+		}
+	}
+
+	fr.WritePayloadLen(fr.Header(), uint32(buf.Len()))
+	// the frame is sent before the buffer is returned to the pool, so the buffer is not copied
+	// (the buffered frames of the transactions and the ordered responses are copied)
+	fr.SetPayload(buf.Bytes())
+	c.writeChecksum(fr)
+	writeCRC(fr, c.crcDisabled)
+	return c.sendFrame(r, fr)
+}
 
-		buf.Grow(len(data) + len(r.ServiceMethod))
-		// writeServiceMethod to the buffer
-		buf.WriteString(r.ServiceMethod)
-		buf.Write(data)
+// relaySend sends the frame to the relay, buffered relays are flushed after the response
+// or after the last frame of the stream. Frames are sent one at a time, so the concurrent responders
+// never interleave the frame bytes on the wire.
+func (c *Codec) relaySend(fr *frame.Frame) error {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
 
-		fr.WritePayloadLen(fr.Header(), uint32(buf.Len()))
-		// copy inside
-		fr.WritePayload(buf.Bytes())
-		fr.WriteCRC(fr.Header())
-		// send buffer
-		return c.relay.Send(fr)
+	return c.relaySendLocked(fr)
+}
 
-	case codec.(byte)&frame.CodecMsgpack != 0:
-		b, err := msgpack.Marshal(body)
-		if err != nil {
-			return errors.E(op, err)
+// relaySendLocked is relaySend for the callers holding sendMu
+func (c *Codec) relaySendLocked(fr *frame.Frame) error {
+	if c.batch != nil {
+		_, err := c.sendBatched(fr, Event{})
+		return err
+	}
+
+	return c.sendWithDeadline(fr)
+}
+
+// relaySendFrame is relaySend for the response frames. Returns true if the frame was appended to the batch,
+// such frame is counted and reported with the event (if the Type is set) when the batch is sent.
+func (c *Codec) relaySendFrame(fr *frame.Frame, ev Event) (bool, error) {
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+
+	if c.batch != nil {
+		return c.sendBatched(fr, ev)
+	}
+
+	return false, c.sendWithDeadline(fr)
+}
+
+// sendFlushed sends the frame and flushes the buffered relays, unless more frames of the stream follow
+func (c *Codec) sendFlushed(fr *frame.Frame) error {
+	// the frame is restored, the callers read the options after the send
+	swapOrder(fr, c.swapOptions)
+	err := c.relay.Send(fr)
+	swapOrder(fr, c.swapOptions)
+	if err != nil || fr.IsStream(fr.Header()) {
+		return err
+	}
+
+	type flusher interface {
+		Flush() error
+	}
+
+	if f, ok := c.relay.(flusher); ok {
+		return f.Flush()
+	}
+
+	return nil
+}
+
+// sendFrame sends the ready frame
+func (c *Codec) sendFrame(r *rpc.Response, fr *frame.Frame) error {
+	ev := Event{
+		Type:       EventFrameSent,
+		Seq:        r.Seq,
+		Method:     r.ServiceMethod,
+		Flags:      fr.ReadFlags(),
+		PayloadLen: len(fr.Payload()),
+	}
+
+	// the frames of the transaction are sent (and counted) by CommitTx, the ordered ones - after the previous
+	// responses, the batched ones - with the batch
+	buffered, err := c.bufferTx(fr)
+
+	switch {
+	case err != nil, buffered:
+	case c.ordered != nil:
+		buffered, err = c.sendOrdered(r.Seq, fr, ev)
+	default:
+		buffered, err = c.relaySendFrame(fr, ev)
+	}
+
+	// stream is finished by the last frame
+	if !fr.IsStream(fr.Header()) {
+		c.reqOpts.Delete(r.Seq)
+		c.respOpts.Delete(r.Seq)
+		c.noPrefix.Delete(r.Seq)
+		if c.requestContexts {
+			c.finishContext(r.Seq)
 		}
-		// initialize buffer
-		buf := c.get()
-		defer c.put(buf)
-
-		buf.Grow(len(b) + len(r.ServiceMethod))
-		// writeServiceMethod to the buffer
-		buf.WriteString(r.ServiceMethod)
-		buf.Write(b)
-
-		fr.WritePayloadLen(fr.Header(), uint32(buf.Len()))
-		// copy inside
-		fr.WritePayload(buf.Bytes())
-		fr.WriteCRC(fr.Header())
-		// send buffer
-		return c.relay.Send(fr)
-
-	case codec.(byte)&frame.CodecGob != 0:
-		// initialize buffer
-		buf := c.get()
-		defer c.put(buf)
-
-		buf.WriteString(r.ServiceMethod)
-
-		dec := gob.NewEncoder(buf)
-		err := dec.Encode(body)
-		if err != nil {
-			return errors.E(op, err)
+		if c.protoPool != nil {
+			c.releasePooled(r.Seq)
 		}
 
-		fr.WritePayloadLen(fr.Header(), uint32(buf.Len()))
-		// copy inside
-		fr.WritePayload(buf.Bytes())
-		fr.WriteCRC(fr.Header())
-		// send buffer
-		return c.relay.Send(fr)
-	default:
-		return c.handleError(r, fr, errors.E(op, errors.Str("unknown codec")).Error())
+		if c.hook != nil {
+			c.finishRequest(r, fr, err)
+		}
+	}
+
+	if err != nil {
+		c.stats.errors.Add(1)
+		return err
+	}
+
+	if buffered {
+		return nil
+	}
+
+	c.frameSent(len(fr.Header())+len(fr.Payload()), ev)
+	return nil
+}
+
+// frameSent counts the sent frame of the given size and reports the event, if the Type is set
+func (c *Codec) frameSent(size int, ev Event) {
+	c.stats.framesOut.Add(1)
+	c.stats.bytesOut.Add(uint64(size)) //nolint:gosec
+
+	if c.sink != nil && ev.Type != 0 {
+		c.sink(ev)
 	}
 }
 
@@ -221,178 +527,427 @@ func (c *Codec) handleError(r *rpc.Response, fr *frame.Frame, err string) error
 	buf.WriteString(r.ServiceMethod)
 
 	const op = errors.Op("handle codec error")
-	fr.WriteFlags(fr.Header(), frame.ERROR)
-	// error should be here
-	if err != "" {
-		buf.WriteString(err)
+	if c.errorEncoder != nil {
+		c.writeEncodedError(r, fr, buf, err)
+		fr.SetFlags(fr.Flags().WithError())
+	} else {
+		// the response codec is set before the ERROR flag
+		structured := c.writeStructuredError(fr, buf, err)
+		fr.SetFlags(fr.Flags().WithError())
+		// error should be here
+		if err != "" && !structured {
+			buf.WriteString(err)
+		}
 	}
 	fr.WritePayloadLen(fr.Header(), uint32(buf.Len()))
-	fr.WritePayload(buf.Bytes())
+	fr.SetPayload(buf.Bytes())
+	c.writeChecksum(fr)
 
-	fr.WriteCRC(fr.Header())
-	_ = c.relay.Send(fr)
+	writeCRC(fr, c.crcDisabled)
+	_ = c.sendFrame(r, fr)
 	return errors.E(op, errors.Str(r.Error))
 }
 
 // ReadRequestHeader receives frame with options
-// options should have 2 values
+// options should have at least 2 values
 // [0] - integer, sequence ID
 // [1] - integer, offset for method name
+// the rest are optional key/value pairs (see OptionContentType)
 // For example:
 // 15Test.Payload
 // SEQ_ID: 15
 // METHOD_LEN: 12 and we take 12 bytes from the payload as method name
 func (c *Codec) ReadRequestHeader(r *rpc.Request) error {
+	return c.ReadRequestHeaderCtx(context.Background(), r)
+}
+
+// ReadRequestHeaderCtx reads the request header like ReadRequestHeader, but unblocks the read when the context is done
+// and returns context.Canceled or context.DeadlineExceeded. The relay should implement relay.ContextRelay,
+// otherwise the context is ignored. With SetMaxInFlight the call blocks until there is a free slot.
+func (c *Codec) ReadRequestHeaderCtx(ctx context.Context, r *rpc.Request) error {
+	if c.draining.Load() {
+		return ErrCodecClosing
+	}
+
+	switch {
+	case c.inFlight != nil && c.seqTTL > 0:
+		err := c.acquireExpiring(ctx)
+		if err != nil {
+			return err
+		}
+	case c.inFlight != nil:
+		select {
+		case c.inFlight <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	err := c.readRequestHeader(ctx, r)
+	if err != nil {
+		// no codec was stored, so there will be no response to free the slot
+		c.release()
+		if !isExpectedReadErr(err) {
+			c.stats.errors.Add(1)
+		}
+	}
+
+	return err
+}
+
+// isExpectedReadErr reports whether the error is the closed connection, keepalive frame or closing codec
+func isExpectedReadErr(err error) bool {
+	return stderr.Is(err, io.EOF) || stderr.Is(err, ErrPing) || stderr.Is(err, ErrPong) || stderr.Is(err, ErrCodecClosing)
+}
+
+func (c *Codec) readRequestHeader(ctx context.Context, r *rpc.Request) error {
 	const op = errors.Op("goridge_read_request_header")
 	f := c.getFrame()
 
-	err := c.relay.Receive(f)
+	err := c.receive(ctx, f)
 	if err != nil {
+		if c.sink != nil {
+			c.sink(Event{Type: EventReceiveError, Err: err})
+		}
+
 		if stderr.Is(err, io.EOF) {
 			c.putFrame(f)
 			return err
 		}
 
+		c.putFrame(f)
+		// the peer closed the connection mid-frame, rpc.ServeCodec stops on the unwrapped io.ErrUnexpectedEOF
+		if stderr.Is(err, frame.ErrTruncatedPayload) {
+			return io.ErrUnexpectedEOF
+		}
+
+		return err
+	}
+
+	c.stats.framesIn.Add(1)
+	c.stats.bytesIn.Add(uint64(len(f.Header()) + len(f.Payload())))
+
+	err = c.checkReadLimit(f)
+	if err != nil {
 		c.putFrame(f)
 		return err
 	}
 
+	// a frame of the unknown version would be misparsed
+	err = checkVersion(f)
+	if err != nil {
+		c.putFrame(f)
+		return err
+	}
+
+	// keepalive frames are not RPC requests
+	switch {
+	case f.IsPing(f.Header()):
+		c.putFrame(f)
+		return ErrPing
+	case f.IsPong(f.Header()):
+		c.putFrame(f)
+		return ErrPong
+	case f.IsClose(f.Header()):
+		// the peer closes the connection, read it as the end of the connection
+		c.closeReason.Store(readCloseReason(f))
+		c.putFrame(f)
+		return io.EOF
+	}
+
 	// opts[0] sequence ID
 	// opts[1] service method name offset from payload in bytes
 	opts := f.ReadOptions(f.Header())
-	if len(opts) != 2 {
+	if len(opts) < 2 {
+		c.putFrame(f)
+		return errors.E(op, errors.Str("should be at least 2 options. SEQ_ID and METHOD_LEN"))
+	}
+
+	// the method name offset comes from the peer and can't be trusted
+	if int(opts[1]) > len(f.Payload()) {
+		c.putFrame(f)
+		return errors.E(op, errors.Str("method name offset is out of the payload bounds"))
+	}
+
+	// the request arrived while the codec is closing
+	if c.draining.Load() {
+		c.putFrame(f)
+		return ErrCodecClosing
+	}
+
+	method, err := c.requestMethod(f, opts)
+	if err != nil {
 		c.putFrame(f)
-		return errors.E(op, errors.Str("should be 2 options. SEQ_ID and METHOD_LEN"))
+		return errors.E(op, err)
+	}
+
+	// the peer might read the response with another codec, e.g. CodecBSON as CodecRaw
+	if codec := registeredCodec(resolveCodec(opts, f.ReadFlags())); !c.IsNegotiated(codec) {
+		c.putFrame(f)
+		return errors.E(op, errors.Errorf("codec %s is not negotiated", frame.Flags(codec)))
 	}
 
 	r.Seq = uint64(opts[0])
-	r.ServiceMethod = string(f.Payload()[:opts[1]])
+	r.ServiceMethod = method
 	c.frame = f
-	return c.storeCodec(r, f.ReadFlags())
+
+	if f.IsNoMethodPrefix(f.Header()) {
+		c.noPrefix.Store(r.Seq, struct{}{})
+	}
+
+	c.markUnknownMethod(r.Seq, method)
+
+	if len(opts) > 2 {
+		c.reqOpts.Store(r.Seq, opts[2:])
+	}
+
+	if c.requestContexts {
+		c.startContext(ctx, r.Seq, opts[2:])
+	}
+
+	if c.sink != nil {
+		c.sink(Event{
+			Type:       EventFrameReceived,
+			Seq:        r.Seq,
+			Method:     r.ServiceMethod,
+			Flags:      f.ReadFlags(),
+			PayloadLen: len(f.Payload()),
+		})
+	}
+	err = c.storeCodec(r, resolveCodec(opts, f.ReadFlags()))
+	if err != nil {
+		return err
+	}
+
+	if c.ordered != nil {
+		c.ordered.enqueue(r.Seq)
+	}
+
+	if c.hook != nil {
+		codec, _ := c.codec.Load(r.Seq)
+		c.startRequest(r, codec.(byte), len(f.Payload())-int(opts[1]))
+	}
+
+	return nil
+}
+
+// receive reads the frame from the relay, using the context if the relay supports it
+func (c *Codec) receive(ctx context.Context, f *frame.Frame) error {
+	err := c.receiveRelay(ctx, f)
+	if err != nil {
+		return err
+	}
+
+	swapOrder(f, c.swapOptions)
+	return nil
+}
+
+func (c *Codec) receiveRelay(ctx context.Context, f *frame.Frame) error {
+	const op = errors.Op("goridge_receive")
+
+	// ContextRelay is needed only for the contexts which can be canceled
+	if ctx.Done() != nil {
+		if cr, ok := c.relay.(relay.ContextRelay); ok {
+			if c.readTimeout > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, c.readTimeout)
+				defer cancel()
+			}
+
+			return cr.ReceiveCtx(ctx, f)
+		}
+	}
+
+	if c.readTimeout == 0 {
+		return c.relay.Receive(f)
+	}
+
+	type deadliner interface {
+		SetReadDeadline(time.Time) error
+	}
+
+	d, ok := c.relay.(deadliner)
+	if !ok {
+		return c.relay.Receive(f)
+	}
+
+	deadline := time.Now().Add(c.readTimeout)
+	err := d.SetReadDeadline(deadline)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	err = c.relay.Receive(f)
+	if err != nil && !time.Now().Before(deadline) {
+		return errors.E(op, errors.TimeOut, err)
+	}
+
+	return err
+}
+
+// registeredCodec returns the registered codec of the request flags
+func registeredCodec(flag byte) byte {
+	if entry, ok := lookupCodec(flag); ok {
+		return entry.flag
+	}
+
+	return frame.CodecGob // fallback codec
 }
 
 func (c *Codec) storeCodec(r *rpc.Request, flag byte) error {
-	switch {
-	case flag&frame.CodecProto != 0:
-		c.codec.Store(r.Seq, frame.CodecProto)
-	case flag&frame.CodecJSON != 0:
-		c.codec.Store(r.Seq, frame.CodecJSON)
-	case flag&frame.CodecRaw != 0:
-		c.codec.Store(r.Seq, frame.CodecRaw)
-	case flag&frame.CodecMsgpack != 0:
-		c.codec.Store(r.Seq, frame.CodecMsgpack)
-	case flag&frame.CodecGob != 0:
-		c.codec.Store(r.Seq, frame.CodecGob)
-	default:
-		c.codec.Store(r.Seq, frame.CodecGob)
+	codec := registeredCodec(flag)
+	// the new request reuses the sequence of the expired one
+	c.seqExpired.Delete(r.Seq)
+
+	// the request with the same sequence replaces the previous one, which will never get its own response
+	if _, loaded := c.codec.Swap(r.Seq, codec); loaded {
+		c.release()
+	}
+
+	if c.seqTTL > 0 {
+		c.trackSequence(r.Seq)
+	}
+
+	if c.sink != nil {
+		c.sink(Event{Type: EventCodecStored, Seq: r.Seq, Method: r.ServiceMethod, Flags: codec})
 	}
 
 	return nil
 }
 
 // ReadRequestBody fetches prefixed body data and automatically unmarshal it as json. RawBody flag will populate
-// []byte lice argument for rpc method.
+// []byte lice argument for rpc method. The unwrapped io.ErrUnexpectedEOF is returned if the payload of the frame
+// is shorter than declared in the header, e.g. the relay delivered the frame of the half-closed connection.
 func (c *Codec) ReadRequestBody(out any) error {
+	err := c.readRequestBody(out)
+	if err != nil {
+		c.stats.errors.Add(1)
+	}
+
+	return err
+}
+
+func (c *Codec) readRequestBody(out any) error {
 	const op = errors.Op("goridge_read_request_body")
+	defer func() {
+		c.putFrame(c.frame)
+		c.frame = nil
+	}()
+
+	// the body is discarded, but the frame is still released
 	if out == nil {
 		return nil
 	}
 
-	defer c.putFrame(c.frame)
+	// truncated frame is the end of the connection rather than the corrupted body
+	if len(c.frame.Payload()) < int(c.frame.ReadPayloadLen(c.frame.Header())) {
+		return io.ErrUnexpectedEOF
+	}
 
-	flags := c.frame.ReadFlags()
+	opts := c.frame.ReadOptions(c.frame.Header())
+	if len(opts) < 2 {
+		return errors.E(op, errors.Str("should be at least 2 options. SEQ_ID and METHOD_LEN"))
+	}
+	if int(opts[1]) > len(c.frame.Payload()) {
+		return errors.E(op, errors.Str("method name offset is out of the payload bounds"))
+	}
 
-	switch { //nolint:dupl
-	case flags&frame.CodecProto != 0:
-		opts := c.frame.ReadOptions(c.frame.Header())
-		if len(opts) != 2 {
-			return errors.E(op, errors.Str("should be 2 options. SEQ_ID and METHOD_LEN"))
-		}
-		payload := c.frame.Payload()[opts[1]:]
-		if len(payload) == 0 {
-			return nil
-		}
+	// the checksum covers the whole payload as sent, the error is matched with errors.Is
+	err := verifyChecksum(c.checksum, opts, c.frame.Payload())
+	if err != nil {
+		return err
+	}
 
-		// check if the out message is a correct proto.Message
-		// instead send an error
-		if pOut, ok := out.(proto.Message); ok {
-			err := proto.Unmarshal(payload, pOut)
-			if err != nil {
-				return errors.E(op, err)
-			}
-			return nil
-		}
+	payload := c.frame.Payload()[opts[1]:]
+	flags := resolveCodec(opts, c.frame.ReadFlags())
 
-		return errors.E(op, errors.Str("message type is not a proto"))
-	case flags&frame.CodecJSON != 0:
-		opts := c.frame.ReadOptions(c.frame.Header())
-		if len(opts) != 2 {
-			return errors.E(op, errors.Str("should be 2 options. SEQ_ID and METHOD_LEN"))
+	if algorithm := c.frame.ReadCompression(c.frame.Header()); algorithm != 0 && len(payload) > 0 {
+		// the peer should not compress, unless the compression is enabled on both sides
+		if c.compression == 0 {
+			return ErrCompressionDisabled
 		}
-		payload := c.frame.Payload()[opts[1]:]
-		if len(payload) == 0 {
-			return nil
-		}
-		return json.Unmarshal(payload, out)
-	case flags&frame.CodecGob != 0:
-		opts := c.frame.ReadOptions(c.frame.Header())
-		if len(opts) != 2 {
-			return errors.E(op, errors.Str("should be 2 options. SEQ_ID and METHOD_LEN"))
-		}
-		payload := c.frame.Payload()[opts[1]:]
-		if len(payload) == 0 {
-			return nil
+
+		var err error
+		payload, err = decompress(algorithm, payload, c.readLimit)
+		if err != nil {
+			return err
 		}
+	}
 
-		buf := c.get()
-		defer c.put(buf)
+	entry, ok := lookupCodecFor(flags, c.json, c.msgpack)
+	if !ok {
+		return errors.E(op, errors.Str("unknown decoder used in frame"))
+	}
 
-		dec := gob.NewDecoder(buf)
-		buf.Write(payload)
+	// allocate the registered or resolved request type for the placeholder
+	if placeholder, ok := out.(*any); ok && (c.types != nil || c.protoResolver != nil) {
+		method, _ := c.requestMethod(c.frame, opts)
+		if msg, found := c.pooledRequest(uint64(opts[0]), method); found {
+			*placeholder = msg
+			out = msg
+		} else if req, found := c.registeredRequest(method); found {
+			*placeholder = req
+			out = req
+		} else if msg, found := c.resolveProto(method, flags); found {
+			*placeholder = msg
+			out = msg
+		}
+	}
 
-		err := dec.Decode(out)
+	if c.requestContexts {
+		c.setContext(uint64(opts[0]), out)
+	}
+
+	// the array elements are decoded by the caller one by one
+	if stream, ok := out.(*MsgpackStream); ok {
+		err = stream.reset(flags, payload, c.msgpack)
 		if err != nil {
 			return errors.E(op, err)
 		}
-
 		return nil
-	case flags&frame.CodecRaw != 0:
-		opts := c.frame.ReadOptions(c.frame.Header())
-		if len(opts) != 2 {
-			return errors.E(op, errors.Str("should be 2 options. SEQ_ID and METHOD_LEN"))
-		}
-		payload := c.frame.Payload()[opts[1]:]
-		if len(payload) == 0 {
-			return nil
-		}
+	}
 
-		if raw, ok := out.(*[]byte); ok {
-			*raw = append(*raw, payload...)
-		}
+	if len(payload) == 0 {
+		return nil
+	}
 
+	if c.borrowRaw && borrowRaw(flags, payload, out) {
 		return nil
-	case flags&frame.CodecMsgpack != 0:
-		opts := c.frame.ReadOptions(c.frame.Header())
-		if len(opts) != 2 {
-			return errors.E(op, errors.Str("should be 2 options. SEQ_ID and METHOD_LEN"))
-		}
-		payload := c.frame.Payload()[opts[1]:]
-		if len(payload) == 0 {
-			return nil
-		}
+	}
 
-		return msgpack.Unmarshal(payload, out)
-	default:
-		return errors.E(op, errors.Str("unknown decoder used in frame"))
+	err = entry.dec.Decode(payload, out)
+	if err != nil && c.fallback != nil {
+		method, _ := c.requestMethod(c.frame, opts)
+		err = c.decodeFallback(method, flags, payload, out, err)
 	}
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
 }
 
 // Close underlying socket.
 func (c *Codec) Close() error {
-	if c.closed {
+	// the codec might be closed by the server concurrently with the serving goroutine
+	if !c.closed.CompareAndSwap(false, true) {
 		return nil
 	}
 
-	c.closed = true
+	c.closing()
+
+	// the pending batch is sent before the connection is closed
+	_ = c.FlushBatch()
 	return c.relay.Close()
 }
+
+// closing reports the closed connection and cancels the contexts of the pending requests
+func (c *Codec) closing() {
+	if c.sink != nil {
+		c.sink(Event{Type: EventConnectionClosed})
+	}
+
+	if c.requestContexts {
+		c.cancelContexts()
+	}
+}
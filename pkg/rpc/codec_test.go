@@ -0,0 +1,1192 @@
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/gob"
+	"io"
+	"net"
+	"net/rpc"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/goccy/go-json"
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/roadrunner-server/goridge/v3/pkg/socket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// requestFrame builds a request frame in the same format as the ClientCodec
+func requestFrame(seq uint32, method string, flags byte, body []byte, extra ...uint32) *frame.Frame {
+	fr := frame.NewFrame()
+	fr.WriteOptions(fr.HeaderPtr(), append([]uint32{seq, uint32(len(method))}, extra...)...)
+	fr.WriteVersion(fr.Header(), frame.Version1)
+	fr.WriteFlags(fr.Header(), flags)
+	fr.WritePayloadLen(fr.Header(), uint32(len(method)+len(body)))
+	fr.WritePayload(append([]byte(method), body...))
+	fr.WriteCRC(fr.Header())
+	return fr
+}
+
+// pipeCodec returns server Codec and a raw socket relay connected to it
+func pipeCodec(t *testing.T) (*Codec, *socket.Relay) {
+	server, client := net.Pipe()
+	c := NewCodec(server)
+	rl := socket.NewSocketRelay(client)
+
+	t.Cleanup(func() {
+		_ = c.Close()
+		_ = rl.Close()
+	})
+
+	return c, rl
+}
+
+func TestCodec_ContentType(t *testing.T) {
+	RegisterContentType("application/json", frame.CodecJSON)
+	RegisterContentType("application/msgpack", frame.CodecMsgpack)
+	RegisterContentType("application/octet-stream", frame.CodecRaw)
+
+	jsonBody, err := json.Marshal(Payload{Name: "json", Value: 1})
+	require.NoError(t, err)
+	msgpackBody, err := msgpack.Marshal(Payload{Name: "msgpack", Value: 2})
+	require.NoError(t, err)
+
+	cases := []struct {
+		contentType string
+		body        []byte
+		codec       byte
+	}{
+		{"application/json", jsonBody, frame.CodecJSON},
+		{"application/msgpack", msgpackBody, frame.CodecMsgpack},
+		{"application/octet-stream", []byte("raw"), frame.CodecRaw},
+	}
+
+	c, rl := pipeCodec(t)
+
+	for i, tt := range cases {
+		// flags are intentionally wrong, codec should be taken from the content type
+		id := ContentTypeID(tt.contentType)
+		fr := requestFrame(uint32(i), "test.Method", frame.CodecGob, tt.body, OptionContentType, id)
+		go func() {
+			_ = rl.Send(fr)
+		}()
+
+		req := &rpc.Request{}
+		require.NoError(t, c.ReadRequestHeader(req))
+		assert.Equal(t, "test.Method", req.ServiceMethod)
+
+		codec, ok := c.codec.Load(req.Seq)
+		require.True(t, ok)
+		assert.Equal(t, tt.codec, codec)
+
+		switch tt.codec {
+		case frame.CodecRaw:
+			out := make([]byte, 0)
+			require.NoError(t, c.ReadRequestBody(&out))
+			assert.Equal(t, tt.body, out)
+		default:
+			out := &Payload{}
+			require.NoError(t, c.ReadRequestBody(out))
+			assert.Equal(t, i+1, out.Value)
+		}
+	}
+}
+
+func TestCodec_UnknownContentType(t *testing.T) {
+	opts := []uint32{1, 2, OptionContentType, ContentTypeID("text/unknown")}
+	assert.Equal(t, frame.CodecProto, resolveCodec(opts, frame.CodecProto))
+	assert.Equal(t, frame.CodecJSON, resolveCodec([]uint32{1, 2}, frame.CodecJSON))
+}
+
+func TestCodec_MethodOffsetOutOfBounds(t *testing.T) {
+	c, rl := pipeCodec(t)
+
+	fr := frame.NewFrame()
+	// method len is bigger than the whole payload
+	fr.WriteOptions(fr.HeaderPtr(), 1, 1000)
+	fr.WriteVersion(fr.Header(), frame.Version1)
+	fr.WriteFlags(fr.Header(), frame.CodecJSON)
+	fr.WritePayloadLen(fr.Header(), uint32(len("test.Method")))
+	fr.WritePayload([]byte("test.Method"))
+	fr.WriteCRC(fr.Header())
+
+	go func() {
+		_ = rl.Send(fr)
+	}()
+
+	err := c.ReadRequestHeader(&rpc.Request{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "goridge_read_request_header")
+
+	// body read on the same malformed frame should not panic either
+	c.frame = fr
+	err = c.ReadRequestBody(&Payload{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "goridge_read_request_body")
+}
+
+func TestCodec_WriteResponseUnknownSeq(t *testing.T) {
+	c, rl := pipeCodec(t)
+
+	errCh := make(chan error, 1)
+	go func() {
+		// sequence was never passed through the ReadRequestHeader
+		errCh <- c.WriteResponse(&rpc.Response{ServiceMethod: "test.Method", Seq: 42}, "hello")
+	}()
+
+	fr := frame.NewFrame()
+	require.NoError(t, rl.Receive(fr))
+	require.NoError(t, <-errCh)
+
+	assert.Equal(t, frame.CodecGob, fr.ReadFlags())
+	assert.Equal(t, []uint32{42, uint32(len("test.Method"))}, fr.ReadOptions(fr.Header()))
+
+	var out string
+	require.NoError(t, gob.NewDecoder(bytes.NewReader(fr.Payload()[len("test.Method"):])).Decode(&out))
+	assert.Equal(t, "hello", out)
+}
+
+func TestCodec_Compression(t *testing.T) {
+	large := bytes.Repeat([]byte("goridge compression "), 10000)
+	small := []byte("small body")
+
+	for _, algorithm := range []byte{frame.CompressedGzip, frame.CompressedZstd} {
+		c, rl := pipeCodec(t)
+		require.NoError(t, c.SetCompression(algorithm, 1024))
+
+		for _, body := range [][]byte{large, small} {
+			c.codec.Store(uint64(1), frame.CodecRaw)
+			go func() {
+				_ = c.WriteResponse(&rpc.Response{ServiceMethod: "test.Method", Seq: 1}, body)
+			}()
+
+			fr := frame.NewFrame()
+			require.NoError(t, rl.Receive(fr))
+
+			if len(body) > 1024 {
+				assert.Equal(t, algorithm, fr.ReadCompression(fr.Header()))
+				assert.Less(t, len(fr.Payload()), len(body))
+			} else {
+				assert.Equal(t, byte(0), fr.ReadCompression(fr.Header()))
+				assert.Equal(t, append([]byte("test.Method"), body...), fr.Payload())
+			}
+
+			// client side inflates the payload
+			conn, _ := net.Pipe()
+			cc := NewClientCodec(conn)
+			cc.frame = fr
+			out := make([]byte, 0)
+			require.NoError(t, cc.ReadResponseBody(&out))
+			assert.Equal(t, body, out)
+		}
+	}
+
+	c, _ := pipeCodec(t)
+	assert.Error(t, c.SetCompression(frame.STREAM, 0))
+}
+
+// compressedRequest returns the request which Raw body is compressed with the algorithm
+func compressedRequest(t *testing.T, algorithm byte, body []byte) *frame.Frame {
+	buf := new(bytes.Buffer)
+	require.NoError(t, compress(algorithm, buf, body))
+	fr := requestFrame(1, "test.Method", frame.CodecRaw, buf.Bytes())
+	fr.SetCompressionFlag(fr.Header(), algorithm)
+	return fr
+}
+
+func TestCodec_CompressedRequests(t *testing.T) {
+	// the body inflated to 1MB from about 1KB
+	bomb := make([]byte, 1024*1024)
+
+	for _, algorithm := range []byte{frame.CompressedGzip, frame.CompressedZstd} {
+		// the compression is not enabled
+		c, rl := pipeCodec(t)
+		go func() {
+			_ = rl.Send(compressedRequest(t, algorithm, []byte("body")))
+		}()
+		require.NoError(t, c.ReadRequestHeader(&rpc.Request{}))
+		var out []byte
+		require.ErrorIs(t, c.ReadRequestBody(&out), ErrCompressionDisabled)
+
+		// the compressed frame passes the read limit, the decompressed body doesn't
+		c, rl = pipeCodec(t)
+		require.NoError(t, c.SetCompression(algorithm, 1024))
+		require.NoError(t, c.SetReadLimit(64*1024))
+		fr := compressedRequest(t, algorithm, bomb)
+		require.Less(t, len(fr.Payload()), 64*1024)
+		go func() {
+			_ = rl.Send(fr)
+			_ = rl.Send(compressedRequest(t, algorithm, []byte("body")))
+		}()
+		require.NoError(t, c.ReadRequestHeader(&rpc.Request{}))
+		var fe *frame.FrameTooLargeError
+		require.ErrorAs(t, c.ReadRequestBody(&out), &fe)
+		assert.Equal(t, uint64(64*1024), fe.Limit)
+
+		// the bodies within the limit are decompressed
+		require.NoError(t, c.ReadRequestHeader(&rpc.Request{}))
+		require.NoError(t, c.ReadRequestBody(&out))
+		assert.Equal(t, []byte("body"), out)
+	}
+}
+
+func TestClientCodec_CompressedResponses(t *testing.T) {
+	for _, algorithm := range []byte{frame.CompressedGzip, frame.CompressedZstd} {
+		conn, _ := net.Pipe()
+		cc := NewClientCodec(conn)
+		require.Error(t, cc.SetDecompressionLimit(-1))
+
+		// the responses are decompressed up to DefaultDecompressionLimit by default
+		var out []byte
+		cc.frame = compressedRequest(t, algorithm, []byte("body"))
+		require.NoError(t, cc.ReadResponseBody(&out))
+		assert.Equal(t, []byte("body"), out)
+
+		require.NoError(t, cc.SetDecompressionLimit(1024))
+		cc.frame = compressedRequest(t, algorithm, make([]byte, 1025))
+		require.ErrorIs(t, cc.ReadResponseBody(&out), frame.ErrFrameTooLarge)
+
+		out = nil
+		cc.frame = compressedRequest(t, algorithm, []byte("body"))
+		require.NoError(t, cc.ReadResponseBody(&out))
+		assert.Equal(t, []byte("body"), out)
+	}
+}
+
+func TestCodec_SharedPool(t *testing.T) {
+	pool := NewPool()
+	wg := &sync.WaitGroup{}
+
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			server, client := net.Pipe()
+			c := NewCodecWithPool(server, pool)
+			rl := socket.NewSocketRelay(client)
+			defer func() {
+				_ = c.Close()
+				_ = rl.Close()
+			}()
+
+			body, err := json.Marshal(Payload{Name: "shared", Value: i})
+			assert.NoError(t, err)
+
+			go func() {
+				_ = rl.Send(requestFrame(uint32(i), "test.Method", frame.CodecJSON, body))
+			}()
+
+			req := &rpc.Request{}
+			assert.NoError(t, c.ReadRequestHeader(req))
+			out := &Payload{}
+			assert.NoError(t, c.ReadRequestBody(out))
+			assert.Equal(t, i, out.Value)
+		}()
+	}
+
+	wg.Wait()
+}
+
+func benchmarkShortConnections(b *testing.B, newCodec func(rwc io.ReadWriteCloser) *Codec) {
+	body, err := json.Marshal(Payload{Name: "bench", Value: 1})
+	require.NoError(b, err)
+	fr := requestFrame(1, "test.Method", frame.CodecJSON, body)
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		server, client := net.Pipe()
+		c := newCodec(server)
+
+		go func() {
+			_, _ = client.Write(fr.Bytes())
+		}()
+
+		req := &rpc.Request{}
+		_ = c.ReadRequestHeader(req)
+		_ = c.ReadRequestBody(&Payload{})
+
+		_ = c.Close()
+		_ = client.Close()
+	}
+}
+
+func BenchmarkCodec_ShortConnections(b *testing.B) {
+	benchmarkShortConnections(b, NewCodec)
+}
+
+func BenchmarkCodec_ShortConnectionsSharedPool(b *testing.B) {
+	pool := NewPool()
+	benchmarkShortConnections(b, func(rwc io.ReadWriteCloser) *Codec {
+		return NewCodecWithPool(rwc, pool)
+	})
+}
+
+// the response buffers are taken from the shared pool too
+func BenchmarkCodec_ShortConnectionsSharedPoolResponse(b *testing.B) {
+	body, err := json.Marshal(Payload{Name: "bench", Value: 1})
+	require.NoError(b, err)
+	fr := requestFrame(1, "test.Method", frame.CodecJSON, body)
+	pool := NewPool()
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		server, client := net.Pipe()
+		c := NewCodecWithPool(server, pool)
+
+		go func() {
+			_, _ = client.Write(fr.Bytes())
+			_, _ = io.Copy(io.Discard, client)
+		}()
+
+		req := &rpc.Request{}
+		_ = c.ReadRequestHeader(req)
+		_ = c.ReadRequestBody(&Payload{})
+		_ = c.WriteResponse(&rpc.Response{ServiceMethod: req.ServiceMethod, Seq: req.Seq}, &Payload{Name: "bench"})
+
+		_ = c.Close()
+		_ = client.Close()
+	}
+}
+
+func TestCodec_RegisterCodec(t *testing.T) {
+	// replaces the built-in codec of the flag for the test
+	const codecReversed byte = frame.CodecFlatbuffers
+	t.Cleanup(func() {
+		RegisterCodec(frame.CodecFlatbuffers, EncoderFunc(encodeFlatbuffers), DecoderFunc(decodeFlatbuffers))
+	})
+
+	reverse := func(data []byte) []byte {
+		out := make([]byte, len(data))
+		for i := range data {
+			out[len(data)-1-i] = data[i]
+		}
+		return out
+	}
+
+	RegisterCodec(codecReversed, EncoderFunc(func(body any, buf *bytes.Buffer) error {
+		buf.Write(reverse([]byte(body.(string))))
+		return nil
+	}), DecoderFunc(func(payload []byte, out any) error {
+		*out.(*string) = string(reverse(payload))
+		return nil
+	}))
+
+	c, rl := pipeCodec(t)
+
+	go func() {
+		_ = rl.Send(requestFrame(7, "test.Method", codecReversed, []byte("olleh")))
+	}()
+
+	req := &rpc.Request{}
+	require.NoError(t, c.ReadRequestHeader(req))
+	var in string
+	require.NoError(t, c.ReadRequestBody(&in))
+	assert.Equal(t, "hello", in)
+
+	go func() {
+		_ = c.WriteResponse(&rpc.Response{ServiceMethod: req.ServiceMethod, Seq: req.Seq}, in+" world")
+	}()
+
+	fr := frame.NewFrame()
+	require.NoError(t, rl.Receive(fr))
+	assert.Equal(t, codecReversed, fr.ReadFlags())
+	assert.Equal(t, []byte("test.Methoddlrow olleh"), fr.Payload())
+
+	// built-in codecs are untouched
+	entry, ok := lookupCodec(frame.CodecJSON)
+	require.True(t, ok)
+	assert.Equal(t, frame.CodecJSON, entry.flag)
+}
+
+func TestCodec_ReadRequestHeaderCtx(t *testing.T) {
+	c, _ := pipeCodec(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel()
+
+	err := c.ReadRequestHeaderCtx(ctx, &rpc.Request{})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestCodec_EventSink(t *testing.T) {
+	c, rl := pipeCodec(t)
+
+	var events []Event
+	c.SetEventSink(func(e Event) {
+		events = append(events, e)
+	})
+
+	go func() {
+		_ = rl.Send(requestFrame(3, "test.Method", frame.CodecRaw, []byte("ping")))
+	}()
+
+	req := &rpc.Request{}
+	require.NoError(t, c.ReadRequestHeader(req))
+	require.NoError(t, c.ReadRequestBody(&[]byte{}))
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.WriteResponse(&rpc.Response{ServiceMethod: req.ServiceMethod, Seq: req.Seq}, []byte("pong"))
+	}()
+	require.NoError(t, rl.Receive(frame.NewFrame()))
+	require.NoError(t, <-errCh)
+
+	// peer is gone
+	require.NoError(t, rl.Close())
+	require.Error(t, c.ReadRequestHeader(&rpc.Request{}))
+	require.NoError(t, c.Close())
+
+	types := make([]EventType, 0, len(events))
+	for _, e := range events {
+		types = append(types, e.Type)
+	}
+
+	assert.Equal(t, []EventType{
+		EventFrameReceived,
+		EventCodecStored,
+		EventCodecEvicted,
+		EventFrameSent,
+		EventReceiveError,
+		EventConnectionClosed,
+	}, types)
+
+	assert.Equal(t, uint64(3), events[0].Seq)
+	assert.Equal(t, "test.Method", events[0].Method)
+	assert.Equal(t, len("test.Methodping"), events[0].PayloadLen)
+	assert.Equal(t, frame.CodecRaw, events[1].Flags)
+	assert.Equal(t, len("test.Methodpong"), events[3].PayloadLen)
+	assert.Error(t, events[4].Err)
+}
+
+func TestCodec_CompressionMinSavings(t *testing.T) {
+	compressible := bytes.Repeat([]byte("goridge compression "), 1000)
+	incompressible := make([]byte, 20000)
+	_, err := rand.Read(incompressible)
+	require.NoError(t, err)
+
+	c, rl := pipeCodec(t)
+	require.NoError(t, c.SetCompression(frame.CompressedGzip, 0))
+	require.NoError(t, c.SetCompressionMinSavings(10))
+	require.Error(t, c.SetCompressionMinSavings(100))
+
+	for _, body := range [][]byte{compressible, incompressible} {
+		c.codec.Store(uint64(1), frame.CodecRaw)
+		go func() {
+			_ = c.WriteResponse(&rpc.Response{ServiceMethod: "test.Method", Seq: 1}, body)
+		}()
+
+		fr := frame.NewFrame()
+		require.NoError(t, rl.Receive(fr))
+
+		if bytes.Equal(body, compressible) {
+			assert.Equal(t, frame.CompressedGzip, fr.ReadCompression(fr.Header()))
+		} else {
+			assert.Equal(t, byte(0), fr.ReadCompression(fr.Header()))
+			assert.Equal(t, append([]byte("test.Method"), body...), fr.Payload())
+		}
+	}
+}
+
+func TestCodec_ReadTimeout(t *testing.T) {
+	c, _ := pipeCodec(t)
+	c.SetReadTimeout(time.Millisecond * 50)
+
+	// peer never sends anything
+	start := time.Now()
+	err := c.ReadRequestHeader(&rpc.Request{})
+	require.Error(t, err)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.True(t, errors.Is(errors.TimeOut, err))
+	assert.NotErrorIs(t, err, io.EOF)
+}
+
+func TestCodec_MaxInFlight(t *testing.T) {
+	c, rl := pipeCodec(t)
+	require.NoError(t, c.SetMaxInFlight(2))
+
+	go func() {
+		for seq := uint32(1); seq <= 3; seq++ {
+			assert.NoError(t, rl.Send(requestFrame(seq, "test.Method", frame.CodecRaw, []byte("hello"))))
+		}
+	}()
+
+	for seq := uint64(1); seq <= 2; seq++ {
+		r := &rpc.Request{}
+		require.NoError(t, c.ReadRequestHeader(r))
+		assert.Equal(t, seq, r.Seq)
+		var body []byte
+		require.NoError(t, c.ReadRequestBody(&body))
+	}
+
+	third := make(chan *rpc.Request, 1)
+	go func() {
+		r := &rpc.Request{}
+		assert.NoError(t, c.ReadRequestHeader(r))
+		third <- r
+	}()
+
+	select {
+	case <-third:
+		t.Fatal("third request was read while two requests are in flight")
+	case <-time.After(time.Millisecond * 100):
+	}
+
+	go func() {
+		assert.NoError(t, c.WriteResponse(&rpc.Response{ServiceMethod: "test.Method", Seq: 1}, []byte("done")))
+	}()
+	require.NoError(t, rl.Receive(frame.NewFrame()))
+
+	select {
+	case r := <-third:
+		assert.Equal(t, uint64(3), r.Seq)
+	case <-time.After(time.Second * 5):
+		t.Fatal("third request was not read after the response")
+	}
+}
+
+// countingJSON is a JSONCodec which counts the calls
+type countingJSON struct {
+	marshal   int
+	unmarshal int
+}
+
+func (j *countingJSON) Marshal(v any) ([]byte, error) {
+	j.marshal++
+	return json.Marshal(v)
+}
+
+func (j *countingJSON) Unmarshal(data []byte, v any) error {
+	j.unmarshal++
+	return json.Unmarshal(data, v)
+}
+
+func TestCodec_SetJSONCodec(t *testing.T) {
+	c, rl := pipeCodec(t)
+	jc := &countingJSON{}
+	c.SetJSONCodec(jc)
+
+	go func() {
+		assert.NoError(t, rl.Send(requestFrame(1, "test.Method", frame.CodecJSON, []byte(`{"name":"goridge"}`))))
+	}()
+
+	r := &rpc.Request{}
+	require.NoError(t, c.ReadRequestHeader(r))
+
+	var in map[string]string
+	require.NoError(t, c.ReadRequestBody(&in))
+	assert.Equal(t, "goridge", in["name"])
+	assert.Equal(t, 1, jc.unmarshal)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.WriteResponse(&rpc.Response{ServiceMethod: r.ServiceMethod, Seq: r.Seq}, map[string]string{"ok": "yes"})
+	}()
+
+	fr := frame.NewFrame()
+	require.NoError(t, rl.Receive(fr))
+	require.NoError(t, <-errCh)
+	assert.Equal(t, frame.CodecJSON, fr.ReadFlags())
+	assert.Equal(t, `test.Method{"ok":"yes"}`, string(fr.Payload()))
+	assert.Equal(t, 1, jc.marshal)
+
+	// the client codec routes JSON responses through it too
+	cc := pipeClientCodec(t, fr)
+	cc.SetJSONCodec(jc)
+
+	var out map[string]string
+	require.NoError(t, cc.ReadResponseBody(&out))
+	assert.Equal(t, "yes", out["ok"])
+	assert.Equal(t, 2, jc.unmarshal)
+}
+
+func TestCodec_Stats(t *testing.T) {
+	c, rl := pipeCodec(t)
+
+	frames := []*frame.Frame{
+		requestFrame(1, "test.Method", frame.CodecJSON, []byte(`"one"`)),
+		requestFrame(2, "test.Method", frame.CodecJSON, []byte(`"two"`)),
+		// broken JSON
+		requestFrame(3, "test.Method", frame.CodecJSON, []byte(`{`)),
+	}
+
+	var bytesIn uint64
+	for _, fr := range frames {
+		bytesIn += uint64(len(fr.Bytes()))
+	}
+
+	go func() {
+		for _, fr := range frames {
+			assert.NoError(t, rl.Send(fr))
+
+			resp := frame.NewFrame()
+			assert.NoError(t, rl.Receive(resp))
+		}
+		_ = rl.Close()
+	}()
+
+	var bytesOut uint64
+	for i := 0; i < len(frames); i++ {
+		r := &rpc.Request{}
+		require.NoError(t, c.ReadRequestHeader(r))
+
+		var body string
+		err := c.ReadRequestBody(&body)
+		resp := &rpc.Response{ServiceMethod: r.ServiceMethod, Seq: r.Seq}
+		if err != nil {
+			resp.Error = err.Error()
+			bytesOut += uint64(12 + 8 + len(r.ServiceMethod) + len(resp.Error))
+		} else {
+			bytesOut += uint64(12 + 8 + len(r.ServiceMethod) + len(`"`+body+`"`))
+		}
+
+		_ = c.WriteResponse(resp, body)
+	}
+
+	// EOF is not an error
+	assert.ErrorIs(t, c.ReadRequestHeader(&rpc.Request{}), io.EOF)
+
+	assert.Equal(t, Stats{
+		FramesIn:  3,
+		FramesOut: 3,
+		BytesIn:   bytesIn,
+		BytesOut:  bytesOut,
+		Errors:    1,
+	}, c.Stats())
+}
+
+func TestCodec_RequestHook(t *testing.T) {
+	c, rl := pipeCodec(t)
+
+	var mu sync.Mutex
+	var started []RequestInfo
+	var finished []ResponseInfo
+	c.SetRequestHook(func(info RequestInfo) func(ResponseInfo) {
+		mu.Lock()
+		started = append(started, info)
+		mu.Unlock()
+
+		return func(resp ResponseInfo) {
+			mu.Lock()
+			finished = append(finished, resp)
+			mu.Unlock()
+		}
+	})
+
+	go func() {
+		assert.NoError(t, rl.Send(requestFrame(1, "test.Ok", frame.CodecRaw, []byte("hello"))))
+		assert.NoError(t, rl.Receive(frame.NewFrame()))
+		assert.NoError(t, rl.Send(requestFrame(2, "test.Fail", frame.CodecJSON, []byte(`"bye"`))))
+		assert.NoError(t, rl.Receive(frame.NewFrame()))
+	}()
+
+	r := &rpc.Request{}
+	require.NoError(t, c.ReadRequestHeader(r))
+	var raw []byte
+	require.NoError(t, c.ReadRequestBody(&raw))
+	require.NoError(t, c.WriteResponse(&rpc.Response{ServiceMethod: r.ServiceMethod, Seq: r.Seq}, []byte("world!")))
+
+	require.NoError(t, c.ReadRequestHeader(r))
+	var str string
+	require.NoError(t, c.ReadRequestBody(&str))
+	_ = c.WriteResponse(&rpc.Response{ServiceMethod: r.ServiceMethod, Seq: r.Seq, Error: "failed"}, nil)
+
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []RequestInfo{
+		{Seq: 1, Method: "test.Ok", Codec: frame.CodecRaw, PayloadLen: 5},
+		{Seq: 2, Method: "test.Fail", Codec: frame.CodecJSON, PayloadLen: 5},
+	}, started)
+	assert.Equal(t, []ResponseInfo{
+		{Flags: frame.CodecRaw, PayloadLen: 6},
+		{Flags: frame.CodecJSON | frame.ERROR, PayloadLen: 6, Error: "failed"},
+	}, finished)
+}
+
+func TestCodec_SetProtocolVersion(t *testing.T) {
+	c, rl := pipeCodec(t)
+
+	for _, v := range []byte{0, 2, 15, 16} {
+		assert.Error(t, c.SetProtocolVersion(v), "version %d", v)
+	}
+
+	for _, v := range SupportedVersions() {
+		require.NoError(t, c.SetProtocolVersion(v))
+
+		go func() {
+			_ = c.WriteResponse(&rpc.Response{ServiceMethod: "test.Method", Seq: 1}, []byte("hello"))
+		}()
+
+		fr := frame.NewFrame()
+		require.NoError(t, rl.Receive(fr))
+		assert.Equal(t, v, fr.ReadVersion(fr.Header()))
+	}
+}
+
+func TestCodec_BufferedRelayFlush(t *testing.T) {
+	server, client := net.Pipe()
+	c := NewCodecWithRelay(socket.NewBufferedSocketRelay(server, 0))
+	rl := socket.NewSocketRelay(client)
+	t.Cleanup(func() {
+		_ = c.Close()
+		_ = rl.Close()
+	})
+
+	go func() {
+		_ = c.WriteResponse(&rpc.Response{ServiceMethod: "test.Method", Seq: 1}, []byte("hello"))
+	}()
+
+	// the response is flushed, otherwise the receive blocks
+	fr := frame.NewFrame()
+	require.NoError(t, rl.Receive(fr))
+	assert.Equal(t, []uint32{1, uint32(len("test.Method"))}, fr.ReadOptions(fr.Header()))
+}
+
+func TestCodec_ExtraOptions(t *testing.T) {
+	server, client := net.Pipe()
+	c := NewCodec(server)
+	cc := NewClientCodec(client)
+	t.Cleanup(func() {
+		_ = c.Close()
+		_ = cc.Close()
+	})
+
+	go func() {
+		// trace ID as the third option
+		assert.NoError(t, cc.relay.Send(requestFrame(1, "test.Method", frame.CodecRaw, []byte("hello"), 0xCAFE)))
+	}()
+
+	r := &rpc.Request{}
+	require.NoError(t, c.ReadRequestHeader(r))
+	assert.Equal(t, []uint32{0xCAFE}, c.RequestOptions(r.Seq))
+
+	var body []byte
+	require.NoError(t, c.ReadRequestBody(&body))
+	assert.Equal(t, []byte("hello"), body)
+
+	assert.Error(t, c.SetResponseOptions(r.Seq, make([]uint32, 9)...))
+	require.NoError(t, c.SetResponseOptions(r.Seq, c.RequestOptions(r.Seq)...))
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.WriteResponse(&rpc.Response{ServiceMethod: r.ServiceMethod, Seq: r.Seq}, []byte("world"))
+	}()
+
+	resp := &rpc.Response{}
+	require.NoError(t, cc.ReadResponseHeader(resp))
+	require.NoError(t, <-errCh)
+	assert.Equal(t, uint64(1), resp.Seq)
+	assert.Equal(t, []uint32{0xCAFE}, cc.ResponseOptions())
+
+	var out []byte
+	require.NoError(t, cc.ReadResponseBody(&out))
+	assert.Equal(t, []byte("world"), out)
+
+	// options are dropped with the response
+	assert.Nil(t, c.RequestOptions(r.Seq))
+}
+
+func TestCodec_Ping(t *testing.T) {
+	c, rl := pipeCodec(t)
+
+	go func() {
+		assert.NoError(t, c.Ping())
+	}()
+
+	fr := frame.NewFrame()
+	require.NoError(t, rl.Receive(fr))
+	assert.True(t, fr.IsPing(fr.Header()))
+	assert.Empty(t, fr.Payload())
+
+	go func() {
+		ping := frame.NewFrame()
+		ping.WriteVersion(ping.Header(), frame.Version1)
+		ping.SetPingBit(ping.Header())
+		ping.WriteCRC(ping.Header())
+		assert.NoError(t, rl.Send(ping))
+		assert.NoError(t, rl.Send(requestFrame(1, "test.Method", frame.CodecRaw, []byte("hello"))))
+	}()
+
+	// ping is recognized, not dispatched as a request
+	r := &rpc.Request{}
+	assert.ErrorIs(t, c.ReadRequestHeader(r), ErrPing)
+	assert.Empty(t, r.ServiceMethod)
+
+	require.NoError(t, c.ReadRequestHeader(r))
+	assert.Equal(t, "test.Method", r.ServiceMethod)
+	assert.Zero(t, c.Stats().Errors)
+}
+
+// onlyReader hides the io.WriterTo implementation of the underlying reader
+type onlyReader struct {
+	io.Reader
+}
+
+func TestCodec_WriteResponseRawReader(t *testing.T) {
+	body := make([]byte, 3*1024*1024)
+	_, err := rand.Read(body)
+	require.NoError(t, err)
+
+	var reader io.Reader = onlyReader{bytes.NewReader(body)}
+	bodies := []any{bytes.NewReader(body), onlyReader{bytes.NewReader(body)}, &reader}
+
+	for _, b := range bodies {
+		c, rl := pipeCodec(t)
+		c.codec.Store(uint64(1), frame.CodecRaw)
+
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- c.WriteResponse(&rpc.Response{ServiceMethod: "test.Method", Seq: 1}, b)
+		}()
+
+		fr := frame.NewFrame()
+		require.NoError(t, rl.Receive(fr))
+		require.NoError(t, <-errCh)
+
+		assert.Equal(t, frame.CodecRaw, fr.ReadFlags())
+		assert.Equal(t, "test.Method", string(fr.Payload()[:len("test.Method")]))
+		assert.Equal(t, body, fr.Payload()[len("test.Method"):])
+	}
+}
+
+func TestCodec_CloseGraceful(t *testing.T) {
+	c, rl := pipeCodec(t)
+
+	go func() {
+		assert.NoError(t, rl.Send(requestFrame(1, "test.Method", frame.CodecRaw, []byte("hello"))))
+		// response for the pending sequence
+		_ = rl.Receive(frame.NewFrame())
+	}()
+
+	r := &rpc.Request{}
+	require.NoError(t, c.ReadRequestHeader(r))
+
+	done := make(chan error, 1)
+	go func() {
+		done <- c.CloseGraceful(time.Second * 5)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("codec was closed with the pending sequence")
+	case <-time.After(time.Millisecond * 100):
+	}
+
+	// no new requests are accepted
+	assert.ErrorIs(t, c.ReadRequestHeader(&rpc.Request{}), ErrCodecClosing)
+
+	require.NoError(t, c.WriteResponse(&rpc.Response{ServiceMethod: r.ServiceMethod, Seq: r.Seq}, []byte("world")))
+	require.NoError(t, <-done)
+	assert.True(t, c.closed.Load())
+}
+
+func TestCodec_CloseGracefulTimeout(t *testing.T) {
+	c, rl := pipeCodec(t)
+
+	go func() {
+		assert.NoError(t, rl.Send(requestFrame(1, "test.Method", frame.CodecRaw, []byte("hello"))))
+	}()
+
+	require.NoError(t, c.ReadRequestHeader(&rpc.Request{}))
+
+	err := c.CloseGraceful(time.Millisecond * 50)
+	require.Error(t, err)
+	assert.True(t, errors.Is(errors.TimeOut, err))
+	assert.True(t, c.closed.Load())
+}
+
+func TestCodec_DrainAndClose(t *testing.T) {
+	c, rl := pipeCodec(t)
+
+	// the peer finishes sending and closes the connection
+	go func() {
+		for seq := uint32(1); seq <= 3; seq++ {
+			assert.NoError(t, rl.Send(requestFrame(seq, "test.Method", frame.CodecRaw, []byte("hello"))))
+		}
+		_ = rl.Close()
+	}()
+
+	require.NoError(t, c.DrainAndClose(time.Second))
+	assert.True(t, c.closed.Load())
+	assert.Equal(t, uint64(3), c.Stats().FramesIn)
+	assert.ErrorIs(t, c.ReadRequestHeader(&rpc.Request{}), ErrCodecClosing)
+}
+
+func TestCodec_DrainAndCloseTimeout(t *testing.T) {
+	c, rl := pipeCodec(t)
+
+	// the peer keeps sending until the connection is closed
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+		for seq := uint32(1); ; seq++ {
+			if rl.Send(requestFrame(seq, "test.Method", frame.CodecRaw, []byte("hello"))) != nil {
+				return
+			}
+		}
+	}()
+
+	start := time.Now()
+	err := c.DrainAndClose(time.Millisecond * 50)
+	require.Error(t, err)
+	assert.True(t, errors.Is(errors.TimeOut, err))
+	assert.GreaterOrEqual(t, time.Since(start), time.Millisecond*50)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.True(t, c.closed.Load())
+	assert.Positive(t, c.Stats().FramesIn)
+
+	// the sender is unblocked by the closed connection
+	select {
+	case <-sent:
+	case <-time.After(time.Second):
+		t.Fatal("the peer is still sending")
+	}
+}
+
+// the registry encoder and the Codec msgpack branch should produce the same msgpack/v5 bytes
+func TestCodec_MsgpackEncoderMatchesCodec(t *testing.T) {
+	// single key: msgpack doesn't sort the map keys
+	body := Payload{Name: "msgpack", Value: 42, Keys: map[string]string{"key": "value"}}
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, encodeMsgpack(body, buf))
+
+	c, rl := pipeCodec(t)
+	go func() {
+		_ = rl.Send(requestFrame(1, "test.Msgpack", frame.CodecMsgpack, buf.Bytes()))
+	}()
+
+	r := &rpc.Request{}
+	require.NoError(t, c.ReadRequestHeader(r))
+	in := Payload{}
+	require.NoError(t, c.ReadRequestBody(&in))
+	assert.Equal(t, body, in)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.WriteResponse(&rpc.Response{ServiceMethod: r.ServiceMethod, Seq: r.Seq}, body)
+	}()
+
+	fr := frame.NewFrame()
+	require.NoError(t, rl.Receive(fr))
+	require.NoError(t, <-errCh)
+
+	assert.Equal(t, frame.CodecMsgpack, fr.ReadFlags())
+	assert.Equal(t, buf.Bytes(), fr.Payload()[len(r.ServiceMethod):])
+}
+
+func TestCodec_UnsupportedVersion(t *testing.T) {
+	c, rl := pipeCodec(t)
+
+	go func() {
+		fr := frame.NewFrame()
+		fr.WriteOptions(fr.HeaderPtr(), 1, uint32(len("test.Version")))
+		fr.WriteVersion(fr.Header(), 2)
+		fr.WriteFlags(fr.Header(), frame.CodecJSON)
+		fr.WritePayloadLen(fr.Header(), uint32(len("test.Version{}")))
+		fr.WritePayload([]byte("test.Version{}"))
+		fr.WriteCRC(fr.Header())
+		_ = rl.Send(fr)
+		_ = rl.Send(requestFrame(2, "test.Version", frame.CodecJSON, []byte(`{}`)))
+	}()
+
+	err := c.ReadRequestHeader(&rpc.Request{})
+	require.ErrorIs(t, err, ErrUnsupportedVersion)
+	var uv *UnsupportedVersionError
+	require.ErrorAs(t, err, &uv)
+	assert.Equal(t, byte(2), uv.Version)
+
+	// Version1 is still accepted
+	r := &rpc.Request{}
+	require.NoError(t, c.ReadRequestHeader(r))
+	assert.Equal(t, uint64(2), r.Seq)
+}
+
+// chunkedConn splits every write into small chunks, so the unsynchronized concurrent writes would interleave
+type chunkedConn struct {
+	net.Conn
+}
+
+func (c chunkedConn) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := min(7, len(p))
+		m, err := c.Conn.Write(p[:n])
+		written += m
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+		runtime.Gosched()
+	}
+
+	return written, nil
+}
+
+func TestCodec_ConcurrentWriteResponse(t *testing.T) {
+	server, client := net.Pipe()
+	c := NewCodec(chunkedConn{server})
+	rl := socket.NewSocketRelay(client)
+	t.Cleanup(func() {
+		_ = c.Close()
+		_ = rl.Close()
+	})
+
+	const writers, responses = 32, 20
+
+	wg := &sync.WaitGroup{}
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < responses; i++ {
+				seq := uint64(w*responses + i)
+				body := strings.Repeat(strconv.FormatUint(seq, 10)+",", 50)
+				assert.NoError(t, c.WriteResponse(&rpc.Response{ServiceMethod: "test.Concurrent", Seq: seq}, body))
+			}
+		}()
+	}
+
+	seen := make(map[uint64]bool, writers*responses)
+	for i := 0; i < writers*responses; i++ {
+		fr := frame.NewFrame()
+		require.NoError(t, rl.Receive(fr))
+
+		opts := fr.ReadOptions(fr.Header())
+		require.Len(t, opts, 2)
+		payload := fr.Payload()
+		require.Equal(t, "test.Concurrent", string(payload[:opts[1]]))
+
+		var body string
+		require.NoError(t, gob.NewDecoder(bytes.NewReader(payload[opts[1]:])).Decode(&body))
+		assert.Equal(t, strings.Repeat(strconv.FormatUint(uint64(opts[0]), 10)+",", 50), body)
+		seen[uint64(opts[0])] = true
+	}
+
+	wg.Wait()
+	assert.Len(t, seen, writers*responses)
+}
+
+func TestCodec_TruncatedFrame(t *testing.T) {
+	body, err := json.Marshal(Payload{Name: "goridge", Value: 42})
+	require.NoError(t, err)
+	data := requestFrame(1, "test.Truncated", frame.CodecJSON, body).Bytes()
+
+	// the peer closes the connection mid-payload
+	server, client := net.Pipe()
+	c := NewCodec(server)
+	go func() {
+		_, _ = client.Write(data[:len(data)-5])
+		_ = client.Close()
+	}()
+
+	r := &rpc.Request{}
+	err = c.ReadRequestHeader(r)
+	assert.True(t, err == io.ErrUnexpectedEOF, err) //nolint:errorlint
+	_ = c.Close()
+
+	// rpc.ServeCodec stops serving the half-closed connection
+	server, client = net.Pipe()
+	done := make(chan struct{})
+	go func() {
+		rpc.ServeCodec(NewCodec(server))
+		close(done)
+	}()
+	_, _ = client.Write(data[:len(data)-5])
+	_ = client.Close()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second * 5):
+		t.Fatal("ServeCodec didn't stop")
+	}
+
+	// the relay delivered the frame with the payload shorter than declared
+	fr := requestFrame(2, "test.Truncated", frame.CodecJSON, body)
+	fr.WritePayload(fr.Payload()[:len(fr.Payload())-5])
+	c = NewCodecWithRelay(&loopRelay{req: fr})
+
+	require.NoError(t, c.ReadRequestHeader(r))
+	assert.Equal(t, "test.Truncated", r.ServiceMethod)
+	out := &Payload{}
+	err = c.ReadRequestBody(out)
+	assert.True(t, err == io.ErrUnexpectedEOF, err) //nolint:errorlint
+}
+
+func TestCodec_WriteResponseNilBody(t *testing.T) {
+	c, rl := pipeCodec(t)
+
+	codecs := []byte{frame.CodecProto, frame.CodecJSON, frame.CodecRaw, frame.CodecMsgpack, frame.CodecGob,
+		frame.CodecFlatbuffers}
+	for i, codec := range codecs {
+		seq := uint64(i + 1)
+		c.codec.Store(seq, codec)
+
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- c.WriteResponse(&rpc.Response{ServiceMethod: "test.Nil", Seq: seq}, nil)
+		}()
+
+		fr := frame.NewFrame()
+		require.NoError(t, rl.Receive(fr))
+		require.NoError(t, <-errCh)
+
+		assert.Equal(t, codec, fr.ReadFlags(), "codec 0x%02x", codec)
+		assert.Equal(t, []byte("test.Nil"), fr.Payload(), "codec 0x%02x", codec)
+	}
+}
+
+func BenchmarkCodec_WriteResponseLarge(b *testing.B) {
+	body := bytes.Repeat([]byte("goridge"), 1<<17)
+	c := NewCodecWithRelay(&loopRelay{})
+	resp := &rpc.Response{ServiceMethod: "test.Large", Seq: 1}
+
+	b.SetBytes(int64(len(body)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c.codec.Store(uint64(1), frame.CodecRaw)
+		_ = c.WriteResponse(resp, body)
+	}
+}
+
+type recordingRelay struct {
+	loopRelay
+	sent [][]byte
+}
+
+func (r *recordingRelay) Send(fr *frame.Frame) error {
+	r.sent = append(r.sent, fr.Bytes())
+	return nil
+}
+
+func TestCodec_WriteResponseNoCopy(t *testing.T) {
+	rl := &recordingRelay{}
+	c := NewCodecWithRelay(rl)
+
+	body := bytes.Repeat([]byte("goridge"), 1024)
+	for seq := uint64(1); seq <= 2; seq++ {
+		c.codec.Store(seq, frame.CodecRaw)
+		require.NoError(t, c.WriteResponse(&rpc.Response{ServiceMethod: "test.Large", Seq: seq}, body))
+	}
+
+	// the frames sent with the pooled buffers match the ones with the copied payload
+	for i, sent := range rl.sent {
+		expected := frame.NewFrame()
+		expected.WriteOptions(expected.HeaderPtr(), uint32(i+1), uint32(len("test.Large")))
+		expected.WriteVersion(expected.Header(), frame.Version1)
+		expected.WriteFlags(expected.Header(), frame.CodecRaw)
+		expected.WritePayloadLen(expected.Header(), uint32(len("test.Large")+len(body)))
+		expected.WritePayload(append([]byte("test.Large"), body...))
+		expected.WriteCRC(expected.Header())
+		assert.Equal(t, expected.Bytes(), sent)
+	}
+}
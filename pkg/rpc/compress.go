@@ -0,0 +1,137 @@
+package rpc
+
+import (
+	"bytes"
+	"compress/gzip"
+	stderr "errors"
+	"io"
+	"sync"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+)
+
+// DefaultDecompressionLimit is the default size limit of the decompressed bodies, see ClientCodec.SetDecompressionLimit
+const DefaultDecompressionLimit = 256 * 1024 * 1024
+
+// ErrCompressionDisabled is returned for the compressed frames received by the codec without the compression enabled
+var ErrCompressionDisabled = errors.Str("compressed frame received, the compression is not enabled") //nolint:gochecknoglobals
+
+// zstd encoder and decoders are safe for concurrent use via EncodeAll/DecodeAll, the decoders by the limit
+// of the decoded size
+var (
+	zstdOnce sync.Once //nolint:gochecknoglobals
+	zstdEnc  *zstd.Encoder
+	zstdDecs sync.Map //nolint:gochecknoglobals
+)
+
+func initZstd() {
+	// errors are possible only with the incorrect options
+	zstdEnc, _ = zstd.NewWriter(nil)
+}
+
+// zstdDecoder returns the shared decoder rejecting the data decoded to more than limit bytes, 0 - unlimited
+func zstdDecoder(limit int) *zstd.Decoder {
+	if dec, ok := zstdDecs.Load(limit); ok {
+		return dec.(*zstd.Decoder)
+	}
+
+	var opts []zstd.DOption
+	if limit > 0 {
+		opts = append(opts, zstd.WithDecoderMaxMemory(uint64(limit)))
+	}
+	// errors are possible only with the incorrect options
+	dec, _ := zstd.NewReader(nil, opts...)
+	actual, loaded := zstdDecs.LoadOrStore(limit, dec)
+	if loaded {
+		dec.Close()
+	}
+
+	return actual.(*zstd.Decoder)
+}
+
+// SetDecompressionLimit sets the size limit of the decompressed responses (see Codec.SetCompression) in bytes,
+// the bigger bodies are rejected with frame.FrameTooLargeError. Default - DefaultDecompressionLimit, 0 disables
+// the limit.
+// Should be called before the codec is used.
+func (c *ClientCodec) SetDecompressionLimit(limit int) error {
+	const op = errors.Op("goridge_set_decompression_limit")
+	if limit < 0 {
+		return errors.E(op, errors.Errorf("decompression limit should not be negative, got: %d", limit))
+	}
+
+	c.decompressLimit = limit
+	return nil
+}
+
+// compress appends data compressed with the algorithm to the dst
+func compress(algorithm byte, dst *bytes.Buffer, data []byte) error {
+	const op = errors.Op("goridge_compress")
+
+	switch algorithm {
+	case frame.CompressedGzip:
+		gw := gzip.NewWriter(dst)
+		_, err := gw.Write(data)
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		err = gw.Close()
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		return nil
+	case frame.CompressedZstd:
+		zstdOnce.Do(initZstd)
+		dst.Write(zstdEnc.EncodeAll(data, make([]byte, 0, len(data))))
+		return nil
+	default:
+		return errors.E(op, errors.Errorf("unknown compression algorithm: %d", algorithm))
+	}
+}
+
+// decompress inflates data compressed with the algorithm, the data inflated to more than limit bytes (0 - unlimited)
+// is rejected with frame.FrameTooLargeError, so the small compressed bombs don't bypass the read limit
+func decompress(algorithm byte, data []byte, limit int) ([]byte, error) {
+	const op = errors.Op("goridge_decompress")
+
+	switch algorithm {
+	case frame.CompressedGzip:
+		gr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+
+		var r io.Reader = gr
+		if limit > 0 {
+			// one byte more to detect the overflow
+			r = io.LimitReader(gr, int64(limit)+1)
+		}
+
+		out, err := io.ReadAll(r)
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+		if limit > 0 && len(out) > limit {
+			// unwrapped to be matched with errors.Is, the size is only known to exceed the limit
+			return nil, &frame.FrameTooLargeError{Size: uint64(len(out)), Limit: uint64(limit)}
+		}
+
+		return out, nil
+	case frame.CompressedZstd:
+		out, err := zstdDecoder(limit).DecodeAll(data, nil)
+		if stderr.Is(err, zstd.ErrDecoderSizeExceeded) {
+			// the size is only known to exceed the limit
+			return nil, &frame.FrameTooLargeError{Size: uint64(limit) + 1, Limit: uint64(limit)}
+		}
+		if err != nil {
+			return nil, errors.E(op, err)
+		}
+
+		return out, nil
+	default:
+		return nil, errors.E(op, errors.Errorf("unknown compression algorithm: %d", algorithm))
+	}
+}
@@ -0,0 +1,49 @@
+package rpc
+
+import (
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/roadrunner-server/goridge/v3/pkg/relay"
+)
+
+// SetCRCDisabled disables the header CRC of the sent frames (frame.CRCDisabled bit) and switches the relay,
+// if it supports it, to the frame.CRCTrusted policy to skip the verification of the received ones.
+// Only for the trusted transports (e.g. loopback unix sockets), the peer should be configured the same way.
+// Should be called before the codec is used.
+func (c *Codec) SetCRCDisabled(disabled bool) {
+	c.crcDisabled = disabled
+	setCRCPolicy(c.relay, disabled)
+}
+
+// SetCRCDisabled disables the header CRC of the sent requests, see Codec.SetCRCDisabled.
+// Should be called before the codec is used.
+func (c *ClientCodec) SetCRCDisabled(disabled bool) {
+	c.crcDisabled = disabled
+	setCRCPolicy(c.relay, disabled)
+}
+
+func setCRCPolicy(rl relay.Relay, disabled bool) {
+	type crcPolicySetter interface {
+		SetCRCPolicy(policy frame.CRCPolicy)
+	}
+
+	s, ok := rl.(crcPolicySetter)
+	if !ok {
+		return
+	}
+
+	if disabled {
+		s.SetCRCPolicy(frame.CRCTrusted)
+	} else {
+		s.SetCRCPolicy(frame.CRCRequired)
+	}
+}
+
+// writeCRC writes the header CRC or marks the frame as sent without it
+func writeCRC(fr *frame.Frame, disabled bool) {
+	if disabled {
+		fr.SetCRCDisabled(fr.Header())
+		return
+	}
+
+	fr.WriteCRC(fr.Header())
+}
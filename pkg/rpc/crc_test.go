@@ -0,0 +1,93 @@
+package rpc
+
+import (
+	"net"
+	"net/rpc"
+	"testing"
+
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/roadrunner-server/goridge/v3/pkg/pipe"
+	"github.com/roadrunner-server/goridge/v3/pkg/socket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodec_SetCRCDisabled(t *testing.T) {
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("pair", new(panicService)))
+
+	srv, cl := pipe.NewRelayPair()
+	sc := NewCodecWithRelay(srv)
+	sc.SetCRCDisabled(true)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- ServeConn(server, sc, nil)
+	}()
+
+	cc := NewClientCodecWithRelay(cl)
+	cc.SetCRCDisabled(true)
+	client := rpc.NewClientWithCodec(cc)
+
+	var out string
+	require.NoError(t, client.Call("pair.Echo", "no crc", &out))
+	assert.Equal(t, "no crc", out)
+
+	require.NoError(t, client.Close())
+	require.NoError(t, <-done)
+}
+
+func TestCodec_SetCRCDisabledRequiredPeer(t *testing.T) {
+	c, rl := pipeCodec(t)
+	c.SetCRCDisabled(true)
+
+	go func() {
+		_ = c.WriteResponse(&rpc.Response{ServiceMethod: "test.CRC", Seq: 1}, "body")
+	}()
+
+	// the peer with the default policy rejects the frames without CRC
+	err := rl.Receive(frame.NewFrame())
+	assert.ErrorIs(t, err, frame.ErrCRCRequired)
+}
+
+func BenchmarkCodec_CRC(b *testing.B) {
+	for _, disabled := range []bool{false, true} {
+		name := "enabled"
+		if disabled {
+			name = "disabled"
+		}
+
+		b.Run(name, func(b *testing.B) {
+			server, client := net.Pipe()
+			c := NewCodec(server)
+			c.SetCRCDisabled(disabled)
+			rl := socket.NewSocketRelay(client)
+			if disabled {
+				rl.SetCRCPolicy(frame.CRCTrusted)
+			}
+			defer func() {
+				_ = c.Close()
+				_ = rl.Close()
+			}()
+
+			go func() {
+				fr := frame.NewFrame()
+				for {
+					fr.Reset()
+					if rl.Receive(fr) != nil {
+						return
+					}
+				}
+			}()
+
+			r := &rpc.Response{ServiceMethod: "test.CRC"}
+			body := []byte("payload")
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				r.Seq = uint64(i)
+				_ = c.WriteResponse(r, &body)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package rpc
+
+import (
+	"net/rpc"
+
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"google.golang.org/protobuf/proto"
+)
+
+// PreEncoded is a response body encoded once and sent with WriteEncodedResponse to any number of codecs.
+// PreEncoded is immutable and safe for the concurrent use.
+type PreEncoded struct {
+	flag byte
+	body []byte
+}
+
+// PreMarshal marshals the proto message for WriteEncodedResponse, e.g. to broadcast the same response
+// to many clients without re-marshaling it per client.
+func PreMarshal(msg proto.Message) (*PreEncoded, error) {
+	const op = errors.Op("goridge_pre_marshal")
+
+	body, err := proto.Marshal(msg)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return &PreEncoded{flag: frame.CodecProto, body: body}, nil
+}
+
+// WriteEncodedResponse writes the pre-encoded body as the response, the frame codec is the codec of the body
+// instead of the codec of the request. The error of the response is sent like in WriteResponse.
+func (c *Codec) WriteEncodedResponse(r *rpc.Response, body *PreEncoded) error {
+	const op = errors.Op("goridge_write_encoded_response")
+	r = c.noPrefixResponse(r)
+	// frees the request codec and the in-flight slot
+	codec, err := c.loadCodec(r)
+	if err != nil {
+		return err
+	}
+
+	fr, err := c.responseFrame(r)
+	if err != nil {
+		return errors.E(op, err)
+	}
+	defer c.putFrame(fr)
+
+	if r.Error != "" {
+		fr.WriteFlags(fr.Header(), codec)
+		return c.handleError(r, fr, r.Error)
+	}
+
+	if body == nil {
+		fr.WriteFlags(fr.Header(), codec)
+		return c.handleError(r, fr, errors.E(op, errors.Str("nil pre-encoded body")).Error())
+	}
+
+	fr.WriteFlags(fr.Header(), body.flag)
+
+	buf := c.get()
+	defer c.put(buf)
+
+	buf.WriteString(r.ServiceMethod)
+	buf.Write(body.body)
+
+	return c.send(r, fr, buf)
+}
@@ -0,0 +1,63 @@
+package rpc
+
+import (
+	"net/rpc"
+	"testing"
+
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/roadrunner-server/goridge/v3/tests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestCodec_WriteEncodedResponse(t *testing.T) {
+	msg := &tests.Payload{Storage: "broadcast", Items: []*tests.Item{{Key: "a", Value: "b"}}}
+	pe, err := PreMarshal(msg)
+	require.NoError(t, err)
+
+	for seq := uint32(1); seq <= 3; seq++ {
+		c, rl := pipeCodec(t)
+
+		go func() {
+			// the request codec doesn't matter
+			_ = rl.Send(requestFrame(seq, "test.Broadcast", frame.CodecJSON, []byte(`{}`)))
+		}()
+
+		r := &rpc.Request{}
+		require.NoError(t, c.ReadRequestHeader(r))
+		require.NoError(t, c.ReadRequestBody(&map[string]any{}))
+
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- c.WriteEncodedResponse(&rpc.Response{ServiceMethod: r.ServiceMethod, Seq: r.Seq}, pe)
+		}()
+
+		fr := frame.NewFrame()
+		require.NoError(t, rl.Receive(fr))
+		require.NoError(t, <-errCh)
+
+		assert.Equal(t, frame.CodecProto, fr.ReadFlags())
+		assert.Equal(t, uint32(seq), fr.ReadOptions(fr.Header())[0])
+
+		out := &tests.Payload{}
+		require.NoError(t, proto.Unmarshal(fr.Payload()[len("test.Broadcast"):], out))
+		assert.True(t, proto.Equal(msg, out))
+	}
+}
+
+func TestCodec_WriteEncodedResponseError(t *testing.T) {
+	c, rl := pipeCodec(t)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.WriteEncodedResponse(&rpc.Response{ServiceMethod: "test.Broadcast", Seq: 1, Error: "failed"}, nil)
+	}()
+
+	fr := frame.NewFrame()
+	require.NoError(t, rl.Receive(fr))
+	assert.Error(t, <-errCh)
+
+	assert.NotZero(t, fr.ReadFlags()&frame.ERROR)
+	assert.Equal(t, []byte("test.Broadcastfailed"), fr.Payload())
+}
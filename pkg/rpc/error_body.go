@@ -0,0 +1,154 @@
+package rpc
+
+import (
+	stderr "errors"
+	"net/rpc"
+	"strconv"
+	"strings"
+
+	"github.com/roadrunner-server/errors"
+)
+
+// errorBodyPrefix starts the string form of the ErrorBody
+const errorBodyPrefix = "goridge error body "
+
+// ErrorBody is the error response which payload is the error of any type (e.g. a proto or msgpack error struct)
+// encoded with the codec of the request, see Codec.WriteErrorResponse. Use AsErrorBody to get it back from
+// the client call error and Decode to decode the payload.
+type ErrorBody struct {
+	// Codec is the codec flag of the payload
+	Codec byte
+	// Payload is the encoded error
+	Payload []byte
+
+	// JSON implementation and msgpack options of the client codec, nil - default
+	json    JSONCodec
+	msgpack *MsgpackOptions
+}
+
+// Error returns the string form of the error: "goridge error body <codec>: <payload>".
+func (e *ErrorBody) Error() string {
+	return errorBodyPrefix + strconv.FormatUint(uint64(e.Codec), 10) + ": " + string(e.Payload)
+}
+
+// Decode decodes the payload into out with the registered codec of the error. The JSON implementation and
+// the msgpack options are the ones of the client codec (see ClientCodec.AsErrorBody and RoundTrip),
+// the default ones otherwise.
+func (e *ErrorBody) Decode(out any) error {
+	const op = errors.Op("goridge_error_body_decode")
+	entry, ok := lookupCodecFor(e.Codec, e.json, e.msgpack)
+	if !ok {
+		return errors.E(op, errors.Errorf("unknown codec: %d", e.Codec))
+	}
+
+	err := entry.dec.Decode(e.Payload, out)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// AsErrorBody returns the ErrorBody from the err: the ErrorBody itself or the rpc.ServerError returned by
+// the rpc.Client call.
+func AsErrorBody(err error) (*ErrorBody, bool) {
+	var e *ErrorBody
+	if stderr.As(err, &e) {
+		return e, true
+	}
+
+	var se rpc.ServerError
+	if stderr.As(err, &se) {
+		return parseErrorBody(string(se))
+	}
+
+	return nil, false
+}
+
+// AsErrorBody returns the ErrorBody from the err (see AsErrorBody), decoded with the JSON implementation and
+// the msgpack options of the codec.
+func (c *ClientCodec) AsErrorBody(err error) (*ErrorBody, bool) {
+	e, ok := AsErrorBody(err)
+	if !ok {
+		return nil, false
+	}
+
+	body := *e
+	body.json, body.msgpack = c.json, c.msgpack
+	return &body, true
+}
+
+// parseErrorBody parses the string form of the ErrorBody, net/rpc passes the errors as strings
+func parseErrorBody(s string) (*ErrorBody, bool) {
+	rest, ok := strings.CutPrefix(s, errorBodyPrefix)
+	if !ok {
+		return nil, false
+	}
+
+	codec, payload, ok := strings.Cut(rest, ": ")
+	if !ok {
+		return nil, false
+	}
+
+	c, err := strconv.ParseUint(codec, 10, 8)
+	if err != nil {
+		return nil, false
+	}
+
+	return &ErrorBody{Codec: byte(c), Payload: []byte(payload)}, true
+}
+
+// WriteErrorResponse sends the error response which payload is the body encoded with the codec of the request,
+// e.g. the error struct of the service, instead of the error string written by WriteResponse. The client gets it
+// as ErrorBody (see AsErrorBody). When the body can't be encoded, the encoding error is sent as the error string.
+// It's meant for the codecs driven by own loop: rpc.Server passes the errors of the methods to WriteResponse
+// as strings.
+func (c *Codec) WriteErrorResponse(r *rpc.Response, body any) error {
+	const op = errors.Op("goridge_write_error_response")
+	r = c.noPrefixResponse(r)
+	codec, err := c.loadCodec(r)
+	if err != nil {
+		return err
+	}
+
+	fr, err := c.responseFrame(r)
+	if err != nil {
+		return errors.E(op, err)
+	}
+	defer c.putFrame(fr)
+
+	fr.WriteFlags(fr.Header(), codec)
+
+	buf := c.get()
+	defer c.put(buf)
+	buf.WriteString(r.ServiceMethod)
+
+	entry, ok := lookupCodecFor(codec, c.json, c.msgpack)
+	if !ok {
+		return c.handleError(&rpc.Response{ServiceMethod: r.ServiceMethod, Seq: r.Seq, Error: "unknown codec"}, fr, "unknown codec")
+	}
+
+	err = entry.enc.Encode(body, buf)
+	if err != nil {
+		return c.handleError(&rpc.Response{ServiceMethod: r.ServiceMethod, Seq: r.Seq, Error: err.Error()}, fr, err.Error())
+	}
+
+	fr.SetFlags(fr.Flags().WithError())
+	fr.SetErrorBodyBit(fr.Header())
+	err = c.send(r, fr, buf)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// readErrorBody returns the string form of the ErrorBody of the received error frame
+func (c *ClientCodec) readErrorBody() (string, bool) {
+	payload, err := c.payload()
+	if err != nil {
+		return "", false
+	}
+
+	return (&ErrorBody{Codec: byte(c.frame.Flags().Codec()), Payload: payload}).Error(), true
+}
@@ -0,0 +1,177 @@
+package rpc
+
+import (
+	"encoding/json"
+	"net/rpc"
+	"testing"
+
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type validationError struct {
+	Field   string   `json:"field" msgpack:"field"`
+	Reasons []string `json:"reasons" msgpack:"reasons"`
+}
+
+func TestCodec_WriteErrorResponse(t *testing.T) {
+	c, rl := pipeCodec(t)
+	client := NewClientCodecWithRelay(rl)
+	verr := validationError{Field: "name", Reasons: []string{"empty", "too short"}}
+
+	for seq, codec := range map[uint64]byte{1: frame.CodecJSON, 2: frame.CodecMsgpack} {
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- client.WriteRequest(&rpc.Request{ServiceMethod: "test.Validate", Seq: seq}, WithCodec(Payload{}, codec))
+		}()
+
+		r := &rpc.Request{}
+		require.NoError(t, c.ReadRequestHeader(r))
+		require.NoError(t, c.ReadRequestBody(&Payload{}))
+		require.NoError(t, <-errCh)
+
+		go func() {
+			errCh <- c.WriteErrorResponse(&rpc.Response{ServiceMethod: r.ServiceMethod, Seq: r.Seq}, verr)
+		}()
+
+		// the error is a string for net/rpc
+		resp := &rpc.Response{}
+		require.NoError(t, client.ReadResponseHeader(resp))
+		require.NoError(t, client.ReadResponseBody(nil))
+		require.NoError(t, <-errCh)
+		assert.Equal(t, seq, resp.Seq)
+
+		// and is decoded with the codec of the request
+		e, ok := AsErrorBody(rpc.ServerError(resp.Error))
+		require.True(t, ok)
+		assert.Equal(t, codec, e.Codec)
+
+		out := validationError{}
+		require.NoError(t, e.Decode(&out))
+		assert.Equal(t, verr, out)
+	}
+}
+
+func TestCodec_WriteErrorResponseWire(t *testing.T) {
+	c, rl := pipeCodec(t)
+
+	go func() {
+		assert.NoError(t, rl.Send(requestFrame(1, "test.Validate", frame.CodecJSON, []byte(`{"name":"a"}`))))
+	}()
+	r := &rpc.Request{}
+	require.NoError(t, c.ReadRequestHeader(r))
+	require.NoError(t, c.ReadRequestBody(&Payload{}))
+
+	go func() {
+		_ = c.WriteErrorResponse(&rpc.Response{ServiceMethod: r.ServiceMethod, Seq: r.Seq}, validationError{Field: "name"})
+	}()
+
+	fr := frame.NewFrame()
+	require.NoError(t, rl.Receive(fr))
+	assert.Equal(t, frame.ERROR|frame.CodecJSON, fr.ReadFlags())
+	assert.True(t, fr.IsErrorBody(fr.Header()))
+	assert.False(t, fr.IsStructuredError(fr.Header()))
+
+	opts := fr.ReadOptions(fr.Header())
+	var out validationError
+	require.NoError(t, json.Unmarshal(fr.Payload()[opts[1]:], &out))
+	assert.Equal(t, "name", out.Field)
+
+	// the body which can't be encoded is sent as the error string
+	go func() {
+		assert.NoError(t, rl.Send(requestFrame(2, "test.Validate", frame.CodecJSON, []byte(`{}`))))
+	}()
+	require.NoError(t, c.ReadRequestHeader(r))
+	require.NoError(t, c.ReadRequestBody(&Payload{}))
+
+	go func() {
+		_ = c.WriteErrorResponse(&rpc.Response{ServiceMethod: r.ServiceMethod, Seq: r.Seq}, make(chan int))
+	}()
+
+	fr = frame.NewFrame()
+	require.NoError(t, rl.Receive(fr))
+	assert.True(t, fr.Flags().IsError())
+	assert.False(t, fr.IsErrorBody(fr.Header()))
+	assert.Contains(t, string(fr.Payload()), "unsupported type")
+}
+
+func TestClientCodec_RoundTripErrorBody(t *testing.T) {
+	c, rl := pipeCodec(t)
+	client := NewClientCodecWithRelay(rl)
+
+	go func() {
+		r := &rpc.Request{}
+		if c.ReadRequestHeader(r) != nil || c.ReadRequestBody(&Payload{}) != nil {
+			return
+		}
+		_ = c.WriteErrorResponse(&rpc.Response{ServiceMethod: r.ServiceMethod, Seq: r.Seq},
+			validationError{Field: "value", Reasons: []string{"negative"}})
+	}()
+
+	err := client.RoundTrip("test.Validate", frame.CodecMsgpack, Payload{}, &Payload{})
+	e, ok := AsErrorBody(err)
+	require.True(t, ok)
+	assert.Equal(t, frame.CodecMsgpack, e.Codec)
+
+	var out validationError
+	require.NoError(t, msgpack.Unmarshal(e.Payload, &out))
+	assert.Equal(t, validationError{Field: "value", Reasons: []string{"negative"}}, out)
+}
+
+func TestAsErrorBody(t *testing.T) {
+	e, ok := AsErrorBody(rpc.ServerError("goridge error body 8: {\"field\":\"a\"}"))
+	require.True(t, ok)
+	assert.Equal(t, &ErrorBody{Codec: frame.CodecJSON, Payload: []byte(`{"field":"a"}`)}, e)
+
+	for _, s := range []string{"", "goridge error body", "goridge error body x: y", "goridge error body 300: y", "goridge error 1: y"} {
+		_, ok = AsErrorBody(rpc.ServerError(s))
+		assert.False(t, ok, s)
+	}
+}
+
+func TestClientCodec_AsErrorBodyMsgpackOptions(t *testing.T) {
+	opts := &MsgpackOptions{CustomStructTag: "custom"}
+	c, rl := pipeCodec(t)
+	c.SetMsgpackOptions(opts)
+	client := NewClientCodecWithRelay(rl)
+	client.SetMsgpackOptions(opts)
+
+	type customError struct {
+		Field string `custom:"f"`
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- client.WriteRequest(&rpc.Request{ServiceMethod: "test.Validate", Seq: 1}, WithCodec(Payload{}, frame.CodecMsgpack))
+	}()
+
+	r := &rpc.Request{}
+	require.NoError(t, c.ReadRequestHeader(r))
+	require.NoError(t, c.ReadRequestBody(&Payload{}))
+	require.NoError(t, <-errCh)
+
+	go func() {
+		errCh <- c.WriteErrorResponse(&rpc.Response{ServiceMethod: r.ServiceMethod, Seq: r.Seq}, customError{Field: "name"})
+	}()
+
+	resp := &rpc.Response{}
+	require.NoError(t, client.ReadResponseHeader(resp))
+	require.NoError(t, client.ReadResponseBody(nil))
+	require.NoError(t, <-errCh)
+
+	// decoded with the msgpack options of the client codec
+	e, ok := client.AsErrorBody(rpc.ServerError(resp.Error))
+	require.True(t, ok)
+	out := customError{}
+	require.NoError(t, e.Decode(&out))
+	assert.Equal(t, "name", out.Field)
+
+	// the default decoder doesn't know the custom tag
+	e, ok = AsErrorBody(rpc.ServerError(resp.Error))
+	require.True(t, ok)
+	out = customError{}
+	require.NoError(t, e.Decode(&out))
+	assert.Empty(t, out.Field)
+}
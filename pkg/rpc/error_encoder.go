@@ -0,0 +1,31 @@
+package rpc
+
+import (
+	"bytes"
+	"net/rpc"
+
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+)
+
+// ErrorEncoder returns the payload of the error response (written after the service method) and the codec flag
+// of the payload (0 - the codec of the request), e.g. to send the errors in a standard envelope with the code
+// and the retryable flag. errStr is the error of the service method.
+type ErrorEncoder func(r *rpc.Response, errStr string) ([]byte, byte)
+
+// SetErrorEncoder sets the encoder of the error responses, replacing the plain error string (and the structured
+// Error). The client gets the payload as the error string. Nil restores the default.
+// Should be called before the codec is used.
+func (c *Codec) SetErrorEncoder(enc ErrorEncoder) {
+	c.errorEncoder = enc
+}
+
+// writeEncodedError writes the error payload returned by the ErrorEncoder and replaces the codec flag
+func (c *Codec) writeEncodedError(r *rpc.Response, fr *frame.Frame, buf *bytes.Buffer, err string) {
+	payload, codec := c.errorEncoder(r, err)
+	if codec != 0 {
+		fr.ResetFlags(fr.Header())
+		fr.WriteFlags(fr.Header(), codec)
+	}
+
+	buf.Write(payload)
+}
@@ -0,0 +1,89 @@
+package rpc
+
+import (
+	"net/rpc"
+	"strings"
+	"testing"
+
+	"github.com/goccy/go-json"
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/roadrunner-server/goridge/v3/pkg/pipe"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type errorEnvelope struct {
+	Code      string `json:"code"`
+	Retryable bool   `json:"retryable"`
+	Method    string `json:"method"`
+	Details   string `json:"details"`
+}
+
+func jsonErrorEncoder(r *rpc.Response, errStr string) ([]byte, byte) {
+	data, _ := json.Marshal(errorEnvelope{
+		Code:      "internal",
+		Retryable: strings.Contains(errStr, "plain"),
+		Method:    r.ServiceMethod,
+		Details:   errStr,
+	})
+	return data, frame.CodecJSON
+}
+
+func TestCodec_SetErrorEncoder(t *testing.T) {
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("errors", errorService{}))
+
+	srv, cl := pipe.NewRelayPair()
+	codec := NewCodecWithRelay(srv)
+	codec.SetErrorEncoder(jsonErrorEncoder)
+	go func() {
+		_ = ServeConn(server, codec, nil)
+	}()
+
+	client := rpc.NewClientWithCodec(NewClientCodecWithRelay(cl))
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	// the gob request gets the JSON error, the Error is not encoded as the structured error
+	for _, method := range []string{"errors.Plain", "errors.NotFound"} {
+		err := client.Call(method, Payload{Name: "a"}, &Payload{})
+		se, ok := err.(rpc.ServerError) //nolint:errorlint
+		require.True(t, ok, err)
+
+		var e errorEnvelope
+		require.NoError(t, json.Unmarshal([]byte(se), &e))
+		assert.Equal(t, "internal", e.Code)
+		assert.Equal(t, method, e.Method)
+		assert.Equal(t, method == "errors.Plain", e.Retryable)
+	}
+}
+
+func TestCodec_SetErrorEncoderWire(t *testing.T) {
+	c, rl := pipeCodec(t)
+	c.SetErrorEncoder(jsonErrorEncoder)
+
+	go func() {
+		assert.NoError(t, rl.Send(requestFrame(1, "errors.Plain", frame.CodecMsgpack, []byte{0x80})))
+	}()
+	r := &rpc.Request{}
+	require.NoError(t, c.ReadRequestHeader(r))
+	require.NoError(t, c.ReadRequestBody(&Payload{}))
+
+	go func() {
+		_ = c.WriteResponse(&rpc.Response{ServiceMethod: r.ServiceMethod, Seq: r.Seq, Error: "plain error"}, nil)
+	}()
+
+	// the codec of the request is replaced by the codec of the encoder
+	fr := frame.NewFrame()
+	require.NoError(t, rl.Receive(fr))
+	assert.Equal(t, frame.ERROR|frame.CodecJSON, fr.ReadFlags())
+	assert.False(t, fr.IsStructuredError(fr.Header()))
+
+	opts := fr.ReadOptions(fr.Header())
+	assert.Equal(t, "errors.Plain", string(fr.Payload()[:opts[1]]))
+
+	var e errorEnvelope
+	require.NoError(t, json.Unmarshal(fr.Payload()[opts[1]:], &e))
+	assert.Equal(t, errorEnvelope{Code: "internal", Retryable: true, Method: "errors.Plain", Details: "plain error"}, e)
+}
@@ -0,0 +1,43 @@
+package rpc
+
+// EventType describes the protocol lifecycle event
+type EventType uint8
+
+const (
+	// EventFrameReceived is emitted when the request frame was received and parsed
+	EventFrameReceived EventType = iota + 1
+	// EventFrameSent is emitted when the response frame was sent
+	EventFrameSent
+	// EventReceiveError is emitted when the frame was not received,
+	// including CRC validation failures (frame.ErrCRCMismatch)
+	EventReceiveError
+	// EventCodecStored is emitted when the request codec was stored for the sequence
+	EventCodecStored
+	// EventCodecEvicted is emitted when the stored codec was removed after the response or when the sequence
+	// expired (Err is ErrSequenceExpired), see Codec.SetSequenceTTL
+	EventCodecEvicted
+	// EventConnectionClosed is emitted when the codec was closed
+	EventConnectionClosed
+)
+
+// Event is a protocol lifecycle event. Only the fields relevant to the event Type are set.
+type Event struct {
+	Type EventType
+	// Seq - request sequence ID
+	Seq uint64
+	// Method - service method
+	Method string
+	// Flags - frame flags for the received/sent frames, codec flag for the codec events
+	Flags byte
+	// PayloadLen - frame payload length in bytes
+	PayloadLen int
+	// Err - error for the EventReceiveError and the expired sequences
+	Err error
+}
+
+// SetEventSink sets the function which receives the protocol lifecycle events, nil disables the events.
+// Sink is called synchronously from the codec methods, so it should not block.
+// Should be called before the codec is used.
+func (c *Codec) SetEventSink(sink func(Event)) {
+	c.sink = sink
+}
@@ -0,0 +1,143 @@
+package rpc
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/roadrunner-server/errors"
+)
+
+// ErrSequenceExpired is passed to the request hook of the sequences evicted without the response and returned
+// by WriteResponse for the late responses of them, see SetSequenceTTL
+var ErrSequenceExpired = errors.Str("sequence expired without the response") //nolint:gochecknoglobals
+
+// SetSequenceTTL enables the eviction of the sequences not answered within the ttl (e.g. the handler panicked or
+// the call was abandoned): their codecs, options, contexts, pooled messages and the in-flight slots are released,
+// the request hook gets ErrSequenceExpired. The sequences are checked on ReadRequestHeader (and while it waits
+// for the SetMaxInFlight slot), at most once per ttl/2, the evictions are counted in Stats.Expired. The late
+// response of the evicted sequence (written within the ttl after the eviction) is dropped, WriteResponse returns
+// ErrSequenceExpired. 0 disables the eviction (the default).
+// To limit the number of the sequences awaiting the response use SetMaxInFlight.
+// Should be called before the codec is used.
+func (c *Codec) SetSequenceTTL(ttl time.Duration) error {
+	const op = errors.Op("goridge_set_sequence_ttl")
+	if ttl < 0 {
+		return errors.E(op, errors.Errorf("ttl should not be negative, got: %s", ttl))
+	}
+
+	c.seqTTL = ttl
+	return nil
+}
+
+// trackSequence records the time the request header of the sequence was read and evicts the expired sequences
+func (c *Codec) trackSequence(seq uint64) {
+	now := time.Now().UnixNano()
+	c.seqStarted.Store(seq, now)
+	c.expireSequences(now)
+}
+
+// acquireExpiring waits for the free in-flight slot evicting the expired sequences, which slots would never be freed
+func (c *Codec) acquireExpiring(ctx context.Context) error {
+	select {
+	case c.inFlight <- struct{}{}:
+		return nil
+	default:
+	}
+
+	ticker := time.NewTicker(max(c.seqTTL/2, time.Millisecond))
+	defer ticker.Stop()
+
+	for {
+		c.expireSequences(time.Now().UnixNano())
+
+		select {
+		case c.inFlight <- struct{}{}:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// expireSequences evicts the sequences older than the ttl, at most once per ttl/2
+func (c *Codec) expireSequences(now int64) {
+	last := c.lastExpire.Load()
+	if now-last < int64(c.seqTTL/2) || !c.lastExpire.CompareAndSwap(last, now) {
+		return
+	}
+
+	c.seqStarted.Range(func(key, started any) bool {
+		if now-started.(int64) >= int64(c.seqTTL) {
+			c.expireSequence(key.(uint64), now)
+		}
+		return true
+	})
+
+	// the late responses are not awaited forever
+	c.seqExpired.Range(func(key, expired any) bool {
+		if now-expired.(int64) >= int64(c.seqTTL) {
+			c.seqExpired.Delete(key)
+		}
+		return true
+	})
+}
+
+// expireSequence releases the state of the sequence which response was never written
+func (c *Codec) expireSequence(seq uint64, now int64) {
+	c.seqStarted.Delete(seq)
+	codec, ok := c.codec.LoadAndDelete(seq)
+	if !ok {
+		// the response is being written
+		return
+	}
+
+	// the late response is dropped instead of being written with the fallback codec
+	c.seqExpired.Store(seq, now)
+
+	c.release()
+	c.stats.expired.Add(1)
+	c.reqOpts.Delete(seq)
+	c.respOpts.Delete(seq)
+	c.noPrefix.Delete(seq)
+	c.unknownSeqs.Delete(seq)
+	if c.requestContexts {
+		c.finishContext(seq)
+	}
+	if c.protoPool != nil {
+		c.releasePooled(seq)
+	}
+	if c.ordered != nil {
+		_ = c.expireOrdered(seq)
+	}
+
+	if done, ok := c.hooks.LoadAndDelete(seq); ok {
+		done.(func(ResponseInfo))(ResponseInfo{Err: ErrSequenceExpired})
+	}
+
+	if c.sink != nil {
+		c.sink(Event{Type: EventCodecEvicted, Seq: seq, Flags: codec.(byte), Err: ErrSequenceExpired})
+	}
+}
+
+// expireOrdered removes the expired sequence from the queue and sends the responses waiting for it
+func (c *Codec) expireOrdered(seq uint64) error {
+	o := c.ordered
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	idx := slices.Index(o.seqs, seq)
+	if idx < 0 {
+		return nil
+	}
+
+	o.seqs = slices.Delete(o.seqs, idx, idx+1)
+	delete(o.pending, seq)
+	delete(o.complete, seq)
+	if idx > 0 {
+		return nil
+	}
+
+	return c.flushOrdered()
+}
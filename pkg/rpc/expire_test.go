@@ -0,0 +1,115 @@
+package rpc
+
+import (
+	stderr "errors"
+	"net/rpc"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodec_SequenceTTL(t *testing.T) {
+	c, rl := pipeCodec(t)
+	require.Error(t, c.SetSequenceTTL(-time.Second))
+	require.NoError(t, c.SetSequenceTTL(20*time.Millisecond))
+
+	var expired atomic.Int64
+	c.SetRequestHook(func(RequestInfo) func(ResponseInfo) {
+		return func(info ResponseInfo) {
+			if stderr.Is(info.Err, ErrSequenceExpired) {
+				expired.Add(1)
+			}
+		}
+	})
+
+	read := func(seq uint32) {
+		go func() {
+			_ = rl.Send(requestFrame(seq, "test.Expire", frame.CodecJSON, []byte(`"body"`), OptionTraceID, seq))
+		}()
+
+		r := &rpc.Request{}
+		require.NoError(t, c.ReadRequestHeader(r))
+		require.NoError(t, c.ReadRequestBody(nil))
+	}
+
+	// the responses are never written
+	const n = 100
+	for seq := uint32(1); seq <= n; seq++ {
+		read(seq)
+	}
+	assert.Equal(t, n, c.inFlightSequences())
+
+	time.Sleep(30 * time.Millisecond)
+	read(n + 1)
+
+	// the state of the expired sequences is reclaimed, the last one is kept
+	assert.Equal(t, 1, c.inFlightSequences())
+	assert.Equal(t, uint64(n), c.Stats().Expired)
+	assert.Equal(t, int64(n), expired.Load())
+	_, ok := c.TraceID(1)
+	assert.False(t, ok)
+	_, ok = c.TraceID(n + 1)
+	assert.True(t, ok)
+}
+
+func TestCodec_SequenceTTLMaxInFlight(t *testing.T) {
+	c, rl := pipeCodec(t)
+	require.NoError(t, c.SetSequenceTTL(20*time.Millisecond))
+	require.NoError(t, c.SetMaxInFlight(2))
+
+	go func() {
+		for seq := uint32(1); seq <= 3; seq++ {
+			_ = rl.Send(requestFrame(seq, "test.Expire", frame.CodecJSON, []byte(`"body"`)))
+		}
+	}()
+
+	// the third request waits for the slots of the unanswered ones, which are freed on the expiration
+	for seq := uint64(1); seq <= 3; seq++ {
+		r := &rpc.Request{}
+		require.NoError(t, c.ReadRequestHeader(r))
+		assert.Equal(t, seq, r.Seq)
+		require.NoError(t, c.ReadRequestBody(nil))
+	}
+
+	assert.Equal(t, uint64(2), c.Stats().Expired)
+	assert.Equal(t, 1, c.inFlightSequences())
+}
+
+func TestCodec_SequenceTTLLateResponse(t *testing.T) {
+	c, rl := pipeCodec(t)
+	require.NoError(t, c.SetSequenceTTL(20*time.Millisecond))
+
+	read := func(seq uint32) {
+		go func() {
+			_ = rl.Send(requestFrame(seq, "test.Expire", frame.CodecJSON, []byte(`"body"`)))
+		}()
+
+		r := &rpc.Request{}
+		require.NoError(t, c.ReadRequestHeader(r))
+		require.NoError(t, c.ReadRequestBody(nil))
+	}
+
+	read(1)
+	time.Sleep(30 * time.Millisecond)
+	read(2)
+
+	// the late response is dropped instead of being sent with the fallback codec
+	err := c.WriteResponse(&rpc.Response{ServiceMethod: "test.Expire", Seq: 1}, "late")
+	require.Error(t, err)
+	assert.True(t, stderr.Is(err, ErrSequenceExpired))
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.WriteResponse(&rpc.Response{ServiceMethod: "test.Expire", Seq: 2}, "body")
+	}()
+
+	// the next frame is the response of the live sequence
+	fr := frame.NewFrame()
+	require.NoError(t, rl.Receive(fr))
+	require.NoError(t, <-errCh)
+	assert.Equal(t, uint32(2), fr.ReadOptions(fr.Header())[0])
+}
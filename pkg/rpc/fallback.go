@@ -0,0 +1,56 @@
+package rpc
+
+import (
+	"log"
+	"reflect"
+)
+
+// FallbackConfig configures the decoders tried when the codec of the request flags fails to decode the body,
+// e.g. during the rolling upgrades where old and new clients send different formats under the same method.
+type FallbackConfig struct {
+	// Codecs are the codec flags (frame.CodecJSON, frame.CodecMsgpack, ...) tried in order, the first success wins
+	Codecs []byte
+	// Logger logs which fallback codec decoded the body, default - log.Default()
+	Logger *log.Logger
+}
+
+// SetFallbackDecoders enables the fallback decoders in ReadRequestBody, nil disables them (the default).
+// Should be called before the codec is used.
+func (c *Codec) SetFallbackDecoders(cfg *FallbackConfig) {
+	if cfg != nil && cfg.Logger == nil {
+		cfg = &FallbackConfig{Codecs: cfg.Codecs, Logger: log.Default()}
+	}
+
+	c.fallback = cfg
+}
+
+// decodeFallback tries the fallback codecs after the flagged codec failed with err, err is returned if all fail
+func (c *Codec) decodeFallback(method string, flags byte, payload []byte, out any, err error) error {
+	for _, flag := range c.fallback.Codecs {
+		if flag == flags {
+			continue
+		}
+
+		entry, ok := lookupCodecFor(flag, c.json, c.msgpack)
+		if !ok {
+			continue
+		}
+
+		// the failed decoders might fill the out partially
+		resetOut(out)
+		if entry.dec.Decode(payload, out) == nil {
+			c.fallback.Logger.Printf("goridge: %s request flagged with codec %d was decoded with the fallback codec %d",
+				method, flags, flag)
+			return nil
+		}
+	}
+
+	return err
+}
+
+func resetOut(out any) {
+	v := reflect.ValueOf(out)
+	if v.Kind() == reflect.Pointer && !v.IsNil() && v.Elem().CanSet() {
+		v.Elem().Set(reflect.Zero(v.Elem().Type()))
+	}
+}
@@ -0,0 +1,42 @@
+package rpc
+
+import (
+	"bytes"
+	"log"
+	"net/rpc"
+	"testing"
+
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestCodec_SetFallbackDecoders(t *testing.T) {
+	body, err := msgpack.Marshal(Payload{Name: "msgpack", Value: 3})
+	require.NoError(t, err)
+
+	c, rl := pipeCodec(t)
+	go func() {
+		// old clients flag msgpack bodies as JSON
+		_ = rl.Send(requestFrame(1, "test.Fallback", frame.CodecJSON, body))
+		_ = rl.Send(requestFrame(2, "test.Fallback", frame.CodecJSON, body))
+	}()
+
+	// disabled by default
+	r := &rpc.Request{}
+	require.NoError(t, c.ReadRequestHeader(r))
+	assert.Error(t, c.ReadRequestBody(&Payload{}))
+
+	out := &bytes.Buffer{}
+	c.SetFallbackDecoders(&FallbackConfig{
+		Codecs: []byte{frame.CodecGob, frame.CodecMsgpack},
+		Logger: log.New(out, "", 0),
+	})
+
+	require.NoError(t, c.ReadRequestHeader(r))
+	in := Payload{}
+	require.NoError(t, c.ReadRequestBody(&in))
+	assert.Equal(t, Payload{Name: "msgpack", Value: 3}, in)
+	assert.Contains(t, out.String(), "test.Fallback request flagged with codec 8 was decoded with the fallback codec 16")
+}
@@ -0,0 +1,64 @@
+package rpc
+
+import (
+	"encoding/gob"
+	"fmt"
+	"strings"
+
+	"github.com/roadrunner-server/errors"
+)
+
+// ErrGobTypeNotRegistered is returned when the Gob codec encodes or decodes the interface value of the concrete type
+// which was not registered with RegisterGobType
+var ErrGobTypeNotRegistered = errors.Str("gob type is not registered") //nolint:gochecknoglobals
+
+// GobTypeError carries the original gob error. It matches ErrGobTypeNotRegistered with errors.Is.
+type GobTypeError struct {
+	Err error
+}
+
+func (e *GobTypeError) Error() string {
+	return fmt.Sprintf("%s, register the concrete types carried in the interface fields with RegisterGobType: %v",
+		ErrGobTypeNotRegistered.Error(), e.Err)
+}
+
+func (e *GobTypeError) Is(target error) bool {
+	return target == ErrGobTypeNotRegistered
+}
+
+// RegisterGobType registers the concrete type of v with gob.Register. The types carried in the interface fields
+// (or passed as the interface bodies) of the Gob codec requests and responses should be registered on both sides,
+// otherwise ReadRequestBody and WriteResponse fail with GobTypeError. The registration is global, like gob.Register,
+// the error is returned when another type is already registered under the same name.
+// Should be called before the codec is used.
+func (c *Codec) RegisterGobType(v any) error {
+	return registerGobType(v)
+}
+
+// RegisterGobType registers the concrete type of v with gob.Register, see Codec.RegisterGobType.
+// Should be called before the codec is used.
+func (c *ClientCodec) RegisterGobType(v any) error {
+	return registerGobType(v)
+}
+
+func registerGobType(v any) (err error) {
+	const op = errors.Op("goridge_register_gob_type")
+	// gob.Register panics on the duplicate names and types
+	defer func() {
+		if r := recover(); r != nil {
+			err = errors.E(op, errors.Errorf("%v", r))
+		}
+	}()
+
+	gob.Register(v)
+	return nil
+}
+
+// gobError wraps the gob errors caused by the unregistered types into GobTypeError
+func gobError(err error) error {
+	if err != nil && strings.Contains(err.Error(), "not registered for interface") {
+		return &GobTypeError{Err: err}
+	}
+
+	return err
+}
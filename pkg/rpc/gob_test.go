@@ -0,0 +1,81 @@
+package rpc
+
+import (
+	"bytes"
+	"net/rpc"
+	"testing"
+
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type gobShape interface {
+	Area() int
+}
+
+type gobSquare struct {
+	Side int
+}
+
+func (s gobSquare) Area() int {
+	return s.Side * s.Side
+}
+
+type gobCircle struct {
+	Radius int
+}
+
+func (c gobCircle) Area() int {
+	return 3 * c.Radius * c.Radius
+}
+
+type gobEnvelope struct {
+	Name  string
+	Shape gobShape
+}
+
+func TestCodec_RegisterGobType(t *testing.T) {
+	c, rl := pipeCodec(t)
+	require.NoError(t, c.RegisterGobType(gobSquare{}))
+
+	buf := new(bytes.Buffer)
+	require.NoError(t, encodeGob(gobEnvelope{Name: "request", Shape: gobSquare{Side: 2}}, buf))
+
+	go func() {
+		_ = rl.Send(requestFrame(1, "test.Gob", frame.CodecGob, buf.Bytes()))
+	}()
+
+	r := &rpc.Request{}
+	require.NoError(t, c.ReadRequestHeader(r))
+	in := gobEnvelope{}
+	require.NoError(t, c.ReadRequestBody(&in))
+	assert.Equal(t, gobEnvelope{Name: "request", Shape: gobSquare{Side: 2}}, in)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.WriteResponse(&rpc.Response{ServiceMethod: r.ServiceMethod, Seq: r.Seq},
+			gobEnvelope{Name: "response", Shape: gobSquare{Side: 3}})
+	}()
+
+	fr := frame.NewFrame()
+	require.NoError(t, rl.Receive(fr))
+	require.NoError(t, <-errCh)
+	assert.Equal(t, frame.CodecGob, fr.ReadFlags())
+
+	out := gobEnvelope{}
+	require.NoError(t, decodeGob(fr.Payload()[len(r.ServiceMethod):], &out))
+	assert.Equal(t, 9, out.Shape.Area())
+}
+
+func TestCodec_GobTypeNotRegistered(t *testing.T) {
+	err := encodeGob(gobEnvelope{Shape: gobCircle{Radius: 1}}, new(bytes.Buffer))
+	require.Error(t, err)
+	assert.ErrorIs(t, err, ErrGobTypeNotRegistered)
+	assert.Contains(t, err.Error(), "RegisterGobType")
+
+	// other gob errors are returned as is
+	err = decodeGob([]byte("not a gob stream"), &gobEnvelope{})
+	require.Error(t, err)
+	assert.NotErrorIs(t, err, ErrGobTypeNotRegistered)
+}
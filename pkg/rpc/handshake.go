@@ -0,0 +1,198 @@
+package rpc
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+)
+
+// ErrHandshakeFailed is returned by Handshake when the peers have no codec in common or the peer didn't send
+// the handshake frame
+var ErrHandshakeFailed = errors.Str("codec negotiation handshake failed") //nolint:gochecknoglobals
+
+// HandshakeError carries the codec flags supported by both sides. It matches ErrHandshakeFailed with errors.Is.
+type HandshakeError struct {
+	// Local and Remote are the advertised codecs: the bitmask of the single codec flags in the lower byte
+	// and the combined codecs (CodecBSON) above it
+	Local  uint32
+	Remote uint32
+	// NoHandshake is set when the peer sent another frame instead of the handshake
+	NoHandshake bool
+}
+
+func (e *HandshakeError) Error() string {
+	if e.NoHandshake {
+		return fmt.Sprintf("%s: the peer didn't send the handshake frame", ErrHandshakeFailed.Error())
+	}
+
+	return fmt.Sprintf("%s: no codec in common, local codecs: 0x%03x, remote codecs: 0x%03x",
+		ErrHandshakeFailed.Error(), e.Local, e.Remote)
+}
+
+func (e *HandshakeError) Is(target error) bool {
+	return target == ErrHandshakeFailed
+}
+
+// Handshake negotiates the codecs with the client right after the connection: receives the codec flags supported
+// by the client (see ClientCodec.Handshake), replies with the supported codecs (all registered, if none passed)
+// and returns HandshakeError if there is no codec in common. Optional, both sides should call it before
+// any other frame. The negotiated codecs are available with NegotiatedCodecs and IsNegotiated, the requests
+// with other codecs are rejected.
+func (c *Codec) Handshake(codecs ...byte) error {
+	const op = errors.Op("goridge_handshake")
+	local := supportedCodecs(codecs)
+
+	fr := c.getFrame()
+	defer c.putFrame(fr)
+
+	err := c.receive(context.Background(), fr)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	remote, err := readHandshake(fr, local)
+	if err != nil {
+		return err
+	}
+
+	err = c.relaySend(handshakeFrame(local, c.crcDisabled))
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	c.negotiated = local & remote
+	if c.negotiated == 0 {
+		return &HandshakeError{Local: local, Remote: remote}
+	}
+
+	return nil
+}
+
+// NegotiatedCodecs returns the bitmask of the single codec flags supported by both sides after Handshake,
+// 0 - no handshake. The combined codecs (CodecBSON) are reported by IsNegotiated.
+func (c *Codec) NegotiatedCodecs() byte {
+	return byte(c.negotiated)
+}
+
+// IsNegotiated reports whether the codec is supported by both sides after Handshake, any codec is
+// without the handshake.
+func (c *Codec) IsNegotiated(codec byte) bool {
+	return isNegotiated(c.negotiated, codec)
+}
+
+// Handshake negotiates the codecs with the server right after the connection: sends the supported codec flags
+// (all registered, if none passed), receives the codecs supported by the server (see Codec.Handshake) and returns
+// HandshakeError if there is no codec in common. Should be called before the codec is used.
+func (c *ClientCodec) Handshake(codecs ...byte) error {
+	const op = errors.Op("goridge_client_handshake")
+	local := supportedCodecs(codecs)
+
+	err := c.send(handshakeFrame(local, c.crcDisabled))
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	fr := c.getFrame()
+	defer c.putFrame(fr)
+
+	err = c.relay.Receive(fr)
+	if err != nil {
+		return errors.E(op, err)
+	}
+	swapOrder(fr, c.swapOptions)
+
+	remote, err := readHandshake(fr, local)
+	if err != nil {
+		return err
+	}
+
+	c.negotiated = local & remote
+	if c.negotiated == 0 {
+		return &HandshakeError{Local: local, Remote: remote}
+	}
+
+	return nil
+}
+
+// NegotiatedCodecs returns the bitmask of the single codec flags supported by both sides after Handshake,
+// 0 - no handshake. The combined codecs (CodecBSON) are reported by IsNegotiated.
+func (c *ClientCodec) NegotiatedCodecs() byte {
+	return byte(c.negotiated)
+}
+
+// IsNegotiated reports whether the codec is supported by both sides after Handshake, any codec is
+// without the handshake.
+func (c *ClientCodec) IsNegotiated(codec byte) bool {
+	return isNegotiated(c.negotiated, codec)
+}
+
+// the combined codecs are advertised above the single codec bits, otherwise the peer supporting CodecRaw
+// and CodecMsgpack would look like the one supporting CodecBSON
+var combinedCodecs = map[byte]uint32{frame.CodecBSON: 1 << 8} //nolint:gochecknoglobals
+
+// codecMask returns the handshake bits of the codec
+func codecMask(codec byte) uint32 {
+	if bit, ok := combinedCodecs[codec]; ok {
+		return bit
+	}
+
+	return uint32(frame.Flags(codec).Codec())
+}
+
+// supportedCodecs returns the handshake bits of the codecs, all registered codecs if none passed
+func supportedCodecs(codecs []byte) uint32 {
+	if len(codecs) == 0 {
+		for _, e := range loadCodecs() {
+			codecs = append(codecs, e.flag)
+		}
+	}
+
+	var mask uint32
+	for _, flag := range codecs {
+		mask |= codecMask(flag)
+	}
+
+	return mask
+}
+
+// isNegotiated reports whether all the handshake bits of the codec were negotiated, 0 - no handshake
+func isNegotiated(negotiated uint32, codec byte) bool {
+	if negotiated == 0 {
+		return true
+	}
+
+	mask := codecMask(codec)
+	return mask != 0 && negotiated&mask == mask
+}
+
+func handshakeFrame(codecs uint32, crcDisabled bool) *frame.Frame {
+	fr := frame.NewFrame()
+	fr.WriteVersion(fr.Header(), frame.Version1)
+	fr.WriteFlags(fr.Header(), frame.CONTROL)
+	fr.SetHandshakeBit(fr.Header())
+	fr.WriteOptions(fr.HeaderPtr(), codecs)
+	writeCRC(fr, crcDisabled)
+	return fr
+}
+
+// readHandshake returns the codecs advertised by the peer in the handshake frame
+func readHandshake(fr *frame.Frame, local uint32) (uint32, error) {
+	const op = errors.Op("goridge_read_handshake")
+	if !fr.IsHandshake(fr.Header()) {
+		return 0, &HandshakeError{Local: local, NoHandshake: true}
+	}
+
+	// the relays without the CRC verification
+	if !fr.IsCRCDisabled(fr.Header()) && !fr.VerifyCRC(fr.Header()) {
+		return 0, errors.E(op, errors.Str("CRC verification failed"))
+	}
+
+	opts := fr.ReadOptions(fr.Header())
+	if len(opts) < 1 {
+		return 0, errors.E(op, errors.Str("handshake frame should carry the codecs option"))
+	}
+
+	return opts[0], nil
+}
@@ -0,0 +1,166 @@
+package rpc
+
+import (
+	"errors"
+	"math"
+	"net/rpc"
+	"testing"
+
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/roadrunner-server/goridge/v3/pkg/pipe"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func handshakePair(t *testing.T) (*Codec, *ClientCodec) {
+	srv, cl := pipe.NewRelayPair()
+	server := NewCodecWithRelay(srv)
+	client := NewClientCodecWithRelay(cl)
+	t.Cleanup(func() {
+		_ = server.Close()
+		_ = client.Close()
+	})
+
+	return server, client
+}
+
+func TestCodec_Handshake(t *testing.T) {
+	server, client := handshakePair(t)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- client.Handshake(frame.CodecJSON)
+	}()
+
+	require.NoError(t, server.Handshake(frame.CodecJSON, frame.CodecGob))
+	require.NoError(t, <-errCh)
+	assert.Equal(t, frame.CodecJSON, server.NegotiatedCodecs())
+	assert.Equal(t, frame.CodecJSON, client.NegotiatedCodecs())
+}
+
+func TestCodec_HandshakeDisjoint(t *testing.T) {
+	server, client := handshakePair(t)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- client.Handshake(frame.CodecJSON)
+	}()
+
+	err := server.Handshake(frame.CodecProto)
+	require.ErrorIs(t, err, ErrHandshakeFailed)
+	var he *HandshakeError
+	require.True(t, errors.As(err, &he))
+	assert.Equal(t, uint32(frame.CodecProto), he.Local)
+	assert.Equal(t, uint32(frame.CodecJSON), he.Remote)
+
+	err = <-errCh
+	require.ErrorIs(t, err, ErrHandshakeFailed)
+	require.True(t, errors.As(err, &he))
+	assert.Equal(t, uint32(frame.CodecJSON), he.Local)
+	assert.Equal(t, uint32(frame.CodecProto), he.Remote)
+
+	assert.Zero(t, server.NegotiatedCodecs())
+	assert.Zero(t, client.NegotiatedCodecs())
+}
+
+func TestCodec_HandshakeNotSent(t *testing.T) {
+	srv, cl := pipe.NewRelayPair()
+	server := NewCodecWithRelay(srv)
+	t.Cleanup(func() {
+		_ = server.Close()
+		_ = cl.Close()
+	})
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- cl.Send(requestFrame(1, "test.Method", frame.CodecJSON, []byte(`"ping"`)))
+	}()
+
+	err := server.Handshake()
+	require.NoError(t, <-errCh)
+	require.ErrorIs(t, err, ErrHandshakeFailed)
+	var he *HandshakeError
+	require.True(t, errors.As(err, &he))
+	assert.True(t, he.NoHandshake)
+}
+
+func TestCodec_HandshakeReset(t *testing.T) {
+	server, client := handshakePair(t)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- client.Handshake(frame.CodecJSON)
+	}()
+
+	require.NoError(t, server.Handshake(frame.CodecJSON))
+	require.NoError(t, <-errCh)
+	require.Equal(t, frame.CodecJSON, server.NegotiatedCodecs())
+
+	go func() {
+		fr := frame.NewFrame()
+		errCh <- client.relay.Receive(fr)
+	}()
+	require.NoError(t, server.Push("event", frame.CodecJSON, nil))
+	require.NoError(t, <-errCh)
+
+	// the new connection starts without the negotiated codecs and with the first push sequence
+	require.NoError(t, server.Close())
+	srv, cl := pipe.NewRelayPair()
+	t.Cleanup(func() {
+		_ = cl.Close()
+	})
+	require.NoError(t, server.ResetRelay(srv))
+	assert.Zero(t, server.NegotiatedCodecs())
+
+	go func() {
+		errCh <- server.Push("event", frame.CodecJSON, nil)
+	}()
+	fr := frame.NewFrame()
+	require.NoError(t, cl.Receive(fr))
+	require.NoError(t, <-errCh)
+	assert.Equal(t, uint32(math.MaxUint32), fr.ReadOptions(fr.Header())[0])
+}
+
+func TestCodec_HandshakeCombinedCodec(t *testing.T) {
+	server, client := handshakePair(t)
+
+	// the client has CodecRaw and CodecMsgpack, but not CodecBSON made of their bits
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- client.Handshake(frame.CodecRaw, frame.CodecMsgpack)
+	}()
+
+	require.NoError(t, server.Handshake())
+	require.NoError(t, <-errCh)
+	assert.Equal(t, frame.CodecRaw|frame.CodecMsgpack, server.NegotiatedCodecs())
+	assert.True(t, server.IsNegotiated(frame.CodecRaw))
+	assert.False(t, server.IsNegotiated(frame.CodecBSON))
+	assert.False(t, client.IsNegotiated(frame.CodecBSON))
+	assert.False(t, server.IsNegotiated(frame.CodecJSON))
+	assert.True(t, frame.Codec(frame.CodecBSON).IsSingle())
+
+	go func() {
+		errCh <- client.relay.Send(requestFrame(1, "test.BSON", frame.CodecBSON, []byte{5, 0, 0, 0, 0}))
+	}()
+	err := server.ReadRequestHeader(&rpc.Request{})
+	require.NoError(t, <-errCh)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "codec 0x14 [BSON] is not negotiated")
+}
+
+func TestCodec_HandshakeBSON(t *testing.T) {
+	server, client := handshakePair(t)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- client.Handshake(frame.CodecBSON)
+	}()
+
+	require.NoError(t, server.Handshake())
+	require.NoError(t, <-errCh)
+	// no single codec bits in common
+	assert.Zero(t, server.NegotiatedCodecs())
+	assert.True(t, server.IsNegotiated(frame.CodecBSON))
+	assert.True(t, client.IsNegotiated(frame.CodecBSON))
+	assert.False(t, client.IsNegotiated(frame.CodecRaw))
+}
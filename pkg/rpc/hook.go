@@ -0,0 +1,63 @@
+package rpc
+
+import (
+	"net/rpc"
+
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+)
+
+// RequestInfo describes the received request
+type RequestInfo struct {
+	Seq    uint64
+	Method string
+	// Codec - codec flag used for the request
+	Codec byte
+	// PayloadLen - request body length in bytes, without the service method
+	PayloadLen int
+}
+
+// ResponseInfo describes the sent response
+type ResponseInfo struct {
+	// Flags - response frame flags (codec and frame.ERROR)
+	Flags byte
+	// PayloadLen - response body length in bytes, without the service method
+	PayloadLen int
+	// Error - error sent to the client, if any
+	Error string
+	// Err - error sending the response
+	Err error
+}
+
+// RequestHook is called when the request header is read, the returned function (might be nil) is called when
+// the response for the request is sent. Useful to start and end tracing spans.
+type RequestHook func(RequestInfo) func(ResponseInfo)
+
+// SetRequestHook sets the hook called for every request, nil disables it.
+// Should be called before the codec is used.
+func (c *Codec) SetRequestHook(hook RequestHook) {
+	c.hook = hook
+}
+
+func (c *Codec) startRequest(r *rpc.Request, codec byte, payloadLen int) {
+	done := c.hook(RequestInfo{Seq: r.Seq, Method: r.ServiceMethod, Codec: codec, PayloadLen: payloadLen})
+	if done != nil {
+		c.hooks.Store(r.Seq, done)
+	}
+}
+
+func (c *Codec) finishRequest(r *rpc.Response, fr *frame.Frame, err error) {
+	done, ok := c.hooks.LoadAndDelete(r.Seq)
+	if !ok {
+		return
+	}
+
+	info := ResponseInfo{Flags: fr.ReadFlags(), Err: err}
+	if pl := len(fr.Payload()) - len(r.ServiceMethod); pl > 0 {
+		info.PayloadLen = pl
+		if frame.Flags(info.Flags).IsError() {
+			info.Error = string(fr.Payload()[len(r.ServiceMethod):])
+		}
+	}
+
+	done.(func(ResponseInfo))(info)
+}
@@ -0,0 +1,57 @@
+package rpc
+
+import (
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"google.golang.org/protobuf/proto"
+)
+
+// codecOverride is the request body with the explicitly selected codec, see WithCodec
+type codecOverride struct {
+	flag byte
+	body any
+}
+
+// WithCodec wraps the request body to send it with the codec flag instead of the inferred one, e.g.
+// client.Call("Service.Method", rpc.WithCodec(req, frame.CodecMsgpack), &resp).
+func WithCodec(body any, flag byte) any {
+	return codecOverride{flag: flag, body: body}
+}
+
+// SetAutoCodec enables the codec inference by the request body type: frame.CodecProto for the proto messages,
+// frame.CodecRaw for []byte and *[]byte and the registered default codec (e.g. frame.CodecJSON) otherwise.
+// Without it the proto messages are sent with frame.CodecProto and other bodies with frame.CodecGob.
+// Should be called before the codec is used.
+func (c *ClientCodec) SetAutoCodec(defaultCodec byte) error {
+	const op = errors.Op("goridge_client_set_auto_codec")
+	if _, ok := lookupCodec(defaultCodec); !ok {
+		return errors.E(op, errors.Errorf("unknown codec: %d", defaultCodec))
+	}
+
+	c.defaultCodec = defaultCodec
+	return nil
+}
+
+// requestCodec returns the codec flag and the body to encode, the WithCodec flag has the priority
+func (c *ClientCodec) requestCodec(body any) (byte, any) {
+	switch b := body.(type) {
+	case codecOverride:
+		_, inner, _ := unwrapTraceID(b.body)
+		return b.flag, inner
+	case tracedBody:
+		return c.requestCodec(b.body)
+	case proto.Message:
+		return frame.CodecProto, body
+	case []byte, *[]byte:
+		if c.defaultCodec != 0 {
+			return frame.CodecRaw, body
+		}
+	}
+
+	if c.defaultCodec != 0 {
+		return c.defaultCodec, body
+	}
+
+	// gob is the fallback
+	return frame.CodecGob, body
+}
@@ -0,0 +1,62 @@
+package rpc
+
+import (
+	"net/rpc"
+	"testing"
+
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/roadrunner-server/goridge/v3/pkg/pipe"
+	"github.com/roadrunner-server/goridge/v3/tests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientCodec_InferredCodec(t *testing.T) {
+	srv, cl := pipe.NewRelayPair()
+	c := NewClientCodecWithRelay(cl)
+	require.Error(t, c.SetAutoCodec(0))
+	require.NoError(t, c.SetAutoCodec(frame.CodecJSON))
+
+	data := []byte("raw")
+	cases := []struct {
+		name string
+		body any
+		flag byte
+	}{
+		{name: "proto", body: &tests.Payload{Storage: "goridge"}, flag: frame.CodecProto},
+		{name: "bytes", body: data, flag: frame.CodecRaw},
+		{name: "bytes pointer", body: &data, flag: frame.CodecRaw},
+		{name: "default", body: Payload{Name: "goridge"}, flag: frame.CodecJSON},
+		{name: "override", body: WithCodec(data, frame.CodecMsgpack), flag: frame.CodecMsgpack},
+	}
+
+	send := func(body any) *frame.Frame {
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- c.WriteRequest(&rpc.Request{ServiceMethod: "test.Method", Seq: 1}, body)
+		}()
+
+		fr := frame.NewFrame()
+		require.NoError(t, srv.Receive(fr))
+		require.NoError(t, <-errCh)
+		return fr
+	}
+
+	for _, tc := range cases {
+		fr := send(tc.body)
+		assert.Equal(t, tc.flag, fr.ReadFlags(), tc.name)
+	}
+
+	// raw payload is sent as is after the method prefix
+	fr := send(data)
+	assert.Equal(t, "test.Methodraw", string(fr.Payload()))
+
+	fr = send(Payload{Name: "goridge"})
+	assert.Contains(t, string(fr.Payload()), `"name":"goridge"`)
+
+	// without the inference only the proto messages aren't sent with gob
+	c = NewClientCodecWithRelay(cl)
+	assert.Equal(t, frame.CodecProto, send(&tests.Payload{}).ReadFlags())
+	assert.Equal(t, frame.CodecGob, send(data).ReadFlags())
+	assert.Equal(t, frame.CodecJSON, send(WithCodec(Payload{}, frame.CodecJSON)).ReadFlags())
+}
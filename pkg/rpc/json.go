@@ -0,0 +1,46 @@
+package rpc
+
+import (
+	"bytes"
+
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+)
+
+// JSONCodec is a JSON implementation used for the frame.CodecJSON frames, e.g. sonic or jsoniter.
+type JSONCodec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// SetJSONCodec sets the JSON implementation for the JSON requests and responses, nil restores the default one.
+// Should be called before the codec is used.
+func (c *Codec) SetJSONCodec(jc JSONCodec) {
+	c.json = jc
+}
+
+// SetJSONCodec sets the JSON implementation for the JSON responses, nil restores the default one.
+// Should be called before the codec is used.
+func (c *ClientCodec) SetJSONCodec(jc JSONCodec) {
+	c.json = jc
+}
+
+// lookupCodecWithJSON finds the registered codec for the flags and replaces the JSON codec with the jc if it is set
+func lookupCodecWithJSON(flags byte, jc JSONCodec) (codecEntry, bool) {
+	entry, ok := lookupCodec(flags)
+	if !ok || jc == nil || entry.flag != frame.CodecJSON {
+		return entry, ok
+	}
+
+	entry.enc = EncoderFunc(func(body any, buf *bytes.Buffer) error {
+		data, err := jc.Marshal(body)
+		if err != nil {
+			return err
+		}
+
+		buf.Write(data)
+		return nil
+	})
+	entry.dec = DecoderFunc(jc.Unmarshal)
+
+	return entry, true
+}
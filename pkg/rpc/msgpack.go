@@ -0,0 +1,77 @@
+package rpc
+
+import (
+	"bytes"
+
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// MsgpackOptions configures the msgpack encoders and decoders of the frame.CodecMsgpack frames, e.g. to match
+// the peer which encodes the structs as arrays.
+type MsgpackOptions struct {
+	// UseArrayEncodedStructs encodes the structs as arrays of the field values instead of maps
+	UseArrayEncodedStructs bool
+	// CustomStructTag is the struct tag used instead of the "msgpack" one, e.g. "json"
+	CustomStructTag string
+	// Encoder and Decoder configure the other options of the pooled encoders and decoders, optional
+	Encoder func(enc *msgpack.Encoder)
+	Decoder func(dec *msgpack.Decoder)
+}
+
+// SetMsgpackOptions sets the msgpack options for the msgpack requests and responses, nil restores
+// the default msgpack.Marshal and msgpack.Unmarshal. Should be called before the codec is used.
+func (c *Codec) SetMsgpackOptions(opts *MsgpackOptions) {
+	c.msgpack = opts
+}
+
+// SetMsgpackOptions sets the msgpack options for the msgpack requests and responses, nil restores
+// the default msgpack.Marshal and msgpack.Unmarshal. Should be called before the codec is used.
+func (c *ClientCodec) SetMsgpackOptions(opts *MsgpackOptions) {
+	c.msgpack = opts
+}
+
+func (o *MsgpackOptions) encode(body any, buf *bytes.Buffer) error {
+	enc := msgpack.GetEncoder()
+	defer msgpack.PutEncoder(enc)
+
+	enc.Reset(buf)
+	enc.UseArrayEncodedStructs(o.UseArrayEncodedStructs)
+	if o.CustomStructTag != "" {
+		enc.SetCustomStructTag(o.CustomStructTag)
+	}
+	if o.Encoder != nil {
+		o.Encoder(enc)
+	}
+
+	return enc.Encode(body)
+}
+
+func (o *MsgpackOptions) decode(payload []byte, out any) error {
+	dec := msgpack.GetDecoder()
+	defer msgpack.PutDecoder(dec)
+
+	dec.Reset(bytes.NewReader(payload))
+	if o.CustomStructTag != "" {
+		dec.SetCustomStructTag(o.CustomStructTag)
+	}
+	if o.Decoder != nil {
+		o.Decoder(dec)
+	}
+
+	return dec.Decode(out)
+}
+
+// lookupCodecFor finds the registered codec for the flags and replaces the JSON and msgpack codecs with
+// the configured ones
+func lookupCodecFor(flags byte, jc JSONCodec, mp *MsgpackOptions) (codecEntry, bool) {
+	entry, ok := lookupCodecWithJSON(flags, jc)
+	if !ok || mp == nil || entry.flag != frame.CodecMsgpack {
+		return entry, ok
+	}
+
+	entry.enc = EncoderFunc(mp.encode)
+	entry.dec = DecoderFunc(mp.decode)
+
+	return entry, true
+}
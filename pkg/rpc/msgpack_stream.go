@@ -0,0 +1,83 @@
+package rpc
+
+import (
+	"bytes"
+	"io"
+
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// MsgpackStream iterates the elements of the msgpack array body one at a time, so the large arrays are not
+// materialized as a whole slice. Pass it to Codec.ReadRequestBody or ClientCodec.ReadResponseBody instead of
+// the slice, the body of the frame.CodecMsgpack frames is decoded with the msgpack options of the codec.
+// The stream reads the received payload in place: it's valid until the next ReadRequestHeader
+// (ReadResponseHeader), the elements should be read before that.
+type MsgpackStream struct {
+	dec *msgpack.Decoder
+	// number of the array elements (-1 - nil array) and the number of the read ones
+	n, read int
+}
+
+// Len returns the number of the array elements, -1 if the body is the nil array.
+func (s *MsgpackStream) Len() int {
+	return s.n
+}
+
+// Next decodes the next array element into out, returns io.EOF after the last one.
+func (s *MsgpackStream) Next(out any) error {
+	if s.dec == nil || s.read >= s.n {
+		return io.EOF
+	}
+
+	err := s.dec.Decode(out)
+	if err != nil {
+		return err
+	}
+
+	s.read++
+	return nil
+}
+
+// Decoder returns the decoder positioned at the next array element, e.g. to skip the elements with Skip
+// (the skipped elements are not counted by Next) or to decode them with DecodeMap and alike.
+func (s *MsgpackStream) Decoder() *msgpack.Decoder {
+	return s.dec
+}
+
+// reset starts the iteration over the payload, the array header is read right away
+func (s *MsgpackStream) reset(flags byte, payload []byte, opts *MsgpackOptions) error {
+	if frame.Flags(flags).Codec() != frame.Codec(frame.CodecMsgpack) {
+		return errors.Errorf("msgpack stream requires the msgpack codec, got: %d", flags)
+	}
+
+	s.n, s.read = 0, 0
+	if s.dec == nil {
+		s.dec = msgpack.NewDecoder(bytes.NewReader(payload))
+	} else {
+		s.dec.Reset(bytes.NewReader(payload))
+	}
+
+	if opts != nil {
+		if opts.CustomStructTag != "" {
+			s.dec.SetCustomStructTag(opts.CustomStructTag)
+		}
+		if opts.Decoder != nil {
+			opts.Decoder(s.dec)
+		}
+	}
+
+	// empty body is the empty array
+	if len(payload) == 0 {
+		return nil
+	}
+
+	n, err := s.dec.DecodeArrayLen()
+	if err != nil {
+		return err
+	}
+
+	s.n = n
+	return nil
+}
@@ -0,0 +1,100 @@
+package rpc
+
+import (
+	stderr "errors"
+	"io"
+	"net/rpc"
+	"testing"
+
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type streamItem struct {
+	ID   int    `msgpack:"id"`
+	Name string `msgpack:"name"`
+}
+
+func TestCodec_MsgpackStream(t *testing.T) {
+	const n = 10000
+	items := make([]streamItem, n)
+	for i := range items {
+		items[i] = streamItem{ID: i, Name: "item"}
+	}
+
+	body, err := msgpack.Marshal(items)
+	require.NoError(t, err)
+
+	c, rl := pipeCodec(t)
+	go func() {
+		_ = rl.Send(requestFrame(1, "test.Stream", frame.CodecMsgpack, body))
+	}()
+
+	r := &rpc.Request{}
+	require.NoError(t, c.ReadRequestHeader(r))
+	stream := &MsgpackStream{}
+	require.NoError(t, c.ReadRequestBody(stream))
+	assert.Equal(t, n, stream.Len())
+
+	count := 0
+	for {
+		item := streamItem{}
+		err = stream.Next(&item)
+		if stderr.Is(err, io.EOF) {
+			break
+		}
+		require.NoError(t, err)
+		assert.Equal(t, streamItem{ID: count, Name: "item"}, item)
+		count++
+	}
+	assert.Equal(t, n, count)
+	assert.ErrorIs(t, stream.Next(&streamItem{}), io.EOF)
+
+	// the response arrays are streamed by the client
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.WriteResponse(&rpc.Response{ServiceMethod: r.ServiceMethod, Seq: r.Seq}, items[:3])
+	}()
+
+	client := NewClientCodecWithRelay(rl)
+	resp := &rpc.Response{}
+	require.NoError(t, client.ReadResponseHeader(resp))
+	require.NoError(t, client.ReadResponseBody(stream))
+	require.NoError(t, <-errCh)
+	require.Equal(t, 3, stream.Len())
+
+	// the elements might be skipped with the decoder
+	require.NoError(t, stream.Decoder().Skip())
+	item := streamItem{}
+	require.NoError(t, stream.Next(&item))
+	assert.Equal(t, 1, item.ID)
+}
+
+func TestCodec_MsgpackStreamCodec(t *testing.T) {
+	c, rl := pipeCodec(t)
+
+	for seq, req := range []*frame.Frame{
+		requestFrame(1, "test.Stream", frame.CodecJSON, []byte(`[1,2]`)),
+		requestFrame(2, "test.Stream", frame.CodecMsgpack, nil),
+	} {
+		go func() {
+			_ = rl.Send(req)
+		}()
+
+		r := &rpc.Request{}
+		require.NoError(t, c.ReadRequestHeader(r))
+		stream := &MsgpackStream{}
+		err := c.ReadRequestBody(stream)
+		if seq == 0 {
+			// only the msgpack bodies are streamed
+			require.Error(t, err)
+			continue
+		}
+
+		require.NoError(t, err)
+		assert.Equal(t, 0, stream.Len())
+		assert.ErrorIs(t, stream.Next(&streamItem{}), io.EOF)
+	}
+}
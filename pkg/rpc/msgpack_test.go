@@ -0,0 +1,74 @@
+package rpc
+
+import (
+	"net/rpc"
+	"testing"
+
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+func TestCodec_MsgpackOptions(t *testing.T) {
+	type point struct {
+		X int `custom:"x"`
+		Y int `custom:"y"`
+	}
+
+	c, rl := pipeCodec(t)
+
+	// sends the request body and returns the msgpack body of the response
+	roundTrip := func(seq uint32, body []byte, in any, out any) []byte {
+		go func() {
+			_ = rl.Send(requestFrame(seq, "test.Msgpack", frame.CodecMsgpack, body))
+		}()
+
+		r := &rpc.Request{}
+		require.NoError(t, c.ReadRequestHeader(r))
+		require.NoError(t, c.ReadRequestBody(in))
+
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- c.WriteResponse(&rpc.Response{ServiceMethod: r.ServiceMethod, Seq: r.Seq}, out)
+		}()
+
+		fr := frame.NewFrame()
+		require.NoError(t, rl.Receive(fr))
+		require.NoError(t, <-errCh)
+		assert.Equal(t, frame.CodecMsgpack, fr.ReadFlags())
+		return fr.Payload()[len(r.ServiceMethod):]
+	}
+
+	// the peer sends and expects the array-encoded structs
+	c.SetMsgpackOptions(&MsgpackOptions{UseArrayEncodedStructs: true})
+	body, err := msgpack.Marshal([]int{1, 2})
+	require.NoError(t, err)
+
+	in := point{}
+	// fixarray of 2 elements
+	assert.Equal(t, []byte{0x92, 0x03, 0x04}, roundTrip(1, body, &in, point{X: 3, Y: 4}))
+	assert.Equal(t, point{X: 1, Y: 2}, in)
+
+	// the custom tag is used for the map-encoded structs
+	c.SetMsgpackOptions(&MsgpackOptions{CustomStructTag: "custom"})
+	body, err = msgpack.Marshal(map[string]int{"x": 1, "y": 2})
+	require.NoError(t, err)
+
+	in = point{}
+	var out map[string]int
+	require.NoError(t, msgpack.Unmarshal(roundTrip(2, body, &in, point{X: 5, Y: 6}), &out))
+	assert.Equal(t, point{X: 1, Y: 2}, in)
+	assert.Equal(t, map[string]int{"x": 5, "y": 6}, out)
+
+	// nil restores the defaults
+	c.SetMsgpackOptions(nil)
+	body, err = msgpack.Marshal(point{X: 1, Y: 2})
+	require.NoError(t, err)
+
+	in = point{}
+	out = nil
+	require.NoError(t, msgpack.Unmarshal(roundTrip(3, body, &in, point{X: 7, Y: 8}), &out))
+	assert.Equal(t, point{X: 1, Y: 2}, in)
+	assert.Equal(t, map[string]int{"X": 7, "Y": 8}, out)
+}
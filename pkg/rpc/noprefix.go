@@ -0,0 +1,68 @@
+package rpc
+
+import (
+	"hash/crc32"
+	"net/rpc"
+
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+)
+
+// MethodID returns the value of the OptionMethodID option for the service method.
+// ID is the CRC32 (IEEE) checksum of the method, so it can be calculated on any side of the connection.
+func MethodID(method string) uint32 {
+	return crc32.ChecksumIEEE([]byte(method))
+}
+
+// RegisterMethodID registers the service method so the requests without the method prefix
+// (frame.NoMethodPrefix) can be resolved from their OptionMethodID option. The method which ID collides
+// with another registered method is rejected. Should be called before the codec is used.
+func (c *Codec) RegisterMethodID(method string) error {
+	const op = errors.Op("codec_register_method_id")
+	id := MethodID(method)
+	if prev, loaded := c.methodIDs.LoadOrStore(id, method); loaded && prev.(string) != method {
+		return errors.E(op, errors.Errorf("method ID %d of %s collides with %s", id, method, prev))
+	}
+
+	return nil
+}
+
+// requestMethod returns the service method of the frame: the payload prefix or the registered method ID
+func (c *Codec) requestMethod(fr *frame.Frame, opts []uint32) (string, error) {
+	if !fr.IsNoMethodPrefix(fr.Header()) {
+		return string(fr.Payload()[:opts[1]]), nil
+	}
+
+	if opts[1] != 0 {
+		return "", errors.Str("frame without the method prefix should have zero METHOD_LEN")
+	}
+
+	id, ok := lookupOption(opts, OptionMethodID)
+	if !ok {
+		return "", errors.Str("frame without the method prefix should have the method ID option")
+	}
+
+	method, ok := c.methodIDs.Load(id)
+	if !ok {
+		return "", errors.Errorf("unknown method ID: %d", id)
+	}
+
+	return method.(string), nil
+}
+
+// noPrefixResponse returns the response without the service method, so the payload carries only the body,
+// if the request was sent without the method prefix
+func (c *Codec) noPrefixResponse(r *rpc.Response) *rpc.Response {
+	if _, ok := c.noPrefix.Load(r.Seq); !ok {
+		return r
+	}
+
+	return &rpc.Response{Seq: r.Seq, Error: r.Error}
+}
+
+// SetNoMethodPrefix enables the requests without the service method prefix in the payload, the method is sent
+// in the OptionMethodID option and should be registered on the server codec with Codec.RegisterMethodID.
+// The server responds without the prefix too. Should be called before the codec is used.
+func (c *ClientCodec) SetNoMethodPrefix(enabled bool) {
+	c.noPrefix = enabled
+}
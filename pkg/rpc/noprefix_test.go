@@ -0,0 +1,101 @@
+package rpc
+
+import (
+	"net/rpc"
+	"testing"
+
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/roadrunner-server/goridge/v3/pkg/pipe"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodec_NoMethodPrefix(t *testing.T) {
+	c, rl := pipeCodec(t)
+	require.NoError(t, c.RegisterMethodID("test.Blob"))
+
+	blob := []byte{0xde, 0xad, 0xbe, 0xef}
+	noPrefixFrame := func(seq uint32, method string) *frame.Frame {
+		fr := frame.NewFrame()
+		fr.WriteOptions(fr.HeaderPtr(), seq, 0, OptionMethodID, MethodID(method))
+		fr.WriteVersion(fr.Header(), frame.Version1)
+		fr.WriteFlags(fr.Header(), frame.CodecRaw)
+		fr.SetNoMethodPrefix(fr.Header())
+		fr.WritePayloadLen(fr.Header(), uint32(len(blob)))
+		fr.WritePayload(blob)
+		fr.WriteCRC(fr.Header())
+		return fr
+	}
+
+	go func() {
+		_ = rl.Send(noPrefixFrame(1, "test.Blob"))
+		// unregistered method
+		_ = rl.Send(noPrefixFrame(2, "test.Unknown"))
+	}()
+
+	r := &rpc.Request{}
+	require.NoError(t, c.ReadRequestHeader(r))
+	assert.Equal(t, "test.Blob", r.ServiceMethod)
+	var in []byte
+	require.NoError(t, c.ReadRequestBody(&in))
+	assert.Equal(t, blob, in)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.WriteResponse(&rpc.Response{ServiceMethod: r.ServiceMethod, Seq: r.Seq}, in)
+	}()
+
+	fr := frame.NewFrame()
+	require.NoError(t, rl.Receive(fr))
+	require.NoError(t, <-errCh)
+
+	// exactly the body, the method length is zero
+	assert.True(t, fr.IsNoMethodPrefix(fr.Header()))
+	assert.Equal(t, []uint32{1, 0}, fr.ReadOptions(fr.Header()))
+	assert.Equal(t, blob, fr.Payload())
+
+	err := c.ReadRequestHeader(&rpc.Request{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown method ID")
+}
+
+func TestClientCodec_NoMethodPrefix(t *testing.T) {
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("pair", new(panicService)))
+
+	srv, cl := pipe.NewRelayPair()
+	codec := NewCodecWithRelay(srv)
+	require.NoError(t, codec.RegisterMethodID("pair.Echo"))
+	done := make(chan error, 1)
+	go func() {
+		done <- ServeConn(server, codec, nil)
+	}()
+
+	cc := NewClientCodecWithRelay(cl)
+	cc.SetNoMethodPrefix(true)
+	client := rpc.NewClientWithCodec(cc)
+
+	var out string
+	require.NoError(t, client.Call("pair.Echo", "no prefix", &out))
+	assert.Equal(t, "no prefix", out)
+
+	require.NoError(t, client.Close())
+	require.NoError(t, <-done)
+}
+
+func TestCodec_RegisterMethodIDCollision(t *testing.T) {
+	c, _ := pipeCodec(t)
+	require.NoError(t, c.RegisterMethodID("plumless"))
+	// registering the same method again is fine
+	require.NoError(t, c.RegisterMethodID("plumless"))
+
+	// "buckeroo" has the same CRC32 checksum
+	require.Equal(t, MethodID("plumless"), MethodID("buckeroo"))
+	err := c.RegisterMethodID("buckeroo")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "collides with plumless")
+
+	// the table is scoped to the codec
+	other, _ := pipeCodec(t)
+	require.NoError(t, other.RegisterMethodID("buckeroo"))
+}
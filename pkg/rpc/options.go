@@ -0,0 +1,104 @@
+package rpc
+
+import (
+	"hash/crc32"
+	"sync"
+
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+)
+
+// maxExtraOptions is the number of options allowed after SEQ_ID and METHOD_LEN (10 options max)
+const maxExtraOptions = 8
+
+// Extended options follow the mandatory SEQ_ID and METHOD_LEN options as key/value pairs of 32bit words:
+// [0] - SEQ_ID
+// [1] - METHOD_LEN
+// [2] - KEY, [3] - VALUE, ...
+const (
+	// OptionContentType carries the ID of the content-type (see ContentTypeID) used to select the codec
+	OptionContentType uint32 = 1
+	// OptionTotalSize is sent in the first frame of the stream with the total body size in bytes
+	OptionTotalSize uint32 = 2
+	// OptionTimestampLow and OptionTimestampHigh carry the lower and upper 32 bits of the send time
+	// in nanoseconds since the Unix epoch (see TimestampOptions)
+	OptionTimestampLow  uint32 = 3
+	OptionTimestampHigh uint32 = 4
+	// OptionMethodID carries the ID of the service method (see MethodID) of the frames without the method prefix
+	OptionMethodID uint32 = 5
+	// OptionTxID carries the ID of the transaction (see Codec.BeginTx) of the response
+	OptionTxID uint32 = 6
+	// OptionTraceID carries the trace (correlation) ID of the request or response, see WithTraceID
+	OptionTraceID uint32 = 7
+)
+
+// appendOptions appends the option pairs, failing if they don't fit into the header (10 options max)
+func appendOptions(opts []uint32, pairs ...uint32) ([]uint32, error) {
+	if len(opts)+len(pairs) > frame.OptionsMaxSize/frame.WORD {
+		return opts, errors.Errorf("options don't fit into the header: %d of %d words are taken, %d more needed",
+			len(opts), frame.OptionsMaxSize/frame.WORD, len(pairs))
+	}
+
+	return append(opts, pairs...), nil
+}
+
+// content type ID -> codec flag
+var contentTypes = &sync.Map{} //nolint:gochecknoglobals
+
+// ContentTypeID returns the value of the OptionContentType option for the content-type name.
+// ID is the CRC32 (IEEE) checksum of the name, so it can be calculated on any side of the connection.
+func ContentTypeID(name string) uint32 {
+	return crc32.ChecksumIEEE([]byte(name))
+}
+
+// RegisterContentType maps a content-type name (e.g. application/json) to the codec flag.
+// When a request carries the OptionContentType option with a registered ID, the codec is resolved from it
+// instead of the frame flags.
+func RegisterContentType(name string, codec byte) {
+	contentTypes.Store(ContentTypeID(name), codec)
+}
+
+// lookupOption searches for the extended option value by its key
+func lookupOption(opts []uint32, key uint32) (uint32, bool) {
+	for i := 2; i+1 < len(opts); i += 2 {
+		if opts[i] == key {
+			return opts[i+1], true
+		}
+	}
+
+	return 0, false
+}
+
+// resolveCodec returns the codec from the content-type option if registered, otherwise frame flags
+func resolveCodec(opts []uint32, flags byte) byte {
+	if id, ok := lookupOption(opts, OptionContentType); ok {
+		if codec, ok := contentTypes.Load(id); ok {
+			return codec.(byte)
+		}
+	}
+
+	return flags
+}
+
+// RequestOptions returns the options of the request following SEQ_ID and METHOD_LEN, if any.
+// Options are available from ReadRequestHeader until the response for the sequence is sent.
+func (c *Codec) RequestOptions(seq uint64) []uint32 {
+	opts, ok := c.reqOpts.Load(seq)
+	if !ok {
+		return nil
+	}
+
+	return opts.([]uint32)
+}
+
+// SetResponseOptions sets the options appended after SEQ_ID and METHOD_LEN to the response for the sequence.
+// Should be called before WriteResponse, up to 8 options.
+func (c *Codec) SetResponseOptions(seq uint64, opts ...uint32) error {
+	const op = errors.Op("goridge_set_response_options")
+	if len(opts) > maxExtraOptions {
+		return errors.E(op, errors.Errorf("up to %d options are allowed, got: %d", maxExtraOptions, len(opts)))
+	}
+
+	c.respOpts.Store(seq, opts)
+	return nil
+}
@@ -0,0 +1,173 @@
+package rpc
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+)
+
+// maxOrderedPending is the number of the frames buffered ahead of the previous responses in the ordered mode
+const maxOrderedPending = 1024
+
+// ordered keeps the sequences in the order of the requests and the frames of the responses written ahead
+// of the previous ones
+type ordered struct {
+	mu sync.Mutex
+	// sequences awaiting the response, in the order of ReadRequestHeader
+	seqs []uint64
+	// copies of the frames written out of order (and the number of them) and the sequences which response
+	// is complete
+	pending       map[uint64][]*frame.Frame
+	pendingFrames int
+	complete      map[uint64]bool
+	// error of the failed send, the codec is closed and the later sends return it
+	failed error
+}
+
+// SetOrderedResponses enables the ordered mode: the responses are sent in the order of the requests (ascending
+// sequences of the net/rpc client), the responses completed ahead of the previous ones are buffered until
+// the previous ones are sent. The stream frames of the request are sent when all the previous responses are sent.
+// Up to 1024 frames are buffered, the response which doesn't fit is dropped with an error. A failed send closes
+// the codec (the buffered responses can't be sent in order anymore), the later responses return its error.
+// Should be called before the codec is used.
+func (c *Codec) SetOrderedResponses(enabled bool) {
+	if !enabled {
+		c.ordered = nil
+		return
+	}
+
+	c.ordered = &ordered{
+		pending:  make(map[uint64][]*frame.Frame),
+		complete: make(map[uint64]bool),
+	}
+}
+
+// enqueue registers the sequence of the received request
+func (o *ordered) enqueue(seq uint64) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	// the request with the same sequence replaces the previous one
+	if !slices.Contains(o.seqs, seq) {
+		o.seqs = append(o.seqs, seq)
+	}
+}
+
+// reset drops the state of the previous connection
+func (o *ordered) reset() {
+	o.mu.Lock()
+	o.seqs = nil
+	clear(o.pending)
+	o.pendingFrames = 0
+	clear(o.complete)
+	o.failed = nil
+	o.mu.Unlock()
+}
+
+// sendOrdered sends the frame if all the previous responses are sent, otherwise buffers the copy of it.
+// Returns true if the frame was buffered.
+func (c *Codec) sendOrdered(seq uint64, fr *frame.Frame, ev Event) (bool, error) {
+	o := c.ordered
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if o.failed != nil {
+		return false, o.failed
+	}
+
+	last := !fr.IsStream(fr.Header())
+	idx := slices.Index(o.seqs, seq)
+	switch {
+	case idx < 0:
+		// the responses of the unknown sequences are not ordered
+		return c.relaySendFrame(fr, ev)
+	case idx > 0:
+		if o.pendingFrames >= maxOrderedPending {
+			// the response is dropped, the next ones don't wait for it
+			o.pendingFrames -= len(o.pending[seq])
+			delete(o.pending, seq)
+			delete(o.complete, seq)
+			o.seqs = slices.Delete(o.seqs, idx, idx+1)
+			return false, errors.Errorf("%d frames are already waiting for the previous ordered responses", maxOrderedPending)
+		}
+
+		o.pending[seq] = append(o.pending[seq], copyFrame(fr))
+		o.pendingFrames++
+		if last {
+			o.complete[seq] = true
+		}
+		return true, nil
+	}
+
+	buffered, err := c.relaySendFrame(fr, ev)
+	if err != nil {
+		return buffered, c.failOrdered(err)
+	}
+	if !last {
+		return buffered, nil
+	}
+
+	o.seqs = o.seqs[1:]
+	return buffered, c.flushOrdered()
+}
+
+// failOrdered closes the codec after the failed send, holding ordered.mu. The responses buffered after
+// the failed one are dropped.
+func (c *Codec) failOrdered(err error) error {
+	o := c.ordered
+	o.failed = err
+	o.seqs = nil
+	clear(o.pending)
+	o.pendingFrames = 0
+	clear(o.complete)
+
+	if c.closed.CompareAndSwap(false, true) {
+		c.closing()
+		_ = c.relay.Close()
+	}
+
+	return err
+}
+
+// flushOrdered sends the buffered frames of the sequences at the head of the queue, holding ordered.mu
+func (c *Codec) flushOrdered() error {
+	o := c.ordered
+	for len(o.seqs) > 0 {
+		head := o.seqs[0]
+		frames := o.pending[head]
+		delete(o.pending, head)
+		o.pendingFrames -= len(frames)
+
+		for _, fr := range frames {
+			buffered, err := c.relaySendFrame(fr, Event{})
+			if err != nil {
+				c.stats.errors.Add(1)
+				return c.failOrdered(err)
+			}
+
+			if !buffered {
+				c.frameSent(len(fr.Header())+len(fr.Payload()), Event{})
+			}
+		}
+
+		if !o.complete[head] {
+			return nil
+		}
+
+		delete(o.complete, head)
+		o.seqs = o.seqs[1:]
+	}
+
+	return nil
+}
+
+func copyFrame(fr *frame.Frame) *frame.Frame {
+	header := make([]byte, len(fr.Header()))
+	copy(header, fr.Header())
+	payload := make([]byte, len(fr.Payload()))
+	copy(payload, fr.Payload())
+
+	return frame.From(header, payload)
+}
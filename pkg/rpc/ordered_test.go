@@ -0,0 +1,99 @@
+package rpc
+
+import (
+	"net/rpc"
+	"testing"
+
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodec_OrderedResponses(t *testing.T) {
+	c, rl := pipeCodec(t)
+	c.SetOrderedResponses(true)
+
+	for seq := uint32(1); seq <= 3; seq++ {
+		go func() {
+			_ = rl.Send(requestFrame(seq, "test.Ordered", frame.CodecJSON, []byte(`"ping"`)))
+		}()
+
+		r := &rpc.Request{}
+		require.NoError(t, c.ReadRequestHeader(r))
+		var in string
+		require.NoError(t, c.ReadRequestBody(&in))
+	}
+
+	// the responses completed ahead of the first one are buffered
+	require.NoError(t, c.WriteResponse(&rpc.Response{ServiceMethod: "test.Ordered", Seq: 3}, "three"))
+	require.NoError(t, c.WriteResponse(&rpc.Response{ServiceMethod: "test.Ordered", Seq: 2}, "two"))
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.WriteResponse(&rpc.Response{ServiceMethod: "test.Ordered", Seq: 1}, "one")
+	}()
+
+	for seq := uint32(1); seq <= 3; seq++ {
+		fr := frame.NewFrame()
+		require.NoError(t, rl.Receive(fr))
+		assert.Equal(t, seq, fr.ReadOptions(fr.Header())[0])
+	}
+	require.NoError(t, <-errCh)
+
+	// nothing is left buffered
+	assert.Empty(t, c.ordered.seqs)
+	assert.Empty(t, c.ordered.pending)
+	assert.Equal(t, uint64(3), c.Stats().FramesOut)
+}
+
+func TestCodec_OrderedResponsesSendFailure(t *testing.T) {
+	c, rl := pipeCodec(t)
+	c.SetOrderedResponses(true)
+
+	for seq := uint32(1); seq <= 2; seq++ {
+		go func() {
+			_ = rl.Send(requestFrame(seq, "test.Ordered", frame.CodecJSON, []byte(`"ping"`)))
+		}()
+
+		r := &rpc.Request{}
+		require.NoError(t, c.ReadRequestHeader(r))
+		var in string
+		require.NoError(t, c.ReadRequestBody(&in))
+	}
+
+	require.NoError(t, c.WriteResponse(&rpc.Response{ServiceMethod: "test.Ordered", Seq: 2}, "two"))
+
+	// the head response fails, the buffered one can't be sent in order anymore
+	require.NoError(t, rl.Close())
+	require.Error(t, c.WriteResponse(&rpc.Response{ServiceMethod: "test.Ordered", Seq: 1}, "one"))
+	assert.True(t, c.closed.Load())
+	assert.Empty(t, c.ordered.seqs)
+	assert.Empty(t, c.ordered.pending)
+	assert.Zero(t, c.ordered.pendingFrames)
+
+	_, err := c.sendOrdered(3, requestFrame(3, "test.Ordered", frame.CodecJSON, nil), Event{})
+	require.Error(t, err)
+}
+
+func TestCodec_OrderedResponsesPendingLimit(t *testing.T) {
+	c, _ := pipeCodec(t)
+	c.SetOrderedResponses(true)
+
+	for seq := uint64(1); seq <= maxOrderedPending+2; seq++ {
+		c.ordered.enqueue(seq)
+	}
+
+	// the head is never written, the responses behind it are buffered up to the limit
+	for seq := uint32(2); seq <= maxOrderedPending+1; seq++ {
+		buffered, err := c.sendOrdered(uint64(seq), requestFrame(seq, "test.Ordered", frame.CodecJSON, nil), Event{})
+		require.NoError(t, err)
+		require.True(t, buffered)
+	}
+
+	last := uint32(maxOrderedPending + 2)
+	_, err := c.sendOrdered(uint64(last), requestFrame(last, "test.Ordered", frame.CodecJSON, nil), Event{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "frames are already waiting for the previous ordered responses")
+	assert.NotContains(t, c.ordered.seqs, uint64(last))
+	assert.Equal(t, maxOrderedPending, c.ordered.pendingFrames)
+}
@@ -0,0 +1,21 @@
+package rpc
+
+// PeekFlags returns the flags of the request frame read by ReadRequestHeader (e.g. to reject the unsupported codecs
+// before ReadRequestBody), 0 if there is no such frame. The frame is released by ReadRequestBody.
+func (c *Codec) PeekFlags() byte {
+	if c.frame == nil {
+		return 0
+	}
+
+	return c.frame.ReadFlags()
+}
+
+// PeekOptions returns all options (including SEQ_ID and METHOD_LEN) of the request frame read by ReadRequestHeader,
+// nil if there is no such frame. The returned slice is a copy. The frame is released by ReadRequestBody.
+func (c *Codec) PeekOptions() []uint32 {
+	if c.frame == nil {
+		return nil
+	}
+
+	return c.frame.ReadOptions(c.frame.Header())
+}
@@ -0,0 +1,47 @@
+package rpc
+
+import (
+	"net/rpc"
+	"testing"
+
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodec_Peek(t *testing.T) {
+	c := NewCodecWithRelay(&loopRelay{req: requestFrame(3, "test.Peek", frame.CodecJSON, []byte(`{"name":"a"}`), OptionTraceID, 5)})
+	assert.Zero(t, c.PeekFlags())
+	assert.Nil(t, c.PeekOptions())
+
+	r := &rpc.Request{}
+	require.NoError(t, c.ReadRequestHeader(r))
+
+	stored, ok := c.codec.Load(r.Seq)
+	require.True(t, ok)
+	assert.Equal(t, stored.(byte), c.PeekFlags())
+	assert.Equal(t, []uint32{3, uint32(len("test.Peek")), OptionTraceID, 5}, c.PeekOptions())
+
+	// peeking doesn't consume the frame
+	assert.Equal(t, frame.CodecJSON, c.PeekFlags())
+	out := &Payload{}
+	require.NoError(t, c.ReadRequestBody(out))
+	assert.Equal(t, "a", out.Name)
+
+	// the frame is released with the body
+	assert.Zero(t, c.PeekFlags())
+	assert.Nil(t, c.PeekOptions())
+}
+
+func TestCodec_PeekDiscardedBody(t *testing.T) {
+	c := NewCodecWithRelay(&loopRelay{req: requestFrame(4, "test.Peek", frame.CodecJSON, []byte(`{"name":"a"}`))})
+
+	r := &rpc.Request{}
+	require.NoError(t, c.ReadRequestHeader(r))
+	assert.Equal(t, frame.CodecJSON, c.PeekFlags())
+
+	// net/rpc discards the body of the unknown methods with nil, the frame is released anyway
+	require.NoError(t, c.ReadRequestBody(nil))
+	assert.Zero(t, c.PeekFlags())
+	assert.Nil(t, c.PeekOptions())
+}
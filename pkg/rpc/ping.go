@@ -0,0 +1,49 @@
+package rpc
+
+import (
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+)
+
+var (
+	// ErrPing is returned by ReadRequestHeader when the peer sent a keepalive ping frame, answer it with Codec.Pong
+	ErrPing = errors.Str("ping frame received") //nolint:gochecknoglobals
+	// ErrPong is returned by ReadRequestHeader when the peer answered the ping frame
+	ErrPong = errors.Str("pong frame received") //nolint:gochecknoglobals
+)
+
+// Ping sends the zero-payload keepalive frame with the frame.PING bit. The peer codec reports it with ErrPing.
+func (c *Codec) Ping() error {
+	const op = errors.Op("goridge_ping")
+	return c.sendKeepalive(op, frame.PING)
+}
+
+// Pong answers the ping frame with the zero-payload frame with the frame.PONG bit.
+func (c *Codec) Pong() error {
+	const op = errors.Op("goridge_pong")
+	return c.sendKeepalive(op, frame.PONG)
+}
+
+func (c *Codec) sendKeepalive(op errors.Op, bit byte) error {
+	fr := c.getFrame()
+	defer c.putFrame(fr)
+
+	fr.WriteVersion(fr.Header(), frame.Version1)
+	fr.WriteFlags(fr.Header(), frame.CONTROL)
+	if bit == frame.PING {
+		fr.SetPingBit(fr.Header())
+	} else {
+		fr.SetPongBit(fr.Header())
+	}
+	writeCRC(fr, c.crcDisabled)
+
+	err := c.relaySend(fr)
+	if err != nil {
+		c.stats.errors.Add(1)
+		return errors.E(op, err)
+	}
+
+	c.stats.framesOut.Add(1)
+	c.stats.bytesOut.Add(uint64(len(fr.Header())))
+	return nil
+}
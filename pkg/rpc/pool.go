@@ -0,0 +1,119 @@
+package rpc
+
+import (
+	"bytes"
+	"sync"
+	"sync/atomic"
+
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+)
+
+// FramePool is a goroutine-safe pool of frames. A single FramePool might be shared between many codecs,
+// so frames released by one connection are reused by another.
+type FramePool struct {
+	pool     sync.Pool
+	counters poolCounters
+}
+
+// PoolCounters describes the pool efficiency. Misses are the Gets which allocated a new item,
+// a high Misses/Gets ratio means that the pooled items are not reused.
+type PoolCounters struct {
+	Gets   uint64
+	Misses uint64
+}
+
+// PoolStats contains the counters of the codec pools
+type PoolStats struct {
+	// Buffers - bytes buffers used to encode the responses, might be shared with other codecs (see NewCodecWithPool)
+	Buffers PoolCounters
+	// Frames - frames pool, might be shared with other codecs (see NewCodecWithSharedPool and NewCodecWithPool)
+	Frames PoolCounters
+}
+
+type poolCounters struct {
+	gets   atomic.Uint64
+	misses atomic.Uint64
+}
+
+func (pc *poolCounters) load() PoolCounters {
+	return PoolCounters{Gets: pc.gets.Load(), Misses: pc.misses.Load()}
+}
+
+// NewFramePool creates an empty frame pool.
+func NewFramePool() *FramePool {
+	p := &FramePool{}
+	p.pool.New = func() any {
+		p.counters.misses.Add(1)
+		return frame.NewFrame()
+	}
+
+	return p
+}
+
+// Get returns a frame from the pool or allocates a new one.
+func (p *FramePool) Get() *frame.Frame {
+	p.counters.gets.Add(1)
+	return p.pool.Get().(*frame.Frame)
+}
+
+// Stats returns the pool counters.
+func (p *FramePool) Stats() PoolCounters {
+	return p.counters.load()
+}
+
+// Put resets the frame and returns it to the pool.
+func (p *FramePool) Put(f *frame.Frame) {
+	f.Reset()
+	p.pool.Put(f)
+}
+
+// Pool bundles the bytes buffers and the frames pools of the codec. A single Pool might be shared between many
+// codecs (see NewCodecWithPool), e.g. on the servers handling many short-lived connections.
+type Pool struct {
+	buffers  sync.Pool
+	counters poolCounters
+	frames   *FramePool
+}
+
+// NewPool creates an empty buffers and frames pool.
+func NewPool() *Pool {
+	return newPool(NewFramePool())
+}
+
+func newPool(frames *FramePool) *Pool {
+	p := &Pool{frames: frames}
+	p.buffers.New = func() any {
+		p.counters.misses.Add(1)
+		return new(bytes.Buffer)
+	}
+
+	return p
+}
+
+// Frames returns the frames pool.
+func (p *Pool) Frames() *FramePool {
+	return p.frames
+}
+
+// Stats returns the counters of the buffers and frames pools.
+func (p *Pool) Stats() PoolStats {
+	return PoolStats{
+		Buffers: p.counters.load(),
+		Frames:  p.frames.Stats(),
+	}
+}
+
+func (p *Pool) getBuffer() *bytes.Buffer {
+	p.counters.gets.Add(1)
+	return p.buffers.Get().(*bytes.Buffer)
+}
+
+func (p *Pool) putBuffer(b *bytes.Buffer) {
+	b.Reset()
+	p.buffers.Put(b)
+}
+
+// PoolStats returns the counters of the buffers and frames pools used by the codec.
+func (c *Codec) PoolStats() PoolStats {
+	return c.pool.Stats()
+}
@@ -0,0 +1,75 @@
+package rpc
+
+import (
+	"net"
+	"testing"
+
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFramePool_Stats(t *testing.T) {
+	pool := NewFramePool()
+
+	// nothing to reuse, every get allocates
+	frames := make([]*frame.Frame, 0, 10)
+	for i := 0; i < 10; i++ {
+		frames = append(frames, pool.Get())
+	}
+
+	assert.Equal(t, PoolCounters{Gets: 10, Misses: 10}, pool.Stats())
+
+	for _, f := range frames {
+		pool.Put(f)
+	}
+
+	_ = pool.Get()
+	st := pool.Stats()
+	assert.Equal(t, uint64(11), st.Gets)
+	// sync.Pool might drop the items, so the hit is not guaranteed
+	assert.LessOrEqual(t, st.Misses, uint64(11))
+}
+
+func TestCodec_PoolStats(t *testing.T) {
+	conn, _ := net.Pipe()
+	c := NewCodec(conn)
+	t.Cleanup(func() {
+		_ = c.Close()
+	})
+
+	for i := 0; i < 5; i++ {
+		_ = c.get()
+		_ = c.getFrame()
+	}
+
+	st := c.PoolStats()
+	assert.Equal(t, PoolCounters{Gets: 5, Misses: 5}, st.Buffers)
+	assert.Equal(t, PoolCounters{Gets: 5, Misses: 5}, st.Frames)
+}
+
+func TestPool_Shared(t *testing.T) {
+	pool := NewPool()
+	codecs := make([]*Codec, 0, 3)
+	for i := 0; i < 3; i++ {
+		conn, _ := net.Pipe()
+		c := NewCodecWithPool(conn, pool)
+		t.Cleanup(func() {
+			_ = c.Close()
+		})
+		codecs = append(codecs, c)
+	}
+
+	for _, c := range codecs {
+		c.put(c.get())
+		c.putFrame(c.getFrame())
+	}
+
+	// every codec reports the counters of the shared pool
+	st := pool.Stats()
+	assert.Equal(t, uint64(3), st.Buffers.Gets)
+	assert.Equal(t, uint64(3), st.Frames.Gets)
+	for _, c := range codecs {
+		assert.Equal(t, st, c.PoolStats())
+	}
+	assert.Same(t, pool.Frames(), codecs[0].pool.Frames())
+}
@@ -0,0 +1,96 @@
+package rpc
+
+import (
+	"reflect"
+	"sync"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// ProtoPool supplies and recycles the proto request messages, keyed by the message type (without the pointer).
+type ProtoPool interface {
+	// Get returns a message of the type, called before proto.Unmarshal
+	Get(t reflect.Type) proto.Message
+	// Put returns the message after the response for the request was sent, the message should not be used after
+	Put(msg proto.Message)
+}
+
+// NewProtoPool creates a ProtoPool backed by a sync.Pool per message type.
+func NewProtoPool() ProtoPool {
+	return &protoPool{}
+}
+
+type protoPool struct {
+	pools sync.Map // reflect.Type -> *sync.Pool
+}
+
+func (p *protoPool) pool(t reflect.Type) *sync.Pool {
+	if sp, ok := p.pools.Load(t); ok {
+		return sp.(*sync.Pool)
+	}
+
+	sp, _ := p.pools.LoadOrStore(t, &sync.Pool{New: func() any {
+		return reflect.New(t).Interface()
+	}})
+	return sp.(*sync.Pool)
+}
+
+func (p *protoPool) Get(t reflect.Type) proto.Message {
+	msg, _ := p.pool(t).Get().(proto.Message)
+	return msg
+}
+
+func (p *protoPool) Put(msg proto.Message) {
+	// don't hold the request data in the pool
+	proto.Reset(msg)
+	p.pool(baseType(msg)).Put(msg)
+}
+
+// SetProtoPool sets the pool of the proto request messages. When the out of ReadRequestBody is a *any placeholder
+// and the registered request type of the method (see SetTypeRegistry) is a proto message, the message is taken
+// from the pool before proto.Unmarshal and returned to it after the response was sent. Nil disables the pool.
+// Should be called before the codec is used.
+func (c *Codec) SetProtoPool(pool ProtoPool) {
+	c.protoPool = pool
+}
+
+// pooledRequest takes the request message of the method from the pool, the message is stored
+// for the sequence to be returned after the response
+func (c *Codec) pooledRequest(seq uint64, method string) (proto.Message, bool) {
+	if c.protoPool == nil || c.types == nil {
+		return nil, false
+	}
+
+	t, ok := c.types.requestType(method)
+	if !ok || !reflect.PointerTo(t).Implements(protoMessageType) {
+		return nil, false
+	}
+
+	msg := c.protoPool.Get(t)
+	if msg == nil {
+		return nil, false
+	}
+
+	c.pooledMu.Lock()
+	if c.pooled == nil {
+		c.pooled = make(map[uint64]proto.Message)
+	}
+	c.pooled[seq] = msg
+	c.pooledMu.Unlock()
+
+	return msg, true
+}
+
+// releasePooled returns the request message of the sequence to the pool
+func (c *Codec) releasePooled(seq uint64) {
+	c.pooledMu.Lock()
+	msg, ok := c.pooled[seq]
+	delete(c.pooled, seq)
+	c.pooledMu.Unlock()
+
+	if ok {
+		c.protoPool.Put(msg)
+	}
+}
+
+var protoMessageType = reflect.TypeOf((*proto.Message)(nil)).Elem() //nolint:gochecknoglobals
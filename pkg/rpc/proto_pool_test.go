@@ -0,0 +1,121 @@
+package rpc
+
+import (
+	"net/rpc"
+	"reflect"
+	"testing"
+
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/roadrunner-server/goridge/v3/tests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+// loopRelay receives the same request frame forever and discards the sent frames
+type loopRelay struct {
+	req *frame.Frame
+}
+
+func (l *loopRelay) Send(*frame.Frame) error {
+	return nil
+}
+
+func (l *loopRelay) Receive(fr *frame.Frame) error {
+	*fr.HeaderPtr() = append((*fr.HeaderPtr())[:0], l.req.Header()...)
+	fr.WritePayload(l.req.Payload())
+	return nil
+}
+
+func (l *loopRelay) Close() error {
+	return nil
+}
+
+type countingProtoPool struct {
+	ProtoPool
+	gets, puts int
+}
+
+func (p *countingProtoPool) Get(t reflect.Type) proto.Message {
+	p.gets++
+	return p.ProtoPool.Get(t)
+}
+
+func (p *countingProtoPool) Put(msg proto.Message) {
+	p.puts++
+	p.ProtoPool.Put(msg)
+}
+
+func TestCodec_SetProtoPool(t *testing.T) {
+	pb, err := proto.Marshal(&tests.Payload{Storage: "goridge"})
+	require.NoError(t, err)
+
+	c := NewCodecWithRelay(&loopRelay{req: requestFrame(1, "test.Proto", frame.CodecProto, pb)})
+	tr := NewTypeRegistry()
+	tr.RegisterMethodTypes("test.Proto", &tests.Payload{}, nil)
+	tr.RegisterMethodTypes("test.JSON", &typedRequest{}, nil)
+	c.SetTypeRegistry(tr)
+
+	pool := &countingProtoPool{ProtoPool: NewProtoPool()}
+	c.SetProtoPool(pool)
+
+	for i := 0; i < 3; i++ {
+		r := &rpc.Request{}
+		require.NoError(t, c.ReadRequestHeader(r))
+		var out any
+		require.NoError(t, c.ReadRequestBody(&out))
+		require.IsType(t, &tests.Payload{}, out)
+		assert.Equal(t, "goridge", out.(*tests.Payload).Storage)
+
+		require.NoError(t, c.WriteResponse(&rpc.Response{ServiceMethod: r.ServiceMethod, Seq: r.Seq}, &tests.Item{}))
+		// returned to the pool and reset after the response
+		assert.Empty(t, out.(*tests.Payload).Storage)
+	}
+
+	assert.Equal(t, 3, pool.gets)
+	assert.Equal(t, 3, pool.puts)
+
+	// non-proto types are allocated by the registry
+	c.relay = &loopRelay{req: requestFrame(2, "test.JSON", frame.CodecJSON, []byte(`{"name":"goridge"}`))}
+	r := &rpc.Request{}
+	require.NoError(t, c.ReadRequestHeader(r))
+	var out any
+	require.NoError(t, c.ReadRequestBody(&out))
+	require.IsType(t, &typedRequest{}, out)
+	assert.Equal(t, 3, pool.gets)
+}
+
+func BenchmarkCodec_ProtoPool(b *testing.B) {
+	pb, err := proto.Marshal(&tests.Payload{Storage: "goridge", Items: []*tests.Item{{Key: "a", Value: "b"}}})
+	require.NoError(b, err)
+
+	for _, pooled := range []bool{false, true} {
+		name := "allocated"
+		if pooled {
+			name = "pooled"
+		}
+
+		b.Run(name, func(b *testing.B) {
+			c := NewCodecWithRelay(&loopRelay{req: requestFrame(1, "test.Proto", frame.CodecProto, pb)})
+			tr := NewTypeRegistry()
+			tr.RegisterMethodTypes("test.Proto", &tests.Payload{}, nil)
+			c.SetTypeRegistry(tr)
+			if pooled {
+				c.SetProtoPool(NewProtoPool())
+			}
+
+			r := &rpc.Request{}
+			resp := &rpc.Response{}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_ = c.ReadRequestHeader(r)
+				var out any
+				_ = c.ReadRequestBody(&out)
+
+				resp.ServiceMethod, resp.Seq = r.ServiceMethod, r.Seq
+				_ = c.WriteResponse(resp, &tests.Item{})
+			}
+		})
+	}
+}
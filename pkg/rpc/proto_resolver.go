@@ -0,0 +1,33 @@
+package rpc
+
+import (
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// ProtoResolver returns the message type of the request of the method, e.g. dynamicpb.NewMessageType for
+// the messages known only by the descriptors or the protoregistry.GlobalTypes lookup by the method.
+type ProtoResolver func(method string) (protoreflect.MessageType, bool)
+
+// SetProtoResolver sets the resolver used by ReadRequestBody for the proto frames: when the out is a *any placeholder
+// and the method has no registered request type (see SetTypeRegistry), the resolved message is allocated, decoded
+// and stored into it, so the gateways might route the proto payloads without the static types.
+// Nil disables the resolver. Should be called before the codec is used.
+func (c *Codec) SetProtoResolver(resolver ProtoResolver) {
+	c.protoResolver = resolver
+}
+
+// resolveProto allocates the resolved proto message of the method
+func (c *Codec) resolveProto(method string, flags byte) (proto.Message, bool) {
+	if c.protoResolver == nil || !frame.Flags(flags).HasCodec(frame.Codec(frame.CodecProto)) {
+		return nil, false
+	}
+
+	mt, ok := c.protoResolver(method)
+	if !ok || mt == nil {
+		return nil, false
+	}
+
+	return mt.New().Interface(), true
+}
@@ -0,0 +1,66 @@
+package rpc
+
+import (
+	"net/rpc"
+	"testing"
+
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/roadrunner-server/goridge/v3/tests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+func TestCodec_ProtoResolver(t *testing.T) {
+	body, err := proto.Marshal(&tests.Payload{Storage: "dynamic", Items: []*tests.Item{{Key: "key", Value: "value"}}})
+	require.NoError(t, err)
+
+	// the gateway knows only the descriptor of the message
+	desc := tests.File_test_proto.Messages().ByName("Payload")
+	c, rl := pipeCodec(t)
+	c.SetProtoResolver(func(method string) (protoreflect.MessageType, bool) {
+		if method != "gateway.Route" {
+			return nil, false
+		}
+
+		return dynamicpb.NewMessageType(desc), true
+	})
+
+	go func() {
+		_ = rl.Send(requestFrame(1, "gateway.Route", frame.CodecProto, body))
+	}()
+
+	r := &rpc.Request{}
+	require.NoError(t, c.ReadRequestHeader(r))
+	var in any
+	require.NoError(t, c.ReadRequestBody(&in))
+
+	msg, ok := in.(*dynamicpb.Message)
+	require.True(t, ok)
+	assert.Equal(t, "dynamic", msg.Get(desc.Fields().ByName("storage")).String())
+	items := msg.Get(desc.Fields().ByName("items")).List()
+	require.Equal(t, 1, items.Len())
+	assert.Equal(t, "key", items.Get(0).Message().Get(desc.Fields().ByName("items").Message().Fields().ByName("key")).String())
+
+	// the reflective target is decoded in place
+	go func() {
+		_ = rl.Send(requestFrame(2, "gateway.Route", frame.CodecProto, body))
+	}()
+
+	require.NoError(t, c.ReadRequestHeader(r))
+	target := &tests.Payload{}
+	require.NoError(t, c.ReadRequestBody(target.ProtoReflect()))
+	assert.Equal(t, "dynamic", target.GetStorage())
+
+	// unknown methods are left to the caller
+	go func() {
+		_ = rl.Send(requestFrame(3, "gateway.Unknown", frame.CodecProto, body))
+	}()
+
+	require.NoError(t, c.ReadRequestHeader(r))
+	in = nil
+	require.Error(t, c.ReadRequestBody(&in))
+	assert.Nil(t, in)
+}
@@ -0,0 +1,129 @@
+package rpc
+
+import (
+	"math"
+
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+)
+
+// Push is the frame sent by the server without a request, see Codec.Push and ClientCodec.SetPushHandler.
+type Push struct {
+	// Seq is the push sequence ID, counted down from math.MaxUint32 and unrelated to the request sequences
+	Seq uint32
+	// Method names the pushed message, e.g. the event or the topic
+	Method string
+	// Codec is the codec flag of the payload
+	Codec byte
+	// Payload is the encoded body, owned by the Push
+	Payload []byte
+
+	// JSON implementation and msgpack options of the client codec, nil - default
+	json    JSONCodec
+	msgpack *MsgpackOptions
+}
+
+// Decode decodes the payload into out with the registered codec of the push, the JSON implementation
+// and the msgpack options of the client codec which received it.
+func (p *Push) Decode(out any) error {
+	const op = errors.Op("goridge_push_decode")
+	entry, ok := lookupCodecFor(p.Codec, p.json, p.msgpack)
+	if !ok {
+		return errors.E(op, errors.Errorf("unknown codec: %d", p.Codec))
+	}
+
+	err := entry.dec.Decode(p.Payload, out)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// PushHandler is called by ReadResponseHeader for every push frame received before the next response, so it
+// runs in the net/rpc reading goroutine and should not block.
+type PushHandler func(*Push)
+
+// Push sends the frame with the frame.Push bit carrying the body encoded with the codec, the server might push
+// it at any time, also while the requests are in flight. The frame has the push sequence ID, the push IDs are
+// counted down from math.MaxUint32, so they are told apart from the responses by the bit and never collide
+// with the request sequences (counted up from 0 by net/rpc) of the clients unaware of the pushes.
+// Safe for the concurrent use, the push frames are never interleaved with the response frames.
+func (c *Codec) Push(method string, codec byte, body any) error {
+	const op = errors.Op("goridge_push")
+
+	buf := c.get()
+	defer c.put(buf)
+
+	buf.WriteString(method)
+	if body != nil {
+		entry, ok := lookupCodecFor(codec, c.json, c.msgpack)
+		if !ok {
+			return errors.E(op, errors.Errorf("unknown codec: %d", codec))
+		}
+
+		err := entry.enc.Encode(body, buf)
+		if err != nil {
+			return errors.E(op, err)
+		}
+	}
+
+	fr := c.getFrame()
+	defer c.putFrame(fr)
+
+	version := c.version
+	if version == 0 {
+		version = frame.Version1
+	}
+	fr.WriteVersion(fr.Header(), version)
+	fr.WriteFlags(fr.Header(), codec)
+	fr.WriteOptions(fr.HeaderPtr(), math.MaxUint32-(c.pushSeq.Add(1)-1), uint32(len(method))) //nolint:gosec
+	fr.SetPushBit(fr.Header())
+	fr.WritePayloadLen(fr.Header(), uint32(buf.Len())) //nolint:gosec
+	fr.SetPayload(buf.Bytes())
+	writeCRC(fr, c.crcDisabled)
+
+	err := c.relaySend(fr)
+	if err != nil {
+		c.stats.errors.Add(1)
+		return errors.E(op, err)
+	}
+
+	c.stats.framesOut.Add(1)
+	c.stats.bytesOut.Add(uint64(len(fr.Header()) + buf.Len()))
+	return nil
+}
+
+// SetPushHandler sets the handler of the frames pushed by the server, nil drops them (the default). The push
+// frames are never delivered to net/rpc as the responses.
+// Should be called before the codec is used.
+func (c *ClientCodec) SetPushHandler(handler PushHandler) {
+	c.push = handler
+}
+
+// handlePush passes the received push frame to the handler
+func (c *ClientCodec) handlePush(fr *frame.Frame) error {
+	if c.push == nil {
+		return nil
+	}
+
+	opts := fr.ReadOptions(fr.Header())
+	if len(opts) < 2 {
+		return errors.Str("push frame should have at least 2 options. SEQ_ID and METHOD_LEN")
+	}
+	if int(opts[1]) > len(fr.Payload()) {
+		return errors.Str("method name offset is out of the payload bounds")
+	}
+
+	payload := fr.Payload()
+	c.push(&Push{
+		Seq:     opts[0],
+		Method:  string(payload[:opts[1]]),
+		Codec:   byte(fr.Flags().Codec()),
+		Payload: append([]byte(nil), payload[opts[1]:]...),
+		json:    c.json,
+		msgpack: c.msgpack,
+	})
+
+	return nil
+}
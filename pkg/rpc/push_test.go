@@ -0,0 +1,132 @@
+package rpc
+
+import (
+	"math"
+	"net/rpc"
+	"testing"
+
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/roadrunner-server/goridge/v3/pkg/pipe"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type pushService struct {
+	codec *Codec
+}
+
+// Echo pushes the progress while the request is in flight
+func (s *pushService) Echo(msg string, r *string) error {
+	err := s.codec.Push("progress", frame.CodecJSON, Payload{Name: msg, Value: 50})
+	if err != nil {
+		return err
+	}
+
+	*r = msg
+	return nil
+}
+
+func TestCodec_Push(t *testing.T) {
+	srv, cl := pipe.NewRelayPair()
+	codec := NewCodecWithRelay(srv)
+
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("push", &pushService{codec: codec}))
+	go func() {
+		_ = ServeConn(server, codec, nil)
+	}()
+
+	pushes := make(chan *Push, 10)
+	cc := NewClientCodecWithRelay(cl)
+	cc.SetPushHandler(func(p *Push) {
+		pushes <- p
+	})
+	client := rpc.NewClientWithCodec(cc)
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	// pushed before any request
+	require.NoError(t, codec.Push("hello", frame.CodecMsgpack, Payload{Name: "welcome"}))
+
+	for _, msg := range []string{"a", "b"} {
+		var out string
+		require.NoError(t, client.Call("push.Echo", msg, &out))
+		assert.Equal(t, msg, out)
+
+		// pushed between the requests
+		require.NoError(t, codec.Push("tick", frame.CodecRaw, []byte(msg)))
+	}
+
+	expected := []struct {
+		method string
+		codec  byte
+	}{
+		{"hello", frame.CodecMsgpack}, {"progress", frame.CodecJSON}, {"tick", frame.CodecRaw},
+		{"progress", frame.CodecJSON}, {"tick", frame.CodecRaw},
+	}
+	for i, e := range expected {
+		p := <-pushes
+		assert.Equal(t, uint32(math.MaxUint32-i), p.Seq) //nolint:gosec
+		assert.Equal(t, e.method, p.Method)
+		assert.Equal(t, e.codec, p.Codec)
+
+		switch p.Method {
+		case "hello":
+			var out Payload
+			require.NoError(t, p.Decode(&out))
+			assert.Equal(t, "welcome", out.Name)
+		case "progress":
+			var out Payload
+			require.NoError(t, p.Decode(&out))
+			assert.Equal(t, 50, out.Value)
+		case "tick":
+			assert.Contains(t, []string{"a", "b"}, string(p.Payload))
+		}
+	}
+}
+
+func TestCodec_PushDropped(t *testing.T) {
+	c, rl := pipeCodec(t)
+	cc := NewClientCodecWithRelay(rl)
+
+	go func() {
+		_ = c.Push("event", frame.CodecJSON, nil)
+		_ = c.WriteResponse(&rpc.Response{ServiceMethod: "test.Method", Seq: 1}, nil)
+	}()
+
+	// without the handler the push is skipped, the response is read
+	r := &rpc.Response{}
+	require.NoError(t, cc.ReadResponseHeader(r))
+	assert.Equal(t, uint64(1), r.Seq)
+	assert.Equal(t, "test.Method", r.ServiceMethod)
+	require.NoError(t, cc.ReadResponseBody(nil))
+
+	assert.Error(t, c.Push("event", 0xFF, "body"))
+}
+
+func TestCodec_PushMsgpackOptions(t *testing.T) {
+	c, rl := pipeCodec(t)
+	opts := &MsgpackOptions{CustomStructTag: "json"}
+	c.SetMsgpackOptions(opts)
+
+	pushes := make(chan *Push, 1)
+	cc := NewClientCodecWithRelay(rl)
+	cc.SetMsgpackOptions(opts)
+	cc.SetPushHandler(func(p *Push) {
+		pushes <- p
+	})
+
+	go func() {
+		_ = c.Push("event", frame.CodecMsgpack, Payload{Name: "tagged", Value: 7})
+		_ = c.WriteResponse(&rpc.Response{ServiceMethod: "test.Method", Seq: 1}, nil)
+	}()
+
+	require.NoError(t, cc.ReadResponseHeader(&rpc.Response{}))
+	require.NoError(t, cc.ReadResponseBody(nil))
+
+	// the payload is keyed by the json tags, the default msgpack decoder would skip the fields
+	var out Payload
+	require.NoError(t, (<-pushes).Decode(&out))
+	assert.Equal(t, Payload{Name: "tagged", Value: 7}, out)
+}
@@ -0,0 +1,46 @@
+package rpc
+
+import (
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/roadrunner-server/goridge/v3/pkg/relay"
+)
+
+// DefaultReadLimit is the default limit of the received frame size, see SetReadLimit
+const DefaultReadLimit = 256 * 1024 * 1024
+
+// SetReadLimit sets the limit of the received frame size (header, options and payload) in bytes. The bigger frames
+// are rejected by ReadRequestHeader with frame.FrameTooLargeError (matches frame.ErrFrameTooLarge), the connection
+// should be closed after that. The relays which support it (socket and pipe relays) reject the frame by the header
+// before the payload is read, other relays - after the frame is received. Default - DefaultReadLimit,
+// 0 disables the limit. Should be called before the codec is used.
+func (c *Codec) SetReadLimit(limit int) error {
+	const op = errors.Op("goridge_set_read_limit")
+	if limit < 0 {
+		return errors.E(op, errors.Errorf("read limit should not be negative, got: %d", limit))
+	}
+
+	c.readLimit = limit
+	setReadLimit(c.relay, limit)
+	return nil
+}
+
+func setReadLimit(rl relay.Relay, limit int) {
+	type maxFrameSizeSetter interface {
+		SetMaxFrameSize(n int)
+	}
+
+	if s, ok := rl.(maxFrameSizeSetter); ok {
+		s.SetMaxFrameSize(limit)
+	}
+}
+
+// checkReadLimit rejects the received frame exceeding the limit, for the relays which can't reject it by the header
+func (c *Codec) checkReadLimit(fr *frame.Frame) error {
+	size := uint64(len(fr.Header()) + len(fr.Payload()))
+	if c.readLimit > 0 && size > uint64(c.readLimit) {
+		return &frame.FrameTooLargeError{Size: size, Limit: uint64(c.readLimit)}
+	}
+
+	return nil
+}
@@ -0,0 +1,56 @@
+package rpc
+
+import (
+	"bytes"
+	"net/rpc"
+	"testing"
+
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodec_SetReadLimit(t *testing.T) {
+	c, rl := pipeCodec(t)
+	assert.Equal(t, DefaultReadLimit, c.readLimit)
+	require.Error(t, c.SetReadLimit(-1))
+	require.NoError(t, c.SetReadLimit(1024))
+
+	// the frames within the limit are accepted
+	go func() {
+		_ = rl.Send(requestFrame(1, "test.Limit", frame.CodecRaw, []byte("small")))
+	}()
+	r := &rpc.Request{}
+	require.NoError(t, c.ReadRequestHeader(r))
+	require.NoError(t, c.ReadRequestBody(nil))
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- rl.Send(requestFrame(2, "test.Limit", frame.CodecRaw, bytes.Repeat([]byte{1}, 64*1024)))
+	}()
+
+	err := c.ReadRequestHeader(r)
+	require.ErrorIs(t, err, frame.ErrFrameTooLarge)
+	var fe *frame.FrameTooLargeError
+	require.ErrorAs(t, err, &fe)
+	assert.Equal(t, uint64(1024), fe.Limit)
+	assert.Equal(t, uint64(20+len("test.Limit")+64*1024), fe.Size)
+
+	// the payload was not read, the connection is closed cleanly and the sender is unblocked
+	require.NoError(t, c.Close())
+	require.Error(t, <-errCh)
+}
+
+func TestCodec_SetReadLimitReceived(t *testing.T) {
+	// the relay without the limit support, the frame is rejected after receiving
+	c := NewCodecWithRelay(&loopRelay{req: requestFrame(1, "test.Limit", frame.CodecRaw, make([]byte, 100))})
+	require.NoError(t, c.SetReadLimit(64))
+
+	err := c.ReadRequestHeader(&rpc.Request{})
+	require.ErrorIs(t, err, frame.ErrFrameTooLarge)
+
+	require.NoError(t, c.SetReadLimit(0))
+	require.NoError(t, c.ReadRequestHeader(&rpc.Request{}))
+	require.NoError(t, c.ReadRequestBody(nil))
+	require.NoError(t, c.Close())
+}
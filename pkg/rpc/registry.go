@@ -0,0 +1,235 @@
+package rpc
+
+import (
+	"bytes"
+	"encoding/gob"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	"github.com/goccy/go-json"
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/vmihailenco/msgpack/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Encoder marshals the body and appends it to the buffer (which already contains the service method prefix).
+type Encoder interface {
+	Encode(body any, buf *bytes.Buffer) error
+}
+
+// Decoder unmarshals the payload (without the service method prefix) into the out value.
+type Decoder interface {
+	Decode(payload []byte, out any) error
+}
+
+// EncoderFunc is an adapter to use ordinary functions as an Encoder.
+type EncoderFunc func(body any, buf *bytes.Buffer) error
+
+func (f EncoderFunc) Encode(body any, buf *bytes.Buffer) error {
+	return f(body, buf)
+}
+
+// DecoderFunc is an adapter to use ordinary functions as a Decoder.
+type DecoderFunc func(payload []byte, out any) error
+
+func (f DecoderFunc) Decode(payload []byte, out any) error {
+	return f(payload, out)
+}
+
+type codecEntry struct {
+	flag byte
+	enc  Encoder
+	dec  Decoder
+}
+
+var (
+	// copy on write, registrations are rare, lookups are on every frame
+	codecs   atomic.Pointer[[]codecEntry] //nolint:gochecknoglobals
+	codecsMu sync.Mutex                   //nolint:gochecknoglobals
+
+	// the order defines a priority when several codec flags are set
+	builtinCodecs = []codecEntry{ //nolint:gochecknoglobals
+		{flag: frame.CodecProto, enc: EncoderFunc(encodeProto), dec: DecoderFunc(decodeProto)},
+		{flag: frame.CodecJSON, enc: EncoderFunc(encodeJSON), dec: DecoderFunc(json.Unmarshal)},
+		{flag: frame.CodecRaw, enc: EncoderFunc(encodeRaw), dec: DecoderFunc(decodeRaw)},
+		{flag: frame.CodecMsgpack, enc: EncoderFunc(encodeMsgpack), dec: DecoderFunc(msgpack.Unmarshal)},
+		{flag: frame.CodecGob, enc: EncoderFunc(encodeGob), dec: DecoderFunc(decodeGob)},
+		{flag: frame.CodecFlatbuffers, enc: EncoderFunc(encodeFlatbuffers), dec: DecoderFunc(decodeFlatbuffers)},
+		// the bits of CodecBSON are the CodecRaw and CodecMsgpack ones, it's selected only by the exact match
+		{flag: frame.CodecBSON, enc: EncoderFunc(encodeBSON), dec: DecoderFunc(bson.Unmarshal)},
+	}
+)
+
+func loadCodecs() []codecEntry {
+	if list := codecs.Load(); list != nil {
+		return *list
+	}
+
+	return builtinCodecs
+}
+
+// RegisterCodec registers encoder and decoder for the codec flag. Registering an already known flag
+// (including the built-in ones) replaces its encoder and decoder.
+func RegisterCodec(flag byte, enc Encoder, dec Decoder) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+
+	old := loadCodecs()
+	list := make([]codecEntry, len(old), len(old)+1)
+	copy(list, old)
+
+	for i := 0; i < len(list); i++ {
+		if list[i].flag == flag {
+			list[i].enc, list[i].dec = enc, dec
+			codecs.Store(&list)
+			return
+		}
+	}
+
+	list = append(list, codecEntry{flag: flag, enc: enc, dec: dec})
+	codecs.Store(&list)
+}
+
+// lookupCodec finds the registered codec for the frame flags. Exact match wins, otherwise the first codec
+// (in the registration order) whose flag bits are set.
+func lookupCodec(flags byte) (codecEntry, bool) {
+	list := loadCodecs()
+	flags = byte(frame.Flags(flags).Codec())
+
+	for _, e := range list {
+		if e.flag == flags {
+			return e, true
+		}
+	}
+
+	for _, e := range list {
+		if flags&e.flag == e.flag {
+			return e, true
+		}
+	}
+
+	return codecEntry{}, false
+}
+
+func encodeProto(body any, buf *bytes.Buffer) error {
+	msg, ok := body.(proto.Message)
+	if !ok {
+		return errors.Str("message type is not a proto")
+	}
+
+	d, err := proto.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	buf.Write(d)
+	return nil
+}
+
+func decodeProto(payload []byte, out any) error {
+	// check if the out message is a correct proto.Message
+	// instead send an error
+	switch pOut := out.(type) {
+	case proto.Message:
+		return proto.Unmarshal(payload, pOut)
+	case protoreflect.Message:
+		// reflective view of the message, e.g. of the dynamicpb message
+		return proto.Unmarshal(payload, pOut.Interface())
+	default:
+		return errors.Str("message type is not a proto")
+	}
+}
+
+func encodeJSON(body any, buf *bytes.Buffer) error {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	buf.Write(data)
+	return nil
+}
+
+// encodeRaw writes the byte slices as is, io.WriterTo and io.Reader bodies are copied to the buffer
+// without the intermediate slice. For the bodies which should not be buffered at all use Codec.WriteStream.
+func encodeRaw(body any, buf *bytes.Buffer) error {
+	switch data := body.(type) {
+	case []byte:
+		buf.Write(data)
+	case *[]byte:
+		buf.Write(*data)
+	case io.WriterTo:
+		_, err := data.WriteTo(buf)
+		return err
+	case io.Reader:
+		_, err := buf.ReadFrom(data)
+		return err
+	case *io.Reader:
+		// reply in the net/rpc methods is always a pointer
+		if *data == nil {
+			return nil
+		}
+		_, err := buf.ReadFrom(*data)
+		return err
+	default:
+		return errors.Str("unknown Raw payload type")
+	}
+
+	return nil
+}
+
+func decodeRaw(payload []byte, out any) error {
+	if raw, ok := out.(*[]byte); ok {
+		*raw = append(*raw, payload...)
+	}
+
+	return nil
+}
+
+func encodeMsgpack(body any, buf *bytes.Buffer) error {
+	b, err := msgpack.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	buf.Write(b)
+	return nil
+}
+
+func encodeGob(body any, buf *bytes.Buffer) error {
+	return gobError(gob.NewEncoder(buf).Encode(body))
+}
+
+func decodeGob(payload []byte, out any) error {
+	return gobError(gob.NewDecoder(bytes.NewReader(payload)).Decode(out))
+}
+
+// encodeFlatbuffers writes the prebuilt FlatBuffers buffer (e.g. flatbuffers.Builder.FinishedBytes()) as is
+func encodeFlatbuffers(body any, buf *bytes.Buffer) error {
+	switch data := body.(type) {
+	case []byte:
+		buf.Write(data)
+	case *[]byte:
+		buf.Write(*data)
+	default:
+		return errors.Str("FlatBuffers payload should be a prebuilt []byte buffer")
+	}
+
+	return nil
+}
+
+// decodeFlatbuffers points the out slice to the payload without copying, the payload is not reused by the codecs,
+// so the slice might be accessed as the FlatBuffers table (e.g. GetRootAsX(*out, 0)) as long as needed
+func decodeFlatbuffers(payload []byte, out any) error {
+	data, ok := out.(*[]byte)
+	if !ok {
+		return errors.Str("FlatBuffers payload should be read into *[]byte")
+	}
+
+	*data = payload
+	return nil
+}
@@ -0,0 +1,102 @@
+package rpc
+
+import (
+	"bytes"
+	"net/rpc"
+	"testing"
+
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/roadrunner-server/goridge/v3/pkg/pipe"
+	"github.com/roadrunner-server/goridge/v3/tests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestBuiltinCodecsRoundTrip(t *testing.T) {
+	body := Payload{Name: "round-trip", Value: 7, Keys: map[string]string{"a": "b", "c": "d"}}
+
+	for _, flag := range []byte{frame.CodecJSON, frame.CodecMsgpack, frame.CodecGob} {
+		entry, ok := lookupCodec(flag)
+		require.True(t, ok)
+
+		buf := &bytes.Buffer{}
+		require.NoError(t, entry.enc.Encode(body, buf))
+
+		out := Payload{}
+		require.NoError(t, entry.dec.Decode(buf.Bytes(), &out))
+		assert.Equal(t, body, out, "codec %d", flag)
+	}
+
+	raw, ok := lookupCodec(frame.CodecRaw)
+	require.True(t, ok)
+	buf := &bytes.Buffer{}
+	require.NoError(t, raw.enc.Encode([]byte("raw"), buf))
+	var rawOut []byte
+	require.NoError(t, raw.dec.Decode(buf.Bytes(), &rawOut))
+	assert.Equal(t, []byte("raw"), rawOut)
+
+	pb, ok := lookupCodec(frame.CodecProto)
+	require.True(t, ok)
+	msg := &tests.Payload{Storage: "proto", Items: []*tests.Item{{Key: "a", Value: "b"}}}
+	buf.Reset()
+	require.NoError(t, pb.enc.Encode(msg, buf))
+	pbOut := &tests.Payload{}
+	require.NoError(t, pb.dec.Decode(buf.Bytes(), pbOut))
+	assert.True(t, proto.Equal(msg, pbOut))
+}
+
+func TestProtoCodecRejectsNonProto(t *testing.T) {
+	entry, ok := lookupCodec(frame.CodecProto)
+	require.True(t, ok)
+
+	err := entry.enc.Encode(Payload{Name: "not a proto"}, &bytes.Buffer{})
+	assert.EqualError(t, err, "message type is not a proto")
+
+	err = entry.dec.Decode([]byte{}, &Payload{})
+	assert.EqualError(t, err, "message type is not a proto")
+}
+
+func TestCodec_FlatbuffersRoundTrip(t *testing.T) {
+	// a prebuilt table: root offset, vtable and the fields, the codec doesn't interpret the bytes
+	table := []byte{0x0c, 0x00, 0x00, 0x00, 0x08, 0x00, 0x0c, 0x00, 0x04, 0x00, 0x08, 0x00, 0x2a, 0x00, 0x00, 0x00}
+
+	srv, cl := pipe.NewRelayPair()
+	c := NewCodecWithRelay(srv)
+	client := NewClientCodecWithRelay(cl)
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- client.WriteRequest(&rpc.Request{ServiceMethod: "test.Flat", Seq: 1}, WithCodec(table, frame.CodecFlatbuffers))
+	}()
+
+	r := &rpc.Request{}
+	require.NoError(t, c.ReadRequestHeader(r))
+	require.NoError(t, <-errCh)
+	assert.Equal(t, frame.CodecFlatbuffers, c.PeekFlags())
+
+	payload := c.frame.Payload()[len("test.Flat"):]
+	var in []byte
+	require.NoError(t, c.ReadRequestBody(&in))
+	assert.Equal(t, table, in)
+	// not copied
+	assert.Same(t, &payload[0], &in[0])
+
+	go func() {
+		errCh <- c.WriteResponse(&rpc.Response{ServiceMethod: r.ServiceMethod, Seq: r.Seq}, in)
+	}()
+
+	resp := &rpc.Response{}
+	require.NoError(t, client.ReadResponseHeader(resp))
+	var out []byte
+	require.NoError(t, client.ReadResponseBody(&out))
+	require.NoError(t, <-errCh)
+	assert.Equal(t, table, out)
+
+	// only the prebuilt buffers
+	entry, ok := lookupCodec(frame.CodecFlatbuffers)
+	require.True(t, ok)
+	assert.Error(t, entry.enc.Encode("string", &bytes.Buffer{}))
+	var s string
+	assert.Error(t, entry.dec.Decode(table, &s))
+}
@@ -0,0 +1,118 @@
+package rpc
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// OptionTimeout carries the time left until the deadline of the request in milliseconds, see TimeoutOptions
+const OptionTimeout uint32 = 9
+
+// ContextReceiver is implemented by the request args of the handlers which need the request context, e.g.
+//
+//	type Args struct {
+//		Name string
+//		ctx  context.Context
+//	}
+//
+//	func (a *Args) SetContext(ctx context.Context) { a.ctx = ctx }
+//
+// The codec sets the context before the body is decoded, see Codec.SetRequestContexts.
+type ContextReceiver interface {
+	SetContext(ctx context.Context)
+}
+
+// requestContext is the context of the request awaiting the response
+type requestContext struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// TimeoutOptions returns the OptionTimeout pair of the timeout (rounded up to milliseconds), to be appended
+// to the request options. The timeout is relative, so it doesn't depend on the synchronized clocks.
+func TimeoutOptions(timeout time.Duration) []uint32 {
+	ms := (timeout + time.Millisecond - 1) / time.Millisecond
+	if ms < 0 {
+		ms = 0
+	}
+	if ms > math.MaxUint32 {
+		ms = math.MaxUint32
+	}
+
+	return []uint32{OptionTimeout, uint32(ms)} //nolint:gosec
+}
+
+// ReadTimeout reads the timeout from the options following SEQ_ID and METHOD_LEN
+// (see Codec.RequestOptions and ClientCodec.ResponseOptions).
+func ReadTimeout(opts []uint32) (time.Duration, bool) {
+	for i := 0; i+1 < len(opts); i += 2 {
+		if opts[i] == OptionTimeout {
+			return time.Duration(opts[i+1]) * time.Millisecond, true
+		}
+	}
+
+	return 0, false
+}
+
+// SetRequestContexts enables the contexts of the requests: every request gets the context derived from the
+// context of ReadRequestHeaderCtx (context.Background for ReadRequestHeader) with the deadline of the
+// OptionTimeout option, counted from the receive time. The context is canceled when the response is sent
+// or the codec is closed. See RequestContext and ContextReceiver.
+// Should be called before the codec is used.
+func (c *Codec) SetRequestContexts(enabled bool) {
+	c.requestContexts = enabled
+}
+
+// RequestContext returns the context of the request, available until the response for the sequence is sent.
+func (c *Codec) RequestContext(seq uint64) (context.Context, bool) {
+	rc, ok := c.reqCtx.Load(seq)
+	if !ok {
+		return nil, false
+	}
+
+	return rc.(*requestContext).ctx, true
+}
+
+// startContext derives the context of the received request
+func (c *Codec) startContext(parent context.Context, seq uint64, opts []uint32) {
+	rc := &requestContext{}
+	if timeout, ok := ReadTimeout(opts); ok {
+		rc.ctx, rc.cancel = context.WithTimeout(parent, timeout)
+	} else {
+		rc.ctx, rc.cancel = context.WithCancel(parent)
+	}
+
+	// the request with the same sequence replaces the previous one
+	if prev, loaded := c.reqCtx.Swap(seq, rc); loaded {
+		prev.(*requestContext).cancel()
+	}
+}
+
+// setContext passes the context of the request to the args implementing ContextReceiver
+func (c *Codec) setContext(seq uint64, out any) {
+	receiver, ok := out.(ContextReceiver)
+	if !ok {
+		return
+	}
+
+	if ctx, ok := c.RequestContext(seq); ok {
+		receiver.SetContext(ctx)
+	}
+}
+
+// finishContext cancels the context of the answered request
+func (c *Codec) finishContext(seq uint64) {
+	if rc, loaded := c.reqCtx.LoadAndDelete(seq); loaded {
+		rc.(*requestContext).cancel()
+	}
+}
+
+// cancelContexts cancels the contexts of all pending requests
+func (c *Codec) cancelContexts() {
+	c.reqCtx.Range(func(seq, rc any) bool {
+		rc.(*requestContext).cancel()
+		c.reqCtx.Delete(seq)
+		return true
+	})
+}
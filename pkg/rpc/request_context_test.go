@@ -0,0 +1,98 @@
+package rpc
+
+import (
+	"context"
+	"net/rpc"
+	"testing"
+	"time"
+
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type ContextArgs struct {
+	Name string
+	ctx  context.Context
+}
+
+func (a *ContextArgs) SetContext(ctx context.Context) {
+	a.ctx = ctx
+}
+
+type ContextService struct {
+	contexts chan context.Context
+}
+
+func (s *ContextService) Deadline(args *ContextArgs, reply *string) error {
+	s.contexts <- args.ctx
+	*reply = args.Name
+	return nil
+}
+
+func TestTimeoutOptions(t *testing.T) {
+	timeout, ok := ReadTimeout(TimeoutOptions(1500 * time.Microsecond))
+	require.True(t, ok)
+	assert.Equal(t, 2*time.Millisecond, timeout)
+
+	_, ok = ReadTimeout([]uint32{OptionTraceID, 1})
+	assert.False(t, ok)
+}
+
+func TestCodec_RequestContext(t *testing.T) {
+	c, rl := pipeCodec(t)
+	c.SetRequestContexts(true)
+
+	server := rpc.NewServer()
+	svc := &ContextService{contexts: make(chan context.Context, 2)}
+	require.NoError(t, server.RegisterName("test", svc))
+	go server.ServeCodec(c)
+
+	before := time.Now()
+	require.NoError(t, rl.Send(requestFrame(1, "test.Deadline", frame.CodecJSON, []byte(`{"Name":"deadline"}`),
+		TimeoutOptions(time.Minute)...)))
+
+	ctx := <-svc.contexts
+	require.NotNil(t, ctx)
+	deadline, ok := ctx.Deadline()
+	require.True(t, ok)
+	assert.WithinDuration(t, before.Add(time.Minute), deadline, time.Second)
+
+	fr := frame.NewFrame()
+	require.NoError(t, rl.Receive(fr))
+	assert.False(t, fr.Flags().IsError())
+
+	// the context is canceled once the response is sent
+	<-ctx.Done()
+	_, ok = c.RequestContext(1)
+	assert.False(t, ok)
+
+	// no deadline without the option, the context is canceled by Close
+	require.NoError(t, rl.Send(requestFrame(2, "test.Deadline", frame.CodecJSON, []byte(`{"Name":"cancel"}`))))
+	ctx = <-svc.contexts
+	_, ok = ctx.Deadline()
+	assert.False(t, ok)
+	require.NoError(t, rl.Receive(frame.NewFrame()))
+	<-ctx.Done()
+}
+
+func TestCodec_RequestContextClose(t *testing.T) {
+	c, rl := pipeCodec(t)
+	c.SetRequestContexts(true)
+
+	go func() {
+		_ = rl.Send(requestFrame(1, "test.Deadline", frame.CodecJSON, []byte(`{}`)))
+	}()
+
+	r := &rpc.Request{}
+	require.NoError(t, c.ReadRequestHeader(r))
+	args := &ContextArgs{}
+	require.NoError(t, c.ReadRequestBody(args))
+	require.NotNil(t, args.ctx)
+
+	go func() {
+		_ = rl.Receive(frame.NewFrame())
+	}()
+	require.NoError(t, c.Close())
+	assert.ErrorIs(t, args.ctx.Err(), context.Canceled)
+}
@@ -0,0 +1,77 @@
+package rpc
+
+import (
+	"io"
+	"sync"
+
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/goridge/v3/pkg/relay"
+	"github.com/roadrunner-server/goridge/v3/pkg/socket"
+)
+
+// Reset rebinds the closed codec to the new connection over the socket relay, so the codecs might be pooled
+// and reused across connections. See ResetRelay.
+func (c *Codec) Reset(rwc io.ReadWriteCloser) error {
+	return c.ResetRelay(socket.NewSocketRelay(rwc))
+}
+
+// ResetRelay rebinds the closed codec to the new relay. The state of the previous connection (the codecs
+// and options of the pending sequences, the open transaction, the negotiated codecs, the push sequences,
+// the close reason) is dropped, the configuration set with the Set* methods and the stats are kept. Returns
+// an error if the codec was not closed.
+func (c *Codec) ResetRelay(rl relay.Relay) error {
+	const op = errors.Op("goridge_codec_reset")
+	if !c.closed.Load() {
+		return errors.E(op, errors.Str("codec should be closed before the reset"))
+	}
+
+	c.relay = rl
+	setCRCPolicy(rl, c.crcDisabled)
+	setReadLimit(rl, c.readLimit)
+	c.frame = nil
+
+	c.codec = sync.Map{}
+	c.hooks = sync.Map{}
+	c.reqOpts = sync.Map{}
+	c.respOpts = sync.Map{}
+	c.noPrefix = sync.Map{}
+	c.unknownSeqs = sync.Map{}
+	c.reqCtx = sync.Map{}
+	c.seqStarted = sync.Map{}
+	c.seqExpired = sync.Map{}
+	if c.inFlight != nil {
+		c.inFlight = make(chan struct{}, cap(c.inFlight))
+	}
+
+	if c.protoPool != nil {
+		c.pooledMu.Lock()
+		for seq, msg := range c.pooled {
+			c.protoPool.Put(msg)
+			delete(c.pooled, seq)
+		}
+		c.pooledMu.Unlock()
+	}
+
+	if c.ordered != nil {
+		c.ordered.reset()
+	}
+
+	if c.batch != nil {
+		c.sendMu.Lock()
+		c.batch.reset()
+		c.sendMu.Unlock()
+	}
+
+	c.txMu.Lock()
+	c.tx = nil
+	c.txOpen.Store(0)
+	c.txMu.Unlock()
+
+	c.negotiated = 0
+	c.pushSeq.Store(0)
+	c.lastExpire.Store(0)
+	c.draining.Store(false)
+	c.closeReason.Store(nil)
+	c.closed.Store(false)
+	return nil
+}
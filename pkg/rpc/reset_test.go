@@ -0,0 +1,74 @@
+package rpc
+
+import (
+	"net"
+	"net/rpc"
+	"testing"
+
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/roadrunner-server/goridge/v3/pkg/socket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodec_Reset(t *testing.T) {
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("pair", new(panicService)))
+
+	srvConn, clConn := net.Pipe()
+	c := NewCodec(srvConn)
+	require.Error(t, c.Reset(srvConn))
+
+	for i, name := range []string{"first", "second"} {
+		if i > 0 {
+			srvConn, clConn = net.Pipe()
+			require.NoError(t, c.Reset(srvConn))
+		}
+
+		done := make(chan error, 1)
+		go func() {
+			done <- ServeConn(server, c, nil)
+		}()
+
+		client := rpc.NewClientWithCodec(NewClientCodec(clConn))
+		var out string
+		require.NoError(t, client.Call("pair.Echo", name, &out))
+		assert.Equal(t, name, out)
+
+		require.NoError(t, client.Close())
+		require.NoError(t, <-done)
+	}
+}
+
+func TestCodec_ResetDropsStaleState(t *testing.T) {
+	c, rl := pipeCodec(t)
+	require.NoError(t, c.SetMaxInFlight(1))
+
+	go func() {
+		assert.NoError(t, rl.Send(requestFrame(1, "test.Stale", frame.CodecJSON, []byte(`{}`), OptionTraceID, 9)))
+	}()
+	r := &rpc.Request{}
+	require.NoError(t, c.ReadRequestHeader(r))
+	require.NoError(t, c.ReadRequestBody(&Payload{}))
+	// the connection is closed before the response
+	require.NoError(t, c.Close())
+
+	srvConn, clConn := net.Pipe()
+	require.NoError(t, c.Reset(srvConn))
+	t.Cleanup(func() {
+		_ = c.Close()
+		_ = clConn.Close()
+	})
+
+	assert.Zero(t, c.inFlightSequences())
+	_, ok := c.TraceID(1)
+	assert.False(t, ok)
+
+	// the in-flight slot of the stale request is free
+	rl = socket.NewSocketRelay(clConn)
+	go func() {
+		assert.NoError(t, rl.Send(requestFrame(1, "test.Fresh", frame.CodecJSON, []byte(`{}`))))
+	}()
+	require.NoError(t, c.ReadRequestHeader(r))
+	assert.Equal(t, "test.Fresh", r.ServiceMethod)
+}
@@ -0,0 +1,58 @@
+package rpc
+
+import (
+	"net/rpc"
+
+	"github.com/roadrunner-server/errors"
+)
+
+// RoundTrip sends the request to the method with the codec (e.g. frame.CodecJSON), waits for the response with
+// the same sequence and decodes its body into resp, without the net/rpc Client. The error responses are returned
+// as *ErrorBody (see Codec.WriteErrorResponse), *Error (see AsError) or rpc.ServerError. The responses of other
+// sequences are skipped, so the codec should not be shared with the net/rpc Client. Calls are serialized.
+func (c *ClientCodec) RoundTrip(method string, codec byte, req any, resp any) error {
+	const op = errors.Op("goridge_client_round_trip")
+
+	c.roundTripMu.Lock()
+	defer c.roundTripMu.Unlock()
+
+	seq := c.roundTripSeq.Add(1)
+	err := c.WriteRequest(&rpc.Request{ServiceMethod: method, Seq: seq}, WithCodec(req, codec))
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	for {
+		r := &rpc.Response{}
+		err = c.ReadResponseHeader(r)
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		// a late response of the previous (failed) round trip
+		if r.Seq != seq {
+			_ = c.ReadResponseBody(nil)
+			continue
+		}
+
+		if r.Error != "" {
+			_ = c.ReadResponseBody(nil)
+			if e, ok := parseErrorBody(r.Error); ok {
+				e.json, e.msgpack = c.json, c.msgpack
+				return e
+			}
+			if e, ok := parseError(r.Error); ok {
+				return e
+			}
+
+			return rpc.ServerError(r.Error)
+		}
+
+		err = c.ReadResponseBody(resp)
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,46 @@
+package rpc
+
+import (
+	"net/rpc"
+	"testing"
+
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/roadrunner-server/goridge/v3/pkg/pipe"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientCodec_RoundTrip(t *testing.T) {
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("test", new(testService)))
+	require.NoError(t, server.RegisterName("errors", errorService{}))
+
+	srv, cl := pipe.NewRelayPair()
+	go server.ServeCodec(NewCodecWithRelay(srv))
+
+	client := NewClientCodecWithRelay(cl)
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	out := Payload{}
+	require.NoError(t, client.RoundTrip("test.Process", frame.CodecJSON, Payload{Name: "name", Value: 10}, &out))
+	assert.Equal(t, Payload{Name: "NAME", Value: -10}, out)
+
+	var echo string
+	require.NoError(t, client.RoundTrip("test.Echo", frame.CodecMsgpack, "hello", &echo))
+	assert.Equal(t, "hello", echo)
+
+	// the error frames
+	err := client.RoundTrip("test.EchoR", frame.CodecJSON, "hello", &echo)
+	assert.Equal(t, rpc.ServerError("echoR error"), err)
+
+	err = client.RoundTrip("errors.NotFound", frame.CodecJSON, Payload{Name: "item"}, &out)
+	e, ok := err.(*Error)
+	require.True(t, ok, "unexpected error: %v", err)
+	assert.Equal(t, &Error{Code: 404, Message: "item not found"}, e)
+
+	// the codec is still usable after the errors
+	require.NoError(t, client.RoundTrip("test.Echo", frame.CodecJSON, "again", &echo))
+	assert.Equal(t, "again", echo)
+}
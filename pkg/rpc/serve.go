@@ -0,0 +1,103 @@
+package rpc
+
+import (
+	stderr "errors"
+	"io"
+	"log"
+	"net/rpc"
+	"runtime/debug"
+)
+
+// DefaultPanicMessage is sent to the client when the request handler panics
+const DefaultPanicMessage = "internal server error"
+
+// ServeConfig configures ServeConn
+type ServeConfig struct {
+	// PanicMessage is sent to the client as an error instead of the panic value, default - DefaultPanicMessage
+	PanicMessage string
+	// Logger logs recovered panics with the stack trace, default - log.Default()
+	Logger *log.Logger
+}
+
+// serveCodec tracks the current request to respond to it if the handler panics
+type serveCodec struct {
+	*Codec
+	req     rpc.Request
+	readErr error
+}
+
+func (sc *serveCodec) ReadRequestHeader(r *rpc.Request) error {
+	sc.readErr = sc.Codec.ReadRequestHeader(r)
+	sc.req.Seq, sc.req.ServiceMethod = r.Seq, r.ServiceMethod
+	return sc.readErr
+}
+
+// ServeConn serves requests from the codec with the server (rpc.DefaultServer if nil) until the connection is closed.
+// Unlike rpc.ServeCodec, panics in the request decoding or in the handlers are recovered, logged and sent to the client
+// as an error response, so the connection stays alive for the next requests. Requests are served sequentially.
+// Keepalive pings are answered with pongs. Returns nil when the peer closes the connection.
+// The codec is closed on return.
+func ServeConn(server *rpc.Server, codec *Codec, cfg *ServeConfig) error {
+	if server == nil {
+		server = rpc.DefaultServer
+	}
+
+	if cfg == nil {
+		cfg = &ServeConfig{}
+	}
+
+	sc := &serveCodec{Codec: codec}
+	defer func() {
+		_ = codec.Close()
+	}()
+
+	for {
+		err := serveRequest(server, sc, cfg)
+		switch {
+		case stderr.Is(sc.readErr, ErrPing):
+			err = codec.Pong()
+			if err != nil {
+				return err
+			}
+			continue
+		case stderr.Is(sc.readErr, ErrPong):
+			continue
+		}
+
+		// errors like unknown method are sent to the client by the server, only the read errors break the connection
+		if err != nil && sc.readErr != nil {
+			if stderr.Is(err, io.EOF) {
+				return nil
+			}
+
+			return err
+		}
+	}
+}
+
+func serveRequest(server *rpc.Server, sc *serveCodec, cfg *ServeConfig) (err error) {
+	defer func() {
+		rec := recover()
+		if rec == nil {
+			return
+		}
+
+		logger := cfg.Logger
+		if logger == nil {
+			logger = log.Default()
+		}
+
+		logger.Printf("goridge: panic serving %s (seq %d): %v\n%s", sc.req.ServiceMethod, sc.req.Seq, rec, debug.Stack())
+
+		msg := cfg.PanicMessage
+		if msg == "" {
+			msg = DefaultPanicMessage
+		}
+
+		// WriteResponse returns the error (with the message) when it sends the error frame
+		_ = sc.WriteResponse(&rpc.Response{ServiceMethod: sc.req.ServiceMethod, Seq: sc.req.Seq, Error: msg}, nil)
+		err = nil
+	}()
+
+	return server.ServeRequest(sc)
+}
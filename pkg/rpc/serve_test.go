@@ -0,0 +1,89 @@
+package rpc
+
+import (
+	"bytes"
+	"log"
+	"net"
+	"net/rpc"
+	"testing"
+
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/roadrunner-server/goridge/v3/pkg/socket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type panicService struct{}
+
+func (s *panicService) Panic(_ string, _ *string) error {
+	panic("handler exploded")
+}
+
+func (s *panicService) Echo(msg string, r *string) error {
+	*r = msg
+	return nil
+}
+
+func TestServeConn_PanicRecovery(t *testing.T) {
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("panic", new(panicService)))
+
+	serverConn, clientConn := net.Pipe()
+	logs := &bytes.Buffer{}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- ServeConn(server, NewCodec(serverConn), &ServeConfig{
+			PanicMessage: "oops",
+			Logger:       log.New(logs, "", 0),
+		})
+	}()
+
+	client := rpc.NewClientWithCodec(NewClientCodec(clientConn))
+
+	var out string
+	err := client.Call("panic.Panic", "hi", &out)
+	require.Error(t, err)
+	assert.Equal(t, "oops", err.Error())
+
+	// connection is alive after the panic
+	require.NoError(t, client.Call("panic.Echo", "hello", &out))
+	assert.Equal(t, "hello", out)
+
+	// unknown methods don't break the connection too
+	assert.Error(t, client.Call("panic.Unknown", "hello", &out))
+	require.NoError(t, client.Call("panic.Echo", "again", &out))
+	assert.Equal(t, "again", out)
+
+	require.NoError(t, client.Close())
+	require.NoError(t, <-done)
+
+	assert.Contains(t, logs.String(), "handler exploded")
+	assert.Contains(t, logs.String(), "panic.Panic")
+	assert.Contains(t, logs.String(), "goroutine")
+}
+
+func TestServeConn_Ping(t *testing.T) {
+	server, client := net.Pipe()
+	rl := socket.NewSocketRelay(client)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- ServeConn(rpc.NewServer(), NewCodec(server), nil)
+	}()
+
+	for i := 0; i < 2; i++ {
+		ping := frame.NewFrame()
+		ping.WriteVersion(ping.Header(), frame.Version1)
+		ping.SetPingBit(ping.Header())
+		ping.WriteCRC(ping.Header())
+		require.NoError(t, rl.Send(ping))
+
+		pong := frame.NewFrame()
+		require.NoError(t, rl.Receive(pong))
+		assert.True(t, pong.IsPong(pong.Header()))
+	}
+
+	require.NoError(t, rl.Close())
+	require.NoError(t, <-done)
+}
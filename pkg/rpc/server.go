@@ -0,0 +1,155 @@
+package rpc
+
+import (
+	"context"
+	stderr "errors"
+	"net"
+	"net/rpc"
+	"sync"
+
+	"github.com/roadrunner-server/errors"
+)
+
+// ErrServerClosed is returned by Server.Serve after Server.Shutdown was called
+var ErrServerClosed = errors.Str("goridge: server closed") //nolint:gochecknoglobals
+
+// Server accepts the connections from the listeners and serves each of them with ServeConn over NewCodec.
+type Server struct {
+	server *rpc.Server
+	cfg    *ServeConfig
+
+	mu        sync.Mutex
+	closed    bool
+	listeners map[net.Listener]struct{}
+	codecs    map[*Codec]struct{}
+	wg        sync.WaitGroup
+}
+
+// NewServer creates the server with its own rpc.Server, the services are registered with Register and RegisterName.
+// The cfg is passed to ServeConn and might be nil.
+func NewServer(cfg *ServeConfig) *Server {
+	return &Server{
+		server:    rpc.NewServer(),
+		cfg:       cfg,
+		listeners: make(map[net.Listener]struct{}),
+		codecs:    make(map[*Codec]struct{}),
+	}
+}
+
+// Register publishes the methods of the receiver, see rpc.Server.Register.
+func (s *Server) Register(rcvr any) error {
+	return s.server.Register(rcvr)
+}
+
+// RegisterName publishes the methods of the receiver under the name, see rpc.Server.RegisterName.
+func (s *Server) RegisterName(name string, rcvr any) error {
+	return s.server.RegisterName(name, rcvr)
+}
+
+// Serve accepts the connections from the listener until it is closed. Every connection is served
+// in its own goroutine. Always returns a non-nil error, ErrServerClosed after Shutdown.
+func (s *Server) Serve(ln net.Listener) error {
+	const op = errors.Op("goridge_server_serve")
+
+	if !s.trackListener(ln) {
+		_ = ln.Close()
+		return ErrServerClosed
+	}
+	defer s.untrackListener(ln)
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if s.isClosed() {
+				return ErrServerClosed
+			}
+
+			var ne net.Error
+			if stderr.As(err, &ne) && ne.Timeout() {
+				continue
+			}
+
+			return errors.E(op, err)
+		}
+
+		codec := NewCodec(conn)
+		if !s.trackCodec(codec) {
+			_ = codec.Close()
+			return ErrServerClosed
+		}
+
+		go func() {
+			defer s.untrackCodec(codec)
+			_ = ServeConn(s.server, codec, s.cfg)
+		}()
+	}
+}
+
+// Shutdown stops accepting the connections, closes the active codecs with the CloseShuttingDown reason and waits
+// until their goroutines return. Returns the context error if it is done before that.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	s.closed = true
+	for ln := range s.listeners {
+		_ = ln.Close()
+	}
+	for codec := range s.codecs {
+		_ = codec.CloseWithReason(CloseShuttingDown, "server is shutting down")
+	}
+	s.mu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *Server) isClosed() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.closed
+}
+
+func (s *Server) trackListener(ln net.Listener) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+
+	s.listeners[ln] = struct{}{}
+	return true
+}
+
+func (s *Server) untrackListener(ln net.Listener) {
+	s.mu.Lock()
+	delete(s.listeners, ln)
+	s.mu.Unlock()
+}
+
+func (s *Server) trackCodec(codec *Codec) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return false
+	}
+
+	s.codecs[codec] = struct{}{}
+	s.wg.Add(1)
+	return true
+}
+
+func (s *Server) untrackCodec(codec *Codec) {
+	s.mu.Lock()
+	delete(s.codecs, codec)
+	s.mu.Unlock()
+	s.wg.Done()
+}
@@ -0,0 +1,52 @@
+package rpc
+
+import (
+	"context"
+	"net"
+	"net/rpc"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_UnixSocket(t *testing.T) {
+	ln, err := net.Listen("unix", filepath.Join(t.TempDir(), "goridge.sock"))
+	require.NoError(t, err)
+
+	srv := NewServer(nil)
+	require.NoError(t, srv.RegisterName("pair", new(panicService)))
+
+	served := make(chan error, 1)
+	go func() {
+		served <- srv.Serve(ln)
+	}()
+
+	conn, err := net.Dial("unix", ln.Addr().String())
+	require.NoError(t, err)
+	cc := NewClientCodec(conn)
+	client := rpc.NewClientWithCodec(cc)
+
+	var out string
+	require.NoError(t, client.Call("pair.Echo", "unix", &out))
+	assert.Equal(t, "unix", out)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	defer cancel()
+	require.NoError(t, srv.Shutdown(ctx))
+	assert.ErrorIs(t, <-served, ErrServerClosed)
+
+	// the active connection was closed with the reason, it's read by the client goroutine
+	require.Eventually(t, func() bool {
+		return cc.CloseReason() != nil
+	}, time.Second*5, time.Millisecond*10)
+	assert.Equal(t, CloseShuttingDown, cc.CloseReason().Code)
+	assert.ErrorIs(t, client.Call("pair.Echo", "unix", &out), rpc.ErrShutdown)
+
+	// no new connections
+	_, err = net.Dial("unix", ln.Addr().String())
+	assert.Error(t, err)
+	assert.ErrorIs(t, srv.Serve(ln), ErrServerClosed)
+}
@@ -0,0 +1,42 @@
+package rpc
+
+import (
+	"sync/atomic"
+)
+
+// Stats contains the codec counters. Bytes include the frame headers.
+type Stats struct {
+	// FramesIn - received request frames
+	FramesIn uint64
+	// FramesOut - sent response frames
+	FramesOut uint64
+	// BytesIn - received bytes
+	BytesIn uint64
+	// BytesOut - sent bytes
+	BytesOut uint64
+	// Errors - failed receives, request decodings and sends
+	Errors uint64
+	// Expired - sequences evicted without the response, see Codec.SetSequenceTTL
+	Expired uint64
+}
+
+type stats struct {
+	framesIn  atomic.Uint64
+	framesOut atomic.Uint64
+	bytesIn   atomic.Uint64
+	bytesOut  atomic.Uint64
+	errors    atomic.Uint64
+	expired   atomic.Uint64
+}
+
+// Stats returns the snapshot of the codec counters. Safe for concurrent use.
+func (c *Codec) Stats() Stats {
+	return Stats{
+		FramesIn:  c.stats.framesIn.Load(),
+		FramesOut: c.stats.framesOut.Load(),
+		BytesIn:   c.stats.bytesIn.Load(),
+		BytesOut:  c.stats.bytesOut.Load(),
+		Errors:    c.stats.errors.Load(),
+		Expired:   c.stats.expired.Load(),
+	}
+}
@@ -0,0 +1,201 @@
+package rpc
+
+import (
+	stderr "errors"
+	"io"
+	"math"
+	"net/rpc"
+
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/roadrunner-server/goridge/v3/pkg/relay"
+)
+
+// DefaultStreamChunkSize is the default maximum body size of a single stream frame
+const DefaultStreamChunkSize = 1024 * 1024
+
+// SetStreamChunkSize sets the maximum body size of a single frame sent by WriteStream.
+// Should be called before the codec is used.
+func (c *Codec) SetStreamChunkSize(size int) error {
+	const op = errors.Op("goridge_set_stream_chunk_size")
+	if size <= 0 {
+		return errors.E(op, errors.Errorf("chunk size should be positive, got: %d", size))
+	}
+
+	c.streamChunkSize = size
+	return nil
+}
+
+// WriteStream sends the body read from the reader as a sequence of Raw frames sharing the response sequence ID.
+// Every frame except the last one has the frame.STREAM bit set, the last one terminates the stream.
+// If the reader fails, the stream is terminated with an error frame.
+// If the body has the Len() int method (like bytes.Reader), the total size is declared in the first frame.
+// Use ReadStream to reassemble the body on the receiving side.
+func (c *Codec) WriteStream(r *rpc.Response, body io.Reader) error {
+	type lener interface {
+		Len() int
+	}
+
+	if l, ok := body.(lener); ok {
+		return c.WriteSizedStream(r, body, int64(l.Len()))
+	}
+
+	return c.WriteSizedStream(r, body, -1)
+}
+
+// WriteSizedStream sends the stream like WriteStream and declares the total body size with the OptionTotalSize
+// option in the first frame, so the receiver can pre-allocate the buffer and report the progress.
+// Negative size (or bigger than 4GB) is not declared, neither is the size which doesn't fit into the header next to
+// the options set with SetResponseOptions.
+func (c *Codec) WriteSizedStream(r *rpc.Response, body io.Reader, total int64) error {
+	const op = errors.Op("goridge_write_stream")
+	r = c.noPrefixResponse(r)
+
+	// stream is always sent as Raw, the stored codec is not needed
+	if _, err := c.loadCodec(r); err != nil {
+		return err
+	}
+
+	if r.Error != "" {
+		fr, err := c.responseFrame(r)
+		if err != nil {
+			return errors.E(op, err)
+		}
+		defer c.putFrame(fr)
+		return c.handleError(r, fr, r.Error)
+	}
+
+	size := c.streamChunkSize
+	if size == 0 {
+		size = DefaultStreamChunkSize
+	}
+
+	chunk := make([]byte, size)
+	buf := c.get()
+	defer c.put(buf)
+
+	first := true
+	for {
+		n, err := io.ReadFull(body, chunk)
+		last := stderr.Is(err, io.EOF) || stderr.Is(err, io.ErrUnexpectedEOF)
+		if err != nil && !last {
+			fr, errF := c.responseFrame(r)
+			if errF == nil {
+				_ = c.handleError(r, fr, err.Error())
+				c.putFrame(fr)
+			}
+			return errors.E(op, err)
+		}
+
+		var fr *frame.Frame
+		var errF error
+		if first && total >= 0 && total <= math.MaxUint32 {
+			fr, errF = c.responseFrame(r, OptionTotalSize, uint32(total))
+		}
+		// the total size is optional, it's not declared when the options don't fit into the header
+		if fr == nil {
+			fr, errF = c.responseFrame(r)
+		}
+		if errF != nil {
+			return errors.E(op, errF)
+		}
+		first = false
+
+		fr.WriteFlags(fr.Header(), frame.CodecRaw)
+		if !last {
+			fr.SetStreamFlag(fr.Header())
+		}
+
+		buf.Reset()
+		buf.WriteString(r.ServiceMethod)
+		buf.Write(chunk[:n])
+
+		err = c.send(r, fr, buf)
+		c.putFrame(fr)
+		if err != nil {
+			return errors.E(op, err)
+		}
+
+		if last {
+			return nil
+		}
+	}
+}
+
+// ReadStream receives the frames sent with Codec.WriteStream from the relay and writes the reassembled body to w.
+// The returned response contains the sequence ID, service method and the error if the stream was terminated with it.
+// The compressed frames decompressed to more than DefaultReadLimit bytes are rejected with frame.FrameTooLargeError.
+func ReadStream(rl relay.Relay, w io.Writer) (*rpc.Response, error) {
+	return ReadStreamWithProgress(rl, w, nil)
+}
+
+// ReadStreamWithProgress receives the stream like ReadStream and calls the progress function (if not nil) after
+// every frame with the received body bytes and the declared total size (-1 if not declared).
+// If the total size is declared and w has the Grow(int) method (like bytes.Buffer), it is pre-allocated.
+func ReadStreamWithProgress(rl relay.Relay, w io.Writer, progress func(received, total int64)) (*rpc.Response, error) {
+	const op = errors.Op("goridge_read_stream")
+	var resp *rpc.Response
+	var received, total int64 = 0, -1
+
+	for {
+		fr := frame.NewFrame()
+		err := rl.Receive(fr)
+		if err != nil {
+			return resp, err
+		}
+
+		opts := fr.ReadOptions(fr.Header())
+		if len(opts) < 2 {
+			return resp, errors.E(op, errors.Str("should be at least 2 options. SEQ_ID and METHOD_LEN"))
+		}
+		if int(opts[1]) > len(fr.Payload()) {
+			return resp, errors.E(op, errors.Str("method name offset is out of the payload bounds"))
+		}
+
+		if resp == nil {
+			resp = &rpc.Response{Seq: uint64(opts[0]), ServiceMethod: string(fr.Payload()[:opts[1]])}
+
+			if size, ok := lookupOption(opts, OptionTotalSize); ok {
+				total = int64(size)
+
+				type grower interface {
+					Grow(n int)
+				}
+
+				// the declared size is not trusted beyond the read limit
+				if g, ok := w.(grower); ok {
+					g.Grow(int(min(size, DefaultReadLimit)))
+				}
+			}
+		} else if resp.Seq != uint64(opts[0]) {
+			return resp, errors.E(op, errors.Errorf("stream frame for the sequence %d, expected %d", opts[0], resp.Seq))
+		}
+
+		payload := fr.Payload()[opts[1]:]
+		if fr.Flags().IsError() {
+			resp.Error = string(payload)
+			return resp, nil
+		}
+
+		if algorithm := fr.ReadCompression(fr.Header()); algorithm != 0 && len(payload) > 0 {
+			payload, err = decompress(algorithm, payload, DefaultReadLimit)
+			if err != nil {
+				return resp, errors.E(op, err)
+			}
+		}
+
+		_, err = w.Write(payload)
+		if err != nil {
+			return resp, errors.E(op, err)
+		}
+
+		received += int64(len(payload))
+		if progress != nil {
+			progress(received, total)
+		}
+
+		if !fr.IsStream(fr.Header()) {
+			return resp, nil
+		}
+	}
+}
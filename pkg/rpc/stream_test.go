@@ -0,0 +1,179 @@
+package rpc
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"math"
+	"net/rpc"
+	"testing"
+
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/roadrunner-server/goridge/v3/pkg/pipe"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type failingReader struct {
+	data []byte
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, errors.Str("disk failure")
+	}
+
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestCodec_WriteStream(t *testing.T) {
+	body := make([]byte, 5*1024*1024+123)
+	_, err := rand.Read(body)
+	require.NoError(t, err)
+
+	c, rl := pipeCodec(t)
+	require.NoError(t, c.SetStreamChunkSize(64*1024))
+	require.Error(t, c.SetStreamChunkSize(0))
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.WriteStream(&rpc.Response{ServiceMethod: "test.Stream", Seq: 11}, bytes.NewReader(body))
+	}()
+
+	out := &bytes.Buffer{}
+	resp, err := ReadStream(rl, out)
+	require.NoError(t, err)
+	require.NoError(t, <-errCh)
+
+	assert.Equal(t, uint64(11), resp.Seq)
+	assert.Equal(t, "test.Stream", resp.ServiceMethod)
+	assert.Empty(t, resp.Error)
+	assert.Equal(t, body, out.Bytes())
+}
+
+func TestCodec_WriteStreamReaderError(t *testing.T) {
+	c, rl := pipeCodec(t)
+	require.NoError(t, c.SetStreamChunkSize(4))
+
+	errCh := make(chan error, 1)
+	go func() {
+		body := &failingReader{data: []byte("0123456789")}
+		errCh <- c.WriteStream(&rpc.Response{ServiceMethod: "test.Stream", Seq: 12}, body)
+	}()
+
+	out := &bytes.Buffer{}
+	resp, err := ReadStream(rl, out)
+	require.NoError(t, err)
+	assert.Error(t, <-errCh)
+
+	assert.Equal(t, "disk failure", resp.Error)
+	assert.Equal(t, []byte("01234567"), out.Bytes())
+}
+
+func TestReadStreamSequenceMismatch(t *testing.T) {
+	c, rl := pipeCodec(t)
+
+	go func() {
+		first := requestFrame(1, "test.Stream", frame.CodecRaw, []byte("a"))
+		first.SetStreamFlag(first.Header())
+		_ = c.relay.Send(first)
+		_ = c.relay.Send(requestFrame(2, "test.Stream", frame.CodecRaw, []byte("b")))
+	}()
+
+	_, err := ReadStream(rl, &bytes.Buffer{})
+	assert.Error(t, err)
+}
+
+func TestCodec_WriteStreamTotalSize(t *testing.T) {
+	body := bytes.Repeat([]byte("goridge"), 100000)
+
+	c, rl := pipeCodec(t)
+	require.NoError(t, c.SetStreamChunkSize(64*1024))
+
+	errCh := make(chan error, 1)
+	go func() {
+		// bytes.Reader declares the size automatically
+		errCh <- c.WriteStream(&rpc.Response{ServiceMethod: "test.Stream", Seq: 14}, bytes.NewReader(body))
+	}()
+
+	out := &bytes.Buffer{}
+	var calls int
+	var received, total int64
+	_, err := ReadStreamWithProgress(rl, out, func(rcv, tot int64) {
+		if calls == 0 {
+			// pre-allocated on the first frame
+			assert.GreaterOrEqual(t, int64(out.Cap()), tot)
+		}
+		calls++
+		received, total = rcv, tot
+	})
+	require.NoError(t, err)
+	require.NoError(t, <-errCh)
+
+	assert.Greater(t, calls, 1)
+	assert.Equal(t, int64(len(body)), total)
+	assert.Equal(t, total, received)
+	assert.Equal(t, body, out.Bytes())
+
+	// the size is not declared for the plain readers
+	go func() {
+		errCh <- c.WriteStream(&rpc.Response{ServiceMethod: "test.Stream", Seq: 15}, io.MultiReader(bytes.NewReader(body)))
+	}()
+
+	_, err = ReadStreamWithProgress(rl, io.Discard, func(_, tot int64) {
+		total = tot
+	})
+	require.NoError(t, err)
+	require.NoError(t, <-errCh)
+	assert.Equal(t, int64(-1), total)
+}
+
+// growRecorder records the pre-allocated size
+type growRecorder struct {
+	bytes.Buffer
+	grown int
+}
+
+func (g *growRecorder) Grow(n int) {
+	g.grown = n
+}
+
+func TestReadStreamTotalSizeLimit(t *testing.T) {
+	srv, cl := pipe.NewRelayPair()
+	t.Cleanup(func() {
+		_ = srv.Close()
+		_ = cl.Close()
+	})
+
+	// the peer declares 4GB of the body and sends a few bytes
+	go func() {
+		assert.NoError(t, srv.Send(requestFrame(16, "test.Stream", frame.CodecRaw, []byte("body"), OptionTotalSize, math.MaxUint32)))
+	}()
+
+	out := &growRecorder{}
+	_, err := ReadStream(cl, out)
+	require.NoError(t, err)
+	assert.Equal(t, DefaultReadLimit, out.grown)
+	assert.Equal(t, []byte("body"), out.Bytes())
+}
+
+// the total size is not declared when the response options take the whole header
+func TestCodec_WriteSizedStreamFullOptions(t *testing.T) {
+	c, rl := pipeCodec(t)
+	require.NoError(t, c.SetResponseOptions(17, 100, 1, 101, 2, 102, 3, 103, 4))
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.WriteSizedStream(&rpc.Response{ServiceMethod: "test.Stream", Seq: 17}, bytes.NewReader([]byte("body")), 4)
+	}()
+
+	fr := frame.NewFrame()
+	require.NoError(t, rl.Receive(fr))
+	require.NoError(t, <-errCh)
+	opts := fr.ReadOptions(fr.Header())
+	assert.Equal(t, []uint32{17, 11, 100, 1, 101, 2, 102, 3, 103, 4}, opts)
+	assert.Equal(t, []byte("test.Streambody"), fr.Payload())
+}
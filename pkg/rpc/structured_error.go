@@ -0,0 +1,100 @@
+package rpc
+
+import (
+	"bytes"
+	stderr "errors"
+	"net/rpc"
+	"strconv"
+	"strings"
+
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+)
+
+// errorPrefix starts the string form of the Error
+const errorPrefix = "goridge error "
+
+// Error is the error with the code. When the service method returns it, the error frame carries the code and
+// the message encoded with the codec of the request (the codecs which can't encode it, like proto or raw,
+// send the string form). Use AsError to get it back from the client call error.
+type Error struct {
+	Code    uint32 `json:"code" msgpack:"code"`
+	Message string `json:"message" msgpack:"message"`
+}
+
+// Error returns the string form of the error: "goridge error <code>: <message>".
+func (e *Error) Error() string {
+	return errorPrefix + strconv.FormatUint(uint64(e.Code), 10) + ": " + e.Message
+}
+
+// AsError returns the Error from the err: the Error itself or the rpc.ServerError returned by the rpc.Client call.
+func AsError(err error) (*Error, bool) {
+	var e *Error
+	if stderr.As(err, &e) {
+		return e, true
+	}
+
+	var se rpc.ServerError
+	if stderr.As(err, &se) {
+		return parseError(string(se))
+	}
+
+	return nil, false
+}
+
+// parseError parses the string form of the Error, net/rpc passes the errors of the service methods as strings
+func parseError(s string) (*Error, bool) {
+	rest, ok := strings.CutPrefix(s, errorPrefix)
+	if !ok {
+		return nil, false
+	}
+
+	code, message, ok := strings.Cut(rest, ": ")
+	if !ok {
+		return nil, false
+	}
+
+	c, err := strconv.ParseUint(code, 10, 32)
+	if err != nil {
+		return nil, false
+	}
+
+	return &Error{Code: uint32(c), Message: message}, true
+}
+
+// writeStructuredError encodes the Error parsed from the error string with the codec of the frame,
+// false if it is not an Error or the codec can't encode it
+func (c *Codec) writeStructuredError(fr *frame.Frame, buf *bytes.Buffer, err string) bool {
+	e, ok := parseError(err)
+	if !ok {
+		return false
+	}
+
+	entry, ok := lookupCodecFor(byte(fr.Flags().Codec()), c.json, c.msgpack)
+	if !ok {
+		return false
+	}
+
+	prefix := buf.Len()
+	if entry.enc.Encode(e, buf) != nil {
+		buf.Truncate(prefix)
+		return false
+	}
+
+	fr.SetStructuredErrorBit(fr.Header())
+	return true
+}
+
+// readStructuredError decodes the error payload of the frame with the StructuredError bit into the string form
+func (c *ClientCodec) readStructuredError(fr *frame.Frame, payload []byte) (string, bool) {
+	entry, ok := lookupCodecFor(byte(fr.Flags().Codec()), c.json, c.msgpack)
+	if !ok {
+		return "", false
+	}
+
+	e := &Error{}
+	if entry.dec.Decode(payload, e) != nil {
+		return "", false
+	}
+
+	return e.Error(), true
+}
@@ -0,0 +1,105 @@
+package rpc
+
+import (
+	"net/rpc"
+	"testing"
+
+	"github.com/goccy/go-json"
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/roadrunner-server/goridge/v3/pkg/pipe"
+	"github.com/roadrunner-server/goridge/v3/tests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type errorService struct{}
+
+func (errorService) NotFound(in Payload, out *Payload) error {
+	return &Error{Code: 404, Message: in.Name + " not found"}
+}
+
+func (errorService) Plain(_ Payload, _ *Payload) error {
+	return errors.Str("plain error")
+}
+
+func (errorService) Proto(_ *tests.Payload, _ *tests.Item) error {
+	return &Error{Code: 500, Message: "proto"}
+}
+
+func TestStructuredError_ClientServer(t *testing.T) {
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("errors", errorService{}))
+
+	srv, cl := pipe.NewRelayPair()
+	go func() {
+		_ = ServeConn(server, NewCodecWithRelay(srv), nil)
+	}()
+
+	client := rpc.NewClientWithCodec(NewClientCodecWithRelay(cl))
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	for _, body := range []any{Payload{Name: "gob"}, WithCodec(Payload{Name: "json"}, frame.CodecJSON),
+		WithCodec(Payload{Name: "msgpack"}, frame.CodecMsgpack)} {
+		err := client.Call("errors.NotFound", body, &Payload{})
+		e, ok := AsError(err)
+		require.True(t, ok, err)
+		assert.Equal(t, uint32(404), e.Code)
+		assert.Contains(t, []string{"gob not found", "json not found", "msgpack not found"}, e.Message)
+	}
+
+	// proto can't encode the error, the string form is sent
+	err := client.Call("errors.Proto", &tests.Payload{}, &tests.Item{})
+	e, ok := AsError(err)
+	require.True(t, ok, err)
+	assert.Equal(t, &Error{Code: 500, Message: "proto"}, e)
+
+	// plain errors are sent as is
+	err = client.Call("errors.Plain", Payload{}, &Payload{})
+	assert.Equal(t, rpc.ServerError("plain error"), err)
+	_, ok = AsError(err)
+	assert.False(t, ok)
+}
+
+func TestStructuredError_Wire(t *testing.T) {
+	c, rl := pipeCodec(t)
+
+	go func() {
+		assert.NoError(t, rl.Send(requestFrame(1, "errors.NotFound", frame.CodecJSON, []byte(`{"name":"a"}`))))
+	}()
+	r := &rpc.Request{}
+	require.NoError(t, c.ReadRequestHeader(r))
+	require.NoError(t, c.ReadRequestBody(&Payload{}))
+
+	go func() {
+		resp := &rpc.Response{ServiceMethod: r.ServiceMethod, Seq: r.Seq, Error: (&Error{Code: 7, Message: "x"}).Error()}
+		_ = c.WriteResponse(resp, nil)
+	}()
+
+	fr := frame.NewFrame()
+	require.NoError(t, rl.Receive(fr))
+	assert.Equal(t, frame.ERROR|frame.CodecJSON, fr.ReadFlags())
+	assert.True(t, fr.IsStructuredError(fr.Header()))
+
+	opts := fr.ReadOptions(fr.Header())
+	var e Error
+	require.NoError(t, json.Unmarshal(fr.Payload()[opts[1]:], &e))
+	assert.Equal(t, Error{Code: 7, Message: "x"}, e)
+}
+
+func TestAsError(t *testing.T) {
+	e, ok := AsError(&Error{Code: 1, Message: "a: b"})
+	require.True(t, ok)
+	assert.Equal(t, "a: b", e.Message)
+
+	e, ok = AsError(rpc.ServerError("goridge error 42: a: b"))
+	require.True(t, ok)
+	assert.Equal(t, &Error{Code: 42, Message: "a: b"}, e)
+
+	for _, s := range []string{"", "goridge error", "goridge error x: y", "goridge error 1 y"} {
+		_, ok = AsError(rpc.ServerError(s))
+		assert.False(t, ok, s)
+	}
+}
@@ -0,0 +1,80 @@
+package rpc
+
+import (
+	"time"
+
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+)
+
+// timestampOptions is the number of the option words of the timestamp
+const timestampOptions = 4
+
+// TimestampOptions returns the OptionTimestampLow and OptionTimestampHigh pairs of the time,
+// to be appended to the request or response options.
+func TimestampOptions(t time.Time) []uint32 {
+	ns := uint64(t.UnixNano()) //nolint:gosec
+
+	return []uint32{OptionTimestampLow, uint32(ns), OptionTimestampHigh, uint32(ns >> 32)} //nolint:gosec
+}
+
+// ReadTimestamp reads the timestamp from the options following SEQ_ID and METHOD_LEN
+// (see Codec.RequestOptions and ClientCodec.ResponseOptions).
+func ReadTimestamp(opts []uint32) (time.Time, bool) {
+	var low, high uint32
+	var found int
+	for i := 0; i+1 < len(opts); i += 2 {
+		switch opts[i] {
+		case OptionTimestampLow:
+			low = opts[i+1]
+			found++
+		case OptionTimestampHigh:
+			high = opts[i+1]
+			found++
+		}
+	}
+
+	if found != 2 {
+		return time.Time{}, false
+	}
+
+	return time.Unix(0, int64(uint64(high)<<32|uint64(low))), true //nolint:gosec
+}
+
+// RequestTimestamp returns the send time of the request carried in the timestamp options, the one-way delay
+// is time.Since of it (given the synchronized clocks). Available until the response for the sequence is sent.
+func (c *Codec) RequestTimestamp(seq uint64) (time.Time, bool) {
+	return ReadTimestamp(c.RequestOptions(seq))
+}
+
+// SetEchoTimestamps enables echoing of the request timestamp options in the responses, so the requester can
+// calculate the round-trip time. The timestamp is not echoed when the response options don't leave room for it.
+// Should be called before the codec is used.
+func (c *Codec) SetEchoTimestamps(enabled bool) {
+	c.echoTimestamps = enabled
+}
+
+// echoTimestamp returns the timestamp options of the request to append to the response options
+func (c *Codec) echoTimestamp(seq uint64, used int) []uint32 {
+	if used+timestampOptions > frame.OptionsMaxSize/frame.WORD {
+		return nil
+	}
+
+	ts, ok := c.RequestTimestamp(seq)
+	if !ok {
+		return nil
+	}
+
+	return TimestampOptions(ts)
+}
+
+// SetSendTimestamps enables the timestamp options with the send time in every request.
+// Should be called before the codec is used.
+func (c *ClientCodec) SetSendTimestamps(enabled bool) {
+	c.sendTimestamps = enabled
+}
+
+// ResponseTimestamp returns the timestamp echoed by the server (see Codec.SetEchoTimestamps), the round-trip time
+// is time.Since of it. Should be called between ReadResponseHeader and ReadResponseBody.
+func (c *ClientCodec) ResponseTimestamp() (time.Time, bool) {
+	return ReadTimestamp(c.ResponseOptions())
+}
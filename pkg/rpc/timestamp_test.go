@@ -0,0 +1,71 @@
+package rpc
+
+import (
+	"net/rpc"
+	"testing"
+	"time"
+
+	"github.com/roadrunner-server/goridge/v3/pkg/pipe"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimestampOptions(t *testing.T) {
+	now := time.Now()
+	ts, ok := ReadTimestamp(TimestampOptions(now))
+	require.True(t, ok)
+	assert.True(t, now.Equal(ts), "%s != %s", now, ts)
+
+	_, ok = ReadTimestamp([]uint32{OptionTimestampLow, 1})
+	assert.False(t, ok)
+	_, ok = ReadTimestamp(nil)
+	assert.False(t, ok)
+}
+
+func TestCodec_RequestTimestamp(t *testing.T) {
+	srv, cl := pipe.NewRelayPair()
+	server := NewCodecWithRelay(srv)
+	server.SetEchoTimestamps(true)
+	client := NewClientCodecWithRelay(cl)
+	client.SetSendTimestamps(true)
+	t.Cleanup(func() {
+		_ = server.Close()
+		_ = client.Close()
+	})
+
+	before := time.Now()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- client.WriteRequest(&rpc.Request{ServiceMethod: "test.Timestamp", Seq: 1}, "ping")
+	}()
+
+	r := &rpc.Request{}
+	require.NoError(t, server.ReadRequestHeader(r))
+	sent, ok := server.RequestTimestamp(r.Seq)
+	require.True(t, ok)
+	assert.False(t, sent.Before(before.Round(0)))
+	assert.Less(t, time.Since(sent), time.Second)
+
+	var in string
+	require.NoError(t, server.ReadRequestBody(&in))
+	require.NoError(t, <-errCh)
+
+	go func() {
+		errCh <- server.WriteResponse(&rpc.Response{ServiceMethod: r.ServiceMethod, Seq: r.Seq}, "pong")
+	}()
+
+	resp := &rpc.Response{}
+	require.NoError(t, client.ReadResponseHeader(resp))
+	echoed, ok := client.ResponseTimestamp()
+	require.True(t, ok)
+	assert.True(t, sent.Equal(echoed))
+
+	var out string
+	require.NoError(t, client.ReadResponseBody(&out))
+	require.NoError(t, <-errCh)
+	assert.Equal(t, "pong", out)
+
+	// options are released after the response
+	_, ok = server.RequestTimestamp(r.Seq)
+	assert.False(t, ok)
+}
@@ -0,0 +1,85 @@
+package rpc
+
+import (
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+)
+
+// tracedBody is the request body with the trace ID, see WithTraceID
+type tracedBody struct {
+	id   uint32
+	body any
+}
+
+// WithTraceID wraps the request body to send the trace ID in the OptionTraceID option, e.g.
+// client.Call("Service.Method", rpc.WithTraceID(req, id), &resp). Might be combined with WithCodec.
+func WithTraceID(body any, id uint32) any {
+	return tracedBody{id: id, body: body}
+}
+
+// requestTraceID returns the trace ID of the WithTraceID body, which might be wrapped with WithCodec
+func requestTraceID(body any) (uint32, bool) {
+	if o, ok := body.(codecOverride); ok {
+		body = o.body
+	}
+
+	id, _, ok := unwrapTraceID(body)
+	return id, ok
+}
+
+// unwrapTraceID returns the trace ID and the wrapped body of the WithTraceID body, the body is returned as is otherwise
+func unwrapTraceID(body any) (uint32, any, bool) {
+	if t, ok := body.(tracedBody); ok {
+		return t.id, t.body, true
+	}
+
+	return 0, body, false
+}
+
+// ReadTraceID reads the trace ID from the options following SEQ_ID and METHOD_LEN
+// (see Codec.RequestOptions and ClientCodec.ResponseOptions).
+func ReadTraceID(opts []uint32) (uint32, bool) {
+	for i := 0; i+1 < len(opts); i += 2 {
+		if opts[i] == OptionTraceID {
+			return opts[i+1], true
+		}
+	}
+
+	return 0, false
+}
+
+// TraceID returns the trace ID of the request, available until the response for the sequence is sent.
+// To stamp the response with the trace ID use SetResponseOptions(seq, OptionTraceID, id) or SetPropagateTraceID.
+func (c *Codec) TraceID(seq uint64) (uint32, bool) {
+	return ReadTraceID(c.RequestOptions(seq))
+}
+
+// SetPropagateTraceID enables copying of the request trace ID to the response. The trace ID is not copied when
+// the response options don't leave room for it or already carry it.
+// Should be called before the codec is used.
+func (c *Codec) SetPropagateTraceID(enabled bool) {
+	c.propagateTrace = enabled
+}
+
+// propagatedTraceID returns the trace ID option of the request to append to the response options
+func (c *Codec) propagatedTraceID(seq uint64, opts []uint32) []uint32 {
+	if len(opts)+2 > frame.OptionsMaxSize/frame.WORD {
+		return nil
+	}
+
+	if _, ok := ReadTraceID(opts[2:]); ok {
+		return nil
+	}
+
+	id, ok := c.TraceID(seq)
+	if !ok {
+		return nil
+	}
+
+	return []uint32{OptionTraceID, id}
+}
+
+// ResponseTraceID returns the trace ID of the response.
+// Should be called between ReadResponseHeader and ReadResponseBody.
+func (c *ClientCodec) ResponseTraceID() (uint32, bool) {
+	return ReadTraceID(c.ResponseOptions())
+}
@@ -0,0 +1,84 @@
+package rpc
+
+import (
+	"net/rpc"
+	"testing"
+
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/roadrunner-server/goridge/v3/pkg/pipe"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodec_TraceID(t *testing.T) {
+	srv, cl := pipe.NewRelayPair()
+	server := NewCodecWithRelay(srv)
+	client := NewClientCodecWithRelay(cl)
+	t.Cleanup(func() {
+		_ = server.Close()
+		_ = client.Close()
+	})
+
+	roundTrip := func(seq uint64, body any, stamp func(seq uint64)) []uint32 {
+		errCh := make(chan error, 1)
+		go func() {
+			errCh <- client.WriteRequest(&rpc.Request{ServiceMethod: "test.Trace", Seq: seq}, body)
+		}()
+
+		r := &rpc.Request{}
+		require.NoError(t, server.ReadRequestHeader(r))
+		var in string
+		require.NoError(t, server.ReadRequestBody(&in))
+		require.NoError(t, <-errCh)
+		assert.Equal(t, "ping", in)
+		if stamp != nil {
+			stamp(r.Seq)
+		}
+
+		go func() {
+			errCh <- server.WriteResponse(&rpc.Response{ServiceMethod: r.ServiceMethod, Seq: r.Seq}, "pong")
+		}()
+
+		resp := &rpc.Response{}
+		require.NoError(t, client.ReadResponseHeader(resp))
+		opts := append([]uint32(nil), client.ResponseOptions()...)
+		id, ok := client.ResponseTraceID()
+		got, found := ReadTraceID(opts)
+		assert.Equal(t, ok, found)
+		assert.Equal(t, id, got)
+
+		var out string
+		require.NoError(t, client.ReadResponseBody(&out))
+		require.NoError(t, <-errCh)
+		assert.Equal(t, "pong", out)
+		return opts
+	}
+
+	// the plain 2-option frames
+	_, ok := ReadTraceID(roundTrip(1, "ping", func(seq uint64) {
+		_, found := server.TraceID(seq)
+		assert.False(t, found)
+	}))
+	assert.False(t, ok)
+
+	// the trace ID is not propagated by default, but might be stamped explicitly
+	id, ok := ReadTraceID(roundTrip(2, WithTraceID(WithCodec("ping", frame.CodecJSON), 0xCAFE), func(seq uint64) {
+		got, found := server.TraceID(seq)
+		require.True(t, found)
+		assert.Equal(t, uint32(0xCAFE), got)
+		require.NoError(t, server.SetResponseOptions(seq, OptionTraceID, got+1))
+	}))
+	require.True(t, ok)
+	assert.Equal(t, uint32(0xCAFF), id)
+
+	server.SetPropagateTraceID(true)
+	id, ok = ReadTraceID(roundTrip(3, WithCodec(WithTraceID("ping", 42), frame.CodecMsgpack), nil))
+	require.True(t, ok)
+	assert.Equal(t, uint32(42), id)
+
+	// the trace ID set with the response options is not duplicated
+	opts := roundTrip(4, WithTraceID("ping", 42), func(seq uint64) {
+		require.NoError(t, server.SetResponseOptions(seq, OptionTraceID, 7))
+	})
+	assert.Equal(t, []uint32{OptionTraceID, 7}, opts)
+}
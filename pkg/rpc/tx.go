@@ -0,0 +1,183 @@
+package rpc
+
+import (
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+)
+
+// Transactions deliver a set of responses all together or not at all:
+//  1. every response frame of the transaction carries the OptionTxID option with the transaction ID,
+//  2. after the frames, the commit marker frame (frame.CONTROL flag, frame.TxCommit bit) is sent with the options
+//     [transaction ID, number of frames],
+//  3. the receiver (ClientCodec) holds the frames with the OptionTxID option until the commit marker with the
+//     matching number of frames arrives. If the connection drops before, the frames are discarded.
+
+// tx is the open transaction, the frames are copies of the sent frames
+type tx struct {
+	id     uint32
+	frames []*frame.Frame
+}
+
+// BeginTx starts the transaction: the responses written until CommitTx or RollbackTx are buffered
+// instead of being sent. The responses should be written completely before CommitTx or RollbackTx,
+// the frames of the closed transaction fail to be written. Only one transaction might be open at a time.
+func (c *Codec) BeginTx() error {
+	const op = errors.Op("goridge_begin_tx")
+
+	c.txMu.Lock()
+	defer c.txMu.Unlock()
+
+	if c.tx != nil {
+		return errors.E(op, errors.Str("transaction is already open"))
+	}
+
+	c.tx = &tx{id: c.txSeq.Add(1)}
+	c.txOpen.Store(c.tx.id)
+	return nil
+}
+
+// CommitTx sends the buffered responses and the commit marker frame. When the connection drops before the marker
+// is sent, the receiver discards the received frames of the transaction.
+func (c *Codec) CommitTx() error {
+	const op = errors.Op("goridge_commit_tx")
+
+	c.txMu.Lock()
+	t := c.tx
+	c.tx = nil
+	c.txOpen.Store(0)
+	c.txMu.Unlock()
+
+	if t == nil {
+		return errors.E(op, errors.Str("no open transaction"))
+	}
+
+	// the transaction is not interleaved with other responses
+	c.sendMu.Lock()
+	defer c.sendMu.Unlock()
+
+	// the batched responses are sent before the transaction
+	err := c.flushBatchLocked()
+	if err != nil {
+		c.stats.errors.Add(1)
+		return errors.E(op, err)
+	}
+
+	for _, fr := range t.frames {
+		err := c.sendWithDeadline(fr)
+		if err != nil {
+			c.stats.errors.Add(1)
+			return errors.E(op, err)
+		}
+
+		c.stats.framesOut.Add(1)
+		c.stats.bytesOut.Add(uint64(len(fr.Header()) + len(fr.Payload())))
+	}
+
+	marker := frame.NewFrame()
+	marker.WriteVersion(marker.Header(), frame.Version1)
+	marker.WriteFlags(marker.Header(), frame.CONTROL)
+	marker.SetTxCommitBit(marker.Header())
+	marker.WriteOptions(marker.HeaderPtr(), t.id, uint32(len(t.frames))) //nolint:gosec
+	writeCRC(marker, c.crcDisabled)
+
+	err = c.relaySendLocked(marker)
+	if err != nil {
+		c.stats.errors.Add(1)
+		return errors.E(op, err)
+	}
+
+	c.stats.framesOut.Add(1)
+	c.stats.bytesOut.Add(uint64(len(marker.Header())))
+	return nil
+}
+
+// RollbackTx discards the buffered responses of the transaction.
+func (c *Codec) RollbackTx() error {
+	const op = errors.Op("goridge_rollback_tx")
+
+	c.txMu.Lock()
+	defer c.txMu.Unlock()
+
+	if c.tx == nil {
+		return errors.E(op, errors.Str("no open transaction"))
+	}
+
+	c.tx = nil
+	c.txOpen.Store(0)
+	return nil
+}
+
+// bufferTx buffers the copy of the frame if it belongs to the open transaction. The frames of the transactions
+// which are already committed or rolled back are not sent (the client would hold them forever) and fail the write.
+func (c *Codec) bufferTx(fr *frame.Frame) (bool, error) {
+	// SEQ_ID, METHOD_LEN and at least one option pair
+	if fr.ReadHL(fr.Header()) <= 5 {
+		return false, nil
+	}
+
+	id, ok := lookupOption(fr.ReadOptions(fr.Header()), OptionTxID)
+	if !ok {
+		return false, nil
+	}
+
+	c.txMu.Lock()
+	defer c.txMu.Unlock()
+
+	if c.tx == nil || c.tx.id != id {
+		return false, errors.Errorf("transaction %d is already closed, the response is written too late", id)
+	}
+
+	c.tx.frames = append(c.tx.frames, copyFrame(fr))
+	return true, nil
+}
+
+// receiveTx returns the next frame to deliver, the frames of the transactions are held until their commit marker
+func (c *ClientCodec) receiveTx() (*frame.Frame, error) {
+	for {
+		if len(c.txReady) > 0 {
+			fr := c.txReady[0]
+			c.txReady = c.txReady[1:]
+			return fr, nil
+		}
+
+		// the batches are split into the frames they carry
+		fr, err := c.receiveFrame()
+		if err != nil {
+			// the uncommitted transactions are never delivered
+			c.txPending = nil
+			return nil, err
+		}
+
+		// only the frames with the extra options might belong to a transaction
+		if fr.ReadHL(fr.Header()) <= 5 && !fr.IsTxCommit(fr.Header()) {
+			return fr, nil
+		}
+
+		opts := fr.ReadOptions(fr.Header())
+		if fr.IsTxCommit(fr.Header()) {
+			if len(opts) < 2 {
+				return nil, errors.Str("transaction commit marker should have 2 options")
+			}
+
+			c.putFrame(fr)
+			frames := c.txPending[opts[0]]
+			delete(c.txPending, opts[0])
+			if len(frames) != int(opts[1]) {
+				return nil, errors.Errorf("transaction %d is incomplete: received %d of %d frames", opts[0], len(frames), opts[1])
+			}
+
+			c.txReady = append(c.txReady, frames...)
+			continue
+		}
+
+		id, ok := lookupOption(opts, OptionTxID)
+		if !ok {
+			return fr, nil
+		}
+
+		if c.txPending == nil {
+			c.txPending = make(map[uint32][]*frame.Frame)
+		}
+		c.txPending[id] = append(c.txPending[id], fr)
+	}
+}
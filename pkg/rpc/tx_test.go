@@ -0,0 +1,159 @@
+package rpc
+
+import (
+	"bytes"
+	"net/rpc"
+	"testing"
+	"time"
+
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/roadrunner-server/goridge/v3/pkg/pipe"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodec_Tx(t *testing.T) {
+	srv, cl := pipe.NewRelayPair()
+	server := NewCodecWithRelay(srv)
+	client := NewClientCodecWithRelay(cl)
+	t.Cleanup(func() {
+		_ = server.Close()
+		_ = client.Close()
+	})
+
+	require.Error(t, server.CommitTx())
+	require.Error(t, server.RollbackTx())
+
+	// rolled back responses are never sent
+	require.NoError(t, server.BeginTx())
+	require.Error(t, server.BeginTx())
+	require.NoError(t, server.WriteResponse(&rpc.Response{ServiceMethod: "test.Tx", Seq: 1}, "rolled back"))
+	require.NoError(t, server.RollbackTx())
+
+	require.NoError(t, server.BeginTx())
+	for seq := uint64(2); seq <= 4; seq++ {
+		require.NoError(t, server.WriteResponse(&rpc.Response{ServiceMethod: "test.Tx", Seq: seq}, "committed"))
+	}
+
+	headers := make(chan uint64, 3)
+	go func() {
+		for i := 0; i < 3; i++ {
+			r := &rpc.Response{}
+			if client.ReadResponseHeader(r) != nil {
+				return
+			}
+			var out string
+			_ = client.ReadResponseBody(&out)
+			headers <- r.Seq
+		}
+	}()
+
+	// nothing is delivered before the commit
+	select {
+	case seq := <-headers:
+		t.Fatalf("response %d was delivered before the commit", seq)
+	case <-time.After(time.Millisecond * 50):
+	}
+
+	require.NoError(t, server.CommitTx())
+	for seq := uint64(2); seq <= 4; seq++ {
+		assert.Equal(t, seq, <-headers)
+	}
+}
+
+// the transaction ID has priority over the optional total size of the stream
+func TestCodec_TxFullOptions(t *testing.T) {
+	c, rl := pipeCodec(t)
+	require.NoError(t, c.BeginTx())
+	require.NoError(t, c.SetResponseOptions(1, 100, 1, 101, 2, 102, 3))
+
+	r := &rpc.Response{ServiceMethod: "test.Tx", Seq: 1}
+	require.NoError(t, c.WriteSizedStream(r, bytes.NewReader([]byte("streamed")), 8))
+
+	go func() {
+		_ = c.CommitTx()
+	}()
+
+	fr := frame.NewFrame()
+	require.NoError(t, rl.Receive(fr))
+	opts := fr.ReadOptions(fr.Header())
+	assert.Len(t, opts, 10)
+	_, ok := lookupOption(opts, OptionTxID)
+	assert.True(t, ok)
+	_, ok = lookupOption(opts, OptionTotalSize)
+	assert.False(t, ok)
+	assert.Equal(t, []byte("test.Txstreamed"), fr.Payload())
+
+	marker := frame.NewFrame()
+	require.NoError(t, rl.Receive(marker))
+	assert.True(t, marker.IsTxCommit(marker.Header()))
+}
+
+// the response of the closed transaction fails instead of being dropped
+func TestCodec_TxLateResponse(t *testing.T) {
+	server, rl := pipeCodec(t)
+
+	require.NoError(t, server.BeginTx())
+	r := &rpc.Response{ServiceMethod: "test.Tx", Seq: 1}
+	fr, err := server.responseFrame(r)
+	require.NoError(t, err)
+	fr.WriteFlags(fr.Header(), frame.CodecRaw)
+	fr.WriteCRC(fr.Header())
+
+	// the commit marker of the empty transaction
+	go func() {
+		_ = rl.Receive(frame.NewFrame())
+	}()
+	require.NoError(t, server.CommitTx())
+	err = server.sendFrame(r, fr)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "already closed")
+	assert.Equal(t, uint64(1), server.Stats().Errors)
+}
+
+func txFrame(seq, txID uint32) *frame.Frame {
+	return requestFrame(seq, "test.Tx", frame.CodecRaw, []byte("tx"), OptionTxID, txID)
+}
+
+func txCommit(txID, count uint32) *frame.Frame {
+	fr := frame.NewFrame()
+	fr.WriteVersion(fr.Header(), frame.Version1)
+	fr.WriteFlags(fr.Header(), frame.CONTROL)
+	fr.SetTxCommitBit(fr.Header())
+	fr.WriteOptions(fr.HeaderPtr(), txID, count)
+	fr.WriteCRC(fr.Header())
+	return fr
+}
+
+func TestClientCodec_TxPartialFailure(t *testing.T) {
+	// the connection drops before the commit marker
+	srv, cl := pipe.NewRelayPair()
+	client := NewClientCodecWithRelay(cl)
+
+	go func() {
+		_ = srv.Send(txFrame(1, 7))
+		_ = srv.Send(txFrame(2, 7))
+		_ = srv.Close()
+	}()
+
+	err := client.ReadResponseHeader(&rpc.Response{})
+	require.Error(t, err)
+	assert.Empty(t, client.txPending)
+	assert.Empty(t, client.txReady)
+	_ = client.Close()
+
+	// the commit marker doesn't match the received frames
+	srv, cl = pipe.NewRelayPair()
+	client = NewClientCodecWithRelay(cl)
+
+	go func() {
+		_ = srv.Send(txFrame(1, 8))
+		_ = srv.Send(txCommit(8, 2))
+	}()
+
+	err = client.ReadResponseHeader(&rpc.Response{})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "transaction 8 is incomplete: received 1 of 2 frames")
+	_ = client.Close()
+	_ = srv.Close()
+}
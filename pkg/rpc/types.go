@@ -0,0 +1,99 @@
+package rpc
+
+import (
+	"reflect"
+	"sync"
+)
+
+// TypeRegistry maps the service methods to their request and response types,
+// used by the generic tooling and by the Codec to allocate the request bodies.
+type TypeRegistry struct {
+	mu    sync.RWMutex
+	types map[string]methodTypes
+}
+
+type methodTypes struct {
+	req  reflect.Type
+	resp reflect.Type
+}
+
+// NewTypeRegistry creates an empty TypeRegistry.
+func NewTypeRegistry() *TypeRegistry {
+	return &TypeRegistry{types: make(map[string]methodTypes)}
+}
+
+// RegisterMethodTypes registers the request and response types of the method, e.g.
+// RegisterMethodTypes("Service.Method", &pb.Request{}, &pb.Response{}). Values and pointers are registered the same.
+// Nil means that the method has no registered type for the request or response.
+func (tr *TypeRegistry) RegisterMethodTypes(method string, req, resp any) {
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	tr.types[method] = methodTypes{req: baseType(req), resp: baseType(resp)}
+}
+
+// NewRequest allocates the registered request type of the method and returns the pointer to it.
+func (tr *TypeRegistry) NewRequest(method string) (any, bool) {
+	t, ok := tr.requestType(method)
+	if !ok {
+		return nil, false
+	}
+
+	return reflect.New(t).Interface(), true
+}
+
+// requestType returns the registered request type of the method without the pointer
+func (tr *TypeRegistry) requestType(method string) (reflect.Type, bool) {
+	tr.mu.RLock()
+	t, ok := tr.types[method]
+	tr.mu.RUnlock()
+
+	if !ok || t.req == nil {
+		return nil, false
+	}
+
+	return t.req, true
+}
+
+// NewResponse allocates the registered response type of the method and returns the pointer to it.
+func (tr *TypeRegistry) NewResponse(method string) (any, bool) {
+	tr.mu.RLock()
+	t, ok := tr.types[method]
+	tr.mu.RUnlock()
+
+	if !ok || t.resp == nil {
+		return nil, false
+	}
+
+	return reflect.New(t.resp).Interface(), true
+}
+
+// baseType returns the type without the pointer
+func baseType(v any) reflect.Type {
+	if v == nil {
+		return nil
+	}
+
+	t := reflect.TypeOf(v)
+	if t.Kind() == reflect.Pointer {
+		return t.Elem()
+	}
+
+	return t
+}
+
+// registeredRequest allocates the registered request type of the method
+func (c *Codec) registeredRequest(method string) (any, bool) {
+	if c.types == nil {
+		return nil, false
+	}
+
+	return c.types.NewRequest(method)
+}
+
+// SetTypeRegistry sets the registry used by ReadRequestBody: when the out is a *any placeholder,
+// the registered request type of the method is allocated, decoded and stored into it.
+// Should be called before the codec is used.
+func (c *Codec) SetTypeRegistry(tr *TypeRegistry) {
+	c.types = tr
+}
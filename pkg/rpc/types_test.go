@@ -0,0 +1,65 @@
+package rpc
+
+import (
+	"net/rpc"
+	"testing"
+
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/roadrunner-server/goridge/v3/tests"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+type typedRequest struct {
+	Name string `json:"name"`
+}
+
+func TestTypeRegistry(t *testing.T) {
+	tr := NewTypeRegistry()
+	tr.RegisterMethodTypes("test.JSON", typedRequest{}, &typedRequest{})
+	tr.RegisterMethodTypes("test.Proto", &tests.Payload{}, nil)
+
+	req, ok := tr.NewRequest("test.JSON")
+	require.True(t, ok)
+	assert.IsType(t, &typedRequest{}, req)
+
+	resp, ok := tr.NewResponse("test.JSON")
+	require.True(t, ok)
+	assert.IsType(t, &typedRequest{}, resp)
+
+	_, ok = tr.NewResponse("test.Proto")
+	assert.False(t, ok)
+	_, ok = tr.NewRequest("test.Unknown")
+	assert.False(t, ok)
+}
+
+func TestCodec_SetTypeRegistry(t *testing.T) {
+	c, rl := pipeCodec(t)
+
+	tr := NewTypeRegistry()
+	tr.RegisterMethodTypes("test.JSON", &typedRequest{}, nil)
+	tr.RegisterMethodTypes("test.Proto", &tests.Payload{}, nil)
+	c.SetTypeRegistry(tr)
+
+	pb, err := proto.Marshal(&tests.Payload{Storage: "goridge"})
+	require.NoError(t, err)
+
+	go func() {
+		assert.NoError(t, rl.Send(requestFrame(1, "test.JSON", frame.CodecJSON, []byte(`{"name":"goridge"}`))))
+		assert.NoError(t, rl.Send(requestFrame(2, "test.Proto", frame.CodecProto, pb)))
+	}()
+
+	r := &rpc.Request{}
+	require.NoError(t, c.ReadRequestHeader(r))
+	var out any
+	require.NoError(t, c.ReadRequestBody(&out))
+	require.IsType(t, &typedRequest{}, out)
+	assert.Equal(t, "goridge", out.(*typedRequest).Name)
+
+	require.NoError(t, c.ReadRequestHeader(r))
+	out = nil
+	require.NoError(t, c.ReadRequestBody(&out))
+	require.IsType(t, &tests.Payload{}, out)
+	assert.Equal(t, "goridge", out.(*tests.Payload).Storage)
+}
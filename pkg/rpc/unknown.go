@@ -0,0 +1,52 @@
+package rpc
+
+import (
+	"net/rpc"
+)
+
+// UnknownMethodHandler returns the response for the method the server doesn't have. A non-nil error is sent
+// as the error response, otherwise the body is encoded with the codec (0 - the codec of the request).
+type UnknownMethodHandler func(method string) (body any, codec byte, err error)
+
+// SetUnknownMethodHandler sets the handler of the requests for the methods not listed in methods (the service
+// methods registered in the net/rpc server, e.g. "pair.Echo"), e.g. to respond with the structured error listing
+// the available methods. The method is checked when the request header is read, the handler response replaces
+// the error response of net/rpc. Nil disables the handler. Should be called before the codec is used.
+func (c *Codec) SetUnknownMethodHandler(methods []string, handler UnknownMethodHandler) {
+	c.unknownMethod = handler
+	c.knownMethods = make(map[string]struct{}, len(methods))
+	for _, m := range methods {
+		c.knownMethods[m] = struct{}{}
+	}
+}
+
+// markUnknownMethod remembers the sequence of the request for the method which is not known to the server
+func (c *Codec) markUnknownMethod(seq uint64, method string) {
+	if c.unknownMethod == nil {
+		return
+	}
+
+	if _, ok := c.knownMethods[method]; !ok {
+		c.unknownSeqs.Store(seq, method)
+	}
+}
+
+// handleUnknownMethod replaces the net/rpc error response for the unknown method with the handler response
+// (the response might be without the method, see noPrefixResponse)
+func (c *Codec) handleUnknownMethod(r *rpc.Response, body any, codec byte) (*rpc.Response, any, byte) {
+	method, ok := c.unknownSeqs.LoadAndDelete(r.Seq)
+	if !ok {
+		return r, body, codec
+	}
+
+	out, flag, err := c.unknownMethod(method.(string))
+	if err != nil {
+		return &rpc.Response{ServiceMethod: r.ServiceMethod, Seq: r.Seq, Error: err.Error()}, nil, codec
+	}
+
+	if flag == 0 {
+		flag = codec
+	}
+
+	return &rpc.Response{ServiceMethod: r.ServiceMethod, Seq: r.Seq}, out, flag
+}
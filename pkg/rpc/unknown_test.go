@@ -0,0 +1,85 @@
+package rpc
+
+import (
+	"net/rpc"
+	"testing"
+
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/goridge/v3/pkg/pipe"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodec_SetUnknownMethodHandler(t *testing.T) {
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("pair", new(panicService)))
+
+	srv, cl := pipe.NewRelayPair()
+	codec := NewCodecWithRelay(srv)
+
+	var called []string
+	codec.SetUnknownMethodHandler([]string{"pair.Echo", "pair.Panic"}, func(method string) (any, byte, error) {
+		called = append(called, method)
+		if method == "missing.Service" {
+			return nil, 0, errors.Errorf("unknown service, available methods: pair.Echo, pair.Panic")
+		}
+		return "fallback for " + method, 0, nil
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- ServeConn(server, codec, nil)
+	}()
+
+	client := rpc.NewClientWithCodec(NewClientCodecWithRelay(cl))
+
+	var out string
+	require.NoError(t, client.Call("pair.Missing", "in", &out))
+	assert.Equal(t, "fallback for pair.Missing", out)
+
+	err := client.Call("missing.Service", "in", &out)
+	require.Error(t, err)
+	assert.Equal(t, "unknown service, available methods: pair.Echo, pair.Panic", err.Error())
+
+	// registered methods are not affected
+	require.NoError(t, client.Call("pair.Echo", "in", &out))
+	assert.Equal(t, "in", out)
+
+	assert.Equal(t, []string{"pair.Missing", "missing.Service"}, called)
+
+	require.NoError(t, client.Close())
+	require.NoError(t, <-done)
+}
+
+type lookalikeService struct{}
+
+func (s *lookalikeService) Fail(_ string, _ *string) error {
+	return errors.Str("rpc: can't find method lookalike.Other")
+}
+
+func TestCodec_UnknownMethodHandlerRegisteredError(t *testing.T) {
+	server := rpc.NewServer()
+	require.NoError(t, server.RegisterName("lookalike", new(lookalikeService)))
+
+	srv, cl := pipe.NewRelayPair()
+	codec := NewCodecWithRelay(srv)
+	codec.SetUnknownMethodHandler([]string{"lookalike.Fail"}, func(method string) (any, byte, error) {
+		return "fallback for " + method, 0, nil
+	})
+
+	done := make(chan error, 1)
+	go func() {
+		done <- ServeConn(server, codec, nil)
+	}()
+
+	client := rpc.NewClientWithCodec(NewClientCodecWithRelay(cl))
+
+	// the error of the registered method looks like the net/rpc one, but the method is known
+	var out string
+	err := client.Call("lookalike.Fail", "in", &out)
+	require.Error(t, err)
+	assert.Equal(t, "rpc: can't find method lookalike.Other", err.Error())
+
+	require.NoError(t, client.Close())
+	require.NoError(t, <-done)
+}
@@ -0,0 +1,36 @@
+package rpc
+
+import (
+	"fmt"
+
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+)
+
+// ErrUnsupportedVersion is returned when the received frame has the protocol version this build doesn't understand
+var ErrUnsupportedVersion = errors.Str("unsupported protocol version") //nolint:gochecknoglobals
+
+// UnsupportedVersionError carries the observed protocol version. It matches ErrUnsupportedVersion with errors.Is.
+type UnsupportedVersionError struct {
+	Version byte
+}
+
+func (e *UnsupportedVersionError) Error() string {
+	return fmt.Sprintf("%s: %d, supported versions: %v", ErrUnsupportedVersion.Error(), e.Version, SupportedVersions())
+}
+
+func (e *UnsupportedVersionError) Is(target error) bool {
+	return target == ErrUnsupportedVersion
+}
+
+// checkVersion returns UnsupportedVersionError if the frame version is not one of SupportedVersions
+func checkVersion(fr *frame.Frame) error {
+	version := fr.ReadVersion(fr.Header())
+	for _, v := range SupportedVersions() {
+		if v == version {
+			return nil
+		}
+	}
+
+	return &UnsupportedVersionError{Version: version}
+}
@@ -0,0 +1,62 @@
+package rpc
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+)
+
+// ErrWriteTimeout is returned when the frame was not sent within the write timeout, see Codec.SetWriteTimeout
+var ErrWriteTimeout = errors.Str("write timeout") //nolint:gochecknoglobals
+
+// WriteTimeoutError carries the expired write timeout. It matches ErrWriteTimeout with errors.Is.
+type WriteTimeoutError struct {
+	Timeout time.Duration
+	// Err is the error returned by the relay
+	Err error
+}
+
+func (e *WriteTimeoutError) Error() string {
+	return fmt.Sprintf("%s: the frame was not sent within %s: %v", ErrWriteTimeout.Error(), e.Timeout, e.Err)
+}
+
+func (e *WriteTimeoutError) Is(target error) bool {
+	return target == ErrWriteTimeout
+}
+
+// SetWriteTimeout sets the timeout applied to every frame sent by the codec, 0 disables the timeout.
+// The relay should support write deadlines (like the socket relays over net.Conn), otherwise the timeout is ignored.
+// The frame might be partially written when the timeout fires, so the connection should be closed.
+// Should be called before the codec is used.
+func (c *Codec) SetWriteTimeout(timeout time.Duration) {
+	c.writeTimeout = timeout
+}
+
+// sendWithDeadline sends the frame with the write deadline set on the relay, if it supports it
+func (c *Codec) sendWithDeadline(fr *frame.Frame) error {
+	type deadliner interface {
+		SetWriteDeadline(time.Time) error
+	}
+
+	d, ok := c.relay.(deadliner)
+	if c.writeTimeout == 0 || !ok {
+		return c.sendFlushed(fr)
+	}
+
+	deadline := time.Now().Add(c.writeTimeout)
+	err := d.SetWriteDeadline(deadline)
+	if err != nil {
+		return err
+	}
+
+	err = c.sendFlushed(fr)
+	// the frames sent by the codec without the timeout are not affected
+	_ = d.SetWriteDeadline(time.Time{})
+	if err != nil && !time.Now().Before(deadline) {
+		return &WriteTimeoutError{Timeout: c.writeTimeout, Err: err}
+	}
+
+	return err
+}
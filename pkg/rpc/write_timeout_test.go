@@ -0,0 +1,35 @@
+package rpc
+
+import (
+	"net/rpc"
+	"testing"
+	"time"
+
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodec_SetWriteTimeout(t *testing.T) {
+	c, rl := pipeCodec(t)
+	c.SetWriteTimeout(time.Millisecond * 100)
+
+	// nobody reads the peer side, so the write blocks
+	start := time.Now()
+	err := c.WriteResponse(&rpc.Response{ServiceMethod: "test.Stalled", Seq: 1}, "pong")
+	require.ErrorIs(t, err, ErrWriteTimeout)
+	assert.Less(t, time.Since(start), time.Second)
+
+	var te *WriteTimeoutError
+	require.ErrorAs(t, err, &te)
+	assert.Equal(t, time.Millisecond*100, te.Timeout)
+
+	// the deadline is applied per frame
+	go func() {
+		time.Sleep(time.Millisecond * 150)
+		fr := frame.NewFrame()
+		assert.NoError(t, rl.Receive(fr))
+	}()
+	c.SetWriteTimeout(time.Second * 5)
+	require.NoError(t, c.WriteResponse(&rpc.Response{ServiceMethod: "test.Stalled", Seq: 2}, "pong"))
+}
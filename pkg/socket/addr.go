@@ -0,0 +1,47 @@
+package socket
+
+import (
+	"io"
+	"net"
+)
+
+type addrConn interface {
+	LocalAddr() net.Addr
+	RemoteAddr() net.Addr
+}
+
+// LocalAddr returns the local address of the underlying net.Conn, nil if the relay is not over a net.Conn.
+func (rl *Relay) LocalAddr() net.Addr {
+	return localAddr(rl.rwc)
+}
+
+// RemoteAddr returns the remote address of the underlying net.Conn, nil if the relay is not over a net.Conn.
+func (rl *Relay) RemoteAddr() net.Addr {
+	return remoteAddr(rl.rwc)
+}
+
+// LocalAddr returns the local address of the underlying net.Conn, nil if the relay is not over a net.Conn.
+func (rl *BufferedRelay) LocalAddr() net.Addr {
+	return localAddr(rl.rwc)
+}
+
+// RemoteAddr returns the remote address of the underlying net.Conn, nil if the relay is not over a net.Conn.
+func (rl *BufferedRelay) RemoteAddr() net.Addr {
+	return remoteAddr(rl.rwc)
+}
+
+func localAddr(rwc io.ReadWriteCloser) net.Addr {
+	if c, ok := rwc.(addrConn); ok {
+		return c.LocalAddr()
+	}
+
+	return nil
+}
+
+func remoteAddr(rwc io.ReadWriteCloser) net.Addr {
+	if c, ok := rwc.(addrConn); ok {
+		return c.RemoteAddr()
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package socket
+
+import (
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/roadrunner-server/goridge/v3/pkg/relay"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// readWriteCloser is not a net.Conn
+type readWriteCloser struct {
+	io.Reader
+	io.Writer
+}
+
+func (readWriteCloser) Close() error {
+	return nil
+}
+
+func TestRelay_Addr(t *testing.T) {
+	ln, err := net.Listen("unix", filepath.Join(t.TempDir(), "rpc.sock"))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = ln.Close()
+	})
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, errA := ln.Accept()
+		assert.NoError(t, errA)
+		accepted <- conn
+	}()
+
+	conn, err := net.Dial("unix", ln.Addr().String())
+	require.NoError(t, err)
+
+	server := NewSocketRelay(<-accepted)
+	client := NewBufferedSocketRelay(conn, 1024)
+	t.Cleanup(func() {
+		_ = server.Close()
+		_ = client.Close()
+	})
+
+	// the relays are checked with the type assertion
+	var rl relay.Relay = server
+	ar, ok := rl.(relay.AddrRelay)
+	require.True(t, ok)
+	assert.Equal(t, "unix", ar.LocalAddr().Network())
+	assert.Equal(t, ln.Addr().String(), ar.LocalAddr().String())
+
+	rl = client
+	ar, ok = rl.(relay.AddrRelay)
+	require.True(t, ok)
+	assert.Equal(t, ln.Addr().String(), ar.RemoteAddr().String())
+
+	// not a net.Conn
+	plain := NewSocketRelay(readWriteCloser{strings.NewReader(""), io.Discard})
+	assert.Nil(t, plain.LocalAddr())
+	assert.Nil(t, plain.RemoteAddr())
+}
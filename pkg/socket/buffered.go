@@ -0,0 +1,157 @@
+package socket
+
+import (
+	"bufio"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/goridge/v3/internal"
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/roadrunner-server/goridge/v3/pkg/relay"
+)
+
+// DefaultBufferSize is the default read and write buffer size of the BufferedRelay
+const DefaultBufferSize = 64 * 1024
+
+// BufferedRelay communicates with underlying process using sockets, reads and writes are buffered to reduce
+// the number of syscalls. Sent frames are written to the connection only on Flush (or when the buffer is full),
+// rpc.Codec flushes the relay after every response.
+type BufferedRelay struct {
+	rwc io.ReadWriteCloser
+	r   *bufio.Reader
+
+	mu sync.Mutex
+	w  *bufio.Writer
+
+	// lifetime limit of the received bytes, 0 - unlimited
+	limit    int64
+	received int64
+	// header CRC verification policy and payload alignment
+	receive internal.ReceiveConfig
+}
+
+// NewBufferedSocketRelay creates new buffered socket based data relay, size <= 0 means DefaultBufferSize.
+func NewBufferedSocketRelay(rwc io.ReadWriteCloser, size int) *BufferedRelay {
+	internal.Preallocate()
+	if size <= 0 {
+		size = DefaultBufferSize
+	}
+
+	return &BufferedRelay{
+		rwc: rwc,
+		r:   bufio.NewReaderSize(rwc, size),
+		// bufio.Writer fails on the short writes of the connection instead of continuing them
+		w: bufio.NewWriterSize(internal.FullWriter{W: rwc}, size),
+	}
+}
+
+// Send writes the frame to the buffer.
+func (rl *BufferedRelay) Send(frame *frame.Frame) error {
+	const op = errors.Op("buffered frame send")
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	_, err := rl.w.Write(frame.Header())
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	_, err = rl.w.Write(frame.Payload())
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// Flush writes the buffered frames to the connection.
+func (rl *BufferedRelay) Flush() error {
+	const op = errors.Op("buffered frame flush")
+	rl.mu.Lock()
+	defer rl.mu.Unlock()
+
+	err := rl.w.Flush()
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// Receive data from the underlying process and returns associated prefix or error.
+func (rl *BufferedRelay) Receive(frame *frame.Frame) error {
+	if frame == nil {
+		return errors.Str("nil frame")
+	}
+	if rl.limit > 0 && rl.received >= rl.limit {
+		return relay.ErrConnLimitExceeded
+	}
+
+	err := internal.ReceiveFrameWithConfig(rl.r, frame, rl.receive)
+	if err != nil {
+		return err
+	}
+
+	rl.received += int64(len(frame.Header()) + len(frame.Payload()))
+	return nil
+}
+
+// SetConnByteLimit sets the limit of bytes received over the connection lifetime, 0 disables the limit.
+// The frame which crosses the limit is received, the next Receive calls return relay.ErrConnLimitExceeded.
+func (rl *BufferedRelay) SetConnByteLimit(n int64) {
+	rl.limit = n
+}
+
+// SetReadDeadline sets the read deadline on the underlying connection.
+func (rl *BufferedRelay) SetReadDeadline(t time.Time) error {
+	d, ok := rl.rwc.(readDeadliner)
+	if !ok {
+		return errors.Str("connection doesn't support read deadlines")
+	}
+
+	return d.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets the write deadline on the underlying connection.
+func (rl *BufferedRelay) SetWriteDeadline(t time.Time) error {
+	d, ok := rl.rwc.(writeDeadliner)
+	if !ok {
+		return errors.Str("connection doesn't support write deadlines")
+	}
+
+	return d.SetWriteDeadline(t)
+}
+
+// Close flushes the buffered frames and closes the connection.
+func (rl *BufferedRelay) Close() error {
+	_ = rl.Flush()
+	return rl.rwc.Close()
+}
+
+// SetCRCPolicy sets how the frames without the header CRC are received, default frame.CRCRequired.
+// Should be called before the relay is used.
+func (rl *BufferedRelay) SetCRCPolicy(policy frame.CRCPolicy) {
+	rl.receive.Policy = policy
+}
+
+// SetMaxFrameSize sets the limit of the received frame size (header, options and payload) in bytes, the bigger
+// frames are rejected with frame.FrameTooLargeError before the payload is read, 0 disables the limit.
+// Should be called before the relay is used.
+func (rl *BufferedRelay) SetMaxFrameSize(n int) {
+	rl.receive.MaxFrameSize = n
+}
+
+// SetPayloadAlignment makes the received payloads start at the address aligned to n bytes (a power of two),
+// so they can be reinterpreted in place. Such payloads aren't taken from the buffer pool, 0 disables the alignment.
+// Should be called before the relay is used.
+func (rl *BufferedRelay) SetPayloadAlignment(n int) error {
+	err := internal.ValidateAlignment(n)
+	if err != nil {
+		return err
+	}
+
+	rl.receive.Alignment = n
+	return nil
+}
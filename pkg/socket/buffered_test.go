@@ -0,0 +1,122 @@
+package socket
+
+import (
+	"net"
+	"sync/atomic"
+	"testing"
+
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/roadrunner-server/goridge/v3/pkg/relay"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingConn counts the Read and Write calls (syscalls for the real connections)
+type countingConn struct {
+	net.Conn
+	reads  atomic.Uint64
+	writes atomic.Uint64
+}
+
+func (c *countingConn) Read(b []byte) (int, error) {
+	c.reads.Add(1)
+	return c.Conn.Read(b)
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	c.writes.Add(1)
+	return c.Conn.Write(b)
+}
+
+func testFrame(seq uint32) *frame.Frame {
+	nf := frame.NewFrame()
+	nf.WriteVersion(nf.Header(), frame.Version1)
+	nf.WriteFlags(nf.Header(), frame.CodecRaw)
+	nf.WriteOptions(nf.HeaderPtr(), seq, 0)
+	nf.WritePayloadLen(nf.Header(), uint32(len([]byte(TestPayload))))
+	nf.WritePayload([]byte(TestPayload))
+	nf.WriteCRC(nf.Header())
+	return nf
+}
+
+func TestBufferedRelay(t *testing.T) {
+	a, b := net.Pipe()
+	sender := NewBufferedSocketRelay(a, 0)
+	receiver := NewBufferedSocketRelay(b, 0)
+
+	go func() {
+		for i := uint32(0); i < 10; i++ {
+			assert.NoError(t, sender.Send(testFrame(i)))
+		}
+		assert.NoError(t, sender.Flush())
+	}()
+
+	for i := uint32(0); i < 10; i++ {
+		fr := frame.NewFrame()
+		require.NoError(t, receiver.Receive(fr))
+		assert.True(t, fr.VerifyCRC(fr.Header()))
+		assert.Equal(t, []uint32{i, 0}, fr.ReadOptions(fr.Header()))
+		assert.Equal(t, []byte(TestPayload), fr.Payload())
+	}
+
+	require.NoError(t, sender.Close())
+	require.NoError(t, receiver.Close())
+}
+
+func BenchmarkRelaySyscalls(b *testing.B) {
+	relays := map[string]func(conn net.Conn) relay.Relay{
+		"unbuffered": func(conn net.Conn) relay.Relay { return NewSocketRelay(conn) },
+		"buffered":   func(conn net.Conn) relay.Relay { return NewBufferedSocketRelay(conn, 0) },
+	}
+
+	for name, newRelay := range relays {
+		b.Run(name, func(b *testing.B) {
+			ls, err := net.Listen("tcp", "127.0.0.1:0")
+			require.NoError(b, err)
+			defer func() {
+				_ = ls.Close()
+			}()
+
+			go func() {
+				conn, errA := ls.Accept()
+				if errA != nil {
+					return
+				}
+				// echo server
+				rl := newRelay(conn)
+				for {
+					fr := frame.NewFrame()
+					if rl.Receive(fr) != nil {
+						return
+					}
+					_ = rl.Send(fr)
+					if f, ok := rl.(*BufferedRelay); ok {
+						_ = f.Flush()
+					}
+				}
+			}()
+
+			dial, err := net.Dial("tcp", ls.Addr().String())
+			require.NoError(b, err)
+			conn := &countingConn{Conn: dial}
+			rl := newRelay(conn)
+			defer func() {
+				_ = rl.Close()
+			}()
+
+			nf := testFrame(1)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_ = rl.Send(nf)
+				if f, ok := rl.(*BufferedRelay); ok {
+					_ = f.Flush()
+				}
+				_ = rl.Receive(frame.NewFrame())
+			}
+
+			b.ReportMetric(float64(conn.reads.Load())/float64(b.N), "reads/op")
+			b.ReportMetric(float64(conn.writes.Load())/float64(b.N), "writes/op")
+		})
+	}
+}
@@ -0,0 +1,23 @@
+//go:build windows
+
+package socket
+
+import (
+	"os"
+
+	"github.com/roadrunner-server/errors"
+)
+
+// NewNamedPipeRelay connects to the Windows named pipe (e.g. \\.\pipe\roadrunner) and creates the socket relay
+// over it, the frames are the same as over the unix sockets. The pipe server should have a free instance,
+// otherwise the connection fails with ERROR_PIPE_BUSY.
+func NewNamedPipeRelay(path string) (*Relay, error) {
+	const op = errors.Op("goridge_named_pipe_relay")
+
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return nil, errors.E(op, err)
+	}
+
+	return NewSocketRelay(f), nil
+}
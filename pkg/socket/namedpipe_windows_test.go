@@ -0,0 +1,94 @@
+//go:build windows
+
+package socket
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	kernel32             = syscall.NewLazyDLL("kernel32.dll")
+	procCreateNamedPipeW = kernel32.NewProc("CreateNamedPipeW")
+	procConnectNamedPipe = kernel32.NewProc("ConnectNamedPipe")
+)
+
+const (
+	pipeAccessDuplex = 0x3
+	// PIPE_TYPE_BYTE | PIPE_READMODE_BYTE | PIPE_WAIT
+	pipeModeByte       = 0x0
+	errorPipeConnected = syscall.Errno(535)
+	pipeBufferSize     = 4096
+	pipeDefaultTimeout = 0
+)
+
+// listenPipe creates the single instance of the named pipe and returns the server end once the client connects
+func listenPipe(t *testing.T, path string) <-chan *os.File {
+	name, err := syscall.UTF16PtrFromString(path)
+	require.NoError(t, err)
+
+	h, _, err := procCreateNamedPipeW.Call(uintptr(unsafe.Pointer(name)), pipeAccessDuplex, pipeModeByte, 1,
+		pipeBufferSize, pipeBufferSize, pipeDefaultTimeout, 0)
+	require.NotEqual(t, uintptr(syscall.InvalidHandle), h, "CreateNamedPipeW: %v", err)
+
+	conn := make(chan *os.File, 1)
+	go func() {
+		r, _, err := procConnectNamedPipe.Call(h, 0)
+		if r == 0 && err != errorPipeConnected {
+			_ = syscall.CloseHandle(syscall.Handle(h))
+			close(conn)
+			return
+		}
+
+		conn <- os.NewFile(h, path)
+	}()
+
+	return conn
+}
+
+func TestNamedPipeRelay(t *testing.T) {
+	path := fmt.Sprintf(`\\.\pipe\goridge-test-%d`, time.Now().UnixNano())
+	conn := listenPipe(t, path)
+
+	client, err := NewNamedPipeRelay(path)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	f, ok := <-conn
+	require.True(t, ok)
+	server := NewSocketRelay(f)
+	t.Cleanup(func() {
+		_ = server.Close()
+	})
+
+	nf := frame.NewFrame()
+	nf.WriteVersion(nf.Header(), frame.Version1)
+	nf.WriteFlags(nf.Header(), frame.CodecRaw)
+	nf.WritePayloadLen(nf.Header(), uint32(len(TestPayload)))
+	nf.WritePayload([]byte(TestPayload))
+	nf.WriteCRC(nf.Header())
+
+	require.NoError(t, client.Send(nf))
+
+	fr := frame.NewFrame()
+	require.NoError(t, server.Receive(fr))
+	assert.Equal(t, TestPayload, string(fr.Payload()))
+
+	// and back
+	require.NoError(t, server.Send(nf))
+
+	fr = frame.NewFrame()
+	require.NoError(t, client.Receive(fr))
+	assert.Equal(t, TestPayload, string(fr.Payload()))
+	assert.Equal(t, frame.CodecRaw, fr.ReadFlags())
+}
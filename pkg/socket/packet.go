@@ -0,0 +1,153 @@
+package socket
+
+import (
+	"bytes"
+	"net"
+	"sync"
+
+	"github.com/roadrunner-server/errors"
+	"github.com/roadrunner-server/goridge/v3/internal"
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+)
+
+// DefaultDatagramSize is the default size limit of the datagrams of the PacketRelay, the maximal UDP payload over IPv4
+const DefaultDatagramSize = 65507
+
+// PacketRelay carries one frame per datagram over the net.PacketConn (e.g. UDP), the frames are never split or
+// reassembled, so the frames bigger than the datagram size are rejected. The datagrams are not retransmitted
+// or ordered, the caller handles the lost frames.
+type PacketRelay struct {
+	conn net.PacketConn
+	size int
+
+	// the datagram buffer of Receive, one byte bigger than the size to detect the truncated datagrams
+	readMu sync.Mutex
+	buf    []byte
+
+	// address of the frames sent, nil - the sender of the last received datagram
+	addrMu sync.Mutex
+	peer   net.Addr
+	last   net.Addr
+
+	// header CRC verification policy and payload alignment
+	receive internal.ReceiveConfig
+}
+
+// NewPacketRelay creates the datagram relay sending the frames to the peer. nil peer means the sender of the last
+// received valid frame (the server side), so such a relay serves only one peer: the responses to the concurrent
+// clients would be sent to the one which sent the last request. size <= 0 means DefaultDatagramSize.
+func NewPacketRelay(conn net.PacketConn, peer net.Addr, size int) *PacketRelay {
+	internal.Preallocate()
+	if size <= 0 {
+		size = DefaultDatagramSize
+	}
+
+	return &PacketRelay{
+		conn: conn,
+		size: size,
+		buf:  make([]byte, size+1),
+		peer: peer,
+	}
+}
+
+// Send writes the frame as one datagram. The frames bigger than the datagram size are rejected with
+// frame.FrameTooLargeError.
+func (rl *PacketRelay) Send(fr *frame.Frame) error {
+	const op = errors.Op("packet frame send")
+
+	size := len(fr.Header()) + len(fr.Payload())
+	if size > rl.size {
+		// unwrapped to be matched with errors.Is
+		return &frame.FrameTooLargeError{Size: uint64(size), Limit: uint64(rl.size)}
+	}
+
+	addr := rl.addr()
+	if addr == nil {
+		return errors.E(op, errors.Str("peer address is unknown, no datagram was received yet"))
+	}
+
+	_, err := rl.conn.WriteTo(fr.Bytes(), addr)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	return nil
+}
+
+// Receive reads the frame from the next datagram. The datagrams bigger than the datagram size are rejected with
+// frame.FrameTooLargeError, the datagrams with the truncated frame - with frame.TruncatedPayloadError.
+func (rl *PacketRelay) Receive(fr *frame.Frame) error {
+	const op = errors.Op("packet frame receive")
+	if fr == nil {
+		return errors.Str("nil frame")
+	}
+
+	rl.readMu.Lock()
+	defer rl.readMu.Unlock()
+
+	n, addr, err := rl.conn.ReadFrom(rl.buf)
+	if err != nil {
+		return errors.E(op, err)
+	}
+
+	// the rest of the datagram is discarded by the conn, so the size is only known to exceed the limit
+	if n > rl.size {
+		return &frame.FrameTooLargeError{Size: uint64(n), Limit: uint64(rl.size)}
+	}
+
+	r := bytes.NewReader(rl.buf[:n])
+	err = internal.ReceiveFrameWithConfig(r, fr, rl.receive)
+	if err != nil {
+		return err
+	}
+
+	if r.Len() != 0 {
+		return errors.E(op, errors.Errorf("datagram carries %d bytes after the frame", r.Len()))
+	}
+
+	// only the valid frames change the reply address, a stray datagram doesn't redirect the responses
+	rl.addrMu.Lock()
+	rl.last = addr
+	rl.addrMu.Unlock()
+
+	return nil
+}
+
+// LocalAddr returns the local address of the underlying net.PacketConn.
+func (rl *PacketRelay) LocalAddr() net.Addr {
+	return rl.conn.LocalAddr()
+}
+
+// RemoteAddr returns the address the frames are sent to, nil if it's unknown yet.
+func (rl *PacketRelay) RemoteAddr() net.Addr {
+	return rl.addr()
+}
+
+// Close closes the underlying net.PacketConn.
+func (rl *PacketRelay) Close() error {
+	return rl.conn.Close()
+}
+
+// SetCRCPolicy sets how the frames without the header CRC are received, default frame.CRCRequired.
+// Should be called before the relay is used.
+func (rl *PacketRelay) SetCRCPolicy(policy frame.CRCPolicy) {
+	rl.receive.Policy = policy
+}
+
+// SetMaxFrameSize sets the limit of the received frame size (header, options and payload) in bytes below the
+// datagram size, the bigger frames are rejected with frame.FrameTooLargeError, 0 disables the limit.
+// Should be called before the relay is used.
+func (rl *PacketRelay) SetMaxFrameSize(n int) {
+	rl.receive.MaxFrameSize = n
+}
+
+func (rl *PacketRelay) addr() net.Addr {
+	rl.addrMu.Lock()
+	defer rl.addrMu.Unlock()
+
+	if rl.peer != nil {
+		return rl.peer
+	}
+
+	return rl.last
+}
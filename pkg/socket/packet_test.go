@@ -0,0 +1,111 @@
+package socket
+
+import (
+	"net"
+	"testing"
+
+	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/roadrunner-server/goridge/v3/pkg/relay"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func packetPair(t *testing.T, size int) (*PacketRelay, *PacketRelay) {
+	serverConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	clientConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	server := NewPacketRelay(serverConn, nil, size)
+	client := NewPacketRelay(clientConn, serverConn.LocalAddr(), size)
+	t.Cleanup(func() {
+		_ = server.Close()
+		_ = client.Close()
+	})
+
+	return server, client
+}
+
+func TestPacketRelay(t *testing.T) {
+	server, client := packetPair(t, 0)
+	var _ relay.Relay = server
+	var _ relay.AddrRelay = server
+
+	// the server replies to the sender of the last datagram
+	assert.Error(t, server.Send(testFrame(0)))
+	assert.Nil(t, server.RemoteAddr())
+
+	for i := uint32(0); i < 5; i++ {
+		require.NoError(t, client.Send(testFrame(i)))
+
+		fr := frame.NewFrame()
+		require.NoError(t, server.Receive(fr))
+		assert.True(t, fr.VerifyCRC(fr.Header()))
+		assert.Equal(t, []uint32{i, 0}, fr.ReadOptions(fr.Header()))
+		assert.Equal(t, []byte(TestPayload), fr.Payload())
+		assert.Equal(t, client.LocalAddr().String(), server.RemoteAddr().String())
+
+		require.NoError(t, server.Send(testFrame(i+100)))
+		fr = frame.NewFrame()
+		require.NoError(t, client.Receive(fr))
+		assert.Equal(t, []uint32{i + 100, 0}, fr.ReadOptions(fr.Header()))
+		assert.Equal(t, []byte(TestPayload), fr.Payload())
+	}
+}
+
+func TestPacketRelayStrayDatagram(t *testing.T) {
+	server, client := packetPair(t, 0)
+	require.NoError(t, client.Send(testFrame(1)))
+	require.NoError(t, server.Receive(frame.NewFrame()))
+
+	// the garbage and the frames with the broken CRC don't change the reply address
+	stray, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = stray.Close()
+	})
+
+	_, err = stray.WriteTo([]byte("garbage, not a frame"), server.LocalAddr())
+	require.NoError(t, err)
+	assert.Error(t, server.Receive(frame.NewFrame()))
+
+	broken := testFrame(2).Bytes()
+	broken[6]++
+	_, err = stray.WriteTo(broken, server.LocalAddr())
+	require.NoError(t, err)
+	assert.Error(t, server.Receive(frame.NewFrame()))
+
+	assert.Equal(t, client.LocalAddr().String(), server.RemoteAddr().String())
+	require.NoError(t, server.Send(testFrame(3)))
+	fr := frame.NewFrame()
+	require.NoError(t, client.Receive(fr))
+	assert.Equal(t, []uint32{3, 0}, fr.ReadOptions(fr.Header()))
+}
+
+func TestPacketRelayTooLarge(t *testing.T) {
+	server, client := packetPair(t, 64)
+	big := testFrame(1)
+
+	// the frame doesn't fit into the datagram
+	err := client.Send(big)
+	assert.ErrorIs(t, err, frame.ErrFrameTooLarge)
+
+	// the datagram from the relay with the bigger size
+	sender := NewPacketRelay(client.conn, server.LocalAddr(), 0)
+	require.NoError(t, sender.Send(big))
+
+	var fe *frame.FrameTooLargeError
+	err = server.Receive(frame.NewFrame())
+	require.ErrorAs(t, err, &fe)
+	assert.Equal(t, uint64(64), fe.Limit)
+
+	// the frames fitting into the datagram are still received
+	small, err := frame.NewBuilder().Flags(frame.CodecRaw).Options(2, 0).Build()
+	require.NoError(t, err)
+	require.NoError(t, client.Send(small))
+
+	fr := frame.NewFrame()
+	require.NoError(t, server.Receive(fr))
+	assert.Equal(t, []uint32{2, 0}, fr.ReadOptions(fr.Header()))
+	assert.Empty(t, fr.Payload())
+}
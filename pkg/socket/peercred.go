@@ -0,0 +1,36 @@
+package socket
+
+import (
+	"net"
+
+	"github.com/roadrunner-server/errors"
+)
+
+// ErrPeerCredUnsupported is returned by PeerCredentials when the relay is not over a unix socket
+// or the platform doesn't support SO_PEERCRED
+var ErrPeerCredUnsupported = errors.Str("peer credentials are not supported") //nolint:gochecknoglobals
+
+// PeerCred is the process on the other end of the unix socket at the time of connect
+type PeerCred struct {
+	PID int32
+	UID uint32
+	GID uint32
+}
+
+// PeerCredentials returns the credentials of the peer process (SO_PEERCRED), e.g. to authorize the connection
+// before serving it. Returns ErrPeerCredUnsupported for the relays not over the *net.UnixConn.
+func (rl *Relay) PeerCredentials() (PeerCred, error) {
+	const op = errors.Op("goridge_socket_peer_credentials")
+
+	conn, ok := rl.rwc.(*net.UnixConn)
+	if !ok {
+		return PeerCred{}, ErrPeerCredUnsupported
+	}
+
+	cred, err := peerCred(conn)
+	if err != nil {
+		return PeerCred{}, errors.E(op, err)
+	}
+
+	return cred, nil
+}
@@ -0,0 +1,52 @@
+//go:build linux
+
+package socket
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelay_PeerCredentials(t *testing.T) {
+	ln, err := net.Listen("unix", filepath.Join(t.TempDir(), "rpc.sock"))
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = ln.Close()
+	})
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		conn, errA := ln.Accept()
+		assert.NoError(t, errA)
+		accepted <- conn
+	}()
+
+	client, err := net.Dial("unix", ln.Addr().String())
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = client.Close()
+	})
+
+	rl := NewSocketRelay(<-accepted)
+	t.Cleanup(func() {
+		_ = rl.Close()
+	})
+
+	// the peer is the test process itself
+	cred, err := rl.PeerCredentials()
+	require.NoError(t, err)
+	assert.Equal(t, PeerCred{PID: int32(os.Getpid()), UID: uint32(os.Getuid()), GID: uint32(os.Getgid())}, cred) //nolint:gosec
+
+	// not a unix socket
+	server, pipeClient := net.Pipe()
+	t.Cleanup(func() {
+		_ = pipeClient.Close()
+	})
+	_, err = NewSocketRelay(server).PeerCredentials()
+	assert.ErrorIs(t, err, ErrPeerCredUnsupported)
+}
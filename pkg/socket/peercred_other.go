@@ -0,0 +1,11 @@
+//go:build !linux
+
+package socket
+
+import (
+	"net"
+)
+
+func peerCred(_ *net.UnixConn) (PeerCred, error) {
+	return PeerCred{}, ErrPeerCredUnsupported
+}
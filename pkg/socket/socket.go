@@ -1,16 +1,32 @@
 package socket
 
 import (
+	"context"
 	"io"
+	"time"
 
 	"github.com/roadrunner-server/errors"
 	"github.com/roadrunner-server/goridge/v3/internal"
 	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/roadrunner-server/goridge/v3/pkg/relay"
 )
 
+type readDeadliner interface {
+	SetReadDeadline(time.Time) error
+}
+
+type writeDeadliner interface {
+	SetWriteDeadline(time.Time) error
+}
+
 // Relay communicates with underlying process using sockets (TPC or Unix).
 type Relay struct {
 	rwc io.ReadWriteCloser
+	// lifetime limit of the received bytes, 0 - unlimited
+	limit    int64
+	received int64
+	// header CRC verification policy and payload alignment
+	receive internal.ReceiveConfig
 }
 
 // NewSocketRelay creates new socket based data relay.
@@ -22,7 +38,8 @@ func NewSocketRelay(rwc io.ReadWriteCloser) *Relay {
 // Send signed (prefixed) data to PHP process.
 func (rl *Relay) Send(frame *frame.Frame) error {
 	const op = errors.Op("pipes frame send")
-	_, err := rl.rwc.Write(frame.Bytes())
+	// the short writes are continued, so the frame is never split, the payload is not copied
+	err := internal.WriteFrame(rl.rwc, frame.Header(), frame.Payload())
 	if err != nil {
 		return errors.E(op, err)
 	}
@@ -34,10 +51,124 @@ func (rl *Relay) Receive(frame *frame.Frame) error {
 	if frame == nil {
 		return errors.Str("nil frame")
 	}
-	return internal.ReceiveFrame(rl.rwc, frame)
+	if rl.limit > 0 && rl.received >= rl.limit {
+		return relay.ErrConnLimitExceeded
+	}
+
+	err := internal.ReceiveFrameWithConfig(rl.rwc, frame, rl.receive)
+	if err != nil {
+		return err
+	}
+
+	rl.received += int64(len(frame.Header()) + len(frame.Payload()))
+	return nil
+}
+
+// SetConnByteLimit sets the limit of bytes received over the connection lifetime, 0 disables the limit.
+// The frame which crosses the limit is received, the next Receive calls return relay.ErrConnLimitExceeded.
+func (rl *Relay) SetConnByteLimit(n int64) {
+	rl.limit = n
+}
+
+// ReceiveCtx receives data and aborts the read when the context is done. Cancellation is translated into
+// the read deadline on the underlying connection, so the connection should be closed after the cancellation,
+// because the frame might be read partially.
+// If the connection doesn't support read deadlines, the context is checked only before the read.
+func (rl *Relay) ReceiveCtx(ctx context.Context, frame *frame.Frame) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	d, ok := rl.rwc.(readDeadliner)
+	if !ok {
+		return rl.Receive(frame)
+	}
+
+	deadline, hasDeadline := ctx.Deadline()
+	if hasDeadline {
+		if err := d.SetReadDeadline(deadline); err != nil {
+			return err
+		}
+	}
+
+	// unblock the read right after the cancellation
+	cancelled := make(chan struct{})
+	stop := context.AfterFunc(ctx, func() {
+		_ = d.SetReadDeadline(time.Unix(1, 0))
+		close(cancelled)
+	})
+
+	err := rl.Receive(frame)
+	if !stop() {
+		// the cancellation func has been started, wait for it to not override the reset below
+		<-cancelled
+	}
+
+	// reset the deadline for the subsequent reads
+	_ = d.SetReadDeadline(time.Time{})
+
+	// the read failed because of the deadline set from the context
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		// connection deadline might fire a moment before the context timer
+		if hasDeadline && !time.Now().Before(deadline) {
+			return context.DeadlineExceeded
+		}
+	}
+
+	return err
+}
+
+// SetReadDeadline sets the read deadline on the underlying connection.
+func (rl *Relay) SetReadDeadline(t time.Time) error {
+	d, ok := rl.rwc.(readDeadliner)
+	if !ok {
+		return errors.Str("connection doesn't support read deadlines")
+	}
+
+	return d.SetReadDeadline(t)
+}
+
+// SetWriteDeadline sets the write deadline on the underlying connection.
+func (rl *Relay) SetWriteDeadline(t time.Time) error {
+	d, ok := rl.rwc.(writeDeadliner)
+	if !ok {
+		return errors.Str("connection doesn't support write deadlines")
+	}
+
+	return d.SetWriteDeadline(t)
 }
 
 // Close the connection.
 func (rl *Relay) Close() error {
 	return rl.rwc.Close()
 }
+
+// SetCRCPolicy sets how the frames without the header CRC are received, default frame.CRCRequired.
+// Should be called before the relay is used.
+func (rl *Relay) SetCRCPolicy(policy frame.CRCPolicy) {
+	rl.receive.Policy = policy
+}
+
+// SetMaxFrameSize sets the limit of the received frame size (header, options and payload) in bytes, the bigger
+// frames are rejected with frame.FrameTooLargeError before the payload is read, 0 disables the limit.
+// Should be called before the relay is used.
+func (rl *Relay) SetMaxFrameSize(n int) {
+	rl.receive.MaxFrameSize = n
+}
+
+// SetPayloadAlignment makes the received payloads start at the address aligned to n bytes (a power of two),
+// so they can be reinterpreted in place. Such payloads aren't taken from the buffer pool, 0 disables the alignment.
+// Should be called before the relay is used.
+func (rl *Relay) SetPayloadAlignment(n int) error {
+	err := internal.ValidateAlignment(n)
+	if err != nil {
+		return err
+	}
+
+	rl.receive.Alignment = n
+	return nil
+}
@@ -1,10 +1,15 @@
 package socket
 
 import (
+	"bytes"
+	"context"
+	"io"
 	"net"
 	"testing"
+	"time"
 
 	"github.com/roadrunner-server/goridge/v3/pkg/frame"
+	"github.com/roadrunner-server/goridge/v3/pkg/relay"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -160,3 +165,145 @@ func TestSocketRelayWrongCRC(t *testing.T) {
 
 	assert.Empty(t, fr.Payload())
 }
+
+func TestSocketRelayReceiveCtx(t *testing.T) {
+	server, client := net.Pipe()
+	r := NewSocketRelay(server)
+	t.Cleanup(func() {
+		_ = r.Close()
+		_ = client.Close()
+	})
+
+	// cancel in the middle of the blocked receive
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(time.Millisecond * 50)
+		cancel()
+	}()
+
+	start := time.Now()
+	err := r.ReceiveCtx(ctx, frame.NewFrame())
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Less(t, time.Since(start), time.Second)
+
+	ctx2, cancel2 := context.WithTimeout(context.Background(), time.Millisecond*50)
+	defer cancel2()
+	err = r.ReceiveCtx(ctx2, frame.NewFrame())
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// relay is still usable with a live context
+	nf := frame.NewFrame()
+	nf.WriteVersion(nf.Header(), frame.Version1)
+	nf.WritePayloadLen(nf.Header(), uint32(len(TestPayload)))
+	nf.WritePayload([]byte(TestPayload))
+	nf.WriteCRC(nf.Header())
+	go func() {
+		_ = NewSocketRelay(client).Send(nf)
+	}()
+
+	fr := frame.NewFrame()
+	assert.NoError(t, r.ReceiveCtx(context.Background(), fr))
+	assert.Equal(t, []byte(TestPayload), fr.Payload())
+}
+
+func TestSocketRelayConnByteLimit(t *testing.T) {
+	a, b := net.Pipe()
+	sender := NewSocketRelay(a)
+	receiver := NewSocketRelay(b)
+	defer func() {
+		_ = sender.Close()
+		_ = receiver.Close()
+	}()
+
+	nf := frame.NewFrame()
+	nf.WriteVersion(nf.Header(), frame.Version1)
+	nf.WriteFlags(nf.Header(), frame.CodecRaw)
+	nf.WritePayloadLen(nf.Header(), uint32(len([]byte(TestPayload))))
+	nf.WritePayload([]byte(TestPayload))
+	nf.WriteCRC(nf.Header())
+
+	// the second frame crosses the limit
+	receiver.SetConnByteLimit(int64(len(nf.Bytes())) + 1)
+
+	go func() {
+		for i := 0; i < 3; i++ {
+			_ = sender.Send(nf)
+		}
+	}()
+
+	for i := 0; i < 2; i++ {
+		assert.NoError(t, receiver.Receive(frame.NewFrame()))
+	}
+
+	assert.ErrorIs(t, receiver.Receive(frame.NewFrame()), relay.ErrConnLimitExceeded)
+}
+
+// discardConn accepts and drops the written frames
+type discardConn struct{}
+
+func (discardConn) Read([]byte) (int, error)    { return 0, io.EOF }
+func (discardConn) Write(b []byte) (int, error) { return len(b), nil }
+func (discardConn) Close() error                { return nil }
+
+func BenchmarkSocketRelaySend(b *testing.B) {
+	fr := frame.NewFrame()
+	fr.WriteVersion(fr.Header(), frame.Version1)
+	fr.WriteFlags(fr.Header(), frame.CodecRaw)
+	payload := bytes.Repeat([]byte(TestPayload), 1024)
+	fr.WritePayloadLen(fr.Header(), uint32(len(payload))) //nolint:gosec
+	fr.WritePayload(payload)
+	fr.WriteCRC(fr.Header())
+
+	rl := NewSocketRelay(discardConn{})
+	b.SetBytes(int64(len(payload)))
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = rl.Send(fr)
+	}
+}
+
+// shortWriteConn writes up to n bytes per Write without an error
+type shortWriteConn struct {
+	net.Conn
+	n int
+}
+
+func (c *shortWriteConn) Write(b []byte) (int, error) {
+	return c.Conn.Write(b[:min(len(b), c.n)])
+}
+
+func TestSocketRelayShortWrites(t *testing.T) {
+	for name, newRelay := range map[string]func(net.Conn) relay.Relay{
+		"socket":   func(c net.Conn) relay.Relay { return NewSocketRelay(c) },
+		"buffered": func(c net.Conn) relay.Relay { return NewBufferedSocketRelay(c, 0) },
+	} {
+		t.Run(name, func(t *testing.T) {
+			a, b := net.Pipe()
+			sender := newRelay(&shortWriteConn{Conn: a, n: 5})
+			receiver := NewSocketRelay(b)
+			defer func() {
+				_ = sender.Close()
+				_ = receiver.Close()
+			}()
+
+			go func() {
+				for i := uint32(0); i < 3; i++ {
+					assert.NoError(t, sender.Send(testFrame(i)))
+				}
+				if f, ok := sender.(*BufferedRelay); ok {
+					assert.NoError(t, f.Flush())
+				}
+			}()
+
+			// every frame is delivered intact
+			for i := uint32(0); i < 3; i++ {
+				fr := frame.NewFrame()
+				assert.NoError(t, receiver.Receive(fr))
+				assert.True(t, fr.VerifyCRC(fr.Header()))
+				assert.Equal(t, []uint32{i, 0}, fr.ReadOptions(fr.Header()))
+				assert.Equal(t, []byte(TestPayload), fr.Payload())
+			}
+		})
+	}
+}